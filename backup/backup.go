@@ -0,0 +1,586 @@
+package backup
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/logger"
+	"gorm.io/gorm"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	VERSION = 1
+	MANIFEST = "manifest.json"
+	DATABASE = "database/" // table per file, the first line is columns and every next one is row
+	FILES = "files/" // relative to instance folder
+	BATCH = 100 // rows inserted at once
+	EXTENSION = ".zip"
+	ENCRYPTED_EXTENSION = ".zip.enc"
+	KEEP = 7
+)
+
+// Paths returns archived folders and files of instance, database is dumped separately. Config folder is read on every
+// call as it may be set after start
+func Paths() []string {
+	return []string{path.Join(os.Getenv("CONFIG_FOLDER"), "config.toml"), "storage", path.Join("hugo", "config.toml"), path.Join("hugo", "themes"), path.Join("hugo", "layouts")}
+}
+
+// Folder returns folder of archives, relative path is relative to root
+func Folder(root, configured string) string {
+	if configured == "" {
+		return path.Join(root, "backups")
+	}
+	if !path.IsAbs(configured) {
+		return path.Join(root, configured)
+	}
+	return configured
+}
+
+// Manifest describes archive
+type Manifest struct {
+	Version int
+	Created time.Time
+	Dialect string
+	Tables map[string]int // rows by table
+	Files int
+}
+
+// skip returns true for tables which describe generated or derived data, they are rebuilt after restore
+func skip(table string) bool {
+	return strings.HasPrefix(table, "search_") || table == "render_states"
+}
+
+// Tables returns tables of database to be archived
+func Tables(connector *gorm.DB) ([]string, error) {
+	db := connector
+	var query string
+	switch db.Dialector.Name() {
+	case "sqlite":
+		query = "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'"
+	case "mysql":
+		query = "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE'"
+	case "postgres":
+		query = "SELECT tablename FROM pg_tables WHERE schemaname = current_schema()"
+	default:
+		return nil, fmt.Errorf("unsupported database %v", db.Dialector.Name())
+	}
+	rows, err := db.Raw(query).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		if !skip(table) {
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+	return tables, rows.Err()
+}
+
+// Create writes archive of database and files, all tables are read in one transaction so they are consistent
+// to each other, folders and files are relative to root and missing ones are skipped. It stops between tables and files
+// when ctx is canceled
+func Create(ctx context.Context, connector *gorm.DB, w io.Writer, root string, paths []string, progress func(stage string, done, total int)) (*Manifest, error) {
+	db := connector
+	manifest := &Manifest{Version: VERSION, Created: time.Now(), Dialect: db.Dialector.Name(), Tables: make(map[string]int)}
+	writer := zip.NewWriter(w)
+	// Database
+	var tx *gorm.DB
+	if db.Dialector.Name() == "sqlite" {
+		tx = db.Begin()
+	}else{
+		tx = db.Begin(&sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	}
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	tables, err := Tables(tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	for i, table := range tables {
+		if err = ctx.Err(); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		f, err := writer.Create(DATABASE + table + ".json")
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if manifest.Tables[table], err = dumpTable(tx, table, f); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("%v: %v", table, err)
+		}
+		if progress != nil {
+			progress("database", i + 1, len(tables))
+		}
+	}
+	tx.Rollback()
+	// Files
+	var files []string
+	for _, p := range paths {
+		if err = filepath.Walk(path.Join(root, p), func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.Mode().IsRegular() {
+				files = append(files, p)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	for i, p := range files {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil, err
+		}
+		if err = addFile(writer, p, FILES + filepath.ToSlash(rel)); err != nil {
+			return nil, err
+		}
+		manifest.Files++
+		if progress != nil {
+			progress("files", i + 1, len(files))
+		}
+	}
+	// Manifest
+	f, err := writer.Create(MANIFEST)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.NewEncoder(f).Encode(manifest); err != nil {
+		return nil, err
+	}
+	return manifest, writer.Close()
+}
+
+func dumpTable(tx *gorm.DB, table string, w io.Writer) (int, error) {
+	rows, err := tx.Table(table).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	encoder := json.NewEncoder(w)
+	if err = encoder.Encode(columns); err != nil {
+		return 0, err
+	}
+	var count int
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(pointers...); err != nil {
+			return count, err
+		}
+		for i, value := range values {
+			if bts, ok := value.([]byte); ok {
+				values[i] = string(bts)
+			}
+		}
+		if err = encoder.Encode(values); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+func addFile(writer *zip.Writer, src, name string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(fi)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	w, err := writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// Archive is opened plain archive, encrypted one is decrypted to temporary file first
+type Archive struct {
+	*zip.ReadCloser
+	Manifest *Manifest
+	temp string
+}
+
+func Open(p, key string) (*Archive, error) {
+	archive := &Archive{}
+	if encrypted, err := IsEncrypted(p); err != nil {
+		return nil, err
+	}else if encrypted {
+		if key == "" {
+			return nil, fmt.Errorf("archive is encrypted, key is required")
+		}
+		f, err := ioutil.TempFile("", "goshop-restore-*.zip")
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+		archive.temp = f.Name()
+		if err = Decrypt(p, archive.temp, key); err != nil {
+			os.Remove(archive.temp)
+			return nil, err
+		}
+		p = archive.temp
+	}
+	var err error
+	if archive.ReadCloser, err = zip.OpenReader(p); err != nil {
+		archive.Close()
+		return nil, err
+	}
+	bts, err := archive.ReadFile(MANIFEST)
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+	archive.Manifest = &Manifest{}
+	if err = json.Unmarshal(bts, archive.Manifest); err != nil {
+		archive.Close()
+		return nil, err
+	}
+	if archive.Manifest.Version > VERSION {
+		archive.Close()
+		return nil, fmt.Errorf("archive version %v is not supported", archive.Manifest.Version)
+	}
+	return archive, nil
+}
+
+func (archive *Archive) Close() error {
+	var err error
+	if archive.ReadCloser != nil {
+		err = archive.ReadCloser.Close()
+	}
+	if archive.temp != "" {
+		os.Remove(archive.temp)
+	}
+	return err
+}
+
+func (archive *Archive) file(name string) *zip.File {
+	for _, f := range archive.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// ReadFile returns content of archived file by its name in archive
+func (archive *Archive) ReadFile(name string) ([]byte, error) {
+	f := archive.file(name)
+	if f == nil {
+		return nil, fmt.Errorf("%v not found in archive", name)
+	}
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// Restore replaces rows of archived tables in one transaction, schema should be migrated already,
+// columns missing in database are skipped so archive of older version can be restored
+func (archive *Archive) Restore(connector *gorm.DB, progress func(stage string, done, total int)) error {
+	db := connector
+	existing, err := Tables(db)
+	if err != nil {
+		return err
+	}
+	tables := make(map[string]bool)
+	for _, table := range existing {
+		tables[table] = true
+	}
+	var files []*zip.File
+	for _, f := range archive.File {
+		if strings.HasPrefix(f.Name, DATABASE) {
+			files = append(files, f)
+		}
+	}
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.Rollback()
+	switch db.Dialector.Name() {
+	case "mysql":
+		if err = tx.Exec("SET FOREIGN_KEY_CHECKS = 0").Error; err != nil {
+			return err
+		}
+	case "postgres":
+		if err = tx.Exec("SET session_replication_role = replica").Error; err != nil {
+			logger.Warningf("Foreign keys are checked: %v", err)
+		}
+	}
+	for i, f := range files {
+		table := strings.TrimSuffix(strings.TrimPrefix(f.Name, DATABASE), ".json")
+		if !tables[table] || skip(table) {
+			logger.Warningf("Table %v is skipped", table)
+			continue
+		}
+		if err = restoreTable(tx, table, f); err != nil {
+			return fmt.Errorf("%v: %v", table, err)
+		}
+		if progress != nil {
+			progress("database", i + 1, len(files))
+		}
+	}
+	return tx.Commit().Error
+}
+
+func restoreTable(tx *gorm.DB, table string, f *zip.File) error {
+	// Types of database columns, archive of other dialect has integers for booleans and strings for times
+	rows, err := tx.Table(table).Limit(1).Rows()
+	if err != nil {
+		return err
+	}
+	types, err := rows.ColumnTypes()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	kinds := make(map[string]string)
+	for _, t := range types {
+		kind := strings.ToUpper(t.DatabaseTypeName())
+		switch {
+		case strings.Contains(kind, "BOOL"):
+			kinds[t.Name()] = "bool"
+		case strings.Contains(kind, "TIME") || strings.Contains(kind, "DATE"):
+			kinds[t.Name()] = "time"
+		default:
+			kinds[t.Name()] = ""
+		}
+	}
+	if err = tx.Exec(fmt.Sprintf("DELETE FROM %v", tx.Statement.Quote(table))).Error; err != nil {
+		return err
+	}
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	decoder.UseNumber()
+	var columns []string
+	if err = decoder.Decode(&columns); err != nil {
+		return err
+	}
+	var batch []map[string]interface{}
+	var hasId bool
+	for {
+		var values []interface{}
+		if err = decoder.Decode(&values); err == io.EOF {
+			break
+		}else if err != nil {
+			return err
+		}
+		row := make(map[string]interface{})
+		for i, column := range columns {
+			kind, found := kinds[column]
+			if !found || i >= len(values) {
+				continue
+			}
+			row[column] = convert(values[i], kind)
+			if column == "id" {
+				hasId = true
+			}
+		}
+		batch = append(batch, row)
+		if len(batch) == BATCH {
+			if err = tx.Table(table).Create(&batch).Error; err != nil {
+				return err
+			}
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		if err = tx.Table(table).Create(&batch).Error; err != nil {
+			return err
+		}
+	}
+	// Sequence of postgres is not moved by explicit ids
+	if hasId && tx.Dialector.Name() == "postgres" {
+		if err = tx.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%v', 'id'), (SELECT MAX(id) FROM %v))", table, tx.Statement.Quote(table))).Error; err != nil {
+			logger.Warningf("%v: %v", table, err)
+		}
+	}
+	return nil
+}
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999", "2006-01-02"}
+
+func convert(value interface{}, kind string) interface{} {
+	if n, ok := value.(json.Number); ok {
+		if v, err := n.Int64(); err == nil {
+			value = v
+		}else if v, err := n.Float64(); err == nil {
+			value = v
+		}
+	}
+	switch kind {
+	case "bool":
+		switch v := value.(type) {
+		case int64:
+			return v != 0
+		case string:
+			return v == "1" || strings.EqualFold(v, "true")
+		}
+	case "time":
+		if v, ok := value.(string); ok {
+			for _, layout := range timeLayouts {
+				if t, err := time.Parse(layout, v); err == nil {
+					return t
+				}
+			}
+		}
+	}
+	return value
+}
+
+// Extract writes archived files to root, names are checked not to leave it
+func (archive *Archive) Extract(root string, exclude func(name string) bool, progress func(stage string, done, total int)) error {
+	var files []*zip.File
+	for _, f := range archive.File {
+		if strings.HasPrefix(f.Name, FILES) && !strings.HasSuffix(f.Name, "/") {
+			name := strings.TrimPrefix(f.Name, FILES)
+			if exclude == nil || !exclude(name) {
+				files = append(files, f)
+			}
+		}
+	}
+	for i, f := range files {
+		name := path.Clean("/" + strings.TrimPrefix(f.Name, FILES))
+		dst := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := extractFile(f, dst); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress("files", i + 1, len(files))
+		}
+	}
+	return nil
+}
+
+func extractFile(f *zip.File, dst string) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, f.Mode().Perm() | 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, f.Modified, f.Modified)
+}
+
+// Item is archive in backups folder
+type Item struct {
+	Name string
+	Size int64
+	Created time.Time
+	Encrypted bool
+}
+
+// List returns archives of folder, newest first
+func List(folder string) ([]*Item, error) {
+	infos, err := ioutil.ReadDir(folder)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var items []*Item
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(info.Name(), ENCRYPTED_EXTENSION) {
+			items = append(items, &Item{Name: info.Name(), Size: info.Size(), Created: info.ModTime(), Encrypted: true})
+		}else if strings.HasSuffix(info.Name(), EXTENSION) {
+			items = append(items, &Item{Name: info.Name(), Size: info.Size(), Created: info.ModTime()})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name > items[j].Name
+	})
+	return items, nil
+}
+
+// Rotate removes all but keep newest archives
+func Rotate(folder string, keep int) ([]string, error) {
+	if keep < 1 {
+		keep = KEEP
+	}
+	items, err := List(folder)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for i, item := range items {
+		if i < keep {
+			continue
+		}
+		if err = os.Remove(path.Join(folder, item.Name)); err != nil {
+			return removed, err
+		}
+		removed = append(removed, item.Name)
+	}
+	return removed, nil
+}
@@ -0,0 +1,136 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"golang.org/x/crypto/pbkdf2"
+	"hash"
+	"io"
+	"os"
+)
+
+const (
+	CRYPT_MAGIC = "GSBACKUP"
+	CRYPT_VERSION = 1
+	CRYPT_ITERATIONS = 100000
+	CRYPT_HEADER = len(CRYPT_MAGIC) + 1 + 16 + aes.BlockSize // magic, version, salt and iv
+)
+
+// Encrypted archive is header, AES-256-CTR stream of zip and HMAC-SHA256 of both, keys are derived from passphrase
+func deriveKeys(key string, salt []byte) ([]byte, []byte) {
+	keys := pbkdf2.Key([]byte(key), salt, CRYPT_ITERATIONS, 64, sha256.New)
+	return keys[:32], keys[32:]
+}
+
+type encryptor struct {
+	w io.Writer
+	stream cipher.Stream
+	mac hash.Hash
+}
+
+// NewEncryptor writes header at once and MAC on Close, closing does not close w
+func NewEncryptor(w io.Writer, key string) (io.WriteCloser, error) {
+	header := make([]byte, CRYPT_HEADER)
+	copy(header, CRYPT_MAGIC)
+	header[len(CRYPT_MAGIC)] = CRYPT_VERSION
+	if _, err := io.ReadFull(rand.Reader, header[len(CRYPT_MAGIC) + 1:]); err != nil {
+		return nil, err
+	}
+	salt := header[len(CRYPT_MAGIC) + 1:len(CRYPT_MAGIC) + 17]
+	iv := header[len(CRYPT_MAGIC) + 17:]
+	encKey, macKey := deriveKeys(key, salt)
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	e := &encryptor{w: w, stream: cipher.NewCTR(block, iv), mac: hmac.New(sha256.New, macKey)}
+	e.mac.Write(header)
+	if _, err = w.Write(header); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *encryptor) Write(p []byte) (int, error) {
+	buff := make([]byte, len(p))
+	e.stream.XORKeyStream(buff, p)
+	e.mac.Write(buff)
+	return e.w.Write(buff)
+}
+
+func (e *encryptor) Close() error {
+	_, err := e.w.Write(e.mac.Sum(nil))
+	return err
+}
+
+// IsEncrypted checks header of archive
+func IsEncrypted(p string) (bool, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	magic := make([]byte, len(CRYPT_MAGIC))
+	if _, err = io.ReadFull(file, magic); err != nil {
+		return false, nil
+	}
+	return string(magic) == CRYPT_MAGIC, nil
+}
+
+// Decrypt verifies MAC of the whole archive before it writes plain zip to dst
+func Decrypt(src, dst, key string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	size := fi.Size() - sha256.Size
+	if size < int64(CRYPT_HEADER) {
+		return fmt.Errorf("archive is too short")
+	}
+	header := make([]byte, CRYPT_HEADER)
+	if _, err = io.ReadFull(file, header); err != nil {
+		return err
+	}
+	if string(header[:len(CRYPT_MAGIC)]) != CRYPT_MAGIC || header[len(CRYPT_MAGIC)] != CRYPT_VERSION {
+		return fmt.Errorf("unsupported archive")
+	}
+	encKey, macKey := deriveKeys(key, header[len(CRYPT_MAGIC) + 1:len(CRYPT_MAGIC) + 17])
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(header)
+	if _, err = io.Copy(mac, io.LimitReader(file, size - int64(CRYPT_HEADER))); err != nil {
+		return err
+	}
+	sum := make([]byte, sha256.Size)
+	if _, err = io.ReadFull(file, sum); err != nil {
+		return err
+	}
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return fmt.Errorf("wrong key or damaged archive")
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Seek(int64(CRYPT_HEADER), io.SeekStart); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	reader := &cipher.StreamReader{S: cipher.NewCTR(block, header[len(CRYPT_MAGIC) + 17:]), R: io.LimitReader(file, size - int64(CRYPT_HEADER))}
+	if _, err = io.Copy(out, reader); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,93 @@
+package backup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestEncryptor(t *testing.T) {
+	folder := t.TempDir()
+	plain := bytes.Repeat([]byte("PK archive content "), 1000)
+	src := path.Join(folder, "backup" + ENCRYPTED_EXTENSION)
+	file, err := os.Create(src)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	w, err := NewEncryptor(file, "secret")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	// Archive is written by parts
+	for i := 0; i < len(plain); i += 4096 {
+		end := i + 4096
+		if end > len(plain) {
+			end = len(plain)
+		}
+		if _, err = w.Write(plain[i:end]); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err = file.Close(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	encrypted, err := ioutil.ReadFile(src)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if bytes.Contains(encrypted, []byte("PK archive content")) {
+		t.Fatalf("archive is not encrypted")
+	}
+	if ok, err := IsEncrypted(src); err != nil || !ok {
+		t.Fatalf("encrypted archive is not detected: %v %v", ok, err)
+	}
+	damaged := append([]byte{}, encrypted...)
+	damaged[CRYPT_HEADER + 10] ^= 1
+	for _, example := range []struct{
+		Name string
+		Bytes []byte
+		Key string
+		Err bool
+	}{
+		{Name: "right key", Bytes: encrypted, Key: "secret"},
+		{Name: "wrong key", Bytes: encrypted, Key: "secret2", Err: true},
+		{Name: "damaged", Bytes: damaged, Key: "secret", Err: true},
+		{Name: "truncated", Bytes: encrypted[:len(encrypted) - 1], Key: "secret", Err: true},
+		{Name: "header only", Bytes: encrypted[:CRYPT_HEADER], Key: "secret", Err: true},
+	}{
+		p := path.Join(folder, "example" + ENCRYPTED_EXTENSION)
+		if err = ioutil.WriteFile(p, example.Bytes, 0644); err != nil {
+			t.Fatalf("%v", err)
+		}
+		dst := path.Join(folder, "example" + EXTENSION)
+		err = Decrypt(p, dst, example.Key)
+		if example.Err {
+			if err == nil {
+				t.Errorf("%v: error expected", example.Name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", example.Name, err)
+			continue
+		}
+		if bts, err := ioutil.ReadFile(dst); err != nil || !bytes.Equal(bts, plain) {
+			t.Errorf("%v: decrypted archive differs: %v", example.Name, err)
+		}
+	}
+}
+
+func TestIsEncrypted(t *testing.T) {
+	p := path.Join(t.TempDir(), "backup" + EXTENSION)
+	if err := ioutil.WriteFile(p, []byte("PK\x03\x04"), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if ok, err := IsEncrypted(p); err != nil || ok {
+		t.Errorf("plain archive is detected as encrypted: %v %v", ok, err)
+	}
+}
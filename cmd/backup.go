@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/google/logger"
+	"github.com/spf13/cobra"
+	"github.com/yonnic/goshop/backup"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/config"
+	"github.com/yonnic/goshop/handler"
+	"github.com/yonnic/goshop/models"
+	"io"
+	"os"
+	"path"
+	"time"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Backup database and files",
+	Long:  `Write archive of database, config.toml, uploaded images and files and themes, email templates are in database. Archive is encrypted if Backup.Key is configured, old archives are rotated`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		t1 := time.Now()
+		if err := openDatabase(); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		if err := createBackup(context.Background(), consoleLogger("backup"), cmd.Flag("output").Value.String(), logProgress); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		logger.Infof("Backed up ~ %.3f ms", float64(time.Since(t1).Nanoseconds())/1000000)
+	},
+}
+
+// createBackup writes archive to output or to backups folder, old archives of the folder are rotated
+func createBackup(ctx context.Context, logger *logger.Logger, output string, progress func(stage string, done, total int)) error {
+	folder := backup.Folder(dir, common.Config.Backup.Path)
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+	name := time.Now().Format("20060102150405")
+	if common.Config.Backup.Key != "" {
+		name += backup.ENCRYPTED_EXTENSION
+	}else{
+		name += backup.EXTENSION
+	}
+	p := path.Join(folder, name)
+	if output != "" {
+		p = output
+	}
+	manifest, err := writeBackup(ctx, p + ".tmp", common.Config.Backup.Key, progress)
+	if err != nil {
+		os.Remove(p + ".tmp")
+		return err
+	}
+	// Unfinished archive is never listed
+	if err = os.Rename(p + ".tmp", p); err != nil {
+		return err
+	}
+	var rows int
+	for _, count := range manifest.Tables {
+		rows += count
+	}
+	logger.Infof("Backup %v: %v tables, %v rows, %v files", p, len(manifest.Tables), rows, manifest.Files)
+	if removed, err := backup.Rotate(folder, common.Config.Backup.Keep); err == nil {
+		for _, name := range removed {
+			logger.Infof("Remove backup %v", name)
+		}
+	}else{
+		logger.Warningf("%+v", err)
+	}
+	return nil
+}
+
+func writeBackup(ctx context.Context, p, key string, progress func(stage string, done, total int)) (*backup.Manifest, error) {
+	file, err := os.Create(p)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var w io.WriteCloser = file
+	if key != "" {
+		if w, err = backup.NewEncryptor(file, key); err != nil {
+			return nil, err
+		}
+	}
+	manifest, err := backup.Create(ctx, common.Database, w, dir, backup.Paths(), progress)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		if err = w.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, file.Close()
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [archive]",
+	Short: "Restore database and files",
+	Long:  `Restore archive made by backup into empty instance, database settings of instance are kept. Site should be rendered after restore`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		t1 := time.Now()
+		key := common.Config.Backup.Key
+		if flagKey := cmd.Flag("key").Value.String(); flagKey != "" {
+			key = flagKey
+		}
+		archive, err := backup.Open(args[0], key)
+		if err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		defer archive.Close()
+		logger.Infof("Archive of %v database created at %v", archive.Manifest.Dialect, archive.Manifest.Created.Format(time.RFC3339))
+		if err = openDatabase(); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		migrateDatabase()
+		if err = common.Database.AutoMigrate(&models.User{}); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		if force, _ := cmd.Flags().GetBool("force"); !force {
+			for _, value := range []interface{}{&models.Category{}, &models.Product{}, &models.Order{}} {
+				var count int64
+				if err = common.Database.Model(value).Count(&count).Error; err != nil {
+					logger.Errorf("%v", err)
+					os.Exit(1)
+				}
+				if count > 0 {
+					logger.Errorf("Instance is not empty, use --force to replace its data")
+					os.Exit(1)
+				}
+			}
+		}
+		if err = archive.Restore(common.Database, logProgress); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		// Config of archive is used with database settings of this instance
+		configFile := path.Join(os.Getenv("CONFIG_FOLDER"), "config.toml")
+		if bts, err := archive.ReadFile(backup.FILES + configFile); err == nil {
+			conf := config.NewConfig(path.Join(dir, configFile))
+			if _, err = toml.Decode(string(bts), conf); err != nil {
+				logger.Errorf("%v", err)
+				os.Exit(1)
+			}
+			conf.Database = common.Config.Database
+			if err = conf.Save(); err != nil {
+				logger.Errorf("%v", err)
+				os.Exit(1)
+			}
+		}
+		if err = archive.Extract(dir, func(name string) bool {
+			return name == configFile
+		}, logProgress); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		if err = models.InitSearch(common.Database); err != nil {
+			logger.Warningf("%+v", err)
+		}else if count, err := models.ReindexProducts(common.Database); err == nil {
+			logger.Infof("%v products indexed", count)
+		}else{
+			logger.Warningf("%+v", err)
+		}
+		if err = handler.MarkChanged(fmt.Sprintf("restore %v", path.Base(args[0]))); err != nil {
+			logger.Warningf("%+v", err)
+		}
+		logger.Infof("Restored ~ %.3f ms", float64(time.Since(t1).Nanoseconds())/1000000)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(backupCmd)
+	backupCmd.Flags().StringP("output", "o", "", "archive file, new file in backups folder by default")
+	RootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().String("key", "", "passphrase of encrypted archive, Backup.Key by default")
+	restoreCmd.Flags().Bool("force", false, "replace data of not empty instance")
+}
@@ -0,0 +1,406 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/logger"
+	"github.com/spf13/cobra"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"github.com/yonnic/goshop/storage"
+	"html"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const CHECK_ISSUES = 100 // issues printed, the rest is counted only
+
+var (
+	reCheckLink = regexp.MustCompile(`(?i)\s(?:href|src|poster|data-src)\s*=\s*["']([^"']*)["']`)
+	reCheckSrcset = regexp.MustCompile(`(?i)\s(?:srcset|data-srcset)\s*=\s*["']([^"']*)["']`)
+	reCheckFile = regexp.MustCompile(`(?:^|/)files/(\d+)-[^/]*$`)
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check rendered site",
+	Long:  `Check internal links and assets of rendered site and references of generated content, exit code is 1 if anything is broken`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		public := path.Join(dir, "hugo", "public")
+		if flagSource := cmd.Flag("source").Value.String(); flagSource != "" {
+			public = flagSource
+		}
+		content := path.Join(dir, "hugo", "content")
+		if flagContent := cmd.Flag("content").Value.String(); flagContent != "" {
+			content = flagContent
+		}
+		remote, _ := cmd.Flags().GetBool("remote")
+		remote = remote || common.Config.Check.Remote
+		var store storage.Storage
+		var err error
+		if store, err = storage.NewLocalStorage(path.Join(dir, "hugo"), common.Config.Resize.Enabled, common.Config.Resize.Quality); err != nil {
+			logger.Warningf("%+v", err)
+		}
+		if remote && common.Config.Storage.Enabled && common.Config.Storage.S3.Enabled {
+			if store, err = storage.NewAWSS3Storage(common.Config.Storage.S3.AccessKeyID,common.Config.Storage.S3.SecretAccessKey, common.Config.Storage.S3.Region, common.Config.Storage.S3.Bucket, common.Config.Storage.S3.Prefix, path.Join(dir, "temp", "s3"), common.Config.Resize.Enabled, common.Config.Resize.Quality, common.Config.Storage.S3.CDN, common.Config.Storage.S3.Rewrite); err != nil {
+				logger.Errorf("%+v", err)
+				os.Exit(1)
+			}
+		}
+		if err = openDatabase(); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		if err = checkSite(context.Background(), consoleLogger("check"), public, content, remote, store); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// checkSite checks generated content and rendered site, it returns error if anything is broken
+func checkSite(ctx context.Context, logger *logger.Logger, public, content string, remote bool, store storage.Storage) error {
+	t1 := time.Now()
+	checker := newChecker(public, path.Join(dir, "hugo", "static"), remote, store)
+	if ids, err := models.GetDownloadFileIds(common.Database); err == nil {
+		for _, id := range ids {
+			checker.downloads[id] = true
+		}
+	}else{
+		return err
+	}
+	if err := checker.Content(content); err != nil {
+		return err
+	}
+	if err := checker.Public(ctx); err != nil {
+		return err
+	}
+	sort.SliceStable(checker.issues, func(i, j int) bool {
+		return checker.issues[i].Page < checker.issues[j].Page
+	})
+	for i, issue := range checker.issues {
+		if i == CHECK_ISSUES {
+			logger.Errorf("... and %v more", len(checker.issues) - CHECK_ISSUES)
+			break
+		}
+		logger.Errorf("%v: %v %v", issue.Page, issue.Link, issue.Problem)
+	}
+	logger.Infof("Checked %v pages, %v links, %v broken ~ %.3f ms", checker.pages, len(checker.cache), len(checker.issues), float64(time.Since(t1).Nanoseconds())/1000000)
+	if len(checker.issues) > 0 {
+		return fmt.Errorf("%v links are broken", len(checker.issues))
+	}
+	return nil
+}
+
+type checkIssue struct {
+	Page string
+	Link string
+	Problem string
+}
+
+// checker collects broken links, result of every link is cached as the same assets are used by many pages
+type checker struct {
+	public string
+	static string
+	base *url.URL // links to this host are internal
+	remote bool
+	store storage.Storage // remote links are checked by
+	mutex sync.Mutex
+	cache map[string]string // problem by link, empty if link is fine
+	downloads map[uint]bool // files available by signed link only
+	issues []checkIssue
+	pages int
+}
+
+func newChecker(public, static string, remote bool, store storage.Storage) *checker {
+	checker := &checker{public: public, static: static, remote: remote, store: store, cache: make(map[string]string), downloads: make(map[uint]bool)}
+	if common.Config.Url != "" {
+		if u, err := url.Parse(common.Config.Url); err == nil {
+			checker.base = u
+		}
+	}
+	return checker
+}
+
+func (checker *checker) issue(page, link, problem string) {
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+	checker.issues = append(checker.issues, checkIssue{Page: page, Link: link, Problem: problem})
+}
+
+// Public checks links of all html pages in rendered site, pages are not fed to workers after ctx is canceled
+func (checker *checker) Public(ctx context.Context) error {
+	if _, err := os.Stat(checker.public); err != nil {
+		return err
+	}
+	var pages []string
+	if err := filepath.Walk(checker.public, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.ToLower(path.Ext(p)) == ".html" {
+			rel, err := filepath.Rel(checker.public, p)
+			if err != nil {
+				return err
+			}
+			pages = append(pages, filepath.ToSlash(rel))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	checker.pages += len(pages)
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for page := range jobs {
+				bts, err := ioutil.ReadFile(path.Join(checker.public, page))
+				if err != nil {
+					checker.issue(page, "", err.Error())
+					continue
+				}
+				for _, res := range reCheckLink.FindAllStringSubmatch(string(bts), -1) {
+					checker.link(page, res[1])
+				}
+				for _, res := range reCheckSrcset.FindAllStringSubmatch(string(bts), -1) {
+					for _, item := range strings.Split(res[1], ",") {
+						if fields := strings.Fields(item); len(fields) > 0 {
+							checker.link(page, fields[0])
+						}
+					}
+				}
+			}
+		}()
+	}
+	for _, page := range pages {
+		select {
+		case jobs <- page:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return ctx.Err()
+}
+
+// link checks link of page, page and local links are relative to public folder
+func (checker *checker) link(page, link string) {
+	link = html.UnescapeString(strings.TrimSpace(link))
+	if link == "" || strings.HasPrefix(link, "#") || strings.Contains(link, "{{") {
+		return
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		checker.issue(page, link, err.Error())
+		return
+	}
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	if checker.download(page, link, u) {
+		return
+	}
+	var key string
+	if u.Host != "" && (checker.base == nil || u.Host != checker.base.Host) {
+		if !checker.remote {
+			return
+		}
+		key = u.Scheme + "://" + u.Host + u.Path
+	}else{
+		p := u.Path
+		if p == "" {
+			return
+		}
+		if !strings.HasPrefix(p, "/") {
+			p = path.Join("/", path.Dir(page), p)
+		}else if checker.base != nil && checker.base.Path != "" && checker.base.Path != "/" {
+			p = "/" + strings.TrimPrefix(p, strings.TrimSuffix(checker.base.Path, "/"))
+		}
+		// Served by application
+		if strings.HasPrefix(p, "/api/") {
+			return
+		}
+		key = path.Clean(p)
+	}
+	checker.mutex.Lock()
+	problem, found := checker.cache[key]
+	checker.mutex.Unlock()
+	if !found {
+		if strings.HasPrefix(key, "/") {
+			problem = checker.local(key)
+		}else{
+			problem = checker.storage(key)
+		}
+		checker.mutex.Lock()
+		checker.cache[key] = problem
+		checker.mutex.Unlock()
+	}
+	if problem != "" {
+		checker.issue(page, link, problem)
+	}
+}
+
+// local checks file of rendered site, folder should have index.html
+func (checker *checker) local(p string) string {
+	file := path.Join(checker.public, p)
+	fi, err := os.Stat(file)
+	if err != nil {
+		return "not found"
+	}
+	if fi.IsDir() {
+		if _, err = os.Stat(path.Join(file, "index.html")); err != nil {
+			return "has no index.html"
+		}
+	}
+	return ""
+}
+
+// storage checks remote link, links which are not served by storage are skipped
+func (checker *checker) storage(link string) string {
+	found, err := checker.store.Exists(link)
+	if err != nil {
+		if errors.Is(err, storage.ErrForeignLink) {
+			return ""
+		}
+		return err.Error()
+	}
+	if !found {
+		return "not found in storage"
+	}
+	return ""
+}
+
+// download reports published copy of purchasable file, such files are copied to files/<id>-<name> by render
+func (checker *checker) download(page, link string, u *url.URL) bool {
+	if res := reCheckFile.FindStringSubmatch(u.Path); len(res) > 1 {
+		if id, err := strconv.ParseUint(res[1], 10, 64); err == nil && checker.downloads[uint(id)] {
+			checker.issue(page, link, "download only file is published")
+			return true
+		}
+	}
+	return false
+}
+
+// asset checks image or file of generated content which is not rendered to public folder yet
+func (checker *checker) asset(page, link string) {
+	if fields := strings.Fields(link); len(fields) > 0 {
+		link = fields[0]
+	}
+	if link == "" {
+		return
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		checker.issue(page, link, err.Error())
+		return
+	}
+	if checker.download(page, link, u) {
+		return
+	}
+	if u.Host == "" {
+		if _, err = os.Stat(path.Join(checker.static, path.Clean("/" + u.Path))); err != nil {
+			checker.issue(page, link, "not found")
+		}
+		return
+	}
+	if checker.remote {
+		if problem := checker.storage(link); problem != "" {
+			checker.issue(page, link, problem)
+		}
+	}
+}
+
+// Content checks generated categories and products: images, files and categories of products
+func (checker *checker) Content(content string) error {
+	if _, err := os.Stat(content); err != nil {
+		return err
+	}
+	categories := make(map[uint]bool)
+	var products []string
+	if err := filepath.Walk(content, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.ToLower(path.Ext(p)) != ".html" {
+			return nil
+		}
+		rel, err := filepath.Rel(content, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		checker.pages++
+		if strings.HasPrefix(info.Name(), "_index.") {
+			categoryFile, err := common.ReadCategoryFile(p)
+			if err != nil {
+				checker.issue(rel, "", err.Error())
+				return nil
+			}
+			if categoryFile.Type == "categories" {
+				categories[categoryFile.ID] = true
+				for _, thumbnail := range strings.Split(categoryFile.Thumbnail, ",") {
+					checker.asset(rel, thumbnail)
+				}
+			}
+		}else{
+			products = append(products, rel)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, rel := range products {
+		productFile, err := common.ReadProductFile(path.Join(content, rel))
+		if err != nil {
+			checker.issue(rel, "", err.Error())
+			continue
+		}
+		if productFile.Type != "products" {
+			continue
+		}
+		if productFile.CategoryId > 0 && !categories[productFile.CategoryId] {
+			checker.issue(rel, productFile.Product.Path, fmt.Sprintf("category #%v is not rendered", productFile.CategoryId))
+		}
+		assets := strings.Split(productFile.Thumbnail, ",")
+		assets = append(assets, productFile.Product.Images...)
+		for _, file := range productFile.Product.Files {
+			assets = append(assets, file.Path)
+		}
+		for _, variation := range productFile.Product.Variations {
+			assets = append(assets, strings.Split(variation.Thumbnail, ",")...)
+			assets = append(assets, variation.Images...)
+			for _, file := range variation.Files {
+				assets = append(assets, file.Path)
+			}
+		}
+		for _, asset := range assets {
+			checker.asset(rel, asset)
+		}
+	}
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(checkCmd)
+	checkCmd.Flags().StringP("source", "s", "", "rendered site folder, hugo/public by default")
+	checkCmd.Flags().StringP("content", "c", "", "generated content folder, hugo/content by default")
+	checkCmd.Flags().Bool("remote", false, "check links to storage too")
+}
@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gorm_logger "gorm.io/gorm/logger"
+	"log"
+	"os"
+	"path"
+	"time"
+)
+
+// openDatabase connects common.Database using configured dialer, used by auxiliary commands
+func openDatabase() error {
+	var dialer gorm.Dialector
+	if common.Config.Database.Dialer == "mysql" {
+		dialer = mysql.Open(common.Config.Database.Uri)
+	} else if common.Config.Database.Dialer == "postgres" {
+		dialer = postgres.Open(common.Config.Database.Uri)
+	} else {
+		var uri = path.Join(dir, os.Getenv("DATABASE_FOLDER"), "database.sqlite")
+		if common.Config.Database.Uri != "" {
+			uri = common.Config.Database.Uri
+		}
+		dialer = sqlite.Open(uri)
+	}
+	var err error
+	common.Database, err = gorm.Open(dialer, &gorm.Config{
+		Logger: gorm_logger.New(
+			log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
+			gorm_logger.Config{
+				SlowThreshold:             100 * time.Millisecond,
+				LogLevel:                  gorm_logger.Silent,
+				IgnoreRecordNotFoundError: true,
+				Colorful:                  true,
+			},
+		),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = common.Database.DB()
+	return err
+}
+
+// migrateDatabase creates and updates tables of all models, errors are logged as some of them are expected for old databases
+func migrateDatabase() {
+	if err := common.Database.AutoMigrate(&models.Category{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Product{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Parameter{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.File{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Image{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Variation{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Property{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Option{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Value{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Rate{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Price{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Coupon{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Discount{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Order{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Item{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Transaction{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Tag{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Tariff{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Transport{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Zone{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.PickupLocation{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Download{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.BundleComponent{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.ProductRelation{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Revision{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.ChangeSet{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Translation{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Redirect{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.RenderState{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Job{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := models.InitSearch(common.Database); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.EmailTemplate{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	//
+	if err := common.Database.AutoMigrate(&models.CacheCategory{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.CacheProduct{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.CacheFile{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.CacheImage{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.CacheVariation{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.CacheValue{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.CachePrice{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.CacheTag{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.CacheTransport{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.CacheVendor{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.CacheComment{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.CacheFacet{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	//
+	if err := common.Database.AutoMigrate(&models.BillingProfile{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.ShippingProfile{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	//
+	if err := common.Database.AutoMigrate(&models.Vendor{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	//
+	if err := common.Database.AutoMigrate(&models.Time{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	//
+	if err := common.Database.AutoMigrate(&models.Widget{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	//
+	if err := common.Database.AutoMigrate(&models.Wish{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	//
+	if err := common.Database.AutoMigrate(&models.Menu{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	//
+	if err := common.Database.AutoMigrate(&models.Comment{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	//
+	if err := common.Database.AutoMigrate(&models.Form{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Message{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.AutoMigrate(&models.Migration{}); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	//
+	if err := common.Database.Exec(`CREATE TABLE IF NOT EXISTS categories_products_sort (
+	CategoryId BIGINT UNSIGNED NOT NULL,
+	ProductId BIGINT UNSIGNED NOT NULL,
+	Value BIGINT UNSIGNED NOT NULL,
+		PRIMARY KEY (CategoryId, ProductId),
+		CONSTRAINT Constr_CategoryId_ProductId_fk
+	FOREIGN KEY (CategoryId) REFERENCES categories (ID)
+	ON DELETE CASCADE ON UPDATE CASCADE,
+		CONSTRAINT Constr_ProductId_CategoryId_fk
+	FOREIGN KEY (ProductId) REFERENCES products (ID)
+	ON DELETE CASCADE ON UPDATE CASCADE
+	)`).Error; err != nil {
+		logger.Errorf("%+v", err)
+	}
+	//
+	if err := common.Database.Exec(`CREATE TABLE IF NOT EXISTS products_relations (
+	ProductIdL BIGINT UNSIGNED NOT NULL,
+	ProductIdR BIGINT UNSIGNED NOT NULL,
+		PRIMARY KEY (ProductIdL, ProductIdR),
+		CONSTRAINT Constr_ProductIdL_ProductIdR_fk
+	FOREIGN KEY (ProductIdL) REFERENCES products (ID)
+	ON DELETE CASCADE ON UPDATE CASCADE,
+		CONSTRAINT Constr_ProductIdR_ProductIdL_fk
+	FOREIGN KEY (ProductIdR) REFERENCES products (ID)
+	ON DELETE CASCADE ON UPDATE CASCADE
+	)`).Error; err != nil {
+		logger.Errorf("%+v", err)
+	}
+	//
+	if err := common.Database.Exec(`update categories set sort = id where sort is null or sort = 0`).Error; err != nil {
+		logger.Errorf("%+v", err)
+	}
+	if err := common.Database.Exec(`update options set sort = id where sort is null or sort = 0`).Error; err != nil {
+		logger.Errorf("%+v", err)
+	}
+	if err := common.Database.Exec("update `values` set sort = id where sort is null or sort = 0").Error; err != nil {
+		logger.Errorf("%+v", err)
+	}
+}
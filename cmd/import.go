@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/logger"
+	"github.com/spf13/cobra"
+	"github.com/yonnic/goshop/handler"
+	"os"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import products file.csv",
+	Short: "Import products from csv",
+	Long:  `Create or update products, variations, properties, prices, categories, tags and images keyed by Name or Sku`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if args[0] != "products" {
+			logger.Errorf("Unsupported entity: %v", args[0])
+			os.Exit(1)
+		}
+		dryRun := cmd.Flag("dry-run").Value.String() == "true"
+		if err := openDatabase(); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		file, err := os.Open(args[1])
+		if err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		result, err := handler.ImportProductsCSV(file, dryRun)
+		if err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		if !dryRun && result.Created + result.Updated > 0 {
+			if err = handler.MarkChanged(fmt.Sprintf("products imported: %d created, %d updated", result.Created, result.Updated)); err != nil {
+				logger.Warningf("%v", err)
+			}
+		}
+		if bts, err := json.MarshalIndent(result, "", "   "); err == nil {
+			fmt.Println(string(bts))
+		}
+		if result.Failed > 0 {
+			os.Exit(2)
+		}
+	},
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export products [file.csv]",
+	Short: "Export products to csv",
+	Long:  `Export products and variations to csv in the same format as import, stdout is used if file is not set`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if args[0] != "products" {
+			logger.Errorf("Unsupported entity: %v", args[0])
+			os.Exit(1)
+		}
+		if err := openDatabase(); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		out := os.Stdout
+		if len(args) > 1 {
+			file, err := os.Create(args[1])
+			if err != nil {
+				logger.Errorf("%v", err)
+				os.Exit(1)
+			}
+			defer file.Close()
+			out = file
+		}
+		if err := handler.ExportProductsCSV(out); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(importCmd)
+	importCmd.Flags().BoolP("dry-run", "d", false, "report changes and errors without saving")
+	RootCmd.AddCommand(exportCmd)
+}
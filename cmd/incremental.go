@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/config"
+	"github.com/yonnic/goshop/models"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// getAffectedProducts returns products to render again since previous render: changed ones, ones which became published
+// and ones which are not published or do not exist anymore
+func getAffectedProducts(since, now time.Time) (map[uint]bool, error) {
+	affected := make(map[uint]bool)
+	ids, err := models.GetChangedProductIds(common.Database, since, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		affected[id] = true
+	}
+	var cached []uint
+	if err = common.Database.Model(&models.CacheProduct{}).Distinct("product_id").Pluck("product_id", &cached).Error; err != nil {
+		return nil, err
+	}
+	rendered := make(map[uint]bool)
+	for _, id := range cached {
+		rendered[id] = true
+	}
+	products, err := models.GetProducts(common.Database)
+	if err != nil {
+		return nil, err
+	}
+	published := make(map[uint]bool)
+	for _, product := range products {
+		if product.IsPublished(now) {
+			published[product.ID] = true
+			if !rendered[product.ID] {
+				affected[product.ID] = true
+			}
+		}
+	}
+	for id := range rendered {
+		if !published[id] {
+			affected[id] = true
+		}
+	}
+	return affected, nil
+}
+
+// removeProducts deletes content folders and cache of products, returns categories products were in
+func removeProducts(output string, affected map[uint]bool) []uint {
+	var ids []uint
+	for id := range affected {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	var categories []uint
+	var cached []*models.CacheProduct
+	if err := common.Database.Where("product_id in ?", ids).Find(&cached).Error; err == nil {
+		for _, product := range cached {
+			if p := path.Join(output, product.Path, product.Name); p != output {
+				if err = os.RemoveAll(p); err != nil {
+					logger.Warningf("%+v", err)
+				}
+			}
+			categories = append(categories, product.CategoryID)
+		}
+	}else{
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.Unscoped().Where("product_id in ?", ids).Delete(&models.CacheProduct{}).Error; err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := models.DeleteCacheFacetsByProductIds(common.Database, ids); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := common.Database.Unscoped().Where("variation_id in (?) or variation_id not in (?)",
+		common.Database.Model(&models.Variation{}).Select("id").Where("product_id in ?", ids),
+		common.Database.Model(&models.Variation{}).Select("id")).Delete(&models.CacheVariation{}).Error; err != nil {
+		logger.Warningf("%+v", err)
+	}
+	return categories
+}
+
+// updateCategoryCounts refreshes product count in category files of categories and their parents, other category data
+// like price ranges and filter options is only extended by rendered products and gets narrower on full render
+func updateCategoryCounts(output string, ids []uint, languages []config.Language) {
+	categories := make(map[uint]bool)
+	for _, id := range ids {
+		for id > 0 && !categories[id] {
+			categories[id] = true
+			if category, err := models.GetCategory(common.Database, int(id)); err == nil {
+				id = category.ParentId
+			}else{
+				break
+			}
+		}
+	}
+	for id := range categories {
+		cacheCategory, err := models.GetCacheCategoryByCategoryId(common.Database, id)
+		if err != nil {
+			continue
+		}
+		tree, err := models.GetCategoriesView(common.Database, int(id), 999, true, true, false)
+		if err != nil {
+			logger.Warningf("%+v", err)
+			continue
+		}
+		for _, language := range languages {
+			updateCategoryCount(path.Join(output, cacheCategory.Link, fmt.Sprintf("_index%s.html", language.Suffix)), tree.Count)
+		}
+	}
+	// Root
+	if tree, err := models.GetCategoriesView(common.Database, 0, 999, true, true, false); err == nil {
+		updateCategoryCount(path.Join(output, strings.ToLower(common.Config.Products), "_index.html"), tree.Count)
+	}else{
+		logger.Warningf("%+v", err)
+	}
+}
+
+func updateCategoryCount(p string, count int) {
+	if categoryFile, err := common.ReadCategoryFile(p); err == nil {
+		if categoryFile.Count != count {
+			categoryFile.Count = count
+			if err = common.WriteCategoryFile(p, categoryFile); err != nil {
+				logger.Warningf("%+v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"testing"
+	"time"
+)
+
+func TestGetAffectedProducts(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%v?mode=memory&cache=shared", t.Name())), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, value := range []interface{}{&models.Category{}, &models.Product{}, &models.Parameter{}, &models.File{}, &models.Image{},
+		&models.Variation{}, &models.Property{}, &models.Option{}, &models.Value{}, &models.Rate{}, &models.Price{}, &models.Tag{},
+		&models.Time{}, &models.Vendor{}, &models.Revision{}, &models.Comment{}, &models.BundleComponent{}, &models.ProductRelation{},
+		&models.CacheProduct{}} {
+		// index names are shared by tables in sqlite, so migration of dependent table stops at index of its dependency
+		if err := db.AutoMigrate(value); err != nil && !db.Migrator().HasTable(value) {
+			db.Migrator().CreateTable(value)
+		}
+	}
+	previous := common.Database
+	common.Database = db
+	defer func() {
+		common.Database = previous
+	}()
+	now := time.Now()
+	products := make(map[string]*models.Product)
+	for _, example := range []struct{
+		Name string
+		Enabled bool
+		Rendered bool
+	}{
+		{Name: "rendered", Enabled: true, Rendered: true},
+		{Name: "new", Enabled: true},
+		{Name: "disabled", Enabled: false, Rendered: true},
+		{Name: "edited", Enabled: true, Rendered: true},
+		{Name: "hidden", Enabled: false},
+	}{
+		product := &models.Product{Enabled: example.Enabled, Name: example.Name, Title: example.Name}
+		if _, err = models.CreateProduct(db, product); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if example.Rendered {
+			if err = db.Create(&models.CacheProduct{ProductID: product.ID, Name: product.Name}).Error; err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+		products[example.Name] = product
+	}
+	deleted := &models.Product{Enabled: true, Name: "deleted"}
+	if _, err = models.CreateProduct(db, deleted); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err = db.Create(&models.CacheProduct{ProductID: deleted.ID, Name: deleted.Name}).Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err = db.Unscoped().Delete(deleted).Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	// Previous render was after all of them except edited one
+	if err = db.Exec("UPDATE products SET updated_at = ?", now.Add(-time.Hour)).Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err = db.Model(products["edited"]).Update("title", "Edited").Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	affected, err := getAffectedProducts(now.Add(-time.Minute), time.Now())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := map[uint]bool{products["new"].ID: true, products["disabled"].ID: true, products["edited"].ID: true, deleted.ID: true}
+	if fmt.Sprint(affected) != fmt.Sprint(expected) {
+		t.Errorf("affected %v, expected %v", affected, expected)
+	}
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"context"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/handler"
+	"io/ioutil"
+	"path"
+)
+
+// Stages of this binary run by server as in-process jobs, hugo and external publisher are still commands
+func init() {
+	handler.RegisterJob(handler.JOB_PREPARE, func(ctx context.Context, log *logger.Logger, full bool, progress func(stage string, done, total int)) error {
+		return render(ctx, log, renderOptions{Output: path.Join(dir, "hugo", "content"), Site: path.Join(dir, "hugo"), Full: full}, progress)
+	})
+	handler.RegisterJob(handler.JOB_CHECK, func(ctx context.Context, log *logger.Logger, full bool, progress func(stage string, done, total int)) error {
+		return checkSite(ctx, log, path.Join(dir, "hugo", "public"), path.Join(dir, "hugo", "content"), common.Config.Check.Remote, common.STORAGE)
+	})
+	handler.RegisterJob(handler.JOB_PUBLISH, func(ctx context.Context, log *logger.Logger, full bool, progress func(stage string, done, total int)) error {
+		return publishSite(ctx, log, path.Join(dir, "hugo", "public"), progress)
+	})
+	handler.RegisterJob(handler.JOB_BACKUP, func(ctx context.Context, log *logger.Logger, full bool, progress func(stage string, done, total int)) error {
+		return createBackup(ctx, log, "", progress)
+	})
+}
+
+// consoleLogger is logger of stage run from command line, messages go to console only
+func consoleLogger(name string) *logger.Logger {
+	return logger.Init(name, true, false, ioutil.Discard)
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/storage"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// renderStage sums time spent by all workers in one stage of render
+type renderStage struct {
+	Name string
+	nanoseconds int64
+	count int64
+}
+
+func (s *renderStage) Add(t time.Time) {
+	atomic.AddInt64(&s.nanoseconds, int64(time.Since(t)))
+	atomic.AddInt64(&s.count, 1)
+}
+
+func (s *renderStage) String() string {
+	return fmt.Sprintf("%v ~ %.3f ms (%d)", s.Name, float64(atomic.LoadInt64(&s.nanoseconds))/1000000, atomic.LoadInt64(&s.count))
+}
+
+// logProgress prints progress line parsed by job runner, about 100 lines per stage at most
+func logProgress(stage string, done, total int) {
+	step := total / 100
+	if step < 1 {
+		step = 1
+	}
+	if done % step == 0 || done == total {
+		logger.Infof("Progress: %v %d/%d", stage, done, total)
+	}
+}
+
+// runOrdered calls work for 0..n-1 in pool of workers and runs returned functions in the calling goroutine strictly
+// in order of indexes, workers may be ahead of the ordered part by limited number of items only. It stops on the first
+// error of ordered function or when ctx is canceled and returns after all workers are done
+func runOrdered(ctx context.Context, n, workers int, work func(i int) func() error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]chan func() error, n)
+	for i := range results {
+		results[i] = make(chan func() error, 1)
+	}
+	jobs := make(chan int)
+	window := make(chan struct{}, workers * 4)
+	stop := make(chan struct{})
+	go func() {
+		defer close(jobs)
+		for i := 0; i < n; i++ {
+			select {
+			case window <- struct{}{}:
+			case <-stop:
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] <- work(i)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(stop)
+	for i := 0; i < n; i++ {
+		select {
+		case f := <-results[i]:
+			if f != nil {
+				if err := f(); err != nil {
+					return err
+				}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		<-window
+	}
+	return nil
+}
+
+// timedStorage measures time spent by storage on copying and resizing of files
+type timedStorage struct {
+	storage.Storage
+	stage *renderStage
+}
+
+func (s *timedStorage) PutFile(src, location string) (string, error) {
+	defer s.stage.Add(time.Now())
+	return s.Storage.PutFile(src, location)
+}
+
+func (s *timedStorage) PutImage(src, location, sizes string) ([]string, error) {
+	defer s.stage.Add(time.Now())
+	return s.Storage.PutImage(src, location, sizes)
+}
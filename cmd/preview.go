@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gorm_logger "gorm.io/gorm/logger"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+const PREVIEW_BATCH = 50 // rows inserted at once into preview database
+
+var (
+	// previewModels are read by render, preview database gets their tables and join tables with data
+	previewModels = []interface{}{&models.Category{}, &models.Product{}, &models.Parameter{}, &models.File{}, &models.Image{},
+		&models.Variation{}, &models.Property{}, &models.Option{}, &models.Value{}, &models.Rate{}, &models.Price{}, &models.Tag{},
+		&models.Tariff{}, &models.Transport{}, &models.Zone{}, &models.PickupLocation{}, &models.BundleComponent{},
+		&models.ProductRelation{}, &models.Translation{}, &models.Vendor{}, &models.Time{}, &models.Widget{}, &models.Menu{},
+		&models.Comment{}, &models.User{}}
+	// previewRawTables are created by migration without model
+	previewRawTables = []string{"categories_products_sort"}
+	// previewCaches are written by render, preview database gets them empty
+	previewCaches = []interface{}{&models.CacheCategory{}, &models.CacheProduct{}, &models.CacheFile{}, &models.CacheImage{},
+		&models.CacheVariation{}, &models.CacheValue{}, &models.CachePrice{}, &models.CacheTag{}, &models.CacheTransport{},
+		&models.CacheVendor{}, &models.CacheComment{}, &models.CacheFacet{}}
+)
+
+// previewPage is written by render in preview mode, server opens its Path in rendered preview site
+type previewPage struct {
+	Path string
+}
+
+func writePreviewPage(site string, productId, categoryId uint) error {
+	page := previewPage{Path: "/"}
+	if productId > 0 {
+		cache, err := models.GetCacheProductByProductId(common.Database, productId)
+		if err != nil {
+			return fmt.Errorf("product %v is not rendered: %v", productId, err)
+		}
+		page.Path = publicUrl(cache.Path + cache.Name + "/")
+	}else if categoryId > 0 {
+		cache, err := models.GetCacheCategoryByCategoryId(common.Database, categoryId)
+		if err != nil {
+			return fmt.Errorf("category %v is not rendered: %v", categoryId, err)
+		}
+		page.Path = publicUrl(cache.Link + "/")
+	}
+	bts, err := json.Marshal(page)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(site, "preview.json"), bts, 0644)
+}
+
+// openPreviewDatabase copies tables read by render to new sqlite database p, source is only read table by table, so
+// preview neither writes to it nor holds its locks while rendering
+func openPreviewDatabase(src *gorm.DB, p string) (*gorm.DB, error) {
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	dst, err := gorm.Open(sqlite.Open(p), &gorm.Config{Logger: gorm_logger.Default.LogMode(gorm_logger.Silent)})
+	if err != nil {
+		return nil, err
+	}
+	tables, err := previewTables(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		if !src.Migrator().HasTable(table) {
+			continue
+		}
+		if err = copyPreviewTable(src, dst, table); err != nil {
+			return nil, fmt.Errorf("%v: %w", table, err)
+		}
+	}
+	for _, value := range previewCaches {
+		// index names are shared by tables in sqlite, table is created even if its index is taken
+		if err = dst.AutoMigrate(value); err != nil && !dst.Migrator().HasTable(value) {
+			if err = dst.Migrator().CreateTable(value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return dst, nil
+}
+
+// previewTables returns tables of preview models, their many to many join tables and raw tables
+func previewTables(db *gorm.DB) ([]string, error) {
+	var tables []string
+	found := make(map[string]bool)
+	add := func(table string) {
+		if !found[table] {
+			found[table] = true
+			tables = append(tables, table)
+		}
+	}
+	for _, model := range previewModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		add(stmt.Schema.Table)
+		for _, relationship := range stmt.Schema.Relationships.Relations {
+			if relationship.JoinTable != nil {
+				add(relationship.JoinTable.Table)
+			}
+		}
+	}
+	for _, table := range previewRawTables {
+		add(table)
+	}
+	return tables, nil
+}
+
+// copyPreviewTable creates table with columns of source table and copies its rows, indexes are not needed for preview
+func copyPreviewTable(src, dst *gorm.DB, table string) error {
+	columns, err := src.Migrator().ColumnTypes(table)
+	if err != nil {
+		return err
+	}
+	var definitions []string
+	for _, column := range columns {
+		// sqlite takes any type name, date and time types are named to be read as time
+		typ := strings.ToUpper(column.DatabaseTypeName())
+		if strings.Contains(typ, "TIME") || strings.Contains(typ, "DATE") {
+			typ = "DATETIME"
+		}
+		definitions = append(definitions, fmt.Sprintf("`%s` %s", column.Name(), typ))
+	}
+	if err = dst.Exec(fmt.Sprintf("CREATE TABLE `%s` (%s)", table, strings.Join(definitions, ", "))).Error; err != nil {
+		return err
+	}
+	rows, err := src.Table(table).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var batch []map[string]interface{}
+	for rows.Next() {
+		row := make(map[string]interface{})
+		if err = src.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		// mysql driver returns text as bytes
+		for key, value := range row {
+			if bts, ok := value.([]byte); ok {
+				row[key] = string(bts)
+			}
+		}
+		if batch = append(batch, row); len(batch) == PREVIEW_BATCH {
+			if err = dst.Table(table).Create(&batch).Error; err != nil {
+				return err
+			}
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		if err = dst.Table(table).Create(&batch).Error; err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"github.com/yonnic/goshop/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestOpenPreviewDatabase(t *testing.T) {
+	folder := t.TempDir()
+	src, err := gorm.Open(sqlite.Open(path.Join(folder, "database.sqlite")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, value := range []interface{}{&models.Category{}, &models.Product{}, &models.Image{}, &models.Variation{}, &models.Order{}} {
+		// index names are shared by tables in sqlite, so migration of dependent table stops at index of its dependency
+		if err := src.AutoMigrate(value); err != nil && !src.Migrator().HasTable(value) {
+			src.Migrator().CreateTable(value)
+		}
+	}
+	if err = src.Exec("CREATE TABLE categories_products_sort (CategoryId BIGINT, ProductId BIGINT, Value BIGINT)").Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	category := &models.Category{Name: "shirts", Title: "Shirts"}
+	if _, err = models.CreateCategory(src, category); err != nil {
+		t.Fatalf("%v", err)
+	}
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	product := &models.Product{Enabled: true, Name: "shirt", Title: "Linen shirt", BasePrice: 12.5, PublishStart: start}
+	if _, err = models.CreateProduct(src, product); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err = models.AddProductToCategory(src, category, product); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err = src.Create(&models.Order{}).Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	db, err := openPreviewDatabase(src, path.Join(folder, "preview.sqlite"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	products, err := models.GetProductsByCategoryId(db, category.ID)
+	if err != nil || len(products) != 1 {
+		t.Fatalf("unexpected products: %+v %v", products, err)
+	}
+	if p := products[0]; p.ID != product.ID || !p.Enabled || p.Title != "Linen shirt" || p.BasePrice != 12.5 || !p.PublishStart.Equal(start) {
+		t.Errorf("unexpected product: %+v", p)
+	}
+	// Orders are not needed by render
+	if db.Migrator().HasTable(&models.Order{}) {
+		t.Errorf("orders are copied")
+	}
+	// Render writes caches to copy only
+	if _, err = models.CreateCacheProduct(db, &models.CacheProduct{ProductID: product.ID, Name: product.Name}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if src.Migrator().HasTable(&models.CacheProduct{}) {
+		t.Errorf("cache is created in source database")
+	}
+	if err = db.Model(&models.Product{}).Where("id = ?", product.ID).Update("title", "Draft").Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	if p, err := models.GetProduct(src, int(product.ID)); err != nil || p.Title != "Linen shirt" {
+		t.Errorf("source is changed: %+v %v", p, err)
+	}
+}
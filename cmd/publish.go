@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/logger"
+	"github.com/spf13/cobra"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/publisher"
+	"os"
+	"path"
+	"time"
+)
+
+var publishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "Publish site",
+	Long:  `Publish rendered site to S3 bucket or folder with built-in publisher, only changed files are transferred`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		t1 := time.Now()
+		src := path.Join(dir, "hugo", "public")
+		if flagSource := cmd.Flag("source").Value.String(); flagSource != "" {
+			src = flagSource
+		}
+		if err := publishSite(context.Background(), consoleLogger("publish"), src, logProgress); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		logger.Infof("Published ~ %.3f ms", float64(time.Since(t1).Nanoseconds())/1000000)
+	},
+}
+
+// publishSite transfers rendered site by built-in publisher
+func publishSite(ctx context.Context, logger *logger.Logger, src string, progress func(stage string, done, total int)) error {
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	p, err := newPublisher()
+	if err != nil {
+		return err
+	}
+	result, err := p.Publish(ctx, src, func(done, total int) {
+		progress("publish", done, total)
+	})
+	if err != nil {
+		return err
+	}
+	logger.Infof("Release %v: %v uploaded, %v unchanged, %v deleted", result.Release, result.Uploaded, result.Unchanged, result.Deleted)
+	return nil
+}
+
+// newPublisher creates built-in publisher by Publisher.Target
+func newPublisher() (publisher.Publisher, error) {
+	conf := common.Config.Publisher
+	headers := publisher.Headers{CacheControl: conf.CacheControl, PagesCacheControl: conf.PagesCacheControl}
+	switch conf.Target {
+	case "s3":
+		if conf.S3.Bucket == "" {
+			return nil, fmt.Errorf("bucket is not specified")
+		}
+		return publisher.NewAWSS3Publisher(conf.S3.AccessKeyID, conf.S3.SecretAccessKey, conf.S3.Region, conf.S3.Bucket, conf.S3.Prefix, headers)
+	case "file":
+		if conf.File.Path == "" {
+			return nil, fmt.Errorf("path is not specified")
+		}
+		return publisher.NewFilePublisher(conf.File.Path, conf.File.Keep)
+	}
+	return nil, fmt.Errorf("unknown publisher target '%v'", conf.Target)
+}
+
+func init() {
+	RootCmd.AddCommand(publishCmd)
+	publishCmd.Flags().StringP("source", "s", "", "rendered site folder, hugo/public by default")
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/config"
+	"github.com/yonnic/goshop/models"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// pageUrl is public url of rendered product or category and folder of its content files
+type pageUrl struct {
+	ObjectType string
+	ObjectId uint
+	Url string
+	Dir string
+}
+
+// getPageUrls collects rendered urls from cache tables, to get urls of previous render call it before cache is cleared
+func getPageUrls(output string) []pageUrl {
+	var urls []pageUrl
+	var products []*models.CacheProduct
+	if err := common.Database.Order("id asc").Find(&products).Error; err == nil {
+		for _, product := range products {
+			urls = append(urls, pageUrl{
+				ObjectType: "product",
+				ObjectId: product.ProductID,
+				Url: publicUrl(product.Path + product.Name + "/"),
+				Dir: path.Join(output, product.Path, product.Name),
+			})
+		}
+	}else{
+		logger.Warningf("%+v", err)
+	}
+	var categories []*models.CacheCategory
+	if err := common.Database.Order("id asc").Find(&categories).Error; err == nil {
+		for _, category := range categories {
+			urls = append(urls, pageUrl{
+				ObjectType: "category",
+				ObjectId: category.CategoryID,
+				Url: publicUrl(category.Link + "/"),
+				Dir: path.Join(output, category.Link),
+			})
+		}
+	}else{
+		logger.Warningf("%+v", err)
+	}
+	return urls
+}
+
+// publicUrl converts content path to url the same way render does for FlatUrl
+func publicUrl(p string) string {
+	if common.Config.FlatUrl {
+		products := "/" + strings.ToLower(common.Config.Products)
+		if p == products + "/" {
+			return products
+		}
+		if strings.HasPrefix(p, products + "/") {
+			return strings.TrimPrefix(p, products)
+		}
+	}
+	return p
+}
+
+// updateRedirects registers redirects for urls of previous render which are gone and emits them as hugo aliases,
+// _redirects (netlify style) and nginx map files into static folder of site
+func updateRedirects(site string, before, after []pageUrl, languages []config.Language) {
+	live := make(map[string]bool)
+	canonical := make(map[string]pageUrl)
+	var urls []string
+	for _, page := range after {
+		live[page.Url] = true
+		urls = append(urls, page.Url)
+		if _, found := canonical[page.ObjectType + fmt.Sprint(page.ObjectId)]; !found {
+			canonical[page.ObjectType + fmt.Sprint(page.ObjectId)] = page
+		}
+	}
+	// Urls served again do not need redirects anymore
+	if err := models.DeleteAutoRedirectsBySource(common.Database, urls); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	for _, page := range before {
+		if live[page.Url] {
+			continue
+		}
+		if target, found := canonical[page.ObjectType + fmt.Sprint(page.ObjectId)]; found {
+			if existing, err := models.GetRedirectBySource(common.Database, page.Url); err == nil && !existing.Auto {
+				continue
+			}
+			if err := models.SaveRedirect(common.Database, &models.Redirect{
+				Source: page.Url,
+				Target: target.Url,
+				Code: models.REDIRECT_CODE_PERMANENT,
+				Auto: true,
+				ObjectType: page.ObjectType,
+				ObjectId: page.ObjectId,
+			}); err != nil {
+				logger.Warningf("%+v", err)
+			}
+		}
+	}
+	redirects, err := models.GetRedirects(common.Database)
+	if err != nil {
+		logger.Warningf("%+v", err)
+		return
+	}
+	// Aliases
+	pages := make(map[string]pageUrl)
+	for _, page := range after {
+		if _, found := pages[page.Url]; !found {
+			pages[page.Url] = page
+		}
+	}
+	for _, redirect := range redirects {
+		if redirect.Code != models.REDIRECT_CODE_PERMANENT {
+			continue
+		}
+		if page, found := pages[redirect.Target]; found {
+			for _, language := range languages {
+				if page.ObjectType == "product" {
+					p := path.Join(page.Dir, fmt.Sprintf("index%s.html", language.Suffix))
+					if productFile, err := common.ReadProductFile(p); err == nil {
+						if !hasString(productFile.Aliases, redirect.Source) {
+							productFile.Aliases = append(productFile.Aliases, redirect.Source)
+							if err = common.WriteProductFile(p, productFile); err != nil {
+								logger.Warningf("%+v", err)
+							}
+						}
+					}
+				}else{
+					p := path.Join(page.Dir, fmt.Sprintf("_index%s.html", language.Suffix))
+					if categoryFile, err := common.ReadCategoryFile(p); err == nil {
+						if !hasString(categoryFile.Aliases, redirect.Source) {
+							categoryFile.Aliases = append(categoryFile.Aliases, redirect.Source)
+							if err = common.WriteCategoryFile(p, categoryFile); err != nil {
+								logger.Warningf("%+v", err)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	// Files for publisher
+	p := path.Join(site, "static")
+	if _, err := os.Stat(p); err != nil {
+		if err = os.MkdirAll(p, 0755); err != nil {
+			logger.Warningf("%+v", err)
+			return
+		}
+	}
+	buff1 := &bytes.Buffer{}
+	buff2 := &bytes.Buffer{}
+	for _, redirect := range redirects {
+		fmt.Fprintf(buff1, "%s %s %d\n", redirect.Source, redirect.Target, redirect.Code)
+		fmt.Fprintf(buff2, "%s %s;\n", redirect.Source, redirect.Target)
+	}
+	if err = ioutil.WriteFile(path.Join(p, "_redirects"), buff1.Bytes(), 0644); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err = ioutil.WriteFile(path.Join(p, "redirects.map"), buff2.Bytes(), 0644); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	logger.Infof("Redirects: %v", len(redirects))
+}
+
+func hasString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestUpdateRedirects(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%v?mode=memory&cache=shared", t.Name())), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err = db.AutoMigrate(&models.Redirect{}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	previous, previousDir := common.Database, dir
+	common.Database, dir = db, t.TempDir()
+	defer func() {
+		common.Database, dir = previous, previousDir
+	}()
+	site := t.TempDir()
+	before := []pageUrl{{ObjectType: "product", ObjectId: 1, Url: "/shirts/red-shirt/"}}
+	after := []pageUrl{{ObjectType: "product", ObjectId: 1, Url: "/shirts/red-shirt-2/", Dir: t.TempDir()}}
+	updateRedirects(site, before, after, nil)
+	bts, err := ioutil.ReadFile(path.Join(site, "static", "_redirects"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if expected := "/shirts/red-shirt/ /shirts/red-shirt-2/ 301\n"; string(bts) != expected {
+		t.Errorf("_redirects %q, expected %q", string(bts), expected)
+	}
+	if _, err = os.Stat(path.Join(dir, "hugo", "static")); err == nil {
+		t.Errorf("redirects are written out of site")
+	}
+}
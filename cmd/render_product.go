@@ -0,0 +1,1039 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/config"
+	"github.com/yonnic/goshop/models"
+	"github.com/yonnic/goshop/storage"
+	"gorm.io/gorm"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// productRenderer renders products in stages: load, images and views are run by pool of workers and only read database,
+// write is run by single writer in order of products and is the only stage creating cache rows
+type productRenderer struct {
+	Output string
+	Now time.Time
+	Preview bool
+	Clear bool
+	Languages []config.Language
+	Translators map[string]*models.Translator
+	TransitMin int
+	TransitMax int
+	Storage storage.Storage // images and files are copied to
+	load *renderStage
+	views *renderStage
+	write *renderStage
+}
+
+// productMedia is result of images stage, images and files are copied to storage, cache rows are created by writer
+type productMedia struct {
+	Thumbnail string
+	Images []string
+	Files []common.FilePF
+	Variations map[uint]*variationMedia
+	Values map[string]string // thumbnails of values by storage path
+	CacheImages []*models.CacheImage
+	CacheFiles []*models.CacheFile
+	CacheValues []*cacheValue
+	storage storage.Storage
+}
+
+type variationMedia struct {
+	Thumbnail string
+	Images []string
+	Files []common.FilePF
+}
+
+type cacheValue struct {
+	Path string
+	*models.CacheValue
+}
+
+// renderedProduct is result of views stage passed to writer
+type renderedProduct struct {
+	Product *models.Product
+	File *common.ProductFile
+	Media *productMedia
+	Variations []string
+	CacheVariations []*models.CacheVariation
+}
+
+// render runs load, images and views stages and returns write stage to be called by writer in order of products
+func (r *productRenderer) render(i int, product *models.Product) func() error {
+	if !product.IsPublished(r.Now) && !r.Preview {
+		return nil
+	}
+	t := time.Now()
+	logger.Infof("[%d] Product ID: %+v Name: %v Title: %v", i, product.ID, product.Name, product.Title)
+	product, err := r.loadProduct(product)
+	if err != nil {
+		logger.Infof("%v", err)
+		return nil
+	}
+	r.load.Add(t)
+	media := r.copyMedia(product)
+	t = time.Now()
+	rendered := r.viewProduct(product, media)
+	r.views.Add(t)
+	return func() error {
+		t := time.Now()
+		if err := r.writeProduct(rendered); err != nil {
+			return err
+		}
+		logger.Infof("[%d] Product ID: %+v ~ %.3f ms", i, product.ID, float64(time.Since(t).Nanoseconds())/1000000)
+		r.write.Add(t)
+		return nil
+	}
+}
+
+// loadProduct returns staged drafts as last published revision, preview shows drafts
+func (r *productRenderer) loadProduct(product *models.Product) (*models.Product, error) {
+	if r.Preview {
+		return models.GetProductFull(common.Database, int(product.ID))
+	}
+	return models.GetPublishedProductFull(common.Database, int(product.ID))
+}
+
+// copyMedia copies images and files of product, its published variations and thumbnails of values
+func (r *productRenderer) copyMedia(product *models.Product) *productMedia {
+	media := &productMedia{
+		Variations: make(map[uint]*variationMedia),
+		Values: make(map[string]string),
+		storage: r.Storage,
+	}
+	// Thumbnail
+	if product.Image != nil {
+		if p1, fi, ok := storageFile(product.Image.Path); ok {
+			filename := fmt.Sprintf("%d-%s-%d%v", product.ID, reSanitizeFilename.ReplaceAllString(truncateName(product.Name), "_"), fi.ModTime().Unix(), path.Ext(p1))
+			if thumbnail, err := putImage(media.storage, p1, path.Join("images", filename), common.Config.Resize.Image.Size, fi); err == nil {
+				media.Thumbnail = thumbnail
+			}
+		}
+	}
+	// Images, thumbnail of main image is shared by products
+	for i, image := range product.Images {
+		if image.Path == "" {
+			continue
+		}
+		main := i == 0 || product.ImageId == image.ID
+		var thumbnail string
+		if v, found := CACHE_IMAGES.Get(image.Path); found {
+			thumbnail = v.(string)
+		}else if p1, fi, ok := storageFile(image.Path); ok {
+			filename := fmt.Sprintf("%d-%s-%d%v", image.ID, reSanitizeFilename.ReplaceAllString(truncateName(product.Name), "_"), fi.ModTime().Unix(), path.Ext(p1))
+			var err error
+			if thumbnail, err = putImage(media.storage, p1, path.Join("images", filename), common.Config.Resize.Image.Size, fi); err != nil {
+				continue
+			}
+			if main {
+				CACHE_IMAGES.Set(image.Path, thumbnail)
+			}
+			media.cacheImage(image, thumbnail)
+		}else{
+			continue
+		}
+		if main {
+			media.Thumbnail = thumbnail
+		}
+		media.Images = append(media.Images, thumbnail)
+	}
+	// Files
+	for _, file := range product.Files {
+		if view, ok := media.copyFile(product, file); ok {
+			media.Files = append(media.Files, view)
+		}
+	}
+	// Variations, default variation uses images and files of product
+	if !product.Container {
+		media.Variations[0] = &variationMedia{Thumbnail: copyThumbnail(media.storage, product.Thumbnail, path.Join("images", "variations"))}
+	}
+	for _, variation := range product.Variations {
+		if !variation.IsPublished(r.Now) {
+			continue
+		}
+		copied := &variationMedia{Thumbnail: copyThumbnail(media.storage, variation.Thumbnail, path.Join("images", "variations"))}
+		for _, image := range variation.Images {
+			if image.Path == "" {
+				continue
+			}
+			if p1, fi, ok := storageFile(image.Path); ok {
+				filename := fmt.Sprintf("%d-%s-%d%v", image.ID, reSanitizeFilename.ReplaceAllString(truncateName(product.Name + "-" + variation.Name), "_"), fi.ModTime().Unix(), path.Ext(p1))
+				if thumbnail, err := putImage(media.storage, p1, path.Join("images", filename), common.Config.Resize.Thumbnail.Size, fi); err == nil {
+					copied.Images = append(copied.Images, thumbnail)
+					media.cacheImage(image, thumbnail)
+				}
+			}
+		}
+		for _, file := range variation.Files {
+			if view, ok := media.copyFile(product, file); ok {
+				copied.Files = append(copied.Files, view)
+			}
+		}
+		media.Variations[variation.ID] = copied
+	}
+	// Values of parameters and properties, also used by options of categories
+	for _, parameter := range product.Parameters {
+		media.copyValue(parameter.Value)
+	}
+	for _, property := range product.Properties {
+		for _, rate := range property.Rates {
+			media.copyValue(rate.Value)
+		}
+	}
+	for _, variation := range product.Variations {
+		for _, property := range variation.Properties {
+			for _, rate := range property.Rates {
+				media.copyValue(rate.Value)
+			}
+		}
+	}
+	return media
+}
+
+func (m *productMedia) cacheImage(image *models.Image, thumbnail string) {
+	m.CacheImages = append(m.CacheImages, &models.CacheImage{
+		ImageId:   image.ID,
+		Name:      image.Name,
+		Thumbnail: thumbnail,
+	})
+}
+
+// copyFile copies file of product or its variation, files for download are not published
+func (m *productMedia) copyFile(product *models.Product, file *models.File) (common.FilePF, bool) {
+	if file.Path == "" || file.Download {
+		return common.FilePF{}, false
+	}
+	p1, fi, ok := storageFile(file.Path)
+	if !ok {
+		return common.FilePF{}, false
+	}
+	filename := fmt.Sprintf("%d-%s-%d%v", file.ID, truncateName(product.Name + "-" + file.Name), fi.ModTime().Unix(), path.Ext(p1))
+	location := path.Join("files", filename)
+	t := time.Now()
+	url, err := m.storage.PutFile(p1, location)
+	if err != nil {
+		logger.Warningf("%v", err)
+		return common.FilePF{}, false
+	}
+	logger.Infof("Copy %v => %v %v bytes in ~ %.3f ms", p1, location, fi.Size(), float64(time.Since(t).Nanoseconds())/1000000)
+	m.CacheFiles = append(m.CacheFiles, &models.CacheFile{
+		FileId: file.ID,
+		Name:   file.Name,
+		File:   url,
+	})
+	return common.FilePF{
+		Id:   file.ID,
+		Type: file.Type,
+		Name: file.Name,
+		Path: url,
+		Size: file.Size,
+	}, true
+}
+
+// copyValue copies thumbnail of value missing in CACHE_VALUES, the writer caches the first copy only
+func (m *productMedia) copyValue(value *models.Value) {
+	if value == nil || value.Thumbnail == "" {
+		return
+	}
+	if _, found := m.Values[value.Thumbnail]; found {
+		return
+	}
+	if v, found := CACHE_VALUES.Get(value.Thumbnail); found {
+		m.Values[value.Thumbnail] = v.(string)
+		return
+	}
+	if thumbnail := copyThumbnail(m.storage, value.Thumbnail, path.Join("images", "values")); thumbnail != "" {
+		m.Values[value.Thumbnail] = thumbnail
+		m.CacheValues = append(m.CacheValues, &cacheValue{
+			Path: value.Thumbnail,
+			CacheValue: &models.CacheValue{
+				ValueID:   value.ID,
+				Title:     value.Title,
+				Thumbnail: thumbnail,
+				Value:     value.Value,
+			},
+		})
+	}
+}
+
+// viewProduct makes product file and views of product and its variations
+func (r *productRenderer) viewProduct(product *models.Product, media *productMedia) *renderedProduct {
+	rendered := &renderedProduct{Product: product, Media: media}
+	// Common actions independent of category
+	productFile := &common.ProductFile{
+		ID:         product.ID,
+		Date:       time.Now(),
+		Title:      product.Title,
+		Type:       "products",
+		Thumbnail:  media.Thumbnail,
+	}
+	productView := common.ProductPF{
+		Id:         product.ID,
+		Name:       product.Name,
+		Title:      product.Title,
+		Thumbnail:  media.Thumbnail,
+		Images:     media.Images,
+		Files:      media.Files,
+	}
+	if product.Type != "" {
+		productView.Type = product.Type
+	}else {
+		productView.Type = "swatch"
+	}
+	if product.Size != "" {
+		productView.Size = product.Size
+	}else {
+		productView.Size = "medium"
+	}
+	productView.Description = reTags.ReplaceAllString(product.Description, "")
+	productView.Description = reSpace.ReplaceAllString(product.Description, " ")
+	if len(productView.Description) > 160 {
+		productView.Description = productView.Description[:160]
+	}
+	// Parameters
+	for _, parameter := range product.Parameters {
+		parameterView := common.ParameterPF{
+			Id:    parameter.ID,
+			Name:  parameter.Name,
+			Title: parameter.Title,
+		}
+		if parameter.Value != nil {
+			parameterView.Value = &common.ValuePPF{
+				Id:    parameter.Value.ID,
+				Title: parameter.Value.Title,
+				Value: parameter.Value.Value,
+				Availability: parameter.Value.Availability,
+			}
+		} else {
+			parameterView.CustomValue = parameter.CustomValue
+		}
+		productView.Parameters = append(productView.Parameters, parameterView)
+	}
+	basePrice, salePrice := minPrices(product.Variations)
+	productFile.BasePrice = fmt.Sprintf("%.2f", basePrice)
+	if product.IsSale(r.Now) {
+		productFile.SalePrice = fmt.Sprintf("%.2f", salePrice)
+	}
+	if product.ItemPrice > 0 {
+		productFile.ItemPrice = fmt.Sprintf("%.2f", product.ItemPrice)
+	}
+	productView.CustomParameters = []common.CustomParameterPF{}
+	if product.CustomParameters != "" {
+		for _, line := range strings.Split(strings.TrimSpace(product.CustomParameters), "\n"){
+			if res := reKV.FindAllStringSubmatch(strings.TrimSpace(line), -1); len(res) > 0 && len(res[0]) > 1 {
+				parameter := common.CustomParameterPF{
+					Key:   res[0][1],
+				}
+				if len(res[0]) > 2 {
+					parameter.Value = res[0][2]
+				}
+				productView.CustomParameters = append(productView.CustomParameters, parameter)
+			}
+		}
+	}
+	// Related
+	if relations, err := models.GetProductRelations(common.Database, product.ID); err == nil {
+		for _, relation := range relations {
+			id, p := relation.Other(product.ID)
+			if p == nil {
+				continue
+			}
+			if !r.Preview {
+				if p, err = models.GetPublishedProduct(common.Database, p); err != nil {
+					continue
+				}
+			}
+			if !p.IsPublished(r.Now) {
+				continue
+			}
+			if relation.Type == models.RELATION_TYPE_RELATED {
+				if id < product.ID {
+					productFile.Related = append(productFile.Related, fmt.Sprintf("%d-%d", id, product.ID))
+				}else{
+					productFile.Related = append(productFile.Related, fmt.Sprintf("%d-%d", product.ID, id))
+				}
+			}
+			relationView := common.RelationPF{
+				Type: relation.Type,
+				Id: id,
+				Name: p.Name,
+				Title: p.Title,
+				Path: productCanonical(p),
+				BasePrice: p.BasePrice,
+				SalePrice: p.SalePrice,
+			}
+			if cache, err := models.GetCacheProductByProductId(common.Database, id); err == nil {
+				relationView.Thumbnail = cache.Thumbnail
+				relationView.BasePrice = cache.BasePrice
+				relationView.SalePrice = cache.SalePrice
+			}
+			productView.Relations = append(productView.Relations, relationView)
+		}
+	}else{
+		logger.Errorf("%v", err)
+	}
+	// Bundle
+	if product.Bundle {
+		getComponents := models.GetPublishedBundleComponents
+		if r.Preview {
+			getComponents = models.GetBundleComponents
+		}
+		if components, err := getComponents(common.Database, product.ID); err == nil {
+			bundleView := &common.BundlePF{Pricing: product.BundlePricing, Discount: product.BundleDiscount}
+			var required []*models.BundleComponent
+			for _, component := range components {
+				if !component.Optional {
+					required = append(required, component)
+				}
+				basePrice, price := component.Prices(r.Now)
+				componentView := common.BundleComponentPF{
+					Id: component.ID,
+					ProductId: component.ProductId,
+					VariationId: component.VariationId,
+					Title: component.Title(),
+					Quantity: component.Quantity,
+					Optional: component.Optional,
+					BasePrice: basePrice,
+					Price: price,
+				}
+				if component.Product != nil {
+					componentView.Path = productCanonical(component.Product)
+				}
+				if cache, err := models.GetCacheProductByProductId(common.Database, component.ProductId); err == nil {
+					componentView.Thumbnail = cache.Thumbnail
+				}
+				bundleView.Components = append(bundleView.Components, componentView)
+			}
+			bundleView.BasePrice, bundleView.SalePrice = models.GetBundlePrice(product, required, r.Now)
+			bundleView.Stock, bundleView.Availability = models.GetBundleStock(components)
+			productView.Bundle = bundleView
+		}else{
+			logger.Warningf("%+v", err)
+		}
+	}
+	//
+	if !product.Container {
+		variation := &models.Variation{
+			ID:           0,
+			Enabled: true,
+			Name:         "default",
+			Title:        "Default",
+			Thumbnail:    product.Thumbnail,
+			Properties:   product.Properties,
+			BasePrice:    product.BasePrice,
+			ManufacturerPrice: product.ManufacturerPrice,
+			SalePrice:    product.SalePrice,
+			Start:        product.Start,
+			End:          product.End,
+			ItemPrice: product.ItemPrice,
+			MinQuantity: product.MinQuantity,
+			MaxQuantity: product.MaxQuantity,
+			PurchasableMultiply: product.PurchasableMultiply,
+			Prices: product.Prices,
+			Pattern: product.Pattern,
+			Dimensions: product.Dimensions,
+			DimensionUnit: product.DimensionUnit,
+			Width:        product.Width,
+			Height:       product.Height,
+			Depth:        product.Depth,
+			Volume:       product.Volume,
+			Weight:       product.Weight,
+			WeightUnit: product.WeightUnit,
+			Packages:     product.Packages,
+			Availability: product.Availability,
+			Time:         product.Time,
+			Sku:          product.Sku,
+			ProductId:    product.ID,
+		}
+		if variation.DimensionUnit == "" && common.Config.DimensionUnit != "" {
+			variation.DimensionUnit = common.Config.DimensionUnit
+		}
+		if variation.WeightUnit == "" && common.Config.WeightUnit != "" {
+			variation.WeightUnit = common.Config.WeightUnit
+		}
+		if product.Variation != "" {
+			variation.Title = product.Variation
+		}
+		if productView.Bundle != nil {
+			variation.BasePrice = productView.Bundle.BasePrice
+			variation.SalePrice = productView.Bundle.SalePrice
+			variation.Start = time.Time{}
+			variation.End = time.Time{}
+			variation.Stock = productView.Bundle.Stock
+			if productView.Bundle.Availability != "" {
+				variation.Availability = productView.Bundle.Availability
+			}
+		}
+		product.Variations = append([]*models.Variation{variation}, product.Variations...)
+	}
+	if len(product.Variations) > 0 {
+		basePrice, salePrice = minPrices(product.Variations)
+		productFile.BasePrice = fmt.Sprintf("%.2f", basePrice)
+		if product.Variations[0].IsSale(r.Now) {
+			productFile.SalePrice = fmt.Sprintf("%.2f", salePrice)
+		}
+		if product.Variations[0].ItemPrice > 0 {
+			productFile.ItemPrice = fmt.Sprintf("%.2f", product.Variations[0].ItemPrice)
+		}
+		for _, variation := range product.Variations {
+			if !variation.IsPublished(r.Now) {
+				continue
+			}
+			copied, found := media.Variations[variation.ID]
+			if !found {
+				copied = &variationMedia{}
+			}
+			variationView := common.VariationPF{
+				Id:    variation.ID,
+				Name:  variation.Name,
+				Title: variation.Title,
+				Thumbnail:   copied.Thumbnail,
+				Description: variation.Description,
+				BasePrice:   variation.BasePrice,
+				ItemPrice:   variation.ItemPrice,
+				MinQuantity:   variation.MinQuantity,
+				MaxQuantity:   variation.MaxQuantity,
+				PurchasableMultiply:   variation.PurchasableMultiply,
+				Pattern:      variation.Pattern,
+				Dimensions:   variation.Dimensions,
+				DimensionUnit:   variation.DimensionUnit,
+				Width:        variation.Width,
+				Height:       variation.Height,
+				Depth:        variation.Depth,
+				Volume:       variation.Volume,
+				Weight:       variation.Weight,
+				WeightUnit:   variation.WeightUnit,
+				Packages:     variation.Packages,
+				Availability: variation.Availability,
+				Sku:          variation.Sku,
+				Selected:     len(productView.Variations) == 0,
+			}
+			if variationView.DimensionUnit == "" && common.Config.DimensionUnit != "" {
+				variationView.DimensionUnit = common.Config.DimensionUnit
+			}
+			if variationView.WeightUnit == "" && common.Config.WeightUnit != "" {
+				variationView.WeightUnit = common.Config.WeightUnit
+			}
+			//
+			for _, price := range variation.Prices {
+				var ids []uint
+				for _, rate := range price.Rates {
+					ids = append(ids, rate.ID)
+				}
+				pricePF := common.PricePF{
+					Ids:          ids,
+					Thumbnail:    price.Thumbnail,
+					BasePrice:        price.BasePrice,
+					Availability: price.Availability,
+					Sku:          price.Sku,
+				}
+				if price.SalePrice > 0 {
+					pricePF.SalePrice = price.SalePrice
+				}
+				if v, found := CACHE_PRICES.Get(pricePF.Thumbnail); found {
+					pricePF.Thumbnail = v.(string)
+				}
+				variationView.Prices = append(variationView.Prices, pricePF)
+			}
+			//
+			if variation.Time != nil {
+				variationView.Time = variation.Time.Title
+				variationView.Estimate = estimate(variation.Time, product.Vendor, r.TransitMin, r.TransitMax)
+			} else {
+				variationView.Estimate = estimate(product.Time, product.Vendor, r.TransitMin, r.TransitMax)
+			}
+			// Images and files
+			if variationView.Id == 0 {
+				variationView.Images = media.Images
+				variationView.Files = media.Files
+			} else {
+				if len(copied.Images) > 0 {
+					variationView.Images = copied.Images
+					productView.Images = append(productView.Images, copied.Images...)
+				}
+				if len(copied.Files) > 0 {
+					variationView.Files = copied.Files
+					productView.Files = append(productView.Files, copied.Files...)
+				}
+			}
+			if variation.IsSale(r.Now) {
+				variationView.SalePrice = variation.SalePrice
+			}
+			rendered.Variations = append(rendered.Variations, strings.Join([]string{fmt.Sprintf("%d", variation.ID), variation.Title}, ","))
+			for _, property := range variation.Properties {
+				propertyView := common.PropertyPF{
+					Id:    property.ID,
+					Type:  property.Type,
+					Size:  property.Size,
+					Name:  property.Name,
+					Title: property.Title,
+				}
+				for h, rate := range property.Rates {
+					valueView := common.ValuePF{
+						Id:          rate.Value.ID,
+						Enabled:     rate.Enabled,
+						Title:       rate.Value.Title,
+						Description: rate.Value.Description,
+						Color:       rate.Value.Color,
+						Thumbnail:   media.Values[rate.Value.Thumbnail],
+						Value:        rate.Value.Value,
+						Availability: rate.Value.Availability,
+						Price: common.RatePF{
+							Id:           rate.ID,
+							Price:        rate.Price,
+							Availability: rate.Availability,
+							Sku:          rate.Sku,
+						},
+						Selected: h == 0,
+					}
+					propertyView.Values = append(propertyView.Values, valueView)
+				}
+				if len(propertyView.Values) > 0 {
+					variationView.Properties = append(variationView.Properties, propertyView)
+				}
+			}
+			productView.Variations = append(productView.Variations, variationView)
+			// Cache
+			rendered.CacheVariations = append(rendered.CacheVariations, &models.CacheVariation{
+				VariationID: variation.ID,
+				Name:        variation.Name,
+				Title:       variation.Title,
+				Description: variation.Description,
+				Thumbnail:   variationView.Thumbnail,
+				BasePrice:   variation.BasePrice,
+				SalePrice:   variation.SalePrice,
+			})
+		}
+	}
+	productView.Pattern = product.Pattern
+	productView.Dimensions = product.Dimensions
+	productView.DimensionUnit = product.DimensionUnit
+	productView.Volume = product.Volume
+	productView.Weight = product.Weight
+	productView.WeightUnit = product.WeightUnit
+	productView.Availability = product.Availability
+	if product.Vendor != nil {
+		productView.Vendor = common.VendorPF{
+			Id:          product.Vendor.ID,
+			Name:        product.Vendor.Name,
+			Title:       product.Vendor.Title,
+			Thumbnail:   product.Vendor.Thumbnail,
+			Description: product.Vendor.Description,
+		}
+		if product.Vendor.Thumbnail != "" {
+			if cache, err := models.GetCacheVendorByVendorId(common.Database, product.VendorId); err == nil {
+				productView.Vendor.Thumbnail = cache.Thumbnail
+			}
+		}
+	}
+	if product.Time != nil {
+		productView.Time = product.Time.Title
+	}
+	productView.Estimate = estimate(product.Time, product.Vendor, r.TransitMin, r.TransitMax)
+	productFile.Product = productView
+	for _, productTag := range product.Tags {
+		if productTag.Enabled {
+			tag := common.TagPF{Id: productTag.ID, Name: productTag.Name, Title: productTag.Title}
+			if productTag.Thumbnail != "" {
+				if cache, err := models.GetCacheTagByTagId(common.Database, productTag.ID); err == nil {
+					tag.Thumbnail = cache.Thumbnail
+				}
+			}
+			productFile.Tags = append(productFile.Tags, tag)
+		}
+	}
+	var max, votes int
+	if comments, err := models.GetCommentsByProduct(common.Database, product.ID); err == nil {
+		for _, comment := range comments {
+			if comment.Enabled {
+				commentPF := common.CommentPF{
+					Id:    comment.ID,
+					Uuid:  comment.Uuid,
+					Title: comment.Title,
+					Body:  comment.Body,
+					Max:   comment.Max,
+				}
+				if cache, err := models.GetCacheCommentByCommentId(common.Database, comment.ID); err == nil {
+					if cache.Images != "" {
+						commentPF.Images = strings.Split(cache.Images, ";")
+					}
+				}
+				if user, err := models.GetUser(common.Database, int(comment.UserId)); err == nil {
+					if user.Name != "" || user.Lastname != "" {
+						commentPF.Author = strings.TrimSpace(fmt.Sprintf("%s %s", user.Name, user.Lastname))
+					}else {
+						commentPF.Author = reTrimEmail.ReplaceAllString(user.Email, "$1")
+					}
+				}
+				productFile.Comments = append(productFile.Comments, commentPF)
+				max += comment.Max
+				votes++
+			}
+		}
+	}
+	if max > 0 && votes > 0 {
+		productFile.Max = math.Round((float64(max) / float64(votes)) * 100) / 100
+	}
+	productFile.Votes = votes
+	productFile.Description = product.Content
+	productFile.Content = product.Content
+	rendered.File = productFile
+	return rendered
+}
+
+// writeProduct creates cache rows, updates files of categories and writes product files in every category
+func (r *productRenderer) writeProduct(rendered *renderedProduct) error {
+	var err error
+	product, productFile, media := rendered.Product, rendered.File, rendered.Media
+	// Cache, the same image, file or value may be copied by several products
+	for _, cacheImage := range media.CacheImages {
+		key := fmt.Sprintf("%v", cacheImage.ImageId)
+		if !CACHE_IMAGES.Has(key) && (r.Clear || !models.HasCacheImageByImageId(common.Database, cacheImage.ImageId)) {
+			if _, err = models.CreateCacheImage(common.Database, cacheImage); err == nil {
+				CACHE_IMAGES.Set(key, true)
+			} else {
+				logger.Warningf("%v", err)
+			}
+		}
+	}
+	for _, cacheFile := range media.CacheFiles {
+		if !models.HasCacheFile(common.Database, cacheFile.FileId, cacheFile.File) {
+			if _, err = models.CreateCacheFile(common.Database, cacheFile); err != nil {
+				logger.Warningf("%v", err)
+			}
+		}
+	}
+	for _, cacheValue := range media.CacheValues {
+		if CACHE_VALUES.SetIfAbsent(cacheValue.Path, cacheValue.Thumbnail) {
+			if _, err = models.CreateCacheValue(common.Database, cacheValue.CacheValue); err != nil {
+				logger.Warningf("%v", err)
+			}
+		}
+	}
+	for _, cacheVariation := range rendered.CacheVariations {
+		if _, err = models.CreateCacheVariation(common.Database, cacheVariation); err != nil {
+			logger.Warningf("%v", err)
+		}
+	}
+	// Filter values as published
+	if err = models.CreateCacheFacets(common.Database, models.NewCacheFacets(product)); err != nil {
+		logger.Warningf("%v", err)
+	}
+	// Categories of snapshot, draft may move product to other categories
+	var canonical string
+	var canonicalBreadcrumbs []*models.Category
+	for i, category := range product.Categories {
+		breadcrumbs := &[]*models.Category{}
+		createBreadcrumbs(common.Database, category.ID, breadcrumbs, product)
+		if common.Config.Products != "" {
+			*breadcrumbs = append([]*models.Category{{Name: strings.ToLower(common.Config.Products), Title: common.Config.Products, Model: gorm.Model{UpdatedAt: time.Now()}}}, *breadcrumbs...)
+		}
+		var names []string
+		for _, crumb := range *breadcrumbs {
+			names = append(names, crumb.Name)
+		}
+		if i == 0 {
+			canonical = fmt.Sprintf("/%s/", path.Join(strings.Join(names, "/"), product.Name))
+			canonicalBreadcrumbs = *breadcrumbs
+		}
+		p1 := path.Join(append([]string{r.Output}, names...)...)
+		if _, err := os.Stat(p1); err != nil {
+			if err = os.MkdirAll(p1, 0755); err == nil {
+				logger.Infof("Create directory %v", p1)
+			} else {
+				return err
+			}
+		}
+		//
+		productFile.CategoryId = category.ID
+		if i > 0 {
+			productFile.Canonical = canonical
+		}
+		//
+		var arr = []string{}
+		t := time.Now()
+		for _, category := range *breadcrumbs {
+			arr = append(arr, category.Name)
+			for _, language := range r.Languages {
+				p2 := path.Join(append(append([]string{r.Output}, arr...), fmt.Sprintf("_index%s.html", language.Suffix))...)
+				// Update category file
+				if categoryFile, err := common.ReadCategoryFile(p2); err == nil {
+					updateCategoryFile(categoryFile, product, media.Values)
+					translateOptions(categoryFile.Options, r.Translators[language.Code])
+					// Sort to put Products options above Variation options
+					sort.Slice(categoryFile.Options, func(i, j int) bool {
+						if categoryFile.Options[i].Type == categoryFile.Options[j].Type {
+							return categoryFile.Options[i].Title < categoryFile.Options[j].Title
+						} else {
+							return categoryFile.Options[i].Type < categoryFile.Options[j].Type
+						}
+					})
+					if err = common.WriteCategoryFile(p2, categoryFile); err != nil {
+						logger.Warningf("%v", err)
+					}
+				}
+			}
+			productFile.Categories = append(productFile.Categories, category.Title)
+		}
+		logger.Infof("Breadcrumbs ~ %.3f ms", float64(time.Since(t).Nanoseconds())/1000000)
+		t = time.Now()
+		productFile.Product.CategoryId = category.ID
+		productFile.Product.Path = "/" + path.Join(append(names, product.Name)...) + "/"
+		//
+		for _, language := range r.Languages {
+			file := path.Join(p1, product.Name, fmt.Sprintf("index%s.html", language.Suffix))
+			if _, err := os.Stat(path.Dir(file)); err != nil {
+				if err = os.MkdirAll(path.Dir(file), 0755); err != nil {
+					return err
+				}
+			}
+			if common.Config.FlatUrl {
+				productFile.Url = "/" + path.Join(append(names[1:], product.Name)...) + "/"
+				productFile.Aliases = append(productFile.Aliases, "/" + path.Join(append(names, product.Name)...) + "/")
+			}
+			productFile.Sku = product.Sku
+			// Sort
+			if rows, err := common.Database.Table("categories_products_sort").Select("Value").Where("CategoryId = ? and ProductId = ?", category.ID, product.ID).Rows(); err == nil {
+				for rows.Next() {
+					var row struct {
+						Value int
+					}
+					if err = common.Database.ScanRows(rows, &row); err == nil {
+						productFile.Sort = row.Value
+					}
+				}
+			}
+			//
+			translated := translateProductFile(productFile, product, r.Translators[language.Code])
+			translated.JsonLd = productJsonLd(translated, canonical, canonicalBreadcrumbs, r.Translators[language.Code], language)
+			if err = common.WriteProductFile(file, translated); err != nil {
+				logger.Errorf("%v", err)
+			}
+		}
+		// Cache
+		cacheProduct := &models.CacheProduct{
+			ProductID:   product.ID,
+			Path:        fmt.Sprintf("/%s/", strings.Join(names, "/")),
+			Name:        product.Name,
+			Title:       product.Title,
+			Description: product.Description,
+			Thumbnail:   productFile.Product.Thumbnail,
+			Images:      strings.Join(media.Images, ";"),
+			Variations:  strings.Join(rendered.Variations, ";"),
+			CategoryID:  category.ID,
+			Width:       product.Width,
+			Height:      product.Height,
+			Depth:       product.Depth,
+			Volume:      product.Volume,
+			Weight:      product.Weight,
+			Sku: product.Sku,
+		}
+		if len(product.Variations) > 0 {
+			cacheProduct.BasePrice, cacheProduct.SalePrice = minPrices(product.Variations)
+		}else{
+			var minPrice = product.BasePrice
+			for _, price := range product.Prices {
+				if price.BasePrice != 0 && (minPrice == 0 || price.BasePrice < minPrice) {
+					minPrice = price.BasePrice
+				}
+			}
+			cacheProduct.BasePrice = minPrice
+			minPrice = product.SalePrice
+			for _, price := range product.Prices {
+				if price.SalePrice != 0 && (minPrice == 0 || price.SalePrice < minPrice) {
+					minPrice = price.SalePrice
+				}
+			}
+			cacheProduct.SalePrice = minPrice
+		}
+		if _, err = models.CreateCacheProduct(common.Database, cacheProduct); err != nil {
+			logger.Warningf("%v", err)
+		}
+		logger.Infof("Rest ~ %.3f ms", float64(time.Since(t).Nanoseconds())/1000000)
+	}
+	return nil
+}
+
+// updateCategoryFile extends ranges and filter options of category by product, thumbnails of values are copied by images
+// stage
+func updateCategoryFile(categoryFile *common.CategoryFile, product *models.Product, values map[string]string) {
+	variations := append([]*models.Variation{{
+		BasePrice: product.BasePrice,
+		Dimensions: product.Dimensions,
+		DimensionUnit: product.DimensionUnit,
+		Width: product.Width,
+		WeightUnit: product.WeightUnit,
+		Height: product.Height,
+		Depth: product.Depth,
+		Volume: product.Volume,
+		Weight: product.Weight,
+		Properties: product.Properties,
+	}}, product.Variations...)
+	for _, variation := range variations {
+		updateMiniMax(&categoryFile.Price, variation.BasePrice)
+		updateMiniMax(&categoryFile.Dimensions.Width, variation.Width)
+		updateMiniMax(&categoryFile.Dimensions.Height, variation.Height)
+		updateMiniMax(&categoryFile.Dimensions.Depth, variation.Depth)
+		updateMiniMax(&categoryFile.Volume, variation.Volume)
+		updateMiniMax(&categoryFile.Weight, variation.Weight)
+		// Products parameters
+		for _, parameter := range product.Parameters {
+			if parameter.ID > 0 && parameter.Filtering && parameter.Option != nil && parameter.Value != nil {
+				opt := findOption(categoryFile, parameter.Option.ID)
+				if opt == nil {
+					opt = &common.OptionCF{
+						ID:    parameter.Option.ID,
+						Type:  "Products",
+						Name:  parameter.Option.Name,
+						Title: parameter.Option.Title,
+					}
+					categoryFile.Options = append(categoryFile.Options, opt)
+				}
+				addOptionValue(opt, parameter.Value, values)
+			}
+		}
+		// Properties
+		addPropertiesOptions(categoryFile, product.Properties, values)
+		// Variation properties
+		addPropertiesOptions(categoryFile, variation.Properties, values)
+	}
+}
+
+// addPropertiesOptions adds values of filtering properties, new options get enabled rates only
+func addPropertiesOptions(categoryFile *common.CategoryFile, properties []*models.Property, values map[string]string) {
+	for _, property := range properties {
+		if !property.Filtering || property.Option == nil {
+			continue
+		}
+		opt := findOption(categoryFile, property.Option.ID)
+		found := opt != nil
+		if !found {
+			opt = &common.OptionCF{
+				ID:    property.Option.ID,
+				Type:  "Variation",
+				Name:  property.Option.Name,
+				Title: property.Option.Title,
+			}
+		}
+		for _, rate := range property.Rates {
+			// Only if the value is a part of some Option
+			if (found || rate.Enabled) && rate.Value != nil && rate.Value.OptionId > 0 {
+				addOptionValue(opt, rate.Value, values)
+			}
+		}
+		if !found {
+			categoryFile.Options = append(categoryFile.Options, opt)
+		}
+	}
+}
+
+func findOption(categoryFile *common.CategoryFile, id uint) *common.OptionCF {
+	for _, opt := range categoryFile.Options {
+		if opt.ID == id {
+			return opt
+		}
+	}
+	return nil
+}
+
+func addOptionValue(opt *common.OptionCF, value *models.Value, values map[string]string) {
+	for _, v := range opt.Values {
+		if v.ID == value.ID {
+			return
+		}
+	}
+	opt.Values = append(opt.Values, &common.ValueCF{
+		ID:        value.ID,
+		Color:     value.Color,
+		Thumbnail: values[value.Thumbnail],
+		Title:     value.Title,
+		Value:     value.Value,
+	})
+}
+
+func updateMiniMax(minimax *common.MiniMaxCF, value float64) {
+	if minimax.Max == 0 {
+		minimax.Max = value
+		if minimax.Min == 0 {
+			minimax.Min = minimax.Max
+		}
+	}
+	if minimax.Min > value {
+		minimax.Min = value
+	}
+	if minimax.Max < value {
+		minimax.Max = value
+	}
+}
+
+// minPrices returns minimal base and sale prices of variations and their prices
+func minPrices(variations []*models.Variation) (float64, float64) {
+	var basePrice, salePrice float64
+	for _, variation := range variations {
+		if variation.BasePrice != 0 && (basePrice == 0 || variation.BasePrice < basePrice) {
+			basePrice = variation.BasePrice
+		}
+		if variation.SalePrice != 0 && (salePrice == 0 || variation.SalePrice < salePrice) {
+			salePrice = variation.SalePrice
+		}
+		for _, price := range variation.Prices {
+			if price.BasePrice != 0 && (basePrice == 0 || price.BasePrice < basePrice) {
+				basePrice = price.BasePrice
+			}
+			if price.SalePrice != 0 && (salePrice == 0 || price.SalePrice < salePrice) {
+				salePrice = price.SalePrice
+			}
+		}
+	}
+	return basePrice, salePrice
+}
+
+// storageFile returns path and info of file in storage folder
+func storageFile(p string) (string, os.FileInfo, bool) {
+	p1 := path.Join(dir, "storage", p)
+	fi, err := os.Stat(p1)
+	return p1, fi, err == nil
+}
+
+func truncateName(name string) string {
+	if len(name) > 32 {
+		return name[:32]
+	}
+	return name
+}
+
+// putImage copies image to location and returns its thumbnails joined by comma
+func putImage(store storage.Storage, p1, location, sizes string, fi os.FileInfo) (string, error) {
+	t := time.Now()
+	thumbnails, err := store.PutImage(p1, location, sizes)
+	if err != nil {
+		logger.Warningf("%v", err)
+		return "", err
+	}
+	logger.Infof("Copy %v => %v %v bytes in ~ %.3f ms", p1, location, fi.Size(), float64(time.Since(t).Nanoseconds())/1000000)
+	return strings.Join(thumbnails, ","), nil
+}
+
+// copyThumbnail copies image of storage to folder with modification time in name
+func copyThumbnail(store storage.Storage, p, folder string) string {
+	if p == "" {
+		return ""
+	}
+	p1, fi, ok := storageFile(p)
+	if !ok {
+		return ""
+	}
+	filename := filepath.Base(p1)
+	filename = fmt.Sprintf("%v-%d%v", filename[:len(filename)-len(filepath.Ext(filename))], fi.ModTime().Unix(), filepath.Ext(filename))
+	thumbnail, _ := putImage(store, p1, path.Join(folder, filename), common.Config.Resize.Thumbnail.Size, fi)
+	return thumbnail
+}
@@ -172,6 +172,17 @@ func initConfig() {
 			log.Printf("[ERR] [APP] %v", err)
 		}
 	}
+	// Download links are signed by key of this instance only
+	if common.Config.Downloads.Secret == "" {
+		if secret, err := config.GenerateSecret(32); err == nil {
+			common.Config.Downloads.Secret = secret
+			if err = common.Config.Save(); err != nil {
+				logger.Errorf("%+v", err)
+			}
+		}else{
+			logger.Errorf("%+v", err)
+		}
+	}
 	v.WatchConfig()
 	var loading bool
 	v.OnConfigChange(func(e fsnotify.Event) {
@@ -236,183 +247,7 @@ var RootCmd = &cobra.Command{
 		if _, err := common.Database.DB(); err != nil {
 			logger.Fatalf("%+v", err)
 		}
-		if err := common.Database.AutoMigrate(&models.Category{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Product{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Parameter{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.File{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Image{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Variation{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Property{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Option{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Value{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Rate{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Price{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Coupon{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Discount{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Order{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Item{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Transaction{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Tag{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Tariff{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Transport{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Zone{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.EmailTemplate{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		//
-		if err := common.Database.AutoMigrate(&models.CacheCategory{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.CacheProduct{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.CacheFile{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.CacheImage{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.CacheVariation{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.CacheValue{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.CachePrice{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.CacheTag{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.CacheTransport{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.CacheVendor{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.CacheComment{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		//
-		if err := common.Database.AutoMigrate(&models.BillingProfile{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.ShippingProfile{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		//
-		if err := common.Database.AutoMigrate(&models.Vendor{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		//
-		if err := common.Database.AutoMigrate(&models.Time{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		//
-		if err := common.Database.AutoMigrate(&models.Widget{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		//
-		if err := common.Database.AutoMigrate(&models.Wish{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		//
-		if err := common.Database.AutoMigrate(&models.Menu{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		//
-		if err := common.Database.AutoMigrate(&models.Comment{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		//
-		if err := common.Database.AutoMigrate(&models.Form{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Message{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		if err := common.Database.AutoMigrate(&models.Migration{}); err != nil {
-			logger.Warningf("%+v", err)
-		}
-		//
-		if err := common.Database.Exec(`CREATE TABLE IF NOT EXISTS categories_products_sort (
-		CategoryId BIGINT UNSIGNED NOT NULL,
-		ProductId BIGINT UNSIGNED NOT NULL,
-		Value BIGINT UNSIGNED NOT NULL,
-			PRIMARY KEY (CategoryId, ProductId),
-			CONSTRAINT Constr_CategoryId_ProductId_fk
-		FOREIGN KEY (CategoryId) REFERENCES categories (ID)
-		ON DELETE CASCADE ON UPDATE CASCADE,
-			CONSTRAINT Constr_ProductId_CategoryId_fk
-		FOREIGN KEY (ProductId) REFERENCES products (ID)
-		ON DELETE CASCADE ON UPDATE CASCADE
-		)`).Error; err != nil {
-			logger.Errorf("%+v", err)
-		}
-		//
-		if err := common.Database.Exec(`CREATE TABLE IF NOT EXISTS products_relations (
-		ProductIdL BIGINT UNSIGNED NOT NULL,
-		ProductIdR BIGINT UNSIGNED NOT NULL,
-			PRIMARY KEY (ProductIdL, ProductIdR),
-			CONSTRAINT Constr_ProductIdL_ProductIdR_fk
-		FOREIGN KEY (ProductIdL) REFERENCES products (ID)
-		ON DELETE CASCADE ON UPDATE CASCADE,
-			CONSTRAINT Constr_ProductIdR_ProductIdL_fk
-		FOREIGN KEY (ProductIdR) REFERENCES products (ID)
-		ON DELETE CASCADE ON UPDATE CASCADE
-		)`).Error; err != nil {
-			logger.Errorf("%+v", err)
-		}
-		//
-		if err := common.Database.Exec(`update categories set sort = id where sort is null or sort = 0`).Error; err != nil {
-			logger.Errorf("%+v", err)
-		}
-		if err := common.Database.Exec(`update options set sort = id where sort is null or sort = 0`).Error; err != nil {
-			logger.Errorf("%+v", err)
-		}
-		if err := common.Database.Exec("update `values` set sort = id where sort is null or sort = 0").Error; err != nil {
-			logger.Errorf("%+v", err)
-		}
+		migrateDatabase()
 		// Database Migration
 		now := time.Now()
 		migrations := []*models.Migration{
@@ -615,6 +450,34 @@ var RootCmd = &cobra.Command{
 					return output, err
 				},
 			},
+			{
+				Timestamp: time.Date(2021, time.September, 1, 0, 0, 0, 0, now.Location()).Format(time.RFC3339),
+				Name: "Convert products relations",
+				Description: "Copy products_relations to typed product relations",
+				Run: func() (string, error) {
+					var output string
+					var relationsFound, relationsCreated int
+					if rows, err := common.Database.Raw("select ProductIdL, ProductIdR from products_relations").Rows(); err == nil {
+						defer rows.Close()
+						for rows.Next() {
+							var l, r uint
+							if err = rows.Scan(&l, &r); err == nil {
+								relation := &models.ProductRelation{ProductId: l, RelatedId: r, Type: models.RELATION_TYPE_RELATED, Bidirectional: true}
+								if _, err = models.CreateProductRelation(common.Database, relation); err == nil {
+									relationsCreated ++
+								} else {
+									return output, err
+								}
+							}
+							relationsFound ++
+						}
+						output = fmt.Sprintf("relations found: %d, relations created: %d", relationsFound, relationsCreated)
+					}else{
+						return output, err
+					}
+					return output, err
+				},
+			},
 		}
 		var newMigrations []*models.Migration
 		if existingMigrations, err := models.GetMigrations(common.Database); err == nil {
@@ -718,6 +581,7 @@ var RootCmd = &cobra.Command{
 				var conf handler.HugoSettingsView
 				//
 				conf.Paginate = common.DEFAULT_PAGINATE
+				conf.DisableKinds = []string{"sitemap", "robotsTXT"}
 				conf.Title = common.DEFAULT_TITLE
 				conf.Theme = common.DEFAULT_THEME
 				conf.Related = struct {
@@ -5335,6 +5199,53 @@ var RootCmd = &cobra.Command{
 <!--[if IE]></div><![endif]-->
 </body>
 
+</html>
+`,
+					}); err != nil {
+						logger.Warningf("%v", err)
+					}
+				}
+				if _, err := models.GetEmailTemplateByType(common.Database, common.NOTIFICATION_TYPE_USER_ORDER_READY_FOR_PICKUP); err != nil {
+					if _, err = models.CreateEmailTemplate(common.Database, &models.EmailTemplate{
+						Enabled: true,
+						Type:    common.NOTIFICATION_TYPE_USER_ORDER_READY_FOR_PICKUP,
+						Topic:   "Your order #{{.Order.ID}} is ready for pickup",
+						Message: `<!DOCTYPE html>
+<html>
+<body style="font-family:'Open Sans',sans-serif;">
+<p>Your order #{{.Order.ID}} is ready for pickup.</p>
+{{if .Pickup}}
+<p>
+  <b>{{.Pickup.Title}}</b><br/>
+  {{.Pickup.Address}}, {{.Pickup.Zip}} {{.Pickup.City}}<br/>
+  {{if .Pickup.Phone}}{{.Pickup.Phone}}<br/>{{end}}
+  {{if .Pickup.Hours}}{{.Pickup.Hours}}{{end}}
+</p>
+{{end}}
+<p>Total: {{.Symbol}}{{printf "%.2f" .Order.Total}}</p>
+<p>&copy; Shop. All Rights Reserved</p>
+</body>
+</html>
+`,
+					}); err != nil {
+						logger.Warningf("%v", err)
+					}
+				}
+				if _, err := models.GetEmailTemplateByType(common.Database, common.NOTIFICATION_TYPE_ADMIN_PIPELINE_FAILED); err != nil {
+					if _, err = models.CreateEmailTemplate(common.Database, &models.EmailTemplate{
+						Enabled: true,
+						Type:    common.NOTIFICATION_TYPE_ADMIN_PIPELINE_FAILED,
+						Topic:   "Scheduled publish of {{.Url}} failed",
+						Message: `<!DOCTYPE html>
+<html>
+<body style="font-family:'Open Sans',sans-serif;">
+<p>Scheduled prepare, render and publish of {{.Url}} failed: {{.Error}}</p>
+<p>Changes are kept and the next scheduled run will try again, you will not be notified until it succeeds.</p>
+{{if .Job}}
+<pre style="font-size:12px;">{{.Job.Log}}</pre>
+{{end}}
+<p>&copy; Shop. All Rights Reserved</p>
+</body>
 </html>
 `,
 					}); err != nil {
@@ -5355,6 +5266,23 @@ var RootCmd = &cobra.Command{
 				}
 			}
 		}
+		// Purchasable downloads are served from private storage only
+		if common.DOWNLOADS, err = storage.NewLocalStorage(path.Join(dir, "storage"), false, 0); err != nil {
+			logger.Warningf("%v", err)
+		}
+		// Jobs and bulk edits of previous run were killed with it
+		if err := models.FailRunningJobs(common.Database); err != nil {
+			logger.Warningf("%+v", err)
+		}
+		if err := models.FailRunningChangeSets(common.Database); err != nil {
+			logger.Warningf("%+v", err)
+		}
+		// Publish and sale windows
+		handler.StartScheduler()
+		// Scheduled prepare, render and publish
+		handler.StartPipeline()
+		// Scheduled backups
+		handler.StartBackups()
 		//
 		app := handler.GetFiber()
 		// Https
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/google/logger"
+	"github.com/spf13/cobra"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"os"
+)
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Rebuild search index",
+	Long:  `Rebuild full text search index of all enabled products`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := openDatabase(); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		if err := models.InitSearch(common.Database); err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		count, err := models.ReindexProducts(common.Database)
+		if err != nil {
+			logger.Errorf("%v", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%d products indexed (%v)\n", count, models.SearchEngine)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(reindexCmd)
+}
@@ -0,0 +1,428 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/config"
+	"github.com/yonnic/goshop/handler"
+	"github.com/yonnic/goshop/models"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	SITEMAP_LIMIT = 50000
+	SITEMAP_NS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	SITEMAP_IMAGE_NS = "http://www.google.com/schemas/sitemap-image/1.1"
+	JSONLD_REVIEWS = 5 // reviews in product structured data
+)
+
+var schemaAvailability = map[string]string{
+	"in_stock": "https://schema.org/InStock",
+	"out_of_stock": "https://schema.org/OutOfStock",
+	"preorder": "https://schema.org/PreOrder",
+	"backorder": "https://schema.org/BackOrder",
+}
+
+// siteUrl returns absolute url of page in language, not default languages are served by hugo from /<code>/
+func siteUrl(p string, language config.Language) string {
+	if strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://") {
+		return p
+	}
+	if language.Code != "" {
+		p = "/" + language.Code + p
+	}
+	return strings.TrimRight(common.Config.Url, "/") + p
+}
+
+type jsonLdProduct struct {
+	Context string `json:"@context"`
+	Type string `json:"@type"`
+	Name string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Url string `json:"url"`
+	Sku string `json:"sku,omitempty"`
+	Image []string `json:"image,omitempty"`
+	Brand *jsonLdThing `json:"brand,omitempty"`
+	Offers []jsonLdOffer `json:"offers,omitempty"`
+	AggregateRating *jsonLdAggregateRating `json:"aggregateRating,omitempty"`
+	Review []jsonLdReview `json:"review,omitempty"`
+}
+
+type jsonLdThing struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+type jsonLdOffer struct {
+	Type string `json:"@type"`
+	Url string `json:"url"`
+	Sku string `json:"sku,omitempty"`
+	Price string `json:"price"`
+	PriceCurrency string `json:"priceCurrency"`
+	Availability string `json:"availability"`
+}
+
+type jsonLdAggregateRating struct {
+	Type string `json:"@type"`
+	RatingValue float64 `json:"ratingValue"`
+	ReviewCount int `json:"reviewCount"`
+}
+
+type jsonLdReview struct {
+	Type string `json:"@type"`
+	Name string `json:"name,omitempty"`
+	ReviewBody string `json:"reviewBody,omitempty"`
+	Author *jsonLdThing `json:"author,omitempty"`
+	ReviewRating *jsonLdRating `json:"reviewRating,omitempty"`
+}
+
+type jsonLdRating struct {
+	Type string `json:"@type"`
+	RatingValue int `json:"ratingValue"`
+}
+
+type jsonLdBreadcrumbList struct {
+	Context string `json:"@context"`
+	Type string `json:"@type"`
+	ItemListElement []jsonLdListItem `json:"itemListElement"`
+}
+
+type jsonLdListItem struct {
+	Type string `json:"@type"`
+	Position int `json:"position"`
+	Name string `json:"name"`
+	Item string `json:"item"`
+}
+
+// productJsonLd builds schema.org Product with offers, rating and reviews and BreadcrumbList of canonical category
+func productJsonLd(productFile *common.ProductFile, canonical string, breadcrumbs []*models.Category, translator *models.Translator, language config.Language) []interface{} {
+	url := siteUrl(publicUrl(canonical), language)
+	product := jsonLdProduct{
+		Context: "https://schema.org",
+		Type: "Product",
+		Name: productFile.Product.Title,
+		Description: strings.TrimSpace(reSpace.ReplaceAllString(reTags.ReplaceAllString(productFile.Product.Description, " "), " ")),
+		Url: url,
+		Sku: productFile.Sku,
+	}
+	var images []string
+	if productFile.Thumbnail != "" {
+		images = append(images, productFile.Thumbnail)
+	}
+	images = append(images, productFile.Product.Images...)
+	for _, image := range images {
+		if link := strings.Fields(strings.Split(image, ",")[0]); len(link) > 0 {
+			if link := siteUrl(link[0], config.Language{}); !hasString(product.Image, link) {
+				product.Image = append(product.Image, link)
+			}
+		}
+	}
+	if productFile.Product.Vendor.Title != "" {
+		product.Brand = &jsonLdThing{Type: "Brand", Name: productFile.Product.Vendor.Title}
+	}
+	currency := strings.ToUpper(common.Config.Currency)
+	if currency == "" {
+		currency = "USD"
+	}
+	offer := func(basePrice, salePrice float64, availability, sku string) {
+		price := basePrice
+		if salePrice > 0 {
+			price = salePrice
+		}
+		if price <= 0 {
+			return
+		}
+		if sku == "" {
+			sku = productFile.Sku
+		}
+		product.Offers = append(product.Offers, jsonLdOffer{
+			Type: "Offer",
+			Url: url,
+			Sku: sku,
+			Price: fmt.Sprintf("%.2f", price),
+			PriceCurrency: currency,
+			Availability: schemaAvailability[handler.FeedAvailability(availability)],
+		})
+	}
+	for _, variation := range productFile.Product.Variations {
+		if len(variation.Prices) > 0 {
+			for _, price := range variation.Prices {
+				availability := price.Availability
+				if availability == "" {
+					availability = variation.Availability
+				}
+				offer(price.BasePrice, price.SalePrice, availability, price.Sku)
+			}
+		}else{
+			offer(variation.BasePrice, variation.SalePrice, variation.Availability, variation.Sku)
+		}
+	}
+	if productFile.Votes > 0 && productFile.Max > 0 {
+		product.AggregateRating = &jsonLdAggregateRating{
+			Type: "AggregateRating",
+			RatingValue: productFile.Max,
+			ReviewCount: productFile.Votes,
+		}
+		for i, comment := range productFile.Comments {
+			if i >= JSONLD_REVIEWS {
+				break
+			}
+			review := jsonLdReview{Type: "Review", Name: comment.Title, ReviewBody: comment.Body}
+			if comment.Author != "" {
+				review.Author = &jsonLdThing{Type: "Person", Name: comment.Author}
+			}
+			if comment.Max > 0 {
+				review.ReviewRating = &jsonLdRating{Type: "Rating", RatingValue: comment.Max}
+			}
+			product.Review = append(product.Review, review)
+		}
+	}
+	list := jsonLdBreadcrumbList{Context: "https://schema.org", Type: "BreadcrumbList"}
+	var names []string
+	for i, crumb := range breadcrumbs {
+		names = append(names, crumb.Name)
+		list.ItemListElement = append(list.ItemListElement, jsonLdListItem{
+			Type: "ListItem",
+			Position: i + 1,
+			Name: translator.Get(models.TRANSLATION_OBJECT_CATEGORY, crumb.ID, "Title", crumb.Title),
+			Item: siteUrl(publicUrl("/" + path.Join(names...) + "/"), language),
+		})
+	}
+	list.ItemListElement = append(list.ItemListElement, jsonLdListItem{
+		Type: "ListItem",
+		Position: len(list.ItemListElement) + 1,
+		Name: productFile.Product.Title,
+		Item: url,
+	})
+	return []interface{}{product, list}
+}
+
+type sitemapUrlSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	NS string `xml:"xmlns,attr"`
+	ImageNS string `xml:"xmlns:image,attr"`
+	Urls []sitemapUrl `xml:"url"`
+}
+
+type sitemapUrl struct {
+	Loc string `xml:"loc"`
+	Lastmod string `xml:"lastmod,omitempty"`
+	Images []sitemapImage `xml:"image:image,omitempty"`
+}
+
+type sitemapImage struct {
+	Loc string `xml:"image:loc"`
+	Title string `xml:"image:title,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName xml.Name `xml:"sitemapindex"`
+	NS string `xml:"xmlns,attr"`
+	Sitemaps []sitemapUrl `xml:"sitemap"`
+}
+
+// getSitemapUrls collects home, categories and canonical urls of products from render cache in every language
+func getSitemapUrls(languages []config.Language) ([]sitemapUrl, error) {
+	var rows []struct {
+		ID uint
+		UpdatedAt time.Time
+	}
+	modified := make(map[string]time.Time)
+	if err := common.Database.Model(&models.Product{}).Select("id, updated_at").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		modified[fmt.Sprintf("product%d", row.ID)] = row.UpdatedAt
+	}
+	rows = rows[:0]
+	if err := common.Database.Model(&models.Category{}).Select("id, updated_at").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		modified[fmt.Sprintf("category%d", row.ID)] = row.UpdatedAt
+	}
+	// Images
+	var images []struct {
+		ProductId uint
+		Name string
+		Thumbnail string
+	}
+	if err := common.Database.Table("products_images").Select("products_images.product_id, cache_images.name, cache_images.thumbnail").
+		Joins("join cache_images on cache_images.image_id = products_images.image_id").Order("products_images.image_id asc").Scan(&images).Error; err != nil {
+		return nil, err
+	}
+	productImages := make(map[uint][]sitemapImage)
+	for _, image := range images {
+		if link := strings.Fields(strings.Split(image.Thumbnail, ",")[0]); len(link) > 0 {
+			productImages[image.ProductId] = append(productImages[image.ProductId], sitemapImage{Loc: siteUrl(link[0], config.Language{}), Title: image.Name})
+		}
+	}
+	lastmod := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02")
+	}
+	var categories []*models.CacheCategory
+	if err := common.Database.Order("id asc").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	var products []*models.CacheProduct
+	if err := common.Database.Order("id asc").Find(&products).Error; err != nil {
+		return nil, err
+	}
+	var urls []sitemapUrl
+	for _, language := range languages {
+		urls = append(urls, sitemapUrl{Loc: siteUrl("/", language)})
+		urls = append(urls, sitemapUrl{Loc: siteUrl(publicUrl("/" + strings.ToLower(common.Config.Products) + "/"), language)})
+		for _, category := range categories {
+			urls = append(urls, sitemapUrl{
+				Loc: siteUrl(publicUrl(category.Link + "/"), language),
+				Lastmod: lastmod(modified[fmt.Sprintf("category%d", category.CategoryID)]),
+			})
+		}
+		// The first cached product is in canonical category
+		rendered := make(map[uint]bool)
+		for _, product := range products {
+			if rendered[product.ProductID] {
+				continue
+			}
+			rendered[product.ProductID] = true
+			urls = append(urls, sitemapUrl{
+				Loc: siteUrl(publicUrl(product.Path + product.Name + "/"), language),
+				Lastmod: lastmod(modified[fmt.Sprintf("product%d", product.ProductID)]),
+				Images: productImages[product.ProductID],
+			})
+		}
+	}
+	return urls, nil
+}
+
+// writeSitemap writes sitemap.xml, above the limit it is index of sitemap-N.xml files
+func writeSitemap(static string, languages []config.Language) error {
+	urls, err := getSitemapUrls(languages)
+	if err != nil {
+		return err
+	}
+	limit := common.Config.Seo.SitemapLimit
+	if limit <= 0 || limit > SITEMAP_LIMIT {
+		limit = SITEMAP_LIMIT
+	}
+	// Parts of previous render
+	if matches, err := filepath.Glob(path.Join(static, "sitemap-*.xml")); err == nil {
+		for _, match := range matches {
+			if err = os.Remove(match); err != nil {
+				logger.Warningf("%+v", err)
+			}
+		}
+	}
+	write := func(name string, v interface{}) error {
+		bts, err := xml.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path.Join(static, name), append([]byte(xml.Header), bts...), 0644)
+	}
+	if len(urls) <= limit {
+		if err = write("sitemap.xml", &sitemapUrlSet{NS: SITEMAP_NS, ImageNS: SITEMAP_IMAGE_NS, Urls: urls}); err != nil {
+			return err
+		}
+	}else{
+		index := &sitemapIndex{NS: SITEMAP_NS}
+		now := time.Now().Format("2006-01-02")
+		for i := 0; i * limit < len(urls); i++ {
+			name := fmt.Sprintf("sitemap-%d.xml", i + 1)
+			end := (i + 1) * limit
+			if end > len(urls) {
+				end = len(urls)
+			}
+			if err = write(name, &sitemapUrlSet{NS: SITEMAP_NS, ImageNS: SITEMAP_IMAGE_NS, Urls: urls[i * limit:end]}); err != nil {
+				return err
+			}
+			index.Sitemaps = append(index.Sitemaps, sitemapUrl{Loc: siteUrl("/" + name, config.Language{}), Lastmod: now})
+		}
+		if err = write("sitemap.xml", index); err != nil {
+			return err
+		}
+	}
+	logger.Infof("Sitemap: %v urls", len(urls))
+	return nil
+}
+
+// writeRobots writes robots.txt with configured rules and link to sitemap
+func writeRobots(static string) error {
+	buff := &bytes.Buffer{}
+	if robots := strings.TrimSpace(common.Config.Seo.Robots); robots != "" {
+		buff.WriteString(robots + "\n")
+	}else{
+		buff.WriteString("User-agent: *\nAllow: /\n")
+	}
+	if common.Config.Url != "" {
+		fmt.Fprintf(buff, "\nSitemap: %s\n", siteUrl("/sitemap.xml", config.Language{}))
+	}
+	return ioutil.WriteFile(path.Join(static, "robots.txt"), buff.Bytes(), 0644)
+}
+
+// disableHugoKinds stops hugo from making its own sitemap and robots.txt over ones made by render, other settings are kept as is
+func disableHugoKinds(site string) error {
+	p := path.Join(site, "config.toml")
+	conf := make(map[string]interface{})
+	if _, err := toml.DecodeFile(p, &conf); err != nil {
+		return err
+	}
+	var kinds []string
+	if values, ok := conf["disableKinds"].([]interface{}); ok {
+		for _, v := range values {
+			kinds = append(kinds, fmt.Sprint(v))
+		}
+	}
+	var changed bool
+	for _, kind := range []string{"sitemap", "robotsTXT"} {
+		if !hasString(kinds, kind) {
+			kinds = append(kinds, kind)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	conf["disableKinds"] = kinds
+	buff := &bytes.Buffer{}
+	if err := toml.NewEncoder(buff).Encode(conf); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, buff.Bytes(), 0644)
+}
+
+// updateSeo writes sitemap and robots.txt to static folder of site
+func updateSeo(site string, languages []config.Language) {
+	static := path.Join(site, "static")
+	if _, err := os.Stat(static); err != nil {
+		if err = os.MkdirAll(static, 0755); err != nil {
+			logger.Warningf("%+v", err)
+			return
+		}
+	}
+	if err := writeRobots(static); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if common.Config.Url == "" {
+		logger.Warningf("Url is not set, sitemap needs absolute urls")
+		return
+	}
+	if err := writeSitemap(static, languages); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := disableHugoKinds(site); err != nil {
+		logger.Warningf("%+v", err)
+	}
+}
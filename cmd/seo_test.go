@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"github.com/BurntSushi/toml"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestDisableHugoKinds(t *testing.T) {
+	site := t.TempDir()
+	p := path.Join(site, "config.toml")
+	if err := ioutil.WriteFile(p, []byte(`baseURL = "https://example.com/"
+theme = "shop"
+disableKinds = ["taxonomy"]
+customKey = "kept"
+
+[params]
+  logo = "/logo.png"
+
+[markup.goldmark.renderer]
+  unsafe = true
+`), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := disableHugoKinds(site); err != nil {
+		t.Fatalf("%v", err)
+	}
+	var conf map[string]interface{}
+	if _, err := toml.DecodeFile(p, &conf); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if kinds := conf["disableKinds"]; !reflect.DeepEqual(kinds, []interface{}{"taxonomy", "sitemap", "robotsTXT"}) {
+		t.Errorf("disableKinds %v", kinds)
+	}
+	if conf["customKey"] != "kept" || conf["baseURL"] != "https://example.com/" || conf["theme"] != "shop" {
+		t.Errorf("top level keys are lost: %+v", conf)
+	}
+	if params, ok := conf["params"].(map[string]interface{}); !ok || params["logo"] != "/logo.png" {
+		t.Errorf("params are lost: %+v", conf["params"])
+	}
+	if markup, ok := conf["markup"].(map[string]interface{}); !ok || !reflect.DeepEqual(markup["goldmark"], map[string]interface{}{"renderer": map[string]interface{}{"unsafe": true}}) {
+		t.Errorf("nested tables are lost: %+v", conf["markup"])
+	}
+	// Second run keeps file untouched
+	before, _ := ioutil.ReadFile(p)
+	if err := disableHugoKinds(site); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if after, _ := ioutil.ReadFile(p); string(after) != string(before) {
+		t.Errorf("config is rewritten without changes")
+	}
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+)
+
+// translateProductFile returns copy of product file with texts of translator language, shared slices are copied before change
+func translateProductFile(productFile *common.ProductFile, product *models.Product, translator *models.Translator) *common.ProductFile {
+	if translator == nil {
+		return productFile
+	}
+	file := *productFile
+	file.Title = translator.Get(models.TRANSLATION_OBJECT_PRODUCT, product.ID, "Title", productFile.Title)
+	if content := translator.Get(models.TRANSLATION_OBJECT_PRODUCT, product.ID, "Content", ""); content != "" {
+		file.Description = content
+		file.Content = content
+	}
+	file.Product.Title = translator.Get(models.TRANSLATION_OBJECT_PRODUCT, product.ID, "Title", productFile.Product.Title)
+	if description := translator.Get(models.TRANSLATION_OBJECT_PRODUCT, product.ID, "Description", ""); description != "" {
+		description = reSpace.ReplaceAllString(reTags.ReplaceAllString(description, ""), " ")
+		if len(description) > 160 {
+			description = description[:160]
+		}
+		file.Product.Description = description
+	}
+	// Options of parameters and properties
+	options := make(map[uint]uint)
+	for _, parameter := range product.Parameters {
+		options[parameter.ID] = parameter.OptionId
+	}
+	properties := make(map[uint]uint)
+	for _, property := range product.Properties {
+		properties[property.ID] = property.OptionId
+	}
+	for _, variation := range product.Variations {
+		for _, property := range variation.Properties {
+			properties[property.ID] = property.OptionId
+		}
+	}
+	if len(productFile.Product.Parameters) > 0 {
+		file.Product.Parameters = make([]common.ParameterPF, len(productFile.Product.Parameters))
+		for i, parameter := range productFile.Product.Parameters {
+			parameter.Title = translator.Get(models.TRANSLATION_OBJECT_OPTION, options[parameter.Id], "Title", parameter.Title)
+			if parameter.Value != nil {
+				value := *parameter.Value
+				value.Title = translator.Get(models.TRANSLATION_OBJECT_VALUE, value.Id, "Title", value.Title)
+				parameter.Value = &value
+			}
+			file.Product.Parameters[i] = parameter
+		}
+	}
+	file.Product.Properties = translateProperties(productFile.Product.Properties, properties, translator)
+	if len(productFile.Product.Variations) > 0 {
+		file.Product.Variations = make([]common.VariationPF, len(productFile.Product.Variations))
+		for i, variation := range productFile.Product.Variations {
+			variation.Title = translator.Get(models.TRANSLATION_OBJECT_VARIATION, variation.Id, "Title", variation.Title)
+			variation.Description = translator.Get(models.TRANSLATION_OBJECT_VARIATION, variation.Id, "Description", variation.Description)
+			variation.Properties = translateProperties(variation.Properties, properties, translator)
+			file.Product.Variations[i] = variation
+		}
+	}
+	if file.Product.Vendor.Id > 0 {
+		file.Product.Vendor.Title = translator.Get(models.TRANSLATION_OBJECT_VENDOR, file.Product.Vendor.Id, "Title", file.Product.Vendor.Title)
+		file.Product.Vendor.Description = translator.Get(models.TRANSLATION_OBJECT_VENDOR, file.Product.Vendor.Id, "Description", file.Product.Vendor.Description)
+	}
+	if len(productFile.Product.Relations) > 0 {
+		file.Product.Relations = make([]common.RelationPF, len(productFile.Product.Relations))
+		for i, relation := range productFile.Product.Relations {
+			relation.Title = translator.Get(models.TRANSLATION_OBJECT_PRODUCT, relation.Id, "Title", relation.Title)
+			file.Product.Relations[i] = relation
+		}
+	}
+	if len(productFile.Tags) > 0 {
+		file.Tags = make([]common.TagPF, len(productFile.Tags))
+		for i, tag := range productFile.Tags {
+			tag.Title = translator.Get(models.TRANSLATION_OBJECT_TAG, tag.Id, "Title", tag.Title)
+			file.Tags[i] = tag
+		}
+	}
+	return &file
+}
+
+// translateProperties copies properties, property title is title of its option
+func translateProperties(properties []common.PropertyPF, options map[uint]uint, translator *models.Translator) []common.PropertyPF {
+	if properties == nil {
+		return nil
+	}
+	result := make([]common.PropertyPF, len(properties))
+	for i, property := range properties {
+		property.Title = translator.Get(models.TRANSLATION_OBJECT_OPTION, options[property.Id], "Title", property.Title)
+		values := make([]common.ValuePF, len(property.Values))
+		for j, value := range property.Values {
+			value.Title = translator.Get(models.TRANSLATION_OBJECT_VALUE, value.Id, "Title", value.Title)
+			value.Description = translator.Get(models.TRANSLATION_OBJECT_VALUE, value.Id, "Description", value.Description)
+			values[j] = value
+		}
+		property.Values = values
+		result[i] = property
+	}
+	return result
+}
+
+// translateOptions updates filter options of category file in place, it is read and written per language
+func translateOptions(options []*common.OptionCF, translator *models.Translator) {
+	if translator == nil {
+		return
+	}
+	for _, option := range options {
+		option.Title = translator.Get(models.TRANSLATION_OBJECT_OPTION, option.ID, "Title", option.Title)
+		for _, value := range option.Values {
+			value.Title = translator.Get(models.TRANSLATION_OBJECT_VALUE, value.ID, "Title", value.Title)
+		}
+	}
+}
@@ -39,6 +39,7 @@ var (
 	VERSION = "1.0.0"
 	COMPILED = "20210827191344"
 	STORAGE storage.Storage
+	DOWNLOADS storage.Storage
 	//
 	Started          time.Time
 	Config           *config.Config
@@ -235,6 +236,7 @@ type ProductFile struct {
 	Comments []CommentPF `json:",omitempty"`
 	Sort int
 	Vars map[string]interface{} `json:",omitempty"`
+	JsonLd []interface{} `json:",omitempty"` // schema.org structured data
 	//
 	Content string
 }
@@ -269,10 +271,47 @@ type ProductPF struct {
 	Availability string `json:",omitempty"`
 	Vendor VendorPF `json:",omitempty"`
 	Time string `json:",omitempty"`
+	Estimate *EstimatePF `json:",omitempty"`
+	Bundle *BundlePF `json:",omitempty"`
+	Relations []RelationPF `json:",omitempty"`
 	Properties []PropertyPF
 	Variations []VariationPF
 }
 
+type RelationPF struct {
+	Type string
+	Id uint
+	Name string
+	Title string
+	Path string `json:",omitempty"`
+	Thumbnail string `json:",omitempty"`
+	BasePrice float64 `json:",omitempty"`
+	SalePrice float64 `json:",omitempty"`
+}
+
+type BundlePF struct {
+	Pricing string
+	Discount float64 `json:",omitempty"`
+	BasePrice float64
+	SalePrice float64 `json:",omitempty"`
+	Stock uint
+	Availability string `json:",omitempty"`
+	Components []BundleComponentPF
+}
+
+type BundleComponentPF struct {
+	Id uint
+	ProductId uint
+	VariationId uint `json:",omitempty"`
+	Title string
+	Thumbnail string `json:",omitempty"`
+	Path string `json:",omitempty"`
+	Quantity int
+	Optional bool `json:",omitempty"`
+	BasePrice float64
+	Price float64
+}
+
 type CustomParameterPF struct {
 	Key string
 	Value string
@@ -331,6 +370,7 @@ type VariationPF struct {
 	Availability string `json:",omitempty"`
 	//Sending string `json:",omitempty"`
 	Time string `json:",omitempty"`
+	Estimate *EstimatePF `json:",omitempty"`
 	Properties []PropertyPF `json:",omitempty"`
 	Sku string `json:",omitempty"`
 	Selected bool
@@ -422,6 +462,7 @@ func (p *ProductFile) MarshalJSON() ([]byte, error) {
 		Votes int `json:",omitempty"`
 		Comments []CommentPF `json:",omitempty"`
 		Vars map[string]interface{} `json:",omitempty"`
+		JsonLd []interface{} `json:",omitempty"`
 		Sort int
 	}{
 		ID: p.ID,
@@ -450,6 +491,7 @@ func (p *ProductFile) MarshalJSON() ([]byte, error) {
 		Votes: p.Votes,
 		Comments: p.Comments,
 		Vars: p.Vars,
+		JsonLd: p.JsonLd,
 		Sort: p.Sort,
 	}); err == nil {
 		bts = append(bts, "\n\n"...)
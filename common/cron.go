@@ -0,0 +1,97 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var cronAliases = map[string]string{
+	"@yearly": "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly": "0 0 * * 0",
+	"@daily": "0 0 * * *",
+	"@hourly": "0 * * * *",
+}
+
+// Cron is standard five fields expression: minute, hour, day of month, month and day of week (0 is sunday),
+// fields support *, lists, ranges and steps like "*/15 9-18 * * 1-5"
+type Cron struct {
+	fields [5]uint64
+	anyDay bool
+	anyWeekday bool
+}
+
+func ParseCron(expression string) (*Cron, error) {
+	if v, found := cronAliases[strings.TrimSpace(expression)]; found {
+		expression = v
+	}
+	parts := strings.Fields(expression)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("cron '%v': 5 fields expected", expression)
+	}
+	cron := &Cron{anyDay: parts[2] == "*", anyWeekday: parts[4] == "*"}
+	for i, bounds := range [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}} {
+		bits, err := parseCronField(parts[i], bounds[0], bounds[1])
+		if err != nil {
+			return nil, fmt.Errorf("cron '%v': %v", expression, err)
+		}
+		cron.fields[i] = bits
+	}
+	// 7 is sunday too
+	if cron.fields[4] & (1 << 7) != 0 {
+		cron.fields[4] |= 1
+	}
+	return cron, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		if arr := strings.SplitN(item, "/", 2); len(arr) == 2 {
+			var err error
+			if step, err = strconv.Atoi(arr[1]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step '%v'", item)
+			}
+			item = arr[0]
+		}
+		from, to := min, max
+		if item != "*" {
+			arr := strings.SplitN(item, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(arr[0]); err != nil {
+				return 0, fmt.Errorf("invalid value '%v'", item)
+			}
+			to = from
+			if len(arr) == 2 {
+				if to, err = strconv.Atoi(arr[1]); err != nil {
+					return 0, fmt.Errorf("invalid value '%v'", item)
+				}
+			}else if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, fmt.Errorf("value '%v' is out of range %v-%v", item, min, max)
+		}
+		for i := from; i <= to; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+// Match checks minute of time, day matches either day of month or day of week when both are restricted like in cron
+func (cron *Cron) Match(t time.Time) bool {
+	if cron.fields[0] & (1 << uint(t.Minute())) == 0 || cron.fields[1] & (1 << uint(t.Hour())) == 0 || cron.fields[3] & (1 << uint(t.Month())) == 0 {
+		return false
+	}
+	day := cron.fields[2] & (1 << uint(t.Day())) != 0
+	weekday := cron.fields[4] & (1 << uint(t.Weekday())) != 0
+	if cron.anyDay || cron.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCron_Match(t *testing.T) {
+	// Monday
+	monday := time.Date(2021, time.August, 9, 9, 30, 0, 0, time.UTC)
+	for _, example := range []struct{
+		Expression string
+		Time time.Time
+		Match bool
+	}{
+		{Expression: "* * * * *", Time: monday, Match: true},
+		{Expression: "30 9 * * *", Time: monday, Match: true},
+		{Expression: "31 9 * * *", Time: monday, Match: false},
+		{Expression: "*/15 9-18 * * 1-5", Time: monday, Match: true},
+		{Expression: "*/15 9-18 * * 1-5", Time: monday.Add(5 * time.Minute), Match: false},
+		{Expression: "*/15 9-18 * * 1-5", Time: monday.AddDate(0, 0, -1), Match: false},
+		{Expression: "0,30 8,9 * * *", Time: monday, Match: true},
+		{Expression: "10/20 * * * *", Time: monday, Match: true},
+		{Expression: "30 9 * 8 *", Time: monday, Match: true},
+		{Expression: "30 9 * 9 *", Time: monday, Match: false},
+		// Sunday is 0 and 7
+		{Expression: "30 9 * * 0", Time: monday.AddDate(0, 0, -1), Match: true},
+		{Expression: "30 9 * * 7", Time: monday.AddDate(0, 0, -1), Match: true},
+		// Restricted day of month and day of week match either of them
+		{Expression: "30 9 1 * 1", Time: monday, Match: true},
+		{Expression: "30 9 9 * 0", Time: monday, Match: true},
+		{Expression: "30 9 1 * 0", Time: monday, Match: false},
+		{Expression: "30 9 1 * *", Time: monday, Match: false},
+		{Expression: "@daily", Time: time.Date(2021, time.August, 9, 0, 0, 0, 0, time.UTC), Match: true},
+		{Expression: "@daily", Time: monday, Match: false},
+		{Expression: "@weekly", Time: time.Date(2021, time.August, 8, 0, 0, 0, 0, time.UTC), Match: true},
+	}{
+		cron, err := ParseCron(example.Expression)
+		if err != nil {
+			t.Errorf("%v: %v", example.Expression, err)
+			continue
+		}
+		if match := cron.Match(example.Time); match != example.Match {
+			t.Errorf("%v at %v: match %v, expected %v", example.Expression, example.Time, match, example.Match)
+		}
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-b * * * *",
+		"@never",
+	}{
+		if _, err := ParseCron(expression); err == nil {
+			t.Errorf("'%v': error expected", expression)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package common
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	weekdays = map[string]time.Weekday{
+		"sun": time.Sunday,
+		"mon": time.Monday,
+		"tue": time.Tuesday,
+		"wed": time.Wednesday,
+		"thu": time.Thursday,
+		"fri": time.Friday,
+		"sat": time.Saturday,
+	}
+)
+
+// EstimatePF is lead time of product for static pages, dates change every day so they are computed from it by client
+type EstimatePF struct {
+	Lead int // business days to prepare shipping
+	Cutoff string `json:",omitempty"` // orders after this time of day are processed the next business day
+	Days string `json:",omitempty"` // business days of week
+	Holidays []string `json:",omitempty"` // configured and vendor's holidays, 2006-01-02 or 01-02
+	TransitMin int `json:",omitempty"`
+	TransitMax int `json:",omitempty"`
+}
+
+// NewEstimatePF combines lead time with configured calendar, cutoff and holidays of vendor
+func NewEstimatePF(lead int, cutoff, holidays string, transitMin, transitMax int) *EstimatePF {
+	estimate := &EstimatePF{Lead: lead, Cutoff: Config.Delivery.Cutoff, Days: Config.Delivery.Days, TransitMin: transitMin, TransitMax: transitMax}
+	if cutoff != "" {
+		estimate.Cutoff = cutoff
+	}
+	for _, holiday := range append(append([]string{}, Config.Delivery.Holidays...), strings.Split(holidays, ",")...) {
+		if holiday = strings.TrimSpace(holiday); holiday != "" {
+			estimate.Holidays = append(estimate.Holidays, holiday)
+		}
+	}
+	return estimate
+}
+
+func (estimate *EstimatePF) Calendar() *Calendar {
+	return NewCalendar(estimate.Days, strings.Join(estimate.Holidays, ","))
+}
+
+// Dates returns ship date and delivery window for order made at now
+func (estimate *EstimatePF) Dates(now time.Time) (time.Time, time.Time, time.Time) {
+	return Estimate(now, estimate.Cutoff, estimate.Lead, estimate.TransitMin, estimate.TransitMax, estimate.Calendar())
+}
+
+// Calendar describes business days, holidays could be set as 2006-01-02 or 01-02 to repeat every year
+type Calendar struct {
+	Days map[time.Weekday]bool
+	Holidays map[string]bool
+}
+
+// NewCalendar creates calendar from comma separated days like "mon,tue,wed,thu,fri" or "1,2,3,4,5"
+func NewCalendar(days string, holidays ...string) *Calendar {
+	calendar := &Calendar{Days: make(map[time.Weekday]bool), Holidays: make(map[string]bool)}
+	for _, day := range strings.Split(days, ",") {
+		day = strings.ToLower(strings.TrimSpace(day))
+		if day == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(day); err == nil {
+			calendar.Days[time.Weekday(n % 7)] = true
+		} else if len(day) >= 3 {
+			if weekday, found := weekdays[day[0:3]]; found {
+				calendar.Days[weekday] = true
+			}
+		}
+	}
+	if len(calendar.Days) == 0 {
+		for _, weekday := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday} {
+			calendar.Days[weekday] = true
+		}
+	}
+	for _, holiday := range holidays {
+		for _, date := range strings.Split(holiday, ",") {
+			if date = strings.TrimSpace(date); date != "" {
+				calendar.Holidays[date] = true
+			}
+		}
+	}
+	return calendar
+}
+
+// GetCalendar returns calendar from configuration extended by additional holidays (vendor for example)
+func GetCalendar(holidays ...string) *Calendar {
+	return NewCalendar(Config.Delivery.Days, append([]string{strings.Join(Config.Delivery.Holidays, ",")}, holidays...)...)
+}
+
+func (c *Calendar) IsBusinessDay(t time.Time) bool {
+	if !c.Days[t.Weekday()] {
+		return false
+	}
+	return !c.Holidays[t.Format("2006-01-02")] && !c.Holidays[t.Format("01-02")]
+}
+
+// NextBusinessDay returns the same date if it is business day or next one
+func (c *Calendar) NextBusinessDay(t time.Time) time.Time {
+	t = date(t)
+	for i := 0; i < 366 && !c.IsBusinessDay(t); i++ {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+func (c *Calendar) AddBusinessDays(t time.Time, n int) time.Time {
+	t = c.NextBusinessDay(t)
+	for i := 0; i < n; i++ {
+		t = c.NextBusinessDay(t.AddDate(0, 0, 1))
+	}
+	return t
+}
+
+// Estimate calculates ship date and delivery window, lead and transit are business days
+func Estimate(now time.Time, cutoff string, lead, transitMin, transitMax int, calendar *Calendar) (time.Time, time.Time, time.Time) {
+	start := now
+	if !calendar.IsBusinessDay(now) || afterCutoff(now, cutoff) {
+		start = calendar.NextBusinessDay(date(now).AddDate(0, 0, 1))
+	}
+	ship := calendar.AddBusinessDays(start, lead)
+	if transitMax < transitMin {
+		transitMax = transitMin
+	}
+	return ship, calendar.AddBusinessDays(ship, transitMin), calendar.AddBusinessDays(ship, transitMax)
+}
+
+func afterCutoff(now time.Time, cutoff string) bool {
+	if cutoff = strings.TrimSpace(cutoff); cutoff == "" {
+		return false
+	}
+	if t, err := time.Parse("15:04", cutoff); err == nil {
+		return now.Hour() * 60 + now.Minute() >= t.Hour() * 60 + t.Minute()
+	}
+	return false
+}
+
+func date(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
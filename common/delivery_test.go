@@ -0,0 +1,66 @@
+package common
+
+import (
+	"encoding/json"
+	"github.com/yonnic/goshop/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendar_AddBusinessDays(t *testing.T) {
+	calendar := NewCalendar("mon,tue,wed,thu,fri", "2021-08-09", "12-25")
+	// Friday + 1 business day skipping weekend and Monday holiday
+	if date := calendar.AddBusinessDays(time.Date(2021, time.August, 6, 0, 0, 0, 0, time.UTC), 1); date.Format("2006-01-02") != "2021-08-10" {
+		t.Fatalf("unexpected date: %v", date)
+	}
+	if calendar.IsBusinessDay(time.Date(2022, time.December, 26, 0, 0, 0, 0, time.UTC)) != true {
+		t.Fatalf("Monday 2022-12-26 should be business day")
+	}
+	if calendar.IsBusinessDay(time.Date(2023, time.December, 25, 0, 0, 0, 0, time.UTC)) != false {
+		t.Fatalf("Christmas should be holiday")
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	calendar := NewCalendar("1,2,3,4,5")
+	// Wednesday after cutoff, processing starts on Thursday
+	ship, from, to := Estimate(time.Date(2021, time.August, 4, 15, 30, 0, 0, time.UTC), "14:00", 2, 1, 3, calendar)
+	if ship.Format("2006-01-02") != "2021-08-09" || from.Format("2006-01-02") != "2021-08-10" || to.Format("2006-01-02") != "2021-08-12" {
+		t.Fatalf("unexpected estimate: %v %v %v", ship, from, to)
+	}
+	// Wednesday before cutoff
+	ship, _, _ = Estimate(time.Date(2021, time.August, 4, 10, 0, 0, 0, time.UTC), "14:00", 0, 0, 0, calendar)
+	if ship.Format("2006-01-02") != "2021-08-04" {
+		t.Fatalf("unexpected ship date: %v", ship)
+	}
+}
+
+func TestEstimatePF_Dates(t *testing.T) {
+	previous := Config
+	Config = &config.Config{}
+	Config.Delivery.Cutoff = "14:00"
+	Config.Delivery.Days = "mon,tue,wed,thu,fri"
+	Config.Delivery.Holidays = []string{"12-25"}
+	defer func() {
+		Config = previous
+	}()
+	// Vendor closed on Tuesday and Wednesday ships on Thursday and delivers by its calendar too
+	estimate := NewEstimatePF(0, "", "2021-08-10, 2021-08-11", 1, 2)
+	if estimate.Cutoff != "14:00" || len(estimate.Holidays) != 3 {
+		t.Fatalf("unexpected estimate: %+v", estimate)
+	}
+	ship, from, to := estimate.Dates(time.Date(2021, time.August, 9, 15, 0, 0, 0, time.UTC))
+	if ship.Format("2006-01-02") != "2021-08-12" || from.Format("2006-01-02") != "2021-08-13" || to.Format("2006-01-02") != "2021-08-16" {
+		t.Errorf("unexpected dates: %v %v %v", ship, from, to)
+	}
+	// Vendor cutoff overrides configured one, the same moment is before cutoff
+	estimate = NewEstimatePF(0, "16:00", "", 0, 0)
+	if ship, _, _ = estimate.Dates(time.Date(2021, time.August, 9, 15, 0, 0, 0, time.UTC)); ship.Format("2006-01-02") != "2021-08-09" {
+		t.Errorf("unexpected ship date: %v", ship)
+	}
+	// Static pages get lead time only
+	if bts, err := json.Marshal(NewEstimatePF(2, "", "", 1, 3)); err != nil || strings.Contains(string(bts), "Ship") {
+		t.Errorf("unexpected json: %s %v", bts, err)
+	}
+}
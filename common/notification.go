@@ -15,7 +15,9 @@ const (
 	NOTIFICATION_TYPE_RESET_PASSWORD             = "reset-password"
 	NOTIFICATION_TYPE_ADMIN_ORDER_PAID           = "admin-order-paid"
 	NOTIFICATION_TYPE_USER_ORDER_PAID            = "user-order-paid"
+	NOTIFICATION_TYPE_USER_ORDER_READY_FOR_PICKUP = "user-order-ready-for-pickup"
 	NOTIFICATION_TYPE_ADMIN_FREE_SAMPLES_ORDERED = "free-samples-ordered"
+	NOTIFICATION_TYPE_ADMIN_PIPELINE_FAILED      = "admin-pipeline-failed"
 )
 
 var (
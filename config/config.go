@@ -7,6 +7,7 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
@@ -84,6 +85,11 @@ type Config struct {
 		Minify bool
 	}
 	Publisher PublisherConfig
+	Render RenderConfig
+	Seo SeoConfig
+	Pipeline PipelineConfig
+	Check CheckConfig
+	Backup BackupConfig
 	//
 	Currency string // usd, eur
 	Symbol string // $, €
@@ -94,6 +100,8 @@ type Config struct {
 	WeightUnit string // kg
 	//
 	Payment PaymentConfig
+	Delivery DeliveryConfig
+	Downloads DownloadsConfig
 	Notification NotificationConfig
 	Swagger struct {
 		Enabled bool
@@ -107,6 +115,52 @@ type PublisherConfig struct {
 	Enabled bool
 	Bin string
 	ApiToken string `json:",omitempty" toml:",omitempty"`
+	Target string // empty to run Bin, s3 or file to use built-in publisher, only file switches site at once
+	S3 struct {
+		AccessKeyID string
+		SecretAccessKey string `json:",omitempty" toml:",omitempty"`
+		Region string
+		Bucket string
+		Prefix string
+	}
+	File struct {
+		Path string // releases are kept in Path/releases, Path/current is link to the published one
+		Keep int // releases to keep, 3 if 0
+	}
+	CacheControl string // for assets, "public, max-age=86400" if empty
+	PagesCacheControl string // for html, xml and json, "public, max-age=0, must-revalidate" if empty
+}
+
+type SeoConfig struct {
+	Robots string // rules of robots.txt, everything is allowed if empty, sitemap line is appended
+	SitemapLimit int // urls in one sitemap file, 50000 if 0
+}
+
+// PipelineConfig schedules prepare, render and publish of pending changes
+type PipelineConfig struct {
+	Enabled bool
+	Cron []string // "minute hour day month weekday", e.g. "*/30 * * * *"
+	QuietHours string // "22:00-07:00", scheduled runs are skipped in this range of local time
+	Publish bool // publish after render
+	Notify bool // email admins about failed runs
+}
+
+// CheckConfig validates links and assets of rendered site before publish
+type CheckConfig struct {
+	Enabled bool // check after render, publish is blocked until the last render is checked without errors
+	Remote bool // check links to storage too, it is one request per asset
+}
+
+// BackupConfig is for backup command and API, archives are rotated after every backup
+type BackupConfig struct {
+	Path string // folder of archives, backups by default
+	Keep int // archives kept, 7 if 0
+	Key string // passphrase to encrypt archives, they are not encrypted if empty
+	Cron []string // scheduled backups, "minute hour day month weekday"
+}
+
+type RenderConfig struct {
+	Workers int // products rendered in parallel, number of CPUs if 0
 }
 
 type PaymentConfig struct {
@@ -138,6 +192,18 @@ type PaymentMethod struct {
 
 }
 
+type DeliveryConfig struct {
+	Cutoff string // 14:00, orders after are processed next business day
+	Days string // mon,tue,wed,thu,fri
+	Holidays []string // 2021-12-31 or 12-25 every year
+}
+
+type DownloadsConfig struct {
+	Secret string // key to sign download links, random key is generated on start if empty
+	Expiration int // hours, link is valid after order was paid
+	Limit int // downloads per link if file has no own limit
+}
+
 type ResizeConfig struct {
 	Enabled bool
 	Thumbnail struct {
@@ -304,6 +370,15 @@ func GenerateSSL(crtPath string, keyPath string, host string) error {
 	return nil
 }
 
+// GenerateSecret returns random hex key of n bytes
+func GenerateSecret(n int) (string, error) {
+	bts := make([]byte, n)
+	if _, err := rand.Read(bts); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bts), nil
+}
+
 func publicKey(priv interface{}) interface{} {
 	switch k := priv.(type) {
 	case *rsa.PrivateKey:
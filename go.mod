@@ -4,47 +4,25 @@ go 1.15
 
 require (
 	github.com/BurntSushi/toml v0.3.1
-	github.com/PuerkitoBio/goquery v1.7.0 // indirect
+	github.com/PuerkitoBio/goquery v1.7.0
 	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751
-	github.com/aprimadi/imagecomp v0.0.0-20180213055428-8f3098b13c82 // indirect
-	github.com/arsmn/fiber-casbin/v2 v2.0.0 // indirect
 	github.com/arsmn/fiber-swagger/v2 v2.0.0
 	github.com/aws/aws-sdk-go v1.38.2
-	github.com/cloudflare/tableflip v1.2.0
 	github.com/dannyvankooten/vat v0.0.0-20200422095635-741cefddc898
-	github.com/davecgh/go-spew v1.1.1
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
-	github.com/disintegration/imaging v1.6.2
-	github.com/dsnet/compress v0.0.1 // indirect
-	github.com/fasthttp/session/v2 v2.2.3 // indirect
 	github.com/fsnotify/fsnotify v1.4.9
-	github.com/ghodss/yaml v1.0.0
 	github.com/go-openapi/spec v0.19.9 // indirect
 	github.com/go-openapi/swag v0.19.9 // indirect
-	github.com/gofiber/adaptor/v2 v2.0.0 // indirect
 	github.com/gofiber/fiber/v2 v2.0.1
-	github.com/gofiber/jwt/v2 v2.0.0
-	github.com/gofiber/keyauth/v2 v2.0.0
-	github.com/gofiber/session/v2 v2.0.0
 	github.com/gofiber/template v1.6.1
-	github.com/golang/snappy v0.0.2 // indirect
 	github.com/google/logger v1.1.0
-	github.com/gorilla/handlers v1.5.0
-	github.com/gorilla/mux v1.8.0
 	github.com/gorilla/securecookie v1.1.1
-	github.com/gorilla/sessions v1.2.0
 	github.com/jinzhu/now v1.1.2
-	github.com/justinas/nosurf v1.1.1
+	github.com/lib/pq v1.8.0 // indirect
 	github.com/mailru/easyjson v0.7.6 // indirect
-	github.com/mholt/archiver v3.1.1+incompatible // indirect
+	github.com/mattn/go-sqlite3 v1.14.3 // indirect
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
-	github.com/nickalie/go-binwrapper v0.0.0-20190114141239-525121d43c84 // indirect
-	github.com/nickalie/go-mozjpegbin v0.0.0-20170427050522-d8a58e243a3d
-	github.com/nwaples/rardecode v1.1.0 // indirect
-	github.com/patrickmn/go-cache v2.1.0+incompatible // indirect
-	github.com/pierrec/lz4 v2.6.0+incompatible // indirect
-	github.com/pkg/errors v0.9.1
-	github.com/ryanuber/go-glob v1.0.0 // indirect
+	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/sendgrid/rest v2.6.2+incompatible // indirect
 	github.com/sendgrid/sendgrid-go v3.7.2+incompatible
 	github.com/spf13/cobra v1.0.0
@@ -52,20 +30,11 @@ require (
 	github.com/streamrail/concurrent-map v0.0.0-20160823150647-8bf1e9bacbf6
 	github.com/stripe/stripe-go/v71 v71.48.0
 	github.com/swaggo/swag v1.6.7
-	github.com/ulikunitz/xz v0.5.8 // indirect
-	github.com/urfave/cli/v2 v2.2.0 // indirect
-	github.com/volatiletech/authboss-clientstate v0.0.0-20200826024349-8d4e74078241
-	github.com/volatiletech/authboss-renderer v0.0.0-20200703184356-6cbaa5b2354e
-	github.com/volatiletech/authboss/v3 v3.0.1
-	github.com/xi2/xz v0.0.0-20171230120015-48954b6210f8 // indirect
-	github.com/yusukebe/go-pngquant v0.0.0-20200223090257-49b91f11b627
-	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a // indirect
+	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a
+	golang.org/x/mod v0.3.1-0.20200828183125-ce943fd02449 // indirect
 	golang.org/x/net v0.0.0-20210316092652-d523dce5a7f4 // indirect
-	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
 	golang.org/x/text v0.3.5 // indirect
 	golang.org/x/tools v0.0.0-20200917221617-d56e4e40bc9d // indirect
-	google.golang.org/appengine v1.6.6 // indirect
-	google.golang.org/protobuf v1.25.0 // indirect
 	gorm.io/driver/mysql v1.0.1
 	gorm.io/driver/postgres v1.0.6
 	gorm.io/driver/sqlite v1.1.1
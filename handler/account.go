@@ -1124,6 +1124,7 @@ type OrderView struct {
 	Delivery float64
 	Total float64
 	Comment string `json:",omitempty"`
+	Downloads []DownloadView `json:",omitempty"`
 }
 
 type ItemView struct{
@@ -1140,6 +1141,7 @@ type ItemView struct{
 	Quantity int
 	Total float64
 	CommentId uint `json:",omitempty"`
+	BundleId uint `json:",omitempty"`
 }
 
 // GetOrders godoc
@@ -1266,6 +1268,9 @@ func getAccountOrderHandler(c *fiber.Ctx) error {
 		var view OrderView
 		if bts, err := json.MarshalIndent(order, "", "   "); err == nil {
 			if err = json.Unmarshal(bts, &view); err == nil {
+				if view.Downloads, err = GetOrderDownloads(order); err != nil {
+					logger.Warningf("%+v", err)
+				}
 				return c.JSON(view)
 			}else{
 				c.Status(http.StatusInternalServerError)
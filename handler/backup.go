@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/backup"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"net/http"
+	"os"
+	"path"
+	"time"
+)
+
+// StartBackups runs backup job at every minute matching configured cron expressions
+func StartBackups() {
+	crons, err := parseCrons(common.Config.Backup.Cron)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return
+	}
+	if len(crons) == 0 {
+		return
+	}
+	startCron(crons, func(now time.Time) {
+		job, err := startJob(JOB_BACKUP, false, 0, "")
+		if err != nil {
+			logger.Warningf("Backup skipped: %v", err)
+			return
+		}
+		if err = job.Wait(); err != nil {
+			logger.Errorf("%v", err)
+		}
+	})
+}
+
+// findBackup returns path of archive by name, only archives of backups folder are available
+func findBackup(name string) (string, error) {
+	folder := backup.Folder(dir, common.Config.Backup.Path)
+	items, err := backup.List(folder)
+	if err != nil {
+		return "", err
+	}
+	for _, item := range items {
+		if item.Name == name {
+			return path.Join(folder, item.Name), nil
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+type BackupsView []*backup.Item
+
+// @security BasicAuth
+// GetBackups godoc
+// @Summary Get backups
+// @Accept json
+// @Produce json
+// @Success 200 {object} BackupsView
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/backups [get]
+// @Tags backups
+func getBackupsHandler(c *fiber.Ctx) error {
+	items, err := backup.List(backup.Folder(dir, common.Config.Backup.Path))
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	return c.JSON(BackupsView(items))
+}
+
+// @security BasicAuth
+// CreateBackup godoc
+// @Summary Start backup job
+// @Description Archive of database and files is written to backups folder, progress is streamed by /api/v1/jobs/{id}/events
+// @Accept json
+// @Produce json
+// @Success 200 {object} JobView
+// @Failure 409 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/backups [post]
+// @Tags backups
+func postBackupHandler(c *fiber.Ctx) error {
+	var userId uint
+	if v := c.Locals("user"); v != nil {
+		if user, ok := v.(*models.User); ok {
+			userId = user.ID
+		}
+	}
+	if job := JOBS.Running(0, JOB_BACKUP); job != nil {
+		c.Status(http.StatusConflict)
+		return c.JSON(HTTPError{"Backup is already running"})
+	}
+	job, err := startJob(JOB_BACKUP, false, userId, "")
+	if err != nil {
+		if errors.Is(err, ErrJobsLocked) {
+			c.Status(http.StatusConflict)
+			return c.JSON(HTTPError{err.Error()})
+		}
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	return c.JSON(job.View())
+}
+
+// @security BasicAuth
+// GetBackup godoc
+// @Summary Download backup
+// @Produce application/octet-stream
+// @Param name path string true "Archive name"
+// @Success 200
+// @Failure 404 {object} HTTPError
+// @Router /api/v1/backups/{name} [get]
+// @Tags backups
+func getBackupHandler(c *fiber.Ctx) error {
+	p, err := findBackup(c.Params("name"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{"Backup not found"})
+	}
+	return c.Download(p, path.Base(p))
+}
+
+// @security BasicAuth
+// DelBackup godoc
+// @Summary Delete backup
+// @Accept json
+// @Produce json
+// @Param name path string true "Archive name"
+// @Success 200 {object} HTTPMessage
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/backups/{name} [delete]
+// @Tags backups
+func delBackupHandler(c *fiber.Ctx) error {
+	p, err := findBackup(c.Params("name"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{"Backup not found"})
+	}
+	if err = os.Remove(p); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	return c.JSON(HTTPMessage{MESSAGE: "OK"})
+}
@@ -0,0 +1,688 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"gorm.io/gorm"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	BULK_ACTION_SET      = "set"
+	BULK_ACTION_INCREASE = "increase" // by percent
+	BULK_ACTION_DECREASE = "decrease" // by percent
+	BULK_ACTION_ADD      = "add"
+	BULK_ACTION_REMOVE   = "remove"
+)
+
+var (
+	bulkProductFields = map[string]string{"BasePrice": "base_price", "SalePrice": "sale_price", "ItemPrice": "item_price", "Availability": "availability", "Enabled": "enabled", "Stock": "stock", "VendorId": "vendor_id"}
+	bulkVariationFields = map[string]string{"BasePrice": "base_price", "SalePrice": "sale_price", "ItemPrice": "item_price", "Availability": "availability", "Enabled": "enabled", "Stock": "stock"}
+)
+
+type BulkRequest struct {
+	Ids []uint
+	Filter *ListRequest // used if Ids are empty, the same as for list endpoint
+	CategoryId uint `json:",omitempty"` // products of category, the same as cid of list endpoint
+	ProductId uint `json:",omitempty"` // variations of product, the same as id of list endpoint
+	Operations []BulkOperation
+}
+
+type BulkOperation struct {
+	Field string // BasePrice, SalePrice, ItemPrice, Availability, Enabled, Stock, VendorId, Category or Tag
+	Action string // set, increase, decrease, add or remove
+	Value string
+}
+
+type ChangeSetView struct {
+	ID uint
+	CreatedAt time.Time
+	Type string
+	Status string
+	Total int
+	Processed int
+	Operations []BulkOperation `json:",omitempty"`
+	Skipped []uint `json:",omitempty"` // items having pending drafts are not changed, items changed after bulk are not undone
+	Error string `json:",omitempty"`
+}
+
+// bulkChange keeps item state before and after change set was applied
+type bulkChange struct {
+	Id uint
+	Before map[string]interface{} `json:",omitempty"`
+	After map[string]interface{} `json:",omitempty"`
+	AddedCategories []uint `json:",omitempty"`
+	RemovedCategories []uint `json:",omitempty"`
+	AddedTags []uint `json:",omitempty"`
+	RemovedTags []uint `json:",omitempty"`
+}
+
+func newChangeSetView(changeSet *models.ChangeSet) ChangeSetView {
+	view := ChangeSetView{
+		ID: changeSet.ID,
+		CreatedAt: changeSet.CreatedAt,
+		Type: changeSet.Type,
+		Status: changeSet.Status,
+		Total: changeSet.Total,
+		Processed: changeSet.Processed,
+		Error: changeSet.Error,
+	}
+	if err := json.Unmarshal([]byte(changeSet.Operations), &view.Operations); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if changeSet.Skipped != "" {
+		if err := json.Unmarshal([]byte(changeSet.Skipped), &view.Skipped); err != nil {
+			logger.Warningf("%+v", err)
+		}
+	}
+	return view
+}
+
+// @security BasicAuth
+// BulkProducts godoc
+// @Summary Bulk edit products in background
+// @Accept json
+// @Produce json
+// @Param request body BulkRequest true "body"
+// @Success 200 {object} ChangeSetView
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/products/bulk [post]
+// @Tags product
+func postProductsBulkHandler(c *fiber.Ctx) error {
+	return postBulk(c, "products")
+}
+
+// @security BasicAuth
+// BulkVariations godoc
+// @Summary Bulk edit variations in background
+// @Accept json
+// @Produce json
+// @Param request body BulkRequest true "body"
+// @Success 200 {object} ChangeSetView
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/variations/bulk [post]
+// @Tags variation
+func postVariationsBulkHandler(c *fiber.Ctx) error {
+	return postBulk(c, "variations")
+}
+
+func postBulk(c *fiber.Ctx, typ string) error {
+	var request BulkRequest
+	if contentType := string(c.Request().Header.ContentType()); contentType != "" {
+		if strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+			if err := c.BodyParser(&request); err != nil {
+				return err
+			}
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{"Unsupported Content-Type"})
+		}
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{"Missed Content-Type header"})
+	}
+	if len(request.Operations) == 0 {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{"No operations defined"})
+	}
+	for _, operation := range request.Operations {
+		if err := validateBulkOperation(typ, operation); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{err.Error()})
+		}
+	}
+	ids := request.Ids
+	if len(ids) == 0 {
+		if request.Filter == nil {
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{"Ids or Filter should be defined"})
+		}
+		var err error
+		if ids, err = selectBulkIds(typ, request); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{err.Error()})
+		}
+	}
+	operations, _ := json.Marshal(request.Operations)
+	changeSet := &models.ChangeSet{
+		Type: typ,
+		Status: models.CHANGE_SET_STATUS_RUNNING,
+		Total: len(ids),
+		Operations: string(operations),
+	}
+	if v := c.Locals("user"); v != nil {
+		if user, ok := v.(*models.User); ok {
+			changeSet.UserId = user.ID
+		}
+	}
+	if _, err := models.CreateChangeSet(common.Database, changeSet); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	go runBulk(changeSet, ids, request.Operations)
+	return c.JSON(newChangeSetView(changeSet))
+}
+
+// @security BasicAuth
+// GetChangeSets godoc
+// @Summary Get bulk change sets
+// @Accept json
+// @Produce json
+// @Success 200 {array} ChangeSetView
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/bulk [get]
+// @Tags bulk
+func getChangeSetsHandler(c *fiber.Ctx) error {
+	if changeSets, err := models.GetChangeSets(common.Database); err == nil {
+		views := []ChangeSetView{}
+		for _, changeSet := range changeSets {
+			views = append(views, newChangeSetView(changeSet))
+		}
+		return c.JSON(views)
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// @security BasicAuth
+// GetChangeSet godoc
+// @Summary Get bulk change set progress
+// @Accept json
+// @Produce json
+// @Param id path int true "Change set ID"
+// @Success 200 {object} ChangeSetView
+// @Failure 404 {object} HTTPError
+// @Router /api/v1/bulk/{id} [get]
+// @Tags bulk
+func getChangeSetHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	if changeSet, err := models.GetChangeSet(common.Database, id); err == nil {
+		return c.JSON(newChangeSetView(changeSet))
+	}else{
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// @security BasicAuth
+// UndoChangeSet godoc
+// @Summary Undo bulk change set in background
+// @Description Change set can be undone only once, even if undo failed
+// @Accept json
+// @Produce json
+// @Param id path int true "Change set ID"
+// @Success 200 {object} ChangeSetView
+// @Failure 404 {object} HTTPError
+// @Failure 409 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/bulk/{id}/undo [post]
+// @Tags bulk
+func postChangeSetUndoHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	changeSet, err := models.GetChangeSet(common.Database, id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	var changes []bulkChange
+	if err = json.Unmarshal([]byte(changeSet.Changes), &changes); err != nil && changeSet.Changes != "" {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	if ok, err := models.BeginChangeSetUndo(common.Database, changeSet); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}else if !ok {
+		if changeSet, err = models.GetChangeSet(common.Database, id); err != nil {
+			c.Status(http.StatusNotFound)
+			return c.JSON(HTTPError{err.Error()})
+		}
+		c.Status(http.StatusConflict)
+		return c.JSON(HTTPError{fmt.Sprintf("Change set is %v", changeSet.Status)})
+	}
+	changeSet.Total = len(changes)
+	changeSet.Processed = 0
+	if err = models.UpdateChangeSet(common.Database, changeSet); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	go undoBulk(changeSet, changes)
+	return c.JSON(newChangeSetView(changeSet))
+}
+
+func validateBulkOperation(typ string, operation BulkOperation) error {
+	fields := bulkProductFields
+	if typ == "variations" {
+		fields = bulkVariationFields
+	}
+	switch operation.Action {
+	case BULK_ACTION_SET:
+		if _, found := fields[operation.Field]; !found {
+			return fmt.Errorf("field %v can not be set", operation.Field)
+		}
+		if _, err := bulkValue(operation.Field, operation.Value); err != nil {
+			return err
+		}
+	case BULK_ACTION_INCREASE, BULK_ACTION_DECREASE:
+		if operation.Field != "BasePrice" && operation.Field != "SalePrice" && operation.Field != "ItemPrice" {
+			return fmt.Errorf("field %v can not be changed by percent", operation.Field)
+		}
+		if _, err := strconv.ParseFloat(strings.TrimSuffix(operation.Value, "%"), 64); err != nil {
+			return fmt.Errorf("incorrect percent %v", operation.Value)
+		}
+	case BULK_ACTION_ADD, BULK_ACTION_REMOVE:
+		if typ != "products" || (operation.Field != "Category" && operation.Field != "Tag") {
+			return fmt.Errorf("%v of %v is not supported", operation.Action, operation.Field)
+		}
+		id, _ := strconv.Atoi(operation.Value)
+		if operation.Field == "Category" {
+			if _, err := models.GetCategory(common.Database, id); err != nil {
+				return fmt.Errorf("category #%v not found", operation.Value)
+			}
+		}else if _, err := models.GetTag(common.Database, id); err != nil {
+			return fmt.Errorf("tag #%v not found", operation.Value)
+		}
+	default:
+		return fmt.Errorf("unknown action %v", operation.Action)
+	}
+	return nil
+}
+
+// bulkValue converts value of set operation to column type
+func bulkValue(field, value string) (interface{}, error) {
+	switch field {
+	case "BasePrice", "SalePrice", "ItemPrice":
+		return strconv.ParseFloat(value, 64)
+	case "Enabled":
+		return strconv.ParseBool(value)
+	case "Stock":
+		return strconv.ParseUint(value, 10, 64)
+	case "VendorId":
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if id > 0 {
+			if _, err = models.GetVendor(common.Database, int(id)); err != nil {
+				return nil, fmt.Errorf("vendor #%v not found", id)
+			}
+		}
+		return id, nil
+	}
+	return value, nil
+}
+
+// selectBulkIds returns ids of all items matching list filter
+func selectBulkIds(typ string, request BulkRequest) ([]uint, error) {
+	var ids []uint
+	if typ == "products" {
+		keys1, values1, keys2, values2 := productsListFilter(*request.Filter, int(request.CategoryId))
+		rows, err := common.Database.Debug().Model(&models.Product{}).Select("products.ID, group_concat(distinct variations.Title) as Variations").Joins("left join categories_products on categories_products.product_id = products.id").Joins("left join variations on variations.product_id = products.id").Group("products.id").Where(strings.Join(keys1, " and "), values1...).Having(strings.Join(keys2, " and "), values2...).Rows()
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var item struct {
+				ID uint
+			}
+			if err = common.Database.ScanRows(rows, &item); err == nil {
+				ids = append(ids, item.ID)
+			}
+		}
+	}else{
+		keys1, values1, keys2, values2 := variationsListFilter(*request.Filter, int(request.ProductId))
+		rows, err := common.Database.Debug().Model(&models.Variation{}).Select("variations.ID, products.Title as ProductTitle, count(properties.ID) as Options").Joins("left join products on products.id = variations.product_id").Joins("left join properties on properties.variation_id = variations.id").Group("variations.id").Where(strings.Join(keys1, " and "), values1...).Having(strings.Join(keys2, " and "), values2...).Rows()
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var item struct {
+				ID uint
+			}
+			if err = common.Database.ScanRows(rows, &item); err == nil {
+				ids = append(ids, item.ID)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// runBulk applies operations one item after another saving progress and previous values, every item is changed in own
+// transaction, items having pending drafts are skipped
+func runBulk(changeSet *models.ChangeSet, ids []uint, operations []BulkOperation) {
+	objectType := models.REVISION_OBJECT_PRODUCT
+	var model interface{} = &models.Product{}
+	if changeSet.Type == "variations" {
+		objectType = models.REVISION_OBJECT_VARIATION
+		model = &models.Variation{}
+	}
+	var changes []bulkChange
+	save := func() {
+		bts, _ := json.Marshal(changes)
+		changeSet.Changes = string(bts)
+		if err := models.UpdateChangeSet(common.Database, changeSet); err != nil {
+			logger.Warningf("%+v", err)
+		}
+	}
+	for i, id := range ids {
+		var change bulkChange
+		var skipped bool
+		err := common.Database.Transaction(func(tx *gorm.DB) error {
+			if skipped = models.HasDraft(tx, objectType, id); skipped {
+				return nil
+			}
+			var err error
+			if change, err = applyBulk(tx, changeSet.Type, model, id, operations); err != nil {
+				return err
+			}
+			if err = saveBulkRevision(tx, objectType, id, fmt.Sprintf("bulk #%d", changeSet.ID)); err != nil {
+				return err
+			}
+			// item is recorded with its change, so set interrupted by restart can still be undone
+			bts, _ := json.Marshal(append(changes, change))
+			return tx.Debug().Model(&models.ChangeSet{}).Where("id = ?", changeSet.ID).Updates(map[string]interface{}{"changes": string(bts), "processed": i + 1}).Error
+		})
+		if err != nil {
+			logger.Warningf("%+v", err)
+			changeSet.Error = err.Error()
+			changeSet.Status = models.CHANGE_SET_STATUS_FAILED
+			save()
+			return
+		}
+		if skipped {
+			skipBulk(changeSet, id)
+		}else{
+			changes = append(changes, change)
+			reindexObject(objectType, id)
+		}
+		changeSet.Processed = i + 1
+		if changeSet.Processed % 10 == 0 {
+			save()
+		}
+	}
+	changeSet.Status = models.CHANGE_SET_STATUS_FINISHED
+	save()
+	if len(changes) > 0 {
+		if err := MarkChanged(fmt.Sprintf("bulk #%d applied", changeSet.ID)); err != nil {
+			logger.Warningf("%v", err)
+		}
+	}
+}
+
+func applyBulk(tx *gorm.DB, typ string, model interface{}, id uint, operations []BulkOperation) (bulkChange, error) {
+	change := bulkChange{Id: id, Before: make(map[string]interface{}), After: make(map[string]interface{})}
+	fields := bulkProductFields
+	if typ == "variations" {
+		fields = bulkVariationFields
+	}
+	current := make(map[string]interface{})
+	if err := tx.Debug().Model(model).Where("id = ?", id).Take(&current).Error; err != nil {
+		return change, err
+	}
+	updates := make(map[string]interface{})
+	for _, operation := range operations {
+		column := fields[operation.Field]
+		switch operation.Action {
+		case BULK_ACTION_SET:
+			value, _ := bulkValue(operation.Field, operation.Value)
+			updates[column] = value
+		case BULK_ACTION_INCREASE, BULK_ACTION_DECREASE:
+			percent, _ := strconv.ParseFloat(strings.TrimSuffix(operation.Value, "%"), 64)
+			if operation.Action == BULK_ACTION_DECREASE {
+				percent = -percent
+			}
+			var price float64
+			if v, found := updates[column]; found {
+				price, _ = v.(float64)
+			}else{
+				price = toFloat(current[column])
+			}
+			updates[column] = math.Round(price * (100 + percent)) / 100
+		case BULK_ACTION_ADD, BULK_ACTION_REMOVE:
+			value, _ := strconv.Atoi(operation.Value)
+			table, column := "categories_products", "category_id"
+			if operation.Field == "Tag" {
+				table, column = "products_tags", "tag_id"
+			}
+			var count int64
+			if err := tx.Debug().Table(table).Where("product_id = ? and " + column + " = ?", id, value).Count(&count).Error; err != nil {
+				return change, err
+			}
+			if operation.Action == BULK_ACTION_ADD && count == 0 {
+				if err := tx.Debug().Exec("insert into " + table + " (product_id, " + column + ") values (?, ?)", id, value).Error; err != nil {
+					return change, err
+				}
+				if operation.Field == "Tag" {
+					change.AddedTags = append(change.AddedTags, uint(value))
+				}else{
+					change.AddedCategories = append(change.AddedCategories, uint(value))
+				}
+			}else if operation.Action == BULK_ACTION_REMOVE && count > 0 {
+				if err := tx.Debug().Exec("delete from " + table + " where product_id = ? and " + column + " = ?", id, value).Error; err != nil {
+					return change, err
+				}
+				if operation.Field == "Tag" {
+					change.RemovedTags = append(change.RemovedTags, uint(value))
+				}else{
+					change.RemovedCategories = append(change.RemovedCategories, uint(value))
+				}
+			}
+		}
+	}
+	if len(updates) > 0 {
+		for column := range updates {
+			// mysql driver returns text as bytes
+			if bts, ok := current[column].([]byte); ok {
+				change.Before[column] = string(bts)
+			}else{
+				change.Before[column] = current[column]
+			}
+			change.After[column] = updates[column]
+		}
+		if err := tx.Debug().Model(model).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return change, err
+		}
+	}
+	return change, nil
+}
+
+func undoBulk(changeSet *models.ChangeSet, changes []bulkChange) {
+	objectType := models.REVISION_OBJECT_PRODUCT
+	var model interface{} = &models.Product{}
+	if changeSet.Type == "variations" {
+		objectType = models.REVISION_OBJECT_VARIATION
+		model = &models.Variation{}
+	}
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		var skipped bool
+		err := common.Database.Transaction(func(tx *gorm.DB) error {
+			if skipped = models.HasDraft(tx, objectType, change.Id); skipped {
+				return nil
+			}
+			var err error
+			if skipped, err = revertBulk(tx, model, change); err != nil || skipped {
+				return err
+			}
+			return saveBulkRevision(tx, objectType, change.Id, fmt.Sprintf("bulk #%d undone", changeSet.ID))
+		})
+		if err != nil {
+			logger.Warningf("%+v", err)
+			changeSet.Error = err.Error()
+			changeSet.Status = models.CHANGE_SET_STATUS_UNDO_FAILED
+			if err = models.UpdateChangeSet(common.Database, changeSet); err != nil {
+				logger.Warningf("%+v", err)
+			}
+			return
+		}
+		if skipped {
+			skipBulk(changeSet, change.Id)
+		}else{
+			reindexObject(objectType, change.Id)
+		}
+		changeSet.Processed ++
+		if changeSet.Processed % 10 == 0 {
+			if err := models.UpdateChangeSet(common.Database, changeSet); err != nil {
+				logger.Warningf("%+v", err)
+			}
+		}
+	}
+	changeSet.Status = models.CHANGE_SET_STATUS_UNDONE
+	if err := models.UpdateChangeSet(common.Database, changeSet); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	if err := MarkChanged(fmt.Sprintf("bulk #%d undone", changeSet.ID)); err != nil {
+		logger.Warningf("%v", err)
+	}
+}
+
+// revertBulk restores previous values, returns true without changes if item was edited after change set was applied
+func revertBulk(tx *gorm.DB, model interface{}, change bulkChange) (bool, error) {
+	if len(change.Before) > 0 {
+		current := make(map[string]interface{})
+		if err := tx.Debug().Model(model).Where("id = ?", change.Id).Take(&current).Error; err != nil {
+			return false, err
+		}
+		for column, value := range change.After {
+			if !sameBulkValue(column, current[column], value) {
+				return true, nil
+			}
+		}
+		if err := tx.Debug().Model(model).Where("id = ?", change.Id).Updates(change.Before).Error; err != nil {
+			return false, err
+		}
+	}
+	for _, id := range change.AddedCategories {
+		if err := tx.Debug().Exec("delete from categories_products where product_id = ? and category_id = ?", change.Id, id).Error; err != nil {
+			return false, err
+		}
+	}
+	for _, id := range change.RemovedCategories {
+		if err := restoreBulkLink(tx, "categories_products", "category_id", change.Id, id); err != nil {
+			return false, err
+		}
+	}
+	for _, id := range change.AddedTags {
+		if err := tx.Debug().Exec("delete from products_tags where product_id = ? and tag_id = ?", change.Id, id).Error; err != nil {
+			return false, err
+		}
+	}
+	for _, id := range change.RemovedTags {
+		if err := restoreBulkLink(tx, "products_tags", "tag_id", change.Id, id); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// restoreBulkLink inserts removed link unless it was added again
+func restoreBulkLink(tx *gorm.DB, table, column string, productId, id uint) error {
+	var count int64
+	if err := tx.Debug().Table(table).Where("product_id = ? and " + column + " = ?", productId, id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return tx.Debug().Exec("insert into " + table + " (product_id, " + column + ") values (?, ?)", productId, id).Error
+}
+
+// sameBulkValue compares column value read from database with value written by change set
+func sameBulkValue(column string, current, value interface{}) bool {
+	switch column {
+	case "availability":
+		if bts, ok := current.([]byte); ok {
+			current = string(bts)
+		}
+		return fmt.Sprintf("%v", current) == fmt.Sprintf("%v", value)
+	case "enabled":
+		return toBool(current) == toBool(value)
+	}
+	return math.Abs(toFloat(current) - toFloat(value)) < 0.000001
+}
+
+// saveBulkRevision keeps revision history complete for bulk changes
+func saveBulkRevision(tx *gorm.DB, objectType string, id uint, comment string) error {
+	data, err := models.NewSnapshot(tx, objectType, id)
+	if err != nil {
+		return err
+	}
+	_, err = models.CreateRevision(tx, &models.Revision{
+		ObjectType: objectType,
+		ObjectId: id,
+		Status: models.REVISION_STATUS_PUBLISHED,
+		Comment: comment,
+		Data: data,
+	})
+	return err
+}
+
+// skipBulk records item left untouched because its pending draft would be published with bulk revision
+func skipBulk(changeSet *models.ChangeSet, id uint) {
+	var skipped []uint
+	if changeSet.Skipped != "" {
+		if err := json.Unmarshal([]byte(changeSet.Skipped), &skipped); err != nil {
+			logger.Warningf("%+v", err)
+		}
+	}
+	for _, v := range skipped {
+		if v == id {
+			return
+		}
+	}
+	bts, _ := json.Marshal(append(skipped, id))
+	changeSet.Skipped = string(bts)
+}
+
+func toFloat(v interface{}) float64 {
+	switch value := v.(type) {
+	case float64:
+		return value
+	case float32:
+		return float64(value)
+	case int64:
+		return float64(value)
+	case uint64:
+		return float64(value)
+	case int:
+		return float64(value)
+	case []byte:
+		f, _ := strconv.ParseFloat(string(value), 64)
+		return f
+	case string:
+		f, _ := strconv.ParseFloat(value, 64)
+		return f
+	}
+	return 0
+}
+
+func toBool(v interface{}) bool {
+	switch value := v.(type) {
+	case bool:
+		return value
+	case []byte:
+		b, _ := strconv.ParseBool(string(value))
+		return b
+	case string:
+		b, _ := strconv.ParseBool(value)
+		return b
+	}
+	return toFloat(v) != 0
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/yonnic/goshop/models"
+	"testing"
+)
+
+func TestBulkUndo(t *testing.T) {
+	db := openCatalogDatabase(t, &models.ChangeSet{})
+	category := &models.Category{Name: "sale", Title: "Sale"}
+	if _, err := models.CreateCategory(db, category); err != nil {
+		t.Fatalf("%v", err)
+	}
+	var ids []uint
+	for _, name := range []string{"shirt", "pants"} {
+		product := &models.Product{Enabled: true, Name: name, Title: name, BasePrice: 10}
+		if _, err := models.CreateProduct(db, product); err != nil {
+			t.Fatalf("%v", err)
+		}
+		ids = append(ids, product.ID)
+	}
+	operations := []BulkOperation{{Field: "BasePrice", Action: BULK_ACTION_INCREASE, Value: "10%"}, {Field: "Category", Action: BULK_ACTION_ADD, Value: fmt.Sprintf("%d", category.ID)}}
+	bts, _ := json.Marshal(operations)
+	changeSet := &models.ChangeSet{Type: "products", Status: models.CHANGE_SET_STATUS_RUNNING, Total: len(ids), Operations: string(bts)}
+	if _, err := models.CreateChangeSet(db, changeSet); err != nil {
+		t.Fatalf("%v", err)
+	}
+	runBulk(changeSet, ids, operations)
+	price := func(id uint) float64 {
+		product, err := models.GetProduct(db, int(id))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		return product.BasePrice
+	}
+	categories := func(id uint) int {
+		product := &models.Product{}
+		product.ID = id
+		categories, err := models.GetCategoriesOfProduct(db, product)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		return len(categories)
+	}
+	for _, id := range ids {
+		if p := price(id); p != 11 {
+			t.Errorf("#%v price %v, expected 11", id, p)
+		}
+		if n := categories(id); n != 1 {
+			t.Errorf("#%v has %v categories, expected 1", id, n)
+		}
+	}
+	// Second product is edited after bulk, undo keeps it
+	if err := db.Model(&models.Product{}).Where("id = ?", ids[1]).Update("base_price", 15).Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	changeSet, err := models.GetChangeSet(db, int(changeSet.ID))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var changes []bulkChange
+	if err = json.Unmarshal([]byte(changeSet.Changes), &changes); err != nil || len(changes) != 2 {
+		t.Fatalf("unexpected changes: %v %v", changeSet.Changes, err)
+	}
+	if ok, err := models.BeginChangeSetUndo(db, changeSet); err != nil || !ok {
+		t.Fatalf("undo is not started: %v", err)
+	}
+	undoBulk(changeSet, changes)
+	if changeSet, err = models.GetChangeSet(db, int(changeSet.ID)); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if view := newChangeSetView(changeSet); view.Status != models.CHANGE_SET_STATUS_UNDONE || len(view.Skipped) != 1 || view.Skipped[0] != ids[1] {
+		t.Errorf("unexpected change set: %+v", view)
+	}
+	if p := price(ids[0]); p != 10 {
+		t.Errorf("price %v, expected 10", p)
+	}
+	if n := categories(ids[0]); n != 0 {
+		t.Errorf("category is not removed")
+	}
+	if p := price(ids[1]); p != 15 {
+		t.Errorf("edited price %v is overwritten", p)
+	}
+	if n := categories(ids[1]); n != 1 {
+		t.Errorf("category of edited product is removed")
+	}
+}
+
+func TestFailRunningChangeSets(t *testing.T) {
+	db := openTestDatabase(t, &models.ChangeSet{})
+	running := &models.ChangeSet{Type: "products", Status: models.CHANGE_SET_STATUS_RUNNING, Changes: `[{"Id":1,"Before":{"base_price":10}}]`}
+	undoing := &models.ChangeSet{Type: "products", Status: models.CHANGE_SET_STATUS_UNDOING}
+	finished := &models.ChangeSet{Type: "products", Status: models.CHANGE_SET_STATUS_FINISHED}
+	for _, changeSet := range []*models.ChangeSet{running, undoing, finished} {
+		if _, err := models.CreateChangeSet(db, changeSet); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	if err := models.FailRunningChangeSets(db); err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, example := range []struct{
+		ChangeSet *models.ChangeSet
+		Status string
+	}{
+		{ChangeSet: running, Status: models.CHANGE_SET_STATUS_FAILED},
+		{ChangeSet: undoing, Status: models.CHANGE_SET_STATUS_UNDO_FAILED},
+		{ChangeSet: finished, Status: models.CHANGE_SET_STATUS_FINISHED},
+	}{
+		changeSet, err := models.GetChangeSet(db, int(example.ChangeSet.ID))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if changeSet.Status != example.Status {
+			t.Errorf("#%v status %v, expected %v", changeSet.ID, changeSet.Status, example.Status)
+		}
+	}
+	// Interrupted change set can be undone
+	if ok, err := models.BeginChangeSetUndo(db, running); err != nil || !ok {
+		t.Errorf("interrupted change set can not be undone: %v", err)
+	}
+}
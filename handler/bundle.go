@@ -0,0 +1,333 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type BundleView struct {
+	Pricing string
+	Discount float64 `json:",omitempty"`
+	BasePrice float64
+	SalePrice float64 `json:",omitempty"`
+	Stock uint
+	Availability string `json:",omitempty"`
+	Components []BundleComponentView
+}
+
+type BundleComponentView struct {
+	ID uint
+	ProductId uint
+	VariationId uint `json:",omitempty"`
+	Title string
+	Quantity int
+	Optional bool `json:",omitempty"`
+	Sort int `json:",omitempty"`
+	BasePrice float64
+	Price float64
+}
+
+type NewBundleComponent struct {
+	ProductId uint
+	VariationId uint
+	Quantity int
+	Optional bool
+	Sort int
+}
+
+func newBundleComponentView(component *models.BundleComponent, now time.Time) BundleComponentView {
+	basePrice, price := component.Prices(now)
+	return BundleComponentView{
+		ID: component.ID,
+		ProductId: component.ProductId,
+		VariationId: component.VariationId,
+		Title: component.Title(),
+		Quantity: component.Quantity,
+		Optional: component.Optional,
+		Sort: component.Sort,
+		BasePrice: basePrice,
+		Price: price,
+	}
+}
+
+// GetBundleView returns bundle contents with price of required components and derived stock
+func GetBundleView(product *models.Product) (*BundleView, error) {
+	components, err := models.GetBundleComponents(common.Database, product.ID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	view := &BundleView{Pricing: product.BundlePricing, Discount: product.BundleDiscount, Components: []BundleComponentView{}}
+	var required []*models.BundleComponent
+	for _, component := range components {
+		if !component.Optional {
+			required = append(required, component)
+		}
+		view.Components = append(view.Components, newBundleComponentView(component, now))
+	}
+	view.BasePrice, view.SalePrice = models.GetBundlePrice(product, required, now)
+	view.Stock, view.Availability = models.GetBundleStock(components)
+	return view, nil
+}
+
+// @security BasicAuth
+// GetBundleComponents godoc
+// @Summary Get bundle contents
+// @Accept json
+// @Produce json
+// @Param product_id query int true "Bundle product id"
+// @Success 200 {object} BundleView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/components [get]
+// @Tags bundle
+func getBundleComponentsHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Query("product_id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	if product, err := models.GetProduct(common.Database, id); err == nil {
+		if view, err := GetBundleView(product); err == nil {
+			return c.JSON(view)
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{err.Error()})
+		}
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// @security BasicAuth
+// CreateBundleComponent godoc
+// @Summary Add component to bundle
+// @Accept json
+// @Produce json
+// @Param product_id query int true "Bundle product id"
+// @Param component body NewBundleComponent true "body"
+// @Success 200 {object} BundleComponentView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/components [post]
+// @Tags bundle
+func postBundleComponentHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Query("product_id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	var err error
+	var product *models.Product
+	if product, err = models.GetProduct(common.Database, id); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	if contentType := string(c.Request().Header.ContentType()); contentType != "" {
+		if strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+			var request NewBundleComponent
+			if err := c.BodyParser(&request); err != nil {
+				return err
+			}
+			if err = validateBundleComponent(product, &request); err != nil {
+				c.Status(http.StatusInternalServerError)
+				return c.JSON(HTTPError{err.Error()})
+			}
+			component := &models.BundleComponent{
+				BundleId: product.ID,
+				ProductId: request.ProductId,
+				VariationId: request.VariationId,
+				Quantity: request.Quantity,
+				Optional: request.Optional,
+				Sort: request.Sort,
+			}
+			if _, err = models.CreateBundleComponent(common.Database, component); err != nil {
+				c.Status(http.StatusInternalServerError)
+				return c.JSON(HTTPError{err.Error()})
+			}
+			if !product.Bundle {
+				product.Bundle = true
+				if product.BundlePricing == "" {
+					product.BundlePricing = models.BUNDLE_PRICING_SUM
+				}
+				if err = models.UpdateProduct(common.Database, product); err != nil {
+					c.Status(http.StatusInternalServerError)
+					return c.JSON(HTTPError{err.Error()})
+				}
+			}
+			if component, err = models.GetBundleComponent(common.Database, int(component.ID)); err != nil {
+				c.Status(http.StatusInternalServerError)
+				return c.JSON(HTTPError{err.Error()})
+			}
+			return c.JSON(newBundleComponentView(component, time.Now()))
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{"Unsupported Content-Type"})
+		}
+	}
+	c.Status(http.StatusInternalServerError)
+	return c.JSON(HTTPError{"Something went wrong"})
+}
+
+// @security BasicAuth
+// GetBundleComponent godoc
+// @Summary Get bundle component
+// @Accept json
+// @Produce json
+// @Param id path int true "Component ID"
+// @Success 200 {object} BundleComponentView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/components/{id} [get]
+// @Tags bundle
+func getBundleComponentHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	if component, err := models.GetBundleComponent(common.Database, id); err == nil {
+		return c.JSON(newBundleComponentView(component, time.Now()))
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// @security BasicAuth
+// UpdateBundleComponent godoc
+// @Summary Update bundle component
+// @Accept json
+// @Produce json
+// @Param component body NewBundleComponent true "body"
+// @Param id path int true "Component ID"
+// @Success 200 {object} BundleComponentView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/components/{id} [put]
+// @Tags bundle
+func putBundleComponentHandler(c *fiber.Ctx) error {
+	var request NewBundleComponent
+	if err := c.BodyParser(&request); err != nil {
+		return err
+	}
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(fiber.Map{"ERROR": "ID is not defined"})
+	}
+	var component *models.BundleComponent
+	var err error
+	if component, err = models.GetBundleComponent(common.Database, id); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	var product *models.Product
+	if product, err = models.GetProduct(common.Database, int(component.BundleId)); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	if err = validateBundleComponent(product, &request); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	component.ProductId = request.ProductId
+	component.VariationId = request.VariationId
+	component.Product = nil
+	component.Variation = nil
+	component.Quantity = request.Quantity
+	component.Optional = request.Optional
+	component.Sort = request.Sort
+	if err = models.UpdateBundleComponent(common.Database, component); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	if component, err = models.GetBundleComponent(common.Database, id); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	return c.JSON(newBundleComponentView(component, time.Now()))
+}
+
+// @security BasicAuth
+// DelBundleComponent godoc
+// @Summary Delete bundle component
+// @Accept json
+// @Produce json
+// @Param id path int true "Component ID"
+// @Success 200 {object} HTTPMessage
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/components/{id} [delete]
+// @Tags bundle
+func delBundleComponentHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	if component, err := models.GetBundleComponent(common.Database, id); err == nil {
+		if err = models.DeleteBundleComponent(common.Database, component); err == nil {
+			touchProducts(component.BundleId)
+			return c.JSON(HTTPMessage{MESSAGE: "OK"})
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{err.Error()})
+		}
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+func validateBundleComponent(bundle *models.Product, request *NewBundleComponent) error {
+	if request.ProductId == bundle.ID {
+		return fmt.Errorf("bundle can not include itself")
+	}
+	product, err := models.GetProduct(common.Database, int(request.ProductId))
+	if err != nil {
+		return fmt.Errorf("product #%v not found", request.ProductId)
+	}
+	if product.Bundle {
+		return fmt.Errorf("nested bundles are not supported")
+	}
+	if request.VariationId > 0 {
+		if variation, err := models.GetVariation(common.Database, int(request.VariationId)); err != nil || variation.ProductId != product.ID {
+			return fmt.Errorf("variation #%v of product #%v not found", request.VariationId, request.ProductId)
+		}
+	}
+	if request.Quantity <= 0 {
+		request.Quantity = 1
+	}
+	return nil
+}
+
+// selectBundleComponents returns required components and optional ones chosen by customer as they were published
+func selectBundleComponents(bundleId uint, optional []uint) ([]*models.BundleComponent, error) {
+	components, err := models.GetPublishedBundleComponents(common.Database, bundleId)
+	if err != nil {
+		return nil, err
+	}
+	var selected []*models.BundleComponent
+	for _, component := range components {
+		if component.Optional {
+			var found bool
+			for _, id := range optional {
+				if id == component.ID {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		selected = append(selected, component)
+	}
+	return selected, nil
+}
@@ -795,6 +795,12 @@ func delCategoryHandler(c *fiber.Ctx) error {
 					}
 				}
 			}
+			if err = models.DeleteRevisionsByObject(common.Database, models.REVISION_OBJECT_CATEGORY, category.ID); err != nil {
+				logger.Errorf("%v", err.Error())
+			}
+			if err = models.DeleteTranslationsByObject(common.Database, models.TRANSLATION_OBJECT_CATEGORY, category.ID); err != nil {
+				logger.Errorf("%v", err.Error())
+			}
 			if err = models.DeleteCategory(common.Database, category); err != nil {
 				c.Status(http.StatusInternalServerError)
 				return c.JSON(HTTPError{err.Error()})
@@ -809,6 +815,12 @@ func delCategoryHandler(c *fiber.Ctx) error {
 				}
 			}
 		}
+		if err = models.DeleteRevisionsByObject(common.Database, models.REVISION_OBJECT_CATEGORY, category.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
+		if err = models.DeleteTranslationsByObject(common.Database, models.TRANSLATION_OBJECT_CATEGORY, category.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
 		if err = models.DeleteCategory(common.Database, category); err != nil {
 			c.Status(http.StatusInternalServerError)
 			return c.JSON(HTTPError{err.Error()})
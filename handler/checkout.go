@@ -3,6 +3,7 @@ package handler
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/logger"
 	"github.com/yonnic/goshop/common"
@@ -22,6 +23,7 @@ type CheckoutRequest struct {
 	Comment string
 	BillingProfileId uint
 	ShippingProfileId uint
+	PickupLocationId uint
 	//
 	PaymentId uint
 	//
@@ -45,6 +47,7 @@ type NewItem struct {
 	UUID string
 	CategoryId uint
 	Quantity int
+	Components []uint `json:",omitempty"` // selected optional bundle components
 }
 
 /**/
@@ -67,6 +70,12 @@ type OrderShortView struct{
 	Payments []PaymentView `json:",omitempty"`
 	//
 	Shipping *ShippingOrderView `json:",omitempty"`
+	Estimate *EstimateView `json:",omitempty"`
+	//
+	Pickups []PickupLocationView `json:",omitempty"`
+	Pickup *PickupOrderView `json:",omitempty"`
+	//
+	Suggestions []SuggestionView `json:",omitempty"`
 	//
 	//PaymentMethods *PaymentMethodsView `json:",omitempty"`
 }
@@ -122,6 +131,20 @@ type ShippingProfileOrderView struct {
 	Country  string `json:",omitempty"`
 }
 
+type PickupOrderView struct {
+	ID        uint
+	Name      string
+	Title     string
+	Address   string `json:",omitempty"`
+	Zip       string `json:",omitempty"`
+	City      string `json:",omitempty"`
+	Region    string `json:",omitempty"`
+	Country   string `json:",omitempty"`
+	Phone     string `json:",omitempty"`
+	Hours     string `json:",omitempty"`
+	Value float64
+}
+
 type CouponOrderView struct {
 	ID uint
 	Code string
@@ -147,6 +170,13 @@ type DeliveryView struct {
 	ByWeight float64  `json:",omitempty"`
 	Services []TransportServiceView `json:",omitempty"`
 	Value float64
+	Estimate *EstimateView `json:",omitempty"`
+}
+
+type EstimateView struct {
+	Ship string
+	From string `json:",omitempty"`
+	To string `json:",omitempty"`
 }
 
 type TransportServiceView struct {
@@ -176,6 +206,15 @@ type ItemShortView struct {
 	Total      float64             `json:",omitempty"`
 	Volume float64 `json:",omitempty"`
 	Weight float64 `json:",omitempty"`
+	Estimate *EstimateView `json:",omitempty"`
+	Components []BundleComponentShortView `json:",omitempty"`
+}
+
+type BundleComponentShortView struct {
+	ID uint `json:",omitempty"`
+	Title string `json:",omitempty"`
+	Quantity int `json:",omitempty"`
+	Optional bool `json:",omitempty"`
 }
 
 type VariationShortView struct {
@@ -237,7 +276,7 @@ func Checkout(request CheckoutRequest) (*models.Order, *OrderShortView, error){
 	}
 	// Shipping ShillingProfile
 	var shippingProfile *models.ShippingProfile
-	if request.ShippingProfileId > 0 {
+	if request.ShippingProfileId > 0 && request.PickupLocationId == 0 {
 		order.ShippingProfileId = request.ShippingProfileId
 		shippingProfile, err = models.GetShippingProfile(common.Database, request.ShippingProfileId)
 		if err == nil {
@@ -278,6 +317,8 @@ func Checkout(request CheckoutRequest) (*models.Order, *OrderShortView, error){
 	}
 	//
 	var itemsShortView []ItemShortView
+	var ship time.Time
+	var shipEstimate *common.EstimatePF // of item shipped the last
 	for _, rItem := range request.Items {
 		var arr []int
 		if err := json.Unmarshal([]byte(rItem.UUID), &arr); err == nil && len(arr) >= 2 {
@@ -288,13 +329,21 @@ func Checkout(request CheckoutRequest) (*models.Order, *OrderShortView, error){
 				logger.Warningf("Product #%+v not exists", productId)
 				continue
 			}
+			// Staged drafts are not purchasable until published
+			if product, err = models.GetPublishedProduct(common.Database, product); err != nil {
+				return nil, nil, err
+			}
+			if !product.IsPublished(now) {
+				return nil, nil, fmt.Errorf("product %v is not available", product.Title)
+			}
 			variationId := arr[1]
 			//var variation *models.Variation
 			var vId uint
 			var name string
 			var title string
 			var basePrice, salePrice, volume, weight float64
-			var start, end time.Time
+			var sale bool
+			timeId := product.TimeId
 			//var dimensions string
 			var prices []*models.Price
 			if variationId == 0 {
@@ -302,26 +351,37 @@ func Checkout(request CheckoutRequest) (*models.Order, *OrderShortView, error){
 				title = "Default"
 				basePrice = product.BasePrice
 				salePrice = product.SalePrice
-				start = product.Start
-				end = product.End
+				sale = product.IsSale(now)
 				volume = product.Volume
 				weight = product.Weight
 				if prices, err = models.GetPricesByProductId(common.Database, uint(productId)); err != nil {
 					logger.Warningf("%+v", err)
 				}
+				if product.Prices != nil {
+					// published snapshot
+					prices = product.Prices
+				}
 			} else {
 				if variation, err := models.GetVariation(common.Database, variationId); err == nil {
+					if variation, err = models.GetPublishedVariation(common.Database, variation); err != nil {
+						return nil, nil, err
+					}
 					if product.ID != variation.ProductId {
 						logger.Warningf("Product #%+v and Variation #%+v mismatch", productId, variationId)
 						continue
 					}
+					if !variation.IsPublished(now) {
+						return nil, nil, fmt.Errorf("product %v %v is not available", product.Title, variation.Title)
+					}
 					vId = variation.ID
+					if variation.TimeId > 0 {
+						timeId = variation.TimeId
+					}
 					name = variation.Name
 					title = variation.Title
 					basePrice = variation.BasePrice
 					salePrice = variation.SalePrice
-					start = variation.Start
-					end = variation.End
+					sale = variation.IsSale(now)
 					volume = product.Volume
 					weight = variation.Weight
 					//
@@ -347,7 +407,7 @@ func Checkout(request CheckoutRequest) (*models.Order, *OrderShortView, error){
 				BasePrice: basePrice,
 				Quantity: rItem.Quantity,
 			}
-			if salePrice > 0 && (end.IsZero() || (start.Before(now) && end.After(now))) {
+			if sale {
 				item.Price = salePrice * tax
 				item.SalePrice = salePrice * tax
 			}else{
@@ -356,6 +416,37 @@ func Checkout(request CheckoutRequest) (*models.Order, *OrderShortView, error){
 			item.VAT = vat
 			item.Volume = volume
 			item.Weight = weight
+			// [Bundle]
+			var components []*models.BundleComponent
+			if product.Bundle {
+				if components, err = selectBundleComponents(product.ID, rItem.Components); err != nil {
+					return nil, nil, err
+				}
+				basePrice, salePrice := models.GetBundlePrice(product, components, now)
+				item.BasePrice = basePrice
+				if salePrice > 0 {
+					item.Price = salePrice * tax
+					item.SalePrice = salePrice * tax
+				}else{
+					item.Price = basePrice * tax
+					item.SalePrice = 0
+				}
+				for _, component := range components {
+					if volume == 0 {
+						if component.Product != nil {
+							item.Volume += component.Product.Volume * float64(component.Quantity)
+						}
+					}
+					if weight == 0 {
+						if component.Variation != nil {
+							item.Weight += component.Variation.Weight * float64(component.Quantity)
+						} else if component.Product != nil {
+							item.Weight += component.Product.Weight * float64(component.Quantity)
+						}
+					}
+				}
+			}
+			// [/Bundle]
 			//
 			if breadcrumbs := models.GetBreadcrumbs(common.Database, categoryId); len(breadcrumbs) > 0 {
 				var chunks []string
@@ -520,6 +611,21 @@ func Checkout(request CheckoutRequest) (*models.Order, *OrderShortView, error){
 			itemShortView.Properties = propertiesShortView
 			itemShortView.Prices = pricesShortView
 			itemShortView.Coupons = couponsOrderView
+			for _, component := range components {
+				itemShortView.Components = append(itemShortView.Components, BundleComponentShortView{
+					ID: component.ID,
+					Title: component.Title(),
+					Quantity: component.Quantity,
+					Optional: component.Optional,
+				})
+			}
+			itemEstimate := estimateShipping(timeId, product.VendorId)
+			itemShip, _, _ := itemEstimate.Dates(now)
+			itemShortView.Estimate = &EstimateView{Ship: itemShip.Format("2006-01-02")}
+			if itemShip.After(ship) {
+				ship = itemShip
+				shipEstimate = itemEstimate
+			}
 			if bts, err := json.Marshal(itemShortView); err == nil {
 				item.Description = string(bts)
 			}
@@ -531,12 +637,80 @@ func Checkout(request CheckoutRequest) (*models.Order, *OrderShortView, error){
 			order.Weight += item.Weight
 			order.Sum += item.Total
 			order.Discount += item.Discount
+			// Component lines are used for fulfilment only, price is included into bundle line
+			for _, component := range components {
+				line := &models.Item{
+					Uuid: fmt.Sprintf("[%d,%d]", component.ProductId, component.VariationId),
+					ProductId: component.ProductId,
+					VariationId: component.VariationId,
+					Title: component.Title(),
+					Quantity: component.Quantity * item.Quantity,
+					BundleId: product.ID,
+				}
+				if cache, err := models.GetCacheProductByProductId(common.Database, component.ProductId); err == nil {
+					line.Path = cache.Path
+					line.Thumbnail = cache.Thumbnail
+				}
+				order.Items = append(order.Items, line)
+			}
+		}
+	}
+	var estimate *EstimateView
+	// [Pickup]
+	var pickupsView []PickupLocationView
+	var pickupView *PickupOrderView
+	if request.PickupLocationId > 0 {
+		location, err := models.GetPickupLocation(common.Database, int(request.PickupLocationId))
+		if err != nil {
+			return nil, nil, err
+		}
+		if !PickupAvailable(location) {
+			return nil, nil, fmt.Errorf("pickup location %v is not available", location.Title)
+		}
+		value := location.Fee * tax
+		if location.Free > 0 && order.Sum > location.Free {
+			value = 0
+		}
+		pickupView = &PickupOrderView{
+			ID: location.ID,
+			Name: location.Name,
+			Title: location.Title,
+			Address: location.Address,
+			Zip: location.Zip,
+			City: location.City,
+			Region: location.Region,
+			Country: location.Country,
+			Phone: location.Phone,
+			Hours: location.Hours,
+			Value: math.Round(value * 100) / 100,
+		}
+		order.PickupLocationId = location.ID
+		order.PickupLocationTitle = location.Title
+		order.Delivery = pickupView.Value
+		estimate = newEstimateView(ship, shipEstimate, 0, 0)
+	} else if locations, err := models.GetPickupLocations(common.Database); err == nil {
+		for _, location := range locations {
+			if PickupAvailable(location) {
+				var pickupLocationView PickupLocationView
+				if bts, err := json.Marshal(location); err == nil {
+					if err = json.Unmarshal(bts, &pickupLocationView); err != nil {
+						logger.Warningf("%+v", err)
+					}
+				}
+				pickupLocationView.Fee = location.Fee * tax
+				if location.Free > 0 && order.Sum > location.Free {
+					pickupLocationView.Fee = 0
+				}
+				pickupLocationView.Fee = math.Round(pickupLocationView.Fee * 100) / 100
+				pickupsView = append(pickupsView, pickupLocationView)
+			}
 		}
 	}
+	// [/Pickup]
 	// Transports
 	var deliveriesShortView []DeliveryView
 	var shippingView *ShippingOrderView
-	if transports, err := models.GetTransports(common.Database); err == nil {
+	if transports, err := models.GetTransports(common.Database); err == nil && request.PickupLocationId == 0 {
 		for _, transport := range transports {
 			// All available transports OR selected
 			if transport.Enabled && (order.Volume >= transport.Volume || order.Weight >= transport.Weight) && (transport.ID == request.TransportId || request.TransportId == 0) {
@@ -630,6 +804,9 @@ func Checkout(request CheckoutRequest) (*models.Order, *OrderShortView, error){
 				// [Delivery]
 				var delivery float64
 				for _, item := range order.Items {
+					if item.BundleId > 0 {
+						continue
+					}
 					if itemIsPercent {
 						delivery += (item.Price * itemPercent / 100.0) * float64(item.Quantity)
 					}else{
@@ -711,6 +888,7 @@ func Checkout(request CheckoutRequest) (*models.Order, *OrderShortView, error){
 					//
 					order.TransportId = request.TransportId
 					order.Delivery = math.Round(value * 100) / 100
+					estimate = newEstimateView(ship, shipEstimate, transport.TransitMin, transport.TransitMax)
 				}else{
 					deliveryView := DeliveryView{
 						ID:        transport.ID,
@@ -721,6 +899,9 @@ func Checkout(request CheckoutRequest) (*models.Order, *OrderShortView, error){
 						Services: services,
 						Value: math.Round(value * 100) / 100,
 					}
+					if !ship.IsZero() {
+						deliveryView.Estimate = newEstimateView(ship, shipEstimate, transport.TransitMin, transport.TransitMax)
+					}
 					if cache, err := models.GetCacheTransportByTransportId(common.Database, transport.ID); err == nil {
 						deliveryView.Thumbnail = cache.Thumbnail
 					}
@@ -912,6 +1093,14 @@ func Checkout(request CheckoutRequest) (*models.Order, *OrderShortView, error){
 			//
 			view.Billing = billingView
 			view.Shipping = shippingView
+			view.Pickups = pickupsView
+			view.Pickup = pickupView
+			if estimate != nil {
+				view.Estimate = estimate
+			} else if !ship.IsZero() {
+				view.Estimate = &EstimateView{Ship: ship.Format("2006-01-02")}
+			}
+			view.Suggestions = GetSuggestions(order.Items, tax)
 			//
 			//view.PaymentMethods = paymentMethodsView
 			view.Payments = paymentsShortView
@@ -927,4 +1116,38 @@ func Checkout(request CheckoutRequest) (*models.Order, *OrderShortView, error){
 	}
 	// [/Order Description]
 	return order, view, nil
-}
\ No newline at end of file
+}
+// estimateShipping returns lead time of item with vendor's cutoff and holidays, vendor's times are used for items
+// without own lead time
+func estimateShipping(timeId, vendorId uint) *common.EstimatePF {
+	var t *models.Time
+	if timeId > 0 {
+		if v, err := models.GetTime(common.Database, int(timeId)); err == nil {
+			t = v
+		}
+	}
+	var cutoff, holidays string
+	if vendorId > 0 {
+		if vendor, err := models.GetVendor(common.Database, int(vendorId)); err == nil {
+			cutoff = vendor.Cutoff
+			holidays = vendor.Holidays
+		}
+	}
+	return common.NewEstimatePF(models.GetLeadTime(common.Database, t, vendorId), cutoff, holidays, 0, 0)
+}
+
+// newEstimateView adds transit days to ship date by calendar of vendor shipping the last item
+func newEstimateView(ship time.Time, estimate *common.EstimatePF, transitMin, transitMax int) *EstimateView {
+	if ship.IsZero() || estimate == nil {
+		return nil
+	}
+	calendar := estimate.Calendar()
+	if transitMax < transitMin {
+		transitMax = transitMin
+	}
+	return &EstimateView{
+		Ship: ship.Format("2006-01-02"),
+		From: calendar.AddBusinessDays(ship, transitMin).Format("2006-01-02"),
+		To: calendar.AddBusinessDays(ship, transitMax).Format("2006-01-02"),
+	}
+}
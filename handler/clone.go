@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"gorm.io/gorm"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	CLONE_MEDIA_SHARE = "share"
+	CLONE_MEDIA_DUPLICATE = "duplicate"
+)
+
+type NewClone struct {
+	Name string // unique name, "<name>-copy" by default
+	Title string
+	Enabled bool
+	Sku struct {
+		Find string
+		Replace string
+		Prefix string
+		Suffix string
+	}
+	Media string // share (default) or duplicate image and file records
+}
+
+// @security BasicAuth
+// CloneProduct godoc
+// @Summary Clone product with variations, properties, prices, parameters and media
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param request body NewClone true "body"
+// @Success 200 {object} ProductShortView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/products/{id}/clone [post]
+// @Tags product
+func postProductCloneHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	product, err := models.GetProduct(common.Database, id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	var request NewClone
+	if contentType := string(c.Request().Header.ContentType()); contentType != "" {
+		if strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+			if err := c.BodyParser(&request); err != nil {
+				return err
+			}
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{"Unsupported Content-Type"})
+		}
+	}
+	options := models.CloneOptions{
+		Name: strings.TrimSpace(request.Name),
+		Title: strings.TrimSpace(request.Title),
+		Enabled: request.Enabled,
+	}
+	if options.Title == "" {
+		options.Title = product.Title
+	}
+	if rule := request.Sku; rule.Find != "" || rule.Prefix != "" || rule.Suffix != "" {
+		options.Sku = func(sku string) string {
+			if rule.Find != "" {
+				sku = strings.Replace(sku, rule.Find, rule.Replace, -1)
+			}
+			return rule.Prefix + sku + rule.Suffix
+		}
+	}
+	var copies storageCopies
+	switch request.Media {
+	case "", CLONE_MEDIA_SHARE:
+	case CLONE_MEDIA_DUPLICATE:
+		options.Image = copies.image
+		options.File = copies.file
+	default:
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{fmt.Sprintf("Unknown media mode %v", request.Media)})
+	}
+	clone, err := models.CloneProduct(common.Database, product.ID, options)
+	if err != nil {
+		copies.remove()
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	owners := []revisionOwner{{ObjectType: models.REVISION_OBJECT_PRODUCT, ObjectId: clone.ID}}
+	if variations, err := models.GetProductVariations(common.Database, int(clone.ID)); err == nil {
+		for _, variation := range variations {
+			owners = append(owners, revisionOwner{ObjectType: models.REVISION_OBJECT_VARIATION, ObjectId: variation.ID})
+		}
+	}
+	reindexOwners(ownerRevisions(common.Database, owners, false, fmt.Sprintf("cloned from #%v", product.ID), userOf(c)))
+	if err = MarkChanged("product cloned"); err != nil {
+		logger.Warningf("%v", err)
+	}
+	return c.JSON(ProductShortView{ID: clone.ID, Name: clone.Name, Title: clone.Title})
+}
+
+// storageCopies keeps storage files copied for cloned records to remove them if transaction is rolled back
+type storageCopies []string
+
+// image creates new image record with own copy of original file
+func (copies *storageCopies) image(tx *gorm.DB, image *models.Image) (*models.Image, error) {
+	clone := *image
+	clone.Model = gorm.Model{}
+	if _, err := models.CreateImage(tx, &clone); err != nil {
+		return nil, err
+	}
+	if image.Path != "" {
+		filename := fmt.Sprintf("%d-%s%s", clone.ID, clone.Name, path.Ext(image.Path))
+		if err := copies.copy(image.Path, path.Join("images", filename)); err != nil {
+			return nil, err
+		}
+		clone.Path = "/" + path.Join("images", filename)
+		if err := models.UpdateImage(tx, &clone); err != nil {
+			return nil, err
+		}
+	}
+	return &clone, nil
+}
+
+func (copies *storageCopies) file(tx *gorm.DB, file *models.File) (*models.File, error) {
+	clone := *file
+	clone.Model = gorm.Model{}
+	if _, err := models.CreateFile(tx, &clone); err != nil {
+		return nil, err
+	}
+	if file.Path != "" {
+		filename := fmt.Sprintf("%d-%s%s", clone.ID, clone.Name, path.Ext(file.Path))
+		if err := copies.copy(file.Path, path.Join("files", filename)); err != nil {
+			return nil, err
+		}
+		clone.Path = "/" + path.Join("files", filename)
+		clone.Url = common.Config.Base + "/" + path.Join("files", filename)
+		if err := models.UpdateFile(tx, &clone); err != nil {
+			return nil, err
+		}
+	}
+	return &clone, nil
+}
+
+func (copies *storageCopies) copy(src, dst string) error {
+	src = path.Join(dir, "storage", src)
+	if _, err := os.Stat(src); err != nil {
+		// nothing to copy, record is duplicated anyway
+		logger.Warningf("%v", err)
+		return nil
+	}
+	dst = path.Join(dir, "storage", dst)
+	*copies = append(*copies, dst)
+	return common.Copy(src, dst)
+}
+
+func (copies storageCopies) remove() {
+	for _, p := range copies {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			logger.Warningf("%v", err)
+		}
+	}
+}
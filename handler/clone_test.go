@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/yonnic/goshop/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCloneProduct_TranslationsAndRelations(t *testing.T) {
+	db := openCatalogDatabase(t)
+	product := &models.Product{Enabled: true, Name: "shirt", Title: "Shirt"}
+	if _, err := models.CreateProduct(db, product); err != nil {
+		t.Fatalf("%v", err)
+	}
+	variation := &models.Variation{Enabled: true, Name: "red", Title: "Red", ProductId: product.ID}
+	if _, err := models.CreateVariation(db, variation); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := models.SetTranslations(db, models.TRANSLATION_OBJECT_PRODUCT, product.ID, "de", map[string]string{"Title": "Hemd"}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := models.SetTranslations(db, models.TRANSLATION_OBJECT_VARIATION, variation.ID, "de", map[string]string{"Title": "Rot"}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	var others []*models.Product
+	for _, name := range []string{"pants", "socks", "hat"} {
+		other := &models.Product{Enabled: true, Name: name, Title: name}
+		if _, err := models.CreateProduct(db, other); err != nil {
+			t.Fatalf("%v", err)
+		}
+		others = append(others, other)
+	}
+	for _, relation := range []*models.ProductRelation{
+		{ProductId: product.ID, RelatedId: others[0].ID, Type: "similar"},
+		{ProductId: others[1].ID, RelatedId: product.ID, Type: "accessory", Bidirectional: true},
+		{ProductId: others[2].ID, RelatedId: product.ID, Type: "similar"},
+	}{
+		if _, err := models.CreateProductRelation(db, relation); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	app := fiber.New()
+	app.Post("/api/v1/products/:id/clone", postProductCloneHandler)
+	req := httptest.NewRequest("POST", fmt.Sprintf("/api/v1/products/%v/clone", product.ID), strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", fiber.MIMEApplicationJSON)
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status %v", res.StatusCode)
+	}
+	var view ProductShortView
+	if err = json.NewDecoder(res.Body).Decode(&view); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if view.Name != "shirt-copy" {
+		t.Errorf("name %v, expected shirt-copy", view.Name)
+	}
+	// Translations
+	if translations, err := models.GetTranslations(db, models.TRANSLATION_OBJECT_PRODUCT, view.ID); err != nil || len(translations) != 1 || translations[0].Value != "Hemd" {
+		t.Errorf("unexpected product translations: %+v %v", translations, err)
+	}
+	variations, err := models.GetProductVariations(db, int(view.ID))
+	if err != nil || len(variations) != 1 {
+		t.Fatalf("unexpected variations: %+v %v", variations, err)
+	}
+	if translations, err := models.GetTranslations(db, models.TRANSLATION_OBJECT_VARIATION, variations[0].ID); err != nil || len(translations) != 1 || translations[0].Value != "Rot" {
+		t.Errorf("unexpected variation translations: %+v %v", translations, err)
+	}
+	if translations, err := models.GetTranslations(db, models.TRANSLATION_OBJECT_VARIATION, variation.ID); err != nil || len(translations) != 1 {
+		t.Errorf("source translations changed: %+v %v", translations, err)
+	}
+	// Relations, one way incoming relation of other product is not copied
+	relations, err := models.GetProductRelations(db, view.ID)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	related := make(map[uint]string)
+	for _, relation := range relations {
+		id, _ := relation.Other(view.ID)
+		related[id] = relation.Type
+	}
+	if len(related) != 2 || related[others[0].ID] != "similar" || related[others[1].ID] != "accessory" {
+		t.Errorf("unexpected relations: %+v", related)
+	}
+	if relations, err := models.GetProductRelations(db, product.ID); err != nil || len(relations) != 2 {
+		t.Errorf("source relations changed: %+v %v", relations, err)
+	}
+}
@@ -665,6 +665,7 @@ func delCommentHandler(c *fiber.Ctx) error {
 		}
 	}
 	if err := models.DeleteComment(common.Database, comment); err == nil {
+		touchProducts(comment.ProductId)
 		return c.JSON(HTTPMessage{MESSAGE: "OK"})
 	}else{
 		c.Status(http.StatusInternalServerError)
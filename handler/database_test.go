@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"testing"
+)
+
+// openTestDatabase replaces common.Database by in-memory database with tables of values for the time of test
+func openTestDatabase(t *testing.T, values ...interface{}) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%v?mode=memory&cache=shared", t.Name())), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err = db.AutoMigrate(values...); err != nil {
+		t.Fatalf("%v", err)
+	}
+	previous := common.Database
+	common.Database = db
+	t.Cleanup(func() {
+		common.Database = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// openCatalogDatabase opens test database with tables of products, their parts, revisions and search index
+func openCatalogDatabase(t *testing.T, values ...interface{}) *gorm.DB {
+	db := openTestDatabase(t, values...)
+	for _, value := range []interface{}{&models.Category{}, &models.Product{}, &models.Parameter{}, &models.File{}, &models.Image{},
+		&models.Variation{}, &models.Property{}, &models.Option{}, &models.Value{}, &models.Rate{}, &models.Price{}, &models.Tag{},
+		&models.Time{}, &models.Vendor{}, &models.Revision{}, &models.Translation{}, &models.ProductRelation{}} {
+		// index names are shared by tables in sqlite, so migration of dependent table stops at index of its dependency
+		if err := db.AutoMigrate(value); err != nil && !db.Migrator().HasTable(value) {
+			db.Migrator().CreateTable(value)
+		}
+	}
+	// join table of prices is created after their indexes
+	db.Exec("CREATE TABLE IF NOT EXISTS prices_rates (price_id integer, rate_id integer, PRIMARY KEY (price_id, rate_id))")
+	if err := models.InitSearch(db); err != nil {
+		t.Fatalf("%v", err)
+	}
+	previous := dir
+	dir = t.TempDir()
+	t.Cleanup(func() {
+		dir = previous
+	})
+	return db
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+)
+
+const (
+	DEFAULT_DOWNLOAD_EXPIRATION = 72 // hours
+	DEFAULT_DOWNLOAD_LIMIT = 5
+)
+
+var ErrDownloadsSecret = errors.New("downloads secret is not configured")
+
+type DownloadView struct {
+	ID uint
+	Name string
+	Type string `json:",omitempty"`
+	Size int64 `json:",omitempty"`
+	Url string
+	Count int
+	Quota int `json:",omitempty"`
+	Expires time.Time
+}
+
+// GetOrderDownloads issues downloads for purchasable files of paid order items and returns signed links
+func GetOrderDownloads(order *models.Order) ([]DownloadView, error) {
+	views := []DownloadView{}
+	switch order.Status {
+	case models.ORDER_STATUS_NEW, models.ORDER_STATUS_WAITING_FROM_PAYMENT, models.ORDER_STATUS_CANCELED, "":
+		return views, nil
+	}
+	downloads, err := models.GetDownloadsByOrderId(common.Database, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	expiration := common.Config.Downloads.Expiration
+	if expiration == 0 {
+		expiration = DEFAULT_DOWNLOAD_EXPIRATION
+	}
+	limit := common.Config.Downloads.Limit
+	if limit == 0 {
+		limit = DEFAULT_DOWNLOAD_LIMIT
+	}
+	for _, item := range order.Items {
+		var files []*models.File
+		if item.VariationId > 0 {
+			if variation, err := models.GetVariation(common.Database, int(item.VariationId)); err == nil {
+				if files, err = models.GetFilesOfVariation(common.Database, variation); err != nil {
+					logger.Warningf("%+v", err)
+				}
+			}
+		}
+		if product, err := models.GetProduct(common.Database, int(item.ProductId)); err == nil {
+			if files2, err := models.GetFilesOfProduct(common.Database, product); err == nil {
+				files = append(files, files2...)
+			}else{
+				logger.Warningf("%+v", err)
+			}
+		}
+		for _, file := range files {
+			if !file.Download {
+				continue
+			}
+			var found bool
+			for _, download := range downloads {
+				if download.FileId == file.ID {
+					found = true
+					break
+				}
+			}
+			if found {
+				continue
+			}
+			download := &models.Download{OrderId: order.ID, ItemId: item.ID, FileId: file.ID, File: file, Quota: limit, Expires: time.Now().Add(time.Duration(expiration) * time.Hour)}
+			if file.Limit > 0 {
+				download.Quota = file.Limit
+			}
+			if _, err = models.CreateDownload(common.Database, download); err != nil {
+				return nil, err
+			}
+			downloads = append(downloads, download)
+		}
+	}
+	for _, download := range downloads {
+		if download.File == nil {
+			continue
+		}
+		signature, err := signDownload(common.Config.Downloads.Secret, download.ID, download.Expires.Unix())
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, DownloadView{
+			ID: download.ID,
+			Name: download.File.Name,
+			Type: download.File.Type,
+			Size: download.File.Size,
+			Url: fmt.Sprintf("%v/api/v1/downloads/%d?expires=%d&signature=%v", common.Config.Base, download.ID, download.Expires.Unix(), signature),
+			Count: download.Count,
+			Quota: download.Quota,
+			Expires: download.Expires,
+		})
+	}
+	return views, nil
+}
+
+// signDownload refuses to sign without secret, links signed by well known key could be forged
+func signDownload(secret string, id uint, expires int64) (string, error) {
+	if secret == "" {
+		return "", ErrDownloadsSecret
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%d:%d", id, expires)))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// GetDownload godoc
+// @Summary Download purchased file by signed link
+// @Produce octet-stream
+// @Param id path int true "Download ID"
+// @Param expires query int true "Expiration unix time"
+// @Param signature query string true "Signature"
+// @Success 200 {file} file
+// @Failure 403 {object} HTTPError
+// @Failure 404 {object} HTTPError
+// @Failure 410 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/downloads/{id} [get]
+// @Tags frontend
+func getDownloadHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+	signature, err := signDownload(common.Config.Downloads.Secret, uint(id), expires)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	if !hmac.Equal([]byte(signature), []byte(c.Query("signature"))) {
+		c.Status(http.StatusForbidden)
+		return c.JSON(HTTPError{"Invalid signature"})
+	}
+	if time.Now().Unix() > expires {
+		c.Status(http.StatusGone)
+		return c.JSON(HTTPError{"Link expired"})
+	}
+	download, err := models.GetDownload(common.Database, id)
+	if err != nil || download.File == nil {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{"Download not found"})
+	}
+	if download.Expires.Unix() != expires {
+		c.Status(http.StatusForbidden)
+		return c.JSON(HTTPError{"Invalid signature"})
+	}
+	if common.DOWNLOADS == nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{"Downloads storage is not configured"})
+	}
+	reader, err := common.DOWNLOADS.ReadFile(download.File.Path)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	if ok, err := models.IncreaseDownloadCount(common.Database, download); err != nil {
+		reader.Close()
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}else if !ok {
+		reader.Close()
+		c.Status(http.StatusGone)
+		return c.JSON(HTTPError{"Download limit reached"})
+	}
+	if download.File.Type != "" {
+		c.Set(fiber.HeaderContentType, download.File.Type)
+	}else{
+		c.Set(fiber.HeaderContentType, fiber.MIMEOctetStream)
+	}
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%v"`, path.Base(download.File.Path)))
+	// fasthttp closes reader when stream is written
+	if download.File.Size > 0 {
+		return c.SendStream(reader, int(download.File.Size))
+	}
+	return c.SendStream(reader)
+}
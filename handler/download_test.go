@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSignDownload(t *testing.T) {
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("12:1630000000"))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	for _, example := range []struct{
+		Name string
+		Secret string
+		ID uint
+		Expires int64
+		Equal bool
+		Err bool
+	}{
+		{Name: "same link", Secret: "secret", ID: 12, Expires: 1630000000, Equal: true},
+		{Name: "other download", Secret: "secret", ID: 13, Expires: 1630000000},
+		{Name: "extended expiration", Secret: "secret", ID: 12, Expires: 1630000001},
+		{Name: "other instance", Secret: "secret2", ID: 12, Expires: 1630000000},
+		{Name: "no secret", Secret: "", ID: 12, Expires: 1630000000, Err: true},
+	}{
+		signature, err := signDownload(example.Secret, example.ID, example.Expires)
+		if example.Err {
+			if err != ErrDownloadsSecret {
+				t.Errorf("%v: expected %v, got %v", example.Name, ErrDownloadsSecret, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", example.Name, err)
+			continue
+		}
+		if (signature == expected) != example.Equal {
+			t.Errorf("%v: signature %v, expected equal %v", example.Name, signature, example.Equal)
+		}
+	}
+}
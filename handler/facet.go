@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"gorm.io/gorm"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const FILTER_FACET_BUCKETS = 5
+
+type FilterFacetsView struct {
+	Options []FilterOptionFacetView `json:",omitempty"`
+	Tags []FilterValueFacetView `json:",omitempty"`
+	Vendors []FilterValueFacetView `json:",omitempty"`
+	Ranges []FilterRangeFacetView `json:",omitempty"`
+}
+
+type FilterOptionFacetView struct {
+	Key string // filter key, Option-<ID>
+	ID uint
+	Title string
+	Values []FilterValueFacetView
+}
+
+type FilterValueFacetView struct {
+	ID uint
+	Title string
+	Thumbnail string `json:",omitempty"`
+	Color string `json:",omitempty"`
+	Count int64 // products matching the rest of filter with this value
+	Selected bool `json:",omitempty"`
+}
+
+type FilterRangeFacetView struct {
+	Key string // filter key, Price, BasePrice, SalePrice, Width, Height, Depth or Weight
+	Min float64
+	Max float64
+	Buckets []FilterBucketView `json:",omitempty"`
+}
+
+type FilterBucketView struct {
+	Value string // filter value, <min>-<max>
+	Min float64
+	Max float64
+	Count int64
+}
+
+// filterFacets counts products for every facet against the current filter minus the facet itself, relPath limits products to the category,
+// values are taken from render cache so staged drafts are not counted
+func filterFacets(request FilterRequest, relPath string) *FilterFacetsView {
+	facets := &FilterFacetsView{}
+	universe := common.Database.Model(&models.CacheProduct{}).Select("cache_products.Product_Id").Where("cache_products.Path LIKE ?", relPath + "%")
+	// Options of filterable parameters and properties
+	var optionIds []uint
+	if err := common.Database.Debug().Model(&models.CacheFacet{}).Distinct("option_id").Where("type = ? and filtering = ?", models.CACHE_FACET_OPTION, true).Pluck("option_id", &optionIds).Error; err != nil {
+		logger.Warningf("%+v", err)
+	}
+	sort.Slice(optionIds, func(i, j int) bool { return optionIds[i] < optionIds[j] })
+	for _, optionId := range optionIds {
+		option, err := models.GetOption(common.Database, int(optionId))
+		if err != nil {
+			continue
+		}
+		key := fmt.Sprintf("Option-%d", optionId)
+		var counts []struct {
+			ID uint
+			Count int64
+		}
+		if err = common.Database.Debug().Raw("select cache_facets.value_id as id, count(distinct case when cache_facets.product_id in (?) then cache_facets.product_id end) as count from cache_facets where cache_facets.type = ? and cache_facets.option_id = ? and cache_facets.filtering = ? and cache_facets.product_id in (?) group by cache_facets.value_id",
+			filterMatching(request, key, relPath), models.CACHE_FACET_OPTION, optionId, true, universe).Scan(&counts).Error; err != nil {
+			logger.Warningf("%+v", err)
+			continue
+		}
+		if len(counts) == 0 {
+			continue
+		}
+		selected := filterSelected(request, key)
+		var ids []uint
+		for _, count := range counts {
+			ids = append(ids, count.ID)
+		}
+		var values []*models.Value
+		if err = common.Database.Debug().Where("id in ?", ids).Order("Sort asc, ID asc").Find(&values).Error; err != nil {
+			logger.Warningf("%+v", err)
+			continue
+		}
+		view := FilterOptionFacetView{Key: key, ID: option.ID, Title: option.Title}
+		for _, value := range values {
+			item := FilterValueFacetView{ID: value.ID, Title: value.Title, Thumbnail: value.Thumbnail, Color: value.Color, Selected: selected[value.ID]}
+			for _, count := range counts {
+				if count.ID == value.ID {
+					item.Count = count.Count
+					break
+				}
+			}
+			view.Values = append(view.Values, item)
+		}
+		facets.Options = append(facets.Options, view)
+	}
+	// Tags
+	var tags []struct {
+		ID uint
+		Title string
+		Thumbnail string
+		Count int64
+	}
+	if err := common.Database.Debug().Raw("select tags.id as id, tags.title as title, tags.thumbnail as thumbnail, count(distinct case when cache_facets.product_id in (?) then cache_facets.product_id end) as count from tags inner join cache_facets on cache_facets.value_id = tags.id and cache_facets.type = ? where tags.enabled = ? and tags.hidden = ? and tags.deleted_at is null and cache_facets.product_id in (?) group by tags.id, tags.title, tags.thumbnail order by tags.title asc",
+		filterMatching(request, "Tag", relPath), models.CACHE_FACET_TAG, true, false, universe).Scan(&tags).Error; err == nil {
+		selected := filterSelected(request, "Tag")
+		for _, tag := range tags {
+			facets.Tags = append(facets.Tags, FilterValueFacetView{ID: tag.ID, Title: tag.Title, Thumbnail: tag.Thumbnail, Count: tag.Count, Selected: selected[tag.ID]})
+		}
+	}else{
+		logger.Warningf("%+v", err)
+	}
+	// Vendors
+	var vendors []struct {
+		ID uint
+		Title string
+		Thumbnail string
+		Count int64
+	}
+	if err := common.Database.Debug().Raw("select vendors.id as id, vendors.title as title, vendors.thumbnail as thumbnail, count(distinct case when cache_facets.product_id in (?) then cache_facets.product_id end) as count from vendors inner join cache_facets on cache_facets.value_id = vendors.id and cache_facets.type = ? where vendors.enabled = ? and vendors.deleted_at is null and cache_facets.product_id in (?) group by vendors.id, vendors.title, vendors.thumbnail order by vendors.title asc",
+		filterMatching(request, "Vendor", relPath), models.CACHE_FACET_VENDOR, true, universe).Scan(&vendors).Error; err == nil {
+		selected := filterSelected(request, "Vendor")
+		for _, vendor := range vendors {
+			facets.Vendors = append(facets.Vendors, FilterValueFacetView{ID: vendor.ID, Title: vendor.Title, Thumbnail: vendor.Thumbnail, Count: vendor.Count, Selected: selected[vendor.ID]})
+		}
+	}else{
+		logger.Warningf("%+v", err)
+	}
+	// Ranges
+	for _, key := range []string{"Price", "BasePrice", "SalePrice", "Width", "Height", "Depth", "Weight"} {
+		var rows []struct {
+			BasePrice float64
+			SalePrice float64
+			Width float64
+			Height float64
+			Depth float64
+			Weight float64
+		}
+		if err := common.Database.Debug().Model(&models.CacheProduct{}).Select("cache_products.Base_Price as base_price, cache_products.Sale_Price as sale_price, cache_products.Width as width, cache_products.Height as height, cache_products.Depth as depth, cache_products.Weight as weight").Where("cache_products.Product_Id in (?)", filterMatching(request, key, relPath)).Scan(&rows).Error; err != nil {
+			logger.Warningf("%+v", err)
+			continue
+		}
+		// every product gives one or two points, price range includes sale price
+		var points [][]float64
+		for _, row := range rows {
+			var values []float64
+			switch key {
+			case "Price":
+				values = []float64{row.BasePrice}
+				if row.SalePrice > 0 {
+					values = append(values, row.SalePrice)
+				}
+			case "BasePrice":
+				values = []float64{row.BasePrice}
+			case "SalePrice":
+				values = []float64{row.SalePrice}
+			case "Width":
+				values = []float64{row.Width}
+			case "Height":
+				values = []float64{row.Height}
+			case "Depth":
+				values = []float64{row.Depth}
+			case "Weight":
+				values = []float64{row.Weight}
+			}
+			var nonzero []float64
+			for _, value := range values {
+				if value > 0 {
+					nonzero = append(nonzero, value)
+				}
+			}
+			if len(nonzero) > 0 {
+				points = append(points, nonzero)
+			}
+		}
+		if view := newRangeFacet(key, points); view != nil {
+			facets.Ranges = append(facets.Ranges, *view)
+		}
+	}
+	return facets
+}
+
+// filterMatching is a subquery of product ids matching filter without exclude key
+func filterMatching(request FilterRequest, exclude string, relPath string) *gorm.DB {
+	keys, values, _ := filterConditions(request.Filter, exclude)
+	keys = append(keys, "cache_products.Path LIKE ?")
+	values = append(values, relPath + "%")
+	return common.Database.Model(&models.CacheProduct{}).Select("cache_products.Product_Id").Where(strings.Join(keys, " and "), values...)
+}
+
+func filterSelected(request FilterRequest, key string) map[uint]bool {
+	selected := make(map[uint]bool)
+	if value, found := request.Filter[key]; found {
+		for _, v := range strings.Split(value, ",") {
+			if id, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				selected[uint(id)] = true
+			}
+		}
+	}
+	return selected
+}
+
+// newRangeFacet splits range to equal buckets, product is counted once in bucket even if several of its points are there
+func newRangeFacet(key string, points [][]float64) *FilterRangeFacetView {
+	if len(points) == 0 {
+		return nil
+	}
+	view := &FilterRangeFacetView{Key: key, Min: math.MaxFloat64}
+	for _, values := range points {
+		for _, value := range values {
+			view.Min = math.Min(view.Min, value)
+			view.Max = math.Max(view.Max, value)
+		}
+	}
+	view.Min = math.Floor(view.Min)
+	view.Max = math.Ceil(view.Max)
+	if view.Max <= view.Min {
+		return view
+	}
+	step := math.Ceil((view.Max - view.Min) / FILTER_FACET_BUCKETS)
+	for from := view.Min; from < view.Max; from += step {
+		to := math.Min(from + step, view.Max)
+		bucket := FilterBucketView{Value: fmt.Sprintf("%v-%v", from, to), Min: from, Max: to}
+		for _, values := range points {
+			for _, value := range values {
+				if value >= from && (value < to || (to == view.Max && value <= to)) {
+					bucket.Count++
+					break
+				}
+			}
+		}
+		view.Buckets = append(view.Buckets, bucket)
+	}
+	return view
+}
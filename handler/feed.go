@@ -0,0 +1,448 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	reFeedTags = regexp.MustCompile(`<.*?>`)
+	reFeedSpace = regexp.MustCompile(`\s{2,}`)
+	FacebookFeedHeader = []string{"id", "item_group_id", "title", "description", "availability", "condition", "price", "sale_price", "sale_price_effective_date", "link", "image_link", "additional_image_link", "brand", "gtin", "mpn", "product_type", "shipping_weight"}
+)
+
+// FeedItem is one purchasable variant: product default variation, variation or price combination
+type FeedItem struct {
+	Id string
+	GroupId string
+	Title string
+	Description string
+	Link string
+	ImageLink string
+	AdditionalImageLinks []string
+	Availability string // in_stock, out_of_stock, preorder, backorder
+	Currency string
+	Price float64
+	SalePrice float64
+	SaleStart time.Time
+	SaleEnd time.Time
+	Sku string
+	Gtin string
+	Brand string
+	ProductType string
+	Weight float64
+	WeightUnit string
+}
+
+// SalePriceEffectiveDate returns ISO 8601 interval or empty string if sale is not limited in time
+func (item *FeedItem) SalePriceEffectiveDate() string {
+	if item.SalePrice == 0 || item.SaleStart.IsZero() || item.SaleEnd.IsZero() {
+		return ""
+	}
+	return item.SaleStart.Format("2006-01-02T15:04-0700") + "/" + item.SaleEnd.Format("2006-01-02T15:04-0700")
+}
+
+// @security BasicAuth
+// GetGoogleFeed godoc
+// @Summary Get Google Merchant Center products feed
+// @Produce application/xml
+// @Success 200 {string} string
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/feeds/google.xml [get]
+// @Tags feed
+func getGoogleFeedHandler(c *fiber.Ctx) error {
+	items, err := GetFeedItems(common.Database)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	c.Set(fiber.HeaderContentType, "application/xml; charset=utf-8")
+	if err = WriteGoogleFeed(c.Response().BodyWriter(), items); err != nil {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		c.Response().ResetBody()
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	return nil
+}
+
+// @security BasicAuth
+// GetFacebookFeed godoc
+// @Summary Get Facebook catalog products feed
+// @Produce text/csv
+// @Success 200 {string} string
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/feeds/facebook.csv [get]
+// @Tags feed
+func getFacebookFeedHandler(c *fiber.Ctx) error {
+	items, err := GetFeedItems(common.Database)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	if err = WriteFacebookFeed(c.Response().BodyWriter(), items); err != nil {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		c.Response().ResetBody()
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	return nil
+}
+
+// GetFeedItems collects one item per purchasable variant of every enabled product as it was published, links and images are taken
+// from render cache
+func GetFeedItems(connector *gorm.DB) ([]*FeedItem, error) {
+	products, err := models.GetProducts(connector)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	currency := strings.ToUpper(common.Config.Currency)
+	if currency == "" {
+		currency = "USD"
+	}
+	var items []*FeedItem
+	for _, product := range products {
+		if product, err = models.GetPublishedProductFull(connector, int(product.ID)); err != nil {
+			logger.Warningf("%+v", err)
+			continue
+		}
+		if !product.IsPublished(now) {
+			continue
+		}
+		base := &FeedItem{
+			Title: product.Title,
+			Description: feedDescription(product.Description),
+			Link: feedProductLink(connector, product),
+			Currency: currency,
+			Gtin: feedGtin(product.CustomParameters),
+			ProductType: feedProductType(connector, product),
+		}
+		if base.Description == "" {
+			base.Description = product.Title
+		}
+		if product.Vendor != nil {
+			base.Brand = product.Vendor.Title
+		}
+		productImages := feedImages(connector, product.Images)
+		variations := product.Variations
+		if !product.Container {
+			variations = append([]*models.Variation{{
+				Enabled: true,
+				Name: "default",
+				Title: product.Variation,
+				BasePrice: product.BasePrice,
+				SalePrice: product.SalePrice,
+				Start: product.Start,
+				End: product.End,
+				Prices: product.Prices,
+				Weight: product.Weight,
+				WeightUnit: product.WeightUnit,
+				Availability: product.Availability,
+				Sku: product.Sku,
+			}}, variations...)
+		}
+		var group []*FeedItem
+		for _, variation := range variations {
+			if !variation.IsPublished(now) {
+				continue
+			}
+			item := *base
+			item.Id = fmt.Sprintf("%d-%d", product.ID, variation.ID)
+			if variation.Title != "" && variation.ID > 0 {
+				item.Title = product.Title + " - " + variation.Title
+			}
+			if description := feedDescription(variation.Description); description != "" {
+				item.Description = description
+			}
+			if images := feedImages(connector, variation.Images); len(images) > 0 {
+				item.ImageLink, item.AdditionalImageLinks = images[0], images[1:]
+			}else if len(productImages) > 0 {
+				item.ImageLink, item.AdditionalImageLinks = productImages[0], productImages[1:]
+			}
+			item.Availability = FeedAvailability(variation.Availability)
+			item.Sku = variation.Sku
+			item.Weight, item.WeightUnit = variation.Weight, variation.WeightUnit
+			if item.WeightUnit == "" {
+				item.WeightUnit = common.Config.WeightUnit
+			}
+			sale := variation.End.IsZero() || variation.End.After(now)
+			if sale {
+				item.SaleStart, item.SaleEnd = variation.Start, variation.End
+			}
+			var prices []*models.Price
+			for _, price := range variation.Prices {
+				if price.Enabled && price.BasePrice > 0 {
+					prices = append(prices, price)
+				}
+			}
+			if len(prices) == 0 {
+				item.Price = variation.BasePrice
+				if sale {
+					item.SalePrice = variation.SalePrice
+				}
+				if item.Sku != "" {
+					item.Id = item.Sku
+				}
+				group = append(group, &item)
+				continue
+			}
+			for _, price := range prices {
+				item2 := item
+				var titles []string
+				for _, rate := range price.Rates {
+					if rate.Value != nil {
+						titles = append(titles, rate.Value.Title)
+					}
+				}
+				if len(titles) > 0 {
+					item2.Title = item.Title + " - " + strings.Join(titles, ", ")
+				}
+				item2.Id = fmt.Sprintf("%v-%d", item.Id, price.ID)
+				item2.Price = price.BasePrice
+				if sale {
+					item2.SalePrice = price.SalePrice
+				}
+				if price.Availability != "" {
+					item2.Availability = FeedAvailability(price.Availability)
+				}
+				if price.Sku != "" {
+					item2.Sku = price.Sku
+					item2.Id = price.Sku
+				}
+				if price.Thumbnail != "" {
+					item2.ImageLink = feedAbsoluteUrl(price.Thumbnail)
+				}
+				group = append(group, &item2)
+			}
+		}
+		if len(group) > 1 {
+			for _, item := range group {
+				item.GroupId = fmt.Sprintf("%d", product.ID)
+			}
+		}
+		items = append(items, group...)
+	}
+	return items, nil
+}
+
+type googleFeedRSS struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string `xml:"version,attr"`
+	NS string `xml:"xmlns:g,attr"`
+	Channel struct {
+		Title string `xml:"title"`
+		Link string `xml:"link"`
+		Description string `xml:"description"`
+		Items []googleFeedItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type googleFeedItem struct {
+	Id string `xml:"g:id"`
+	GroupId string `xml:"g:item_group_id,omitempty"`
+	Title string `xml:"g:title"`
+	Description string `xml:"g:description"`
+	Link string `xml:"g:link"`
+	ImageLink string `xml:"g:image_link,omitempty"`
+	AdditionalImageLinks []string `xml:"g:additional_image_link,omitempty"`
+	Availability string `xml:"g:availability"`
+	Condition string `xml:"g:condition"`
+	Price string `xml:"g:price"`
+	SalePrice string `xml:"g:sale_price,omitempty"`
+	SalePriceEffectiveDate string `xml:"g:sale_price_effective_date,omitempty"`
+	Brand string `xml:"g:brand,omitempty"`
+	Gtin string `xml:"g:gtin,omitempty"`
+	Mpn string `xml:"g:mpn,omitempty"`
+	IdentifierExists string `xml:"g:identifier_exists,omitempty"`
+	ProductType string `xml:"g:product_type,omitempty"`
+	ShippingWeight string `xml:"g:shipping_weight,omitempty"`
+}
+
+// WriteGoogleFeed writes items as Google Merchant Center RSS 2.0 feed
+func WriteGoogleFeed(writer io.Writer, items []*FeedItem) error {
+	var rss googleFeedRSS
+	rss.Version = "2.0"
+	rss.NS = "http://base.google.com/ns/1.0"
+	rss.Channel.Title = common.DEFAULT_TITLE
+	rss.Channel.Link = common.Config.Url
+	var conf HugoSettingsView
+	if _, err := toml.DecodeFile(path.Join(dir, "hugo", "config.toml"), &conf); err == nil {
+		if conf.Title != "" {
+			rss.Channel.Title = conf.Title
+		}
+		rss.Channel.Description = conf.Params.Description
+	}
+	for _, item := range items {
+		view := googleFeedItem{
+			Id: item.Id,
+			GroupId: item.GroupId,
+			Title: feedTruncate(item.Title, 150),
+			Description: feedTruncate(item.Description, 5000),
+			Link: item.Link,
+			ImageLink: item.ImageLink,
+			AdditionalImageLinks: item.AdditionalImageLinks,
+			Availability: item.Availability,
+			Condition: "new",
+			Price: feedPrice(item.Price, item.Currency),
+			Brand: item.Brand,
+			Gtin: item.Gtin,
+			Mpn: item.Sku,
+			ProductType: item.ProductType,
+			ShippingWeight: feedWeight(item.Weight, item.WeightUnit),
+		}
+		if item.SalePrice > 0 {
+			view.SalePrice = feedPrice(item.SalePrice, item.Currency)
+			view.SalePriceEffectiveDate = item.SalePriceEffectiveDate()
+		}
+		if item.Gtin == "" && item.Sku == "" {
+			view.IdentifierExists = "no"
+		}
+		rss.Channel.Items = append(rss.Channel.Items, view)
+	}
+	if _, err := io.WriteString(writer, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(writer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(rss); err != nil {
+		return err
+	}
+	_, err := io.WriteString(writer, "\n")
+	return err
+}
+
+// WriteFacebookFeed writes items as Facebook (Meta) catalog CSV feed
+func WriteFacebookFeed(writer io.Writer, items []*FeedItem) error {
+	w := csv.NewWriter(writer)
+	if err := w.Write(FacebookFeedHeader); err != nil {
+		return err
+	}
+	for _, item := range items {
+		var salePrice, effective string
+		if item.SalePrice > 0 {
+			salePrice = feedPrice(item.SalePrice, item.Currency)
+			effective = item.SalePriceEffectiveDate()
+		}
+		availability := strings.ReplaceAll(item.Availability, "_", " ")
+		if item.Availability == "backorder" {
+			availability = "available for order"
+		}
+		if err := w.Write([]string{item.Id, item.GroupId, feedTruncate(item.Title, 150), feedTruncate(item.Description, 5000), availability, "new", feedPrice(item.Price, item.Currency), salePrice, effective, item.Link, item.ImageLink, strings.Join(item.AdditionalImageLinks, ","), item.Brand, item.Gtin, item.Sku, item.ProductType, feedWeight(item.Weight, item.WeightUnit)}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// feedAvailability maps free text Availability to feed values
+// FeedAvailability maps free text availability to in_stock, out_of_stock, preorder or backorder
+func FeedAvailability(availability string) string {
+	availability = strings.ToLower(strings.TrimSpace(availability))
+	switch {
+	case availability == "" || availability == common.AVAILABILITY_AVAILABLE || strings.Contains(availability, "in stock"):
+		return "in_stock"
+	case strings.Contains(availability, "pre"):
+		return "preorder"
+	case strings.Contains(availability, "back") || strings.Contains(availability, "order") || strings.Contains(availability, "days") || strings.Contains(availability, "week"):
+		return "backorder"
+	case strings.Contains(availability, "out") || strings.Contains(availability, "sold") || strings.Contains(availability, "unavailable") || strings.Contains(availability, "not available"):
+		return "out_of_stock"
+	}
+	return "in_stock"
+}
+
+func feedDescription(description string) string {
+	description = reFeedTags.ReplaceAllString(description, " ")
+	return strings.TrimSpace(reFeedSpace.ReplaceAllString(description, " "))
+}
+
+// feedGtin takes GTIN from custom parameters like "GTIN: 4006381333931", EAN, UPC and ISBN are accepted too
+func feedGtin(parameters string) string {
+	for _, line := range strings.Split(parameters, "\n") {
+		if chunks := strings.SplitN(line, ":", 2); len(chunks) == 2 {
+			switch strings.ToLower(strings.TrimSpace(chunks[0])) {
+			case "gtin", "ean", "upc", "isbn":
+				return strings.TrimSpace(chunks[1])
+			}
+		}
+	}
+	return ""
+}
+
+func feedProductLink(connector *gorm.DB, product *models.Product) string {
+	if cache, err := models.GetCacheProductByProductId(connector, product.ID); err == nil && cache.Path != "" {
+		return feedAbsoluteUrl(cache.Path)
+	}
+	return feedAbsoluteUrl("/" + common.PRODUCTS_NAME + "/" + product.Name + "/")
+}
+
+func feedProductType(connector *gorm.DB, product *models.Product) string {
+	if len(product.Categories) == 0 {
+		return ""
+	}
+	var titles []string
+	for _, crumb := range models.GetBreadcrumbs(connector, product.Categories[0].ID) {
+		if crumb.ID > 0 {
+			titles = append(titles, crumb.Title)
+		}
+	}
+	return strings.Join(titles, " > ")
+}
+
+// feedImages returns original image links stored in cache by render
+func feedImages(connector *gorm.DB, images []*models.Image) []string {
+	var links []string
+	for _, image := range images {
+		if cache, err := models.GetCacheImageByImageId(connector, image.ID); err == nil && cache.Thumbnail != "" {
+			if link := strings.Fields(strings.Split(cache.Thumbnail, ",")[0]); len(link) > 0 {
+				links = append(links, feedAbsoluteUrl(link[0]))
+			}
+		}
+	}
+	return links
+}
+
+func feedAbsoluteUrl(link string) string {
+	if strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") {
+		return link
+	}
+	return strings.TrimRight(common.Config.Url, "/") + "/" + strings.TrimLeft(link, "/")
+}
+
+func feedPrice(price float64, currency string) string {
+	return fmt.Sprintf("%.2f %s", price, currency)
+}
+
+func feedWeight(weight float64, unit string) string {
+	if weight == 0 {
+		return ""
+	}
+	if unit == "" {
+		unit = "kg"
+	}
+	return strconv.FormatFloat(weight, 'f', -1, 64) + " " + unit
+}
+
+func feedTruncate(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
@@ -23,6 +23,8 @@ import (
 type NewFile struct {
 	Name string
 	File string
+	Download bool
+	Limit int
 }
 
 // @security BasicAuth
@@ -47,12 +49,20 @@ func postFileHandler(c *fiber.Ctx) error {
 			if v, found := data.Value["Name"]; found && len(v) > 0 {
 				name = strings.TrimSpace(v[0])
 			}
+			var download bool
+			if v, found := data.Value["Download"]; found && len(v) > 0 {
+				download, _ = strconv.ParseBool(v[0])
+			}
+			var limit int
+			if v, found := data.Value["Limit"]; found && len(v) > 0 {
+				limit, _ = strconv.Atoi(v[0])
+			}
 			if v, found := data.File["File"]; found && len(v) > 0 {
 				for _, vv := range v {
 					if name == "" {
 						name = strings.TrimSuffix(vv.Filename, filepath.Ext(vv.Filename))
 					}
-					file := &models.File{Name: name, Size: vv.Size}
+					file := &models.File{Name: name, Size: vv.Size, Download: download, Limit: limit}
 					if id, err := models.CreateFile(common.Database, file); err == nil {
 						p := path.Join(dir, "storage", "files")
 						if _, err := os.Stat(p); err != nil {
@@ -84,7 +94,8 @@ func postFileHandler(c *fiber.Ctx) error {
 										logger.Warningf("%v", err)
 									}
 								}
-								if p1 := path.Join(dir, "storage", file.Path); len(p1) > 0 {
+								// Purchasable downloads are never published
+								if p1 := path.Join(dir, "storage", file.Path); len(p1) > 0 && !file.Download {
 									if fi, err := os.Stat(p1); err == nil {
 										filename := fmt.Sprintf("%d-%s-%d%v", file.ID, file.Name, fi.ModTime().Unix(), path.Ext(p1))
 										location := path.Join("files", filename)
@@ -113,6 +124,7 @@ func postFileHandler(c *fiber.Ctx) error {
 											if err = models.AddFileToProduct(common.Database, product, file); err != nil {
 												logger.Errorf("%v", err.Error())
 											}
+											touchProducts(product.ID)
 										}else{
 											logger.Errorf("%v", err.Error())
 										}
@@ -125,6 +137,7 @@ func postFileHandler(c *fiber.Ctx) error {
 											if err = models.AddFileToVariation(common.Database, variation, file); err != nil {
 												logger.Errorf("%v", err.Error())
 											}
+											touchProducts(variation.ProductId)
 										}else{
 											logger.Errorf("%v", err.Error())
 										}
@@ -344,6 +357,8 @@ type File2View struct {
 	Url string `json:",omitempty"`
 	File string `json:",omitempty"`
 	Size int `json:",omitempty"`
+	Download bool `json:",omitempty"`
+	Limit int `json:",omitempty"`
 	Updated time.Time `json:",omitempty"`
 }
 
@@ -425,6 +440,17 @@ func putFileHandler(c *fiber.Ctx) error {
 			if v := data.Value["Name"]; len(v) > 0 {
 				file.Name = strings.TrimSpace(v[0])
 			}
+			if v := data.Value["Download"]; len(v) > 0 {
+				file.Download, _ = strconv.ParseBool(v[0])
+				if file.Download {
+					if err = unpublishFile(file); err != nil {
+						logger.Warningf("%v", err)
+					}
+				}
+			}
+			if v := data.Value["Limit"]; len(v) > 0 {
+				file.Limit, _ = strconv.Atoi(v[0])
+			}
 			if v, found := data.File["File"]; found && len(v) > 0 {
 				p := path.Dir(path.Join(dir, "storage", file.Path))
 				if _, err := os.Stat(p); err != nil {
@@ -461,7 +487,7 @@ func putFileHandler(c *fiber.Ctx) error {
 							}
 						}
 						//
-						if p1 := path.Join(dir, "storage", file.Path); len(p1) > 0 {
+						if p1 := path.Join(dir, "storage", file.Path); len(p1) > 0 && !file.Download {
 							if fi, err := os.Stat(p1); err == nil {
 								filename := fmt.Sprintf("%d-%s-%d%v", file.ID, file.Name, fi.ModTime().Unix(), path.Ext(p1))
 								location := path.Join("files", filename)
@@ -521,7 +547,13 @@ func delFileHandler(c *fiber.Ctx) error {
 			if err = os.Remove(path.Join(dir, file.Path)); err != nil {
 				logger.Errorf("%v", err.Error())
 			}
+			productIds, variationIds, err := models.GetFileOwners(common.Database, file.ID)
+			if err != nil {
+				logger.Warningf("%+v", err)
+			}
 			if err = models.DeleteFile(common.Database, file); err == nil {
+				touchProducts(productIds...)
+				touchVariations(variationIds...)
 				return c.JSON(HTTPMessage{MESSAGE: "OK"})
 			}else{
 				c.Status(http.StatusInternalServerError)
@@ -535,4 +567,22 @@ func delFileHandler(c *fiber.Ctx) error {
 		c.Status(http.StatusInternalServerError)
 		return c.JSON(fiber.Map{"ERROR": "File ID is not defined"})
 	}
-}
\ No newline at end of file
+}
+
+// unpublishFile deletes copies of file made by upload and render, download files are available by signed link only
+func unpublishFile(file *models.File) error {
+	cacheFiles, err := models.GetCacheFilesByFileId(common.Database, file.ID)
+	if err != nil {
+		return err
+	}
+	for _, cacheFile := range cacheFiles {
+		location, err := common.STORAGE.Location(cacheFile.File)
+		if err != nil {
+			return err
+		}
+		if err = common.STORAGE.DeleteFile(location); err != nil {
+			return err
+		}
+	}
+	return models.DeleteCacheFileByFileId(common.Database, file.ID)
+}
@@ -0,0 +1,433 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	GENERATE_MODE_VARIATIONS = "variations"
+	GENERATE_MODE_PRICES = "prices"
+	GENERATE_LIMIT = 1000 // combinations per request
+)
+
+type GenerateRequest struct {
+	Mode string // variations (default) or prices
+	VariationId uint `json:",omitempty"` // prices mode: create prices of variation instead of product
+	Options []GenerateOption
+	Name string // template, {product}, {sku}, {values} and {<option name>} are replaced, default {values}
+	Title string
+	Sku string
+	BasePrice float64 // product base price is used if 0
+	Preview bool
+}
+
+type GenerateOption struct {
+	OptionId uint
+	Values []GenerateValue // all option values if empty
+}
+
+type GenerateValue struct {
+	ValueId uint
+	Surcharge float64
+}
+
+type GenerateView struct {
+	Preview bool
+	Created []GeneratedItemView
+	Skipped []GeneratedItemView
+}
+
+type GeneratedItemView struct {
+	ID uint `json:",omitempty"`
+	Name string `json:",omitempty"`
+	Title string `json:",omitempty"`
+	Sku string `json:",omitempty"`
+	BasePrice float64
+	Values []string
+}
+
+type generateValue struct {
+	option *models.Option
+	value *models.Value
+	surcharge float64
+}
+
+// @security BasicAuth
+// GenerateVariations godoc
+// @Summary Generate variations or prices for every combination of option values
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param preview query bool false "Return what would be created"
+// @Param draft query bool false "Stage changes of product as draft"
+// @Param request body GenerateRequest true "body"
+// @Success 200 {object} GenerateView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/products/{id}/variations/generate [post]
+// @Tags product
+func postProductVariationsGenerateHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	var request GenerateRequest
+	if err := c.BodyParser(&request); err != nil {
+		return err
+	}
+	if v := c.Query("preview"); v != "" {
+		request.Preview, _ = strconv.ParseBool(v)
+	}
+	product, err := models.GetProduct(common.Database, id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	combinations, err := getCombinations(request.Options)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	if request.BasePrice == 0 {
+		request.BasePrice = product.BasePrice
+	}
+	if request.Name == "" {
+		request.Name = "{values}"
+	}
+	if request.Title == "" {
+		request.Title = "{values}"
+	}
+	if request.Sku == "" && product.Sku != "" {
+		request.Sku = "{sku}-{values}"
+	}
+	var view *GenerateView
+	if request.Mode == "" {
+		request.Mode = GENERATE_MODE_VARIATIONS
+	}
+	owners := ownerOf(product.ID, 0)
+	if request.Mode == GENERATE_MODE_PRICES {
+		owners = ownerOf(product.ID, request.VariationId)
+	}
+	if !request.Preview {
+		initialRevisions(common.Database, owners, userOf(c))
+	}
+	switch request.Mode {
+	case GENERATE_MODE_VARIATIONS:
+		view, err = generateVariations(product, &request, combinations)
+	case GENERATE_MODE_PRICES:
+		view, err = generatePrices(product, &request, combinations)
+	default:
+		err = fmt.Errorf("unknown mode %v", request.Mode)
+	}
+	if view != nil && !view.Preview && len(view.Created) > 0 {
+		comment := fmt.Sprintf("%d %v generated", len(view.Created), request.Mode)
+		published := ownerRevisions(common.Database, owners, isDraft(c), comment, userOf(c))
+		if request.Mode == GENERATE_MODE_VARIATIONS {
+			// new variations have nothing published to stage them against
+			var created []revisionOwner
+			for _, item := range view.Created {
+				created = append(created, revisionOwner{ObjectType: models.REVISION_OBJECT_VARIATION, ObjectId: item.ID})
+			}
+			published = append(published, ownerRevisions(common.Database, created, false, comment, userOf(c))...)
+		}
+		reindexOwners(published)
+		if err := MarkChanged(comment); err != nil {
+			logger.Warningf("%v", err)
+		}
+	}
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	return c.JSON(view)
+}
+
+// getCombinations returns Cartesian product of requested option values
+func getCombinations(options []GenerateOption) ([][]generateValue, error) {
+	if len(options) == 0 {
+		return nil, fmt.Errorf("options are not defined")
+	}
+	var groups [][]generateValue
+	count := 1
+	for _, o := range options {
+		option, err := models.GetOption(common.Database, int(o.OptionId))
+		if err != nil {
+			return nil, fmt.Errorf("option #%v not found", o.OptionId)
+		}
+		var group []generateValue
+		if len(o.Values) == 0 {
+			for _, value := range option.Values {
+				group = append(group, generateValue{option: option, value: value})
+			}
+		} else {
+			for _, v := range o.Values {
+				var found bool
+				for _, value := range option.Values {
+					if value.ID == v.ValueId {
+						group = append(group, generateValue{option: option, value: value, surcharge: v.Surcharge})
+						found = true
+						break
+					}
+				}
+				if !found {
+					return nil, fmt.Errorf("value #%v of option %v not found", v.ValueId, option.Name)
+				}
+			}
+		}
+		if len(group) == 0 {
+			return nil, fmt.Errorf("option %v has no values", option.Name)
+		}
+		if count *= len(group); count > GENERATE_LIMIT {
+			return nil, fmt.Errorf("too many combinations, %d max", GENERATE_LIMIT)
+		}
+		groups = append(groups, group)
+	}
+	combinations := [][]generateValue{{}}
+	for _, group := range groups {
+		var next [][]generateValue
+		for _, combination := range combinations {
+			for _, value := range group {
+				next = append(next, append(append([]generateValue{}, combination...), value))
+			}
+		}
+		combinations = next
+	}
+	return combinations, nil
+}
+
+func combinationKey(ids []uint) string {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	var chunks []string
+	for _, id := range ids {
+		chunks = append(chunks, strconv.Itoa(int(id)))
+	}
+	return strings.Join(chunks, ",")
+}
+
+func newGeneratedItemView(product *models.Product, request *GenerateRequest, combination []generateValue) GeneratedItemView {
+	view := GeneratedItemView{BasePrice: request.BasePrice}
+	var titles []string
+	for _, v := range combination {
+		titles = append(titles, v.value.Title)
+		view.BasePrice += v.surcharge
+	}
+	view.Values = titles
+	format := func(template, separator string) string {
+		pairs := []string{"{product}", product.Title, "{sku}", product.Sku, "{values}", strings.Join(titles, separator)}
+		for _, v := range combination {
+			pairs = append(pairs, "{" + v.option.Name + "}", v.value.Title)
+		}
+		return strings.NewReplacer(pairs...).Replace(template)
+	}
+	view.Name = strings.Trim(reNotAbc.ReplaceAllString(strings.ToLower(format(request.Name, "-")), "-"), "-")
+	view.Title = format(request.Title, " / ")
+	if request.Sku != "" {
+		view.Sku = strings.Trim(reNotAbc.ReplaceAllString(strings.ToUpper(format(request.Sku, "-")), "-"), "-")
+	}
+	return view
+}
+
+func generateVariations(product *models.Product, request *GenerateRequest, combinations [][]generateValue) (*GenerateView, error) {
+	view := &GenerateView{Preview: request.Preview, Created: []GeneratedItemView{}, Skipped: []GeneratedItemView{}}
+	variations, err := models.GetProductVariations(common.Database, int(product.ID))
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]uint)
+	names := make(map[string]uint)
+	for _, variation := range variations {
+		var ids []uint
+		for _, property := range variation.Properties {
+			for _, rate := range property.Rates {
+				ids = append(ids, rate.ValueId)
+			}
+		}
+		if len(ids) > 0 {
+			existing[combinationKey(ids)] = variation.ID
+		}
+		names[variation.Name] = variation.ID
+	}
+	for _, combination := range combinations {
+		item := newGeneratedItemView(product, request, combination)
+		var ids []uint
+		for _, v := range combination {
+			ids = append(ids, v.value.ID)
+		}
+		key := combinationKey(ids)
+		if id, found := existing[key]; found {
+			item.ID = id
+			view.Skipped = append(view.Skipped, item)
+			continue
+		}
+		if id, found := names[item.Name]; found {
+			item.ID = id
+			view.Skipped = append(view.Skipped, item)
+			continue
+		}
+		if !request.Preview {
+			variation := &models.Variation{
+				Enabled: true,
+				Name: item.Name,
+				Title: item.Title,
+				Sku: item.Sku,
+				BasePrice: item.BasePrice,
+				Pattern: product.Pattern,
+				Dimensions: product.Dimensions,
+				DimensionUnit: product.DimensionUnit,
+				Width: product.Width,
+				Height: product.Height,
+				Depth: product.Depth,
+				Volume: product.Volume,
+				Weight: product.Weight,
+				WeightUnit: product.WeightUnit,
+				Packages: product.Packages,
+				Availability: product.Availability,
+				TimeId: product.TimeId,
+				ProductId: product.ID,
+			}
+			for _, v := range combination {
+				variation.Properties = append(variation.Properties, &models.Property{
+					Name: v.option.Name,
+					Title: v.option.Title,
+					OptionId: v.option.ID,
+					Rates: []*models.Rate{{Enabled: true, ValueId: v.value.ID, Availability: v.value.Availability}},
+				})
+			}
+			if _, err = models.CreateVariation(common.Database, variation); err != nil {
+				return view, err
+			}
+			item.ID = variation.ID
+		}
+		existing[key] = item.ID
+		names[item.Name] = item.ID
+		view.Created = append(view.Created, item)
+	}
+	if !request.Preview && len(view.Created) > 0 && !product.Container {
+		product.Container = true
+		if err = models.UpdateProduct(common.Database, product); err != nil {
+			return view, err
+		}
+	}
+	return view, nil
+}
+
+func generatePrices(product *models.Product, request *GenerateRequest, combinations [][]generateValue) (*GenerateView, error) {
+	view := &GenerateView{Preview: request.Preview, Created: []GeneratedItemView{}, Skipped: []GeneratedItemView{}}
+	var properties []*models.Property
+	var prices []*models.Price
+	var err error
+	if request.VariationId > 0 {
+		variation, err := models.GetVariation(common.Database, int(request.VariationId))
+		if err != nil || variation.ProductId != product.ID {
+			return nil, fmt.Errorf("variation #%v of product #%v not found", request.VariationId, product.ID)
+		}
+		properties = variation.Properties
+		prices = variation.Prices
+	} else {
+		if properties, err = models.GetPropertiesByProductId(common.Database, int(product.ID)); err != nil {
+			return nil, err
+		}
+		if prices, err = models.GetPricesByProductId(common.Database, product.ID); err != nil {
+			return nil, err
+		}
+	}
+	existing := make(map[string]uint)
+	for _, price := range prices {
+		var ids []uint
+		for _, rate := range price.Rates {
+			ids = append(ids, rate.ValueId)
+		}
+		existing[combinationKey(ids)] = price.ID
+	}
+	// Rates of combination values, missing properties and rates are created unless preview
+	rates := make(map[uint]*models.Rate)
+	for _, combination := range combinations {
+		for _, v := range combination {
+			if _, found := rates[v.value.ID]; found {
+				continue
+			}
+			var property *models.Property
+			for _, p := range properties {
+				if p.OptionId == v.option.ID {
+					property = p
+					break
+				}
+			}
+			if property == nil {
+				property = &models.Property{Name: v.option.Name, Title: v.option.Title, OptionId: v.option.ID, ProductId: product.ID}
+				if request.VariationId > 0 {
+					property.ProductId = 0
+					property.VariationId = request.VariationId
+				}
+				if !request.Preview {
+					if _, err = models.CreateProperty(common.Database, property); err != nil {
+						return view, err
+					}
+				}
+				properties = append(properties, property)
+			}
+			var rate *models.Rate
+			for _, r := range property.Rates {
+				if r.ValueId == v.value.ID {
+					rate = r
+					break
+				}
+			}
+			if rate == nil {
+				rate = &models.Rate{Enabled: true, PropertyId: property.ID, ValueId: v.value.ID, Availability: v.value.Availability}
+				if !request.Preview {
+					if _, err = models.CreateRate(common.Database, rate); err != nil {
+						return view, err
+					}
+				}
+				property.Rates = append(property.Rates, rate)
+			}
+			rates[v.value.ID] = rate
+		}
+	}
+	var items []GeneratedItemView
+	var requests []NewPrice
+	for _, combination := range combinations {
+		item := newGeneratedItemView(product, request, combination)
+		var ids []uint
+		var priceRates []*models.Rate
+		for _, v := range combination {
+			ids = append(ids, v.value.ID)
+			priceRates = append(priceRates, rates[v.value.ID])
+		}
+		key := combinationKey(ids)
+		if id, found := existing[key]; found {
+			item.ID = id
+			view.Skipped = append(view.Skipped, item)
+			continue
+		}
+		existing[key] = 0
+		newPrice := NewPrice{Enabled: true, Rates: priceRates, BasePrice: item.BasePrice, Availability: product.Availability, Sku: item.Sku}
+		if request.VariationId > 0 {
+			newPrice.VariationId = request.VariationId
+		} else {
+			newPrice.ProductId = product.ID
+		}
+		items = append(items, item)
+		requests = append(requests, newPrice)
+	}
+	if !request.Preview {
+		created, err := createPrices(requests)
+		for i, price := range created {
+			items[i].ID = price.ID
+		}
+		if err != nil {
+			return view, err
+		}
+	}
+	view.Created = append(view.Created, items...)
+	return view, nil
+}
@@ -44,7 +44,6 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"regexp"
@@ -89,26 +88,12 @@ func GetFiber() *fiber.App {
 	//
 	changed := func (messages ...string) func (c *fiber.Ctx) error {
 		return func (c *fiber.Ctx) error {
-			p := path.Join(dir, HAS_CHANGES)
-			if pp := path.Dir(p); len(pp) > 0 {
-				if _, err := os.Stat(pp); err != nil {
-					if err = os.MkdirAll(pp, 0755); err != nil {
-						logger.Warningf("%v", err)
-					}
-				}
-			}
-			file, err := os.OpenFile(p,
-				os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				logger.Warningf("%v", err)
-			}
-			defer file.Close()
-			for _, message := range messages {
-				if _, err := file.WriteString(message + "\n"); err != nil {
+			// drafts are not visible until published
+			if !isDraft(c) {
+				if err := MarkChanged(messages...); err != nil {
 					logger.Warningf("%v", err)
 				}
 			}
-
 			return c.Next()
 		}
 	}
@@ -140,6 +125,9 @@ func GetFiber() *fiber.App {
 	v1.Post("/reset", csrf, postResetHandler)
 	v1.Get("/logout", authRequired, getLogoutHandler)
 	v1.Get("/preview", authOptional, getPreviewHandler)
+	v1.Get("/preview/products/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getPreviewProductHandler)
+	v1.Get("/preview/categories/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getPreviewCategoryHandler)
+	v1.Get("/preview/site/:name/*", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getPreviewSiteHandler)
 	v1.Get("/info", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getInfoHandler)
 	v1.Get("/dashboard", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getDashboardHandler)
 	v1.Get("/resize", getResizeHandler)
@@ -155,9 +143,10 @@ func GetFiber() *fiber.App {
 	v1.Post("/categories/autocomplete", authRequired, postCategoriesAutocompleteHandler)
 	v1.Post("/categories/list", authRequired, postCategoriesListHandler)
 	v1.Get("/categories/:id", authRequired, getCategoryHandler)
-	v1.Patch("/categories/:id", authRequired, changed("category updated"), patchCategoryHandler)
-	v1.Put("/categories/:id", authRequired, changed("category updated"), putCategoryHandler)
-	v1.Delete("/categories/:id", authRequired, changed("category deleted"), delCategoryHandler)
+	v1.Patch("/categories/:id", authRequired, changed("category updated"), revisioned(models.REVISION_OBJECT_CATEGORY), reindexed(models.GetProductIdsByCategory), patchCategoryHandler)
+	v1.Put("/categories/:id", authRequired, changed("category updated"), revisioned(models.REVISION_OBJECT_CATEGORY), reindexed(models.GetProductIdsByCategory), putCategoryHandler)
+	v1.Delete("/categories/:id", authRequired, changed("category deleted"), reindexed(models.GetProductIdsByCategory), delCategoryHandler)
+	v1.Get("/categories/:id/revisions", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getCategoryRevisionsHandler)
 	//
 	v1.Get("/contents", authRequired, getContentsHandler)
 	v1.Get("/contents/*", authRequired, getContentHandler)
@@ -167,54 +156,85 @@ func GetFiber() *fiber.App {
 	//
 	v1.Get("/products", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getProductsHandler)
 	v1.Post("/products", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("product created"), postProductsHandler)
+	v1.Post("/products/import", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postProductsImportHandler)
+	v1.Get("/products/export", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getProductsExportHandler)
 	v1.Post("/products/list", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postProductsListHandler)
 	v1.Get("/products/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getProductHandler)
-	v1.Patch("/products/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("product updated"), patchProductHandler)
-	v1.Put("/products/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("product updated"), putProductHandler)
+	v1.Patch("/products/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("product updated"), revisioned(models.REVISION_OBJECT_PRODUCT), patchProductHandler)
+	v1.Put("/products/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("product updated"), revisioned(models.REVISION_OBJECT_PRODUCT), putProductHandler)
 	v1.Delete("/products/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("product deleted"), delProductHandler)
+	v1.Post("/products/bulk", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postProductsBulkHandler)
+	v1.Post("/products/:id/clone", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postProductCloneHandler)
+	v1.Get("/products/:id/revisions", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getProductRevisionsHandler)
+	v1.Post("/products/:id/variations/generate", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postProductVariationsGenerateHandler)
+	v1.Get("/products/:id/relations", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getProductRelationsHandler)
+	v1.Post("/products/:id/relations", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("relation created"), postProductRelationHandler)
+	v1.Put("/products/:id/relations/:relation_id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("relation updated"), putProductRelationHandler)
+	v1.Delete("/products/:id/relations/:relation_id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("relation deleted"), delProductRelationHandler)
 	//
-	v1.Post("/parameters", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("parameter created"), postParameterHandler)
+	v1.Post("/parameters", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("parameter created"), revisionedOwners(parameterOwners), postParameterHandler)
 	v1.Get("/parameters/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getParameterHandler)
-	v1.Put("/parameters/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("parameter updated"), putParameterHandler)
-	v1.Delete("/parameters/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("parameter deleted"), deleteParameterHandler)
+	v1.Put("/parameters/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("parameter updated"), revisionedOwners(parameterOwners), putParameterHandler)
+	v1.Delete("/parameters/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("parameter deleted"), revisionedOwners(parameterOwners), deleteParameterHandler)
+	//
+	v1.Get("/components", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getBundleComponentsHandler)
+	v1.Post("/components", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("component created"), postBundleComponentHandler)
+	v1.Get("/components/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getBundleComponentHandler)
+	v1.Put("/components/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("component updated"), putBundleComponentHandler)
+	v1.Delete("/components/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("component deleted"), delBundleComponentHandler)
 	//
 	v1.Get("/variations", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getVariationsHandler)
 	v1.Post("/variations", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("variation created"), postVariationHandler)
 	v1.Post("/variations/list", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postVariationsListHandler)
 	v1.Get("/variations/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getVariationHandler)
-	v1.Patch("/variations/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), patchVariationHandler)
-	v1.Put("/variations/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("variation updated"), putVariationHandler)
+	v1.Patch("/variations/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("variation updated"), revisioned(models.REVISION_OBJECT_VARIATION), patchVariationHandler)
+	v1.Put("/variations/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("variation updated"), revisioned(models.REVISION_OBJECT_VARIATION), putVariationHandler)
 	v1.Delete("/variations/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("variation deleted"), delVariationHandler)
+	v1.Post("/variations/bulk", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postVariationsBulkHandler)
+	v1.Get("/variations/:id/revisions", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getVariationRevisionsHandler)
 	//
-	v1.Post("/properties", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("property created"), postPropertyHandler)
+	v1.Get("/bulk", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getChangeSetsHandler)
+	v1.Get("/bulk/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getChangeSetHandler)
+	v1.Post("/bulk/:id/undo", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postChangeSetUndoHandler)
+	//
+	v1.Get("/revisions", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getDraftsHandler)
+	v1.Post("/revisions/publish", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postRevisionsPublishHandler)
+	v1.Get("/revisions/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getRevisionHandler)
+	v1.Get("/revisions/:id/diff", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getRevisionDiffHandler)
+	v1.Post("/revisions/:id/restore", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postRevisionRestoreHandler)
+	// Translations
+	v1.Get("/translations/:type/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getTranslationsHandler)
+	v1.Put("/translations/:type/:id/:language", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), putTranslationsHandler)
+	//
+	v1.Post("/properties", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("property created"), revisionedOwners(propertyOwners), postPropertyHandler)
 	v1.Post("/properties/list", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postPropertiesListHandler)
 	v1.Get("/properties/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getPropertyHandler)
-	v1.Put("/properties/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("property updated"), putPropertyHandler)
-	v1.Delete("/properties/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("property deleted"), deletePropertyHandler)
+	v1.Put("/properties/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("property updated"), revisionedOwners(propertyOwners), putPropertyHandler)
+	v1.Delete("/properties/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("property deleted"), revisionedOwners(propertyOwners), deletePropertyHandler)
 	//
 	v1.Get("/rates", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getRatesHandler)
 	v1.Post("/rates/list", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postRatesListHandler)
-	v1.Post("/rates", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("rate created"), postRateHandler)
+	v1.Post("/rates", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("rate created"), revisionedOwners(rateOwners), postRateHandler)
 	v1.Get("/rates/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getRateHandler)
-	v1.Put("/rates/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("rate updated"), putRateHandler)
-	v1.Delete("/rates/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("rate deleted"), deleteRateHandler)
+	v1.Put("/rates/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("rate updated"), revisionedOwners(rateOwners), putRateHandler)
+	v1.Delete("/rates/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("rate deleted"), revisionedOwners(rateOwners), deleteRateHandler)
 	//
 	v1.Get("/prices", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getPricesHandler)
 	//v1.Post("/prices/list", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postPricesListHandler)
-	v1.Post("/prices", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("price created"), postPriceHandler)
-	v1.Post("/prices/all", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("prices created"), postPriceAllHandler)
-	v1.Put("/prices/all", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("prices updated"), putPriceAllHandler)
+	v1.Post("/prices", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("price created"), revisionedOwners(priceOwners), postPriceHandler)
+	v1.Post("/prices/all", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("prices created"), revisionedOwners(priceOwners), postPriceAllHandler)
+	v1.Put("/prices/all", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("prices updated"), revisionedOwners(priceOwners), putPriceAllHandler)
 	v1.Get("/prices/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getPriceHandler)
-	v1.Put("/prices/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("price updated"), putPriceHandler)
-	v1.Patch("/prices/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("price updated"), patchPriceHandler)
-	v1.Delete("/prices/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("price deleted"), deletePriceHandler)
+	v1.Put("/prices/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("price updated"), revisionedOwners(priceOwners), putPriceHandler)
+	v1.Patch("/prices/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("price updated"), revisionedOwners(priceOwners), patchPriceHandler)
+	v1.Delete("/prices/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("price deleted"), revisionedOwners(priceOwners), deletePriceHandler)
 	//
 	v1.Get("/tags", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getTagsHandler)
 	v1.Post("/tags", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("tag created"), postTagHandler)
 	v1.Post("/tags/list", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postTagsListHandler)
 	v1.Get("/tags/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getTagHandler)
-	v1.Put("/tags/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("tag updated"), putTagHandler)
-	v1.Delete("/tags/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("tag deleted"), delTagHandler)
+	v1.Put("/tags/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("tag updated"), reindexed(models.GetProductIdsByTag), putTagHandler)
+	v1.Delete("/tags/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("tag deleted"), reindexed(models.GetProductIdsByTag), delTagHandler)
 	// Options
 	v1.Get("/options", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getOptionsHandler)
 	v1.Post("/options", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("option created"), postOptionHandler)
@@ -228,9 +248,9 @@ func GetFiber() *fiber.App {
 	v1.Post("/values", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("value created"), postValueHandler)
 	v1.Post("/values/list", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postValuesListHandler)
 	v1.Get("/values/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getValueHandler)
-	v1.Patch("/values/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("value updated"), patchValueHandler)
-	v1.Put("/values/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("value updated"), putValueHandler)
-	v1.Delete("/values/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("value deleted"), delValueHandler)
+	v1.Patch("/values/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("value updated"), reindexed(models.GetProductIdsByValue), patchValueHandler)
+	v1.Put("/values/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("value updated"), reindexed(models.GetProductIdsByValue), putValueHandler)
+	v1.Delete("/values/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("value deleted"), reindexed(models.GetProductIdsByValue), delValueHandler)
 	// Files
 	v1.Post("/files", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("file created"), postFileHandler)
 	v1.Post("/files/list", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postFilesListHandler)
@@ -238,11 +258,11 @@ func GetFiber() *fiber.App {
 	v1.Put("/files/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("file updated"), putFileHandler)
 	v1.Delete("/files/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("file deleted"), delFileHandler)
 	// Images
-	v1.Post("/images", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("image created"), postImageHandler)
+	v1.Post("/images", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("image created"), revisionedOwners(imageOwners), postImageHandler)
 	v1.Post("/images/list", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postImagesListHandler)
 	v1.Get("/images/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getImageHandler)
-	v1.Put("/images/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("image updated"), putImageHandler)
-	v1.Delete("/images/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("image deleted"), delImageHandler)
+	v1.Put("/images/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("image updated"), revisionedOwners(imageOwners), putImageHandler)
+	v1.Delete("/images/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("image deleted"), revisionedOwners(imageOwners), delImageHandler)
 	// Coupons
 	v1.Get("/coupons", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getCouponsHandler)
 	v1.Post("/coupons", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("coupon created"), postCouponHandler)
@@ -272,6 +292,12 @@ func GetFiber() *fiber.App {
 	v1.Get("/menus/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getMenuHandler)
 	v1.Put("/menus/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("menu updated"), putMenuHandler)
 	v1.Delete("/menus/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("menu deleted"), delMenuHandler)
+	// Redirects
+	v1.Get("/redirects", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getRedirectsHandler)
+	v1.Post("/redirects", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("redirect created"), postRedirectHandler)
+	v1.Get("/redirects/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getRedirectHandler)
+	v1.Put("/redirects/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("redirect updated"), putRedirectHandler)
+	v1.Delete("/redirects/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("redirect deleted"), delRedirectHandler)
 	// Form
 	v1.Get("/forms", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getFormsHandler)
 	v1.Post("/forms", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("form created"), postFormHandler)
@@ -313,6 +339,18 @@ func GetFiber() *fiber.App {
 	v1.Put("/transports/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("transport updated"), putTransportHandler)
 	v1.Delete("/transports/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("transport deleted"), delTransportHandler)
 	//
+	v1.Get("/pickups", getPickupLocationsHandler)
+	v1.Post("/pickups", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("pickup location created"), postPickupLocationHandler)
+	v1.Post("/pickups/list", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postPickupLocationsListHandler)
+	v1.Get("/pickups/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getPickupLocationHandler)
+	v1.Put("/pickups/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("pickup location updated"), putPickupLocationHandler)
+	v1.Delete("/pickups/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("pickup location deleted"), delPickupLocationHandler)
+	//
+	v1.Get("/downloads/:id", getDownloadHandler)
+	//
+	v1.Get("/feeds/google.xml", getGoogleFeedHandler)
+	v1.Get("/feeds/facebook.csv", getFacebookFeedHandler)
+	//
 	v1.Get("/zones", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getZonesHandler)
 	v1.Post("/zones", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), changed("zone created"), postZoneHandler)
 	v1.Post("/zones/list", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postZonesListHandler)
@@ -351,6 +389,16 @@ func GetFiber() *fiber.App {
 	v1.Post("/prepare", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postPrepareHandler)
 	v1.Post("/render", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postRenderHandler)
 	v1.Post("/publish", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postPublishHandler)
+	v1.Get("/jobs", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getJobsHandler)
+	v1.Post("/jobs", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postJobHandler)
+	v1.Get("/jobs/:id", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getJobHandler)
+	v1.Post("/jobs/:id/cancel", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postJobCancelHandler)
+	v1.Get("/jobs/:id/events", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getJobEventsHandler)
+	//
+	v1.Get("/backups", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN), getBackupsHandler)
+	v1.Post("/backups", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN), postBackupHandler)
+	v1.Get("/backups/:name", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN), getBackupHandler)
+	v1.Delete("/backups/:name", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN), delBackupHandler)
 	//
 	v1.Get("/themes", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), getThemesHandler)
 	//v1.Post("/themes", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postThemeHandler)
@@ -411,6 +459,9 @@ func GetFiber() *fiber.App {
 	v1.Post("/checkout", postCheckoutHandler)
 	//
 	v1.Post("/filter", postFilterHandler)
+	v1.Get("/search", getSearchHandler)
+	v1.Post("/search", postSearchHandler)
+	v1.Post("/search/reindex", authRequired, hasRole(models.ROLE_ROOT, models.ROLE_ADMIN, models.ROLE_MANAGER), postSearchReindexHandler)
 	//
 	v1.Get("/error/200", func(c *fiber.Ctx) error {
 		c.Status(http.StatusOK)
@@ -625,7 +676,7 @@ func postResetHandler(c *fiber.Ctx) error {
 // @security BasicAuth
 // Logout godoc
 // @Summary preview
-// @Description run preview
+// @Description run preview, live=true renders drafts of referred product or category
 // @Accept json
 // @Produce json
 // @Success 200 {object} HTTPMessage
@@ -654,6 +705,12 @@ func getPreviewHandler (c *fiber.Ctx) error {
 					}else if v := string(c.Request().Header.Referer()); v != "" {
 						referer = v
 					}
+					// Live preview renders drafts of referred product or category on demand
+					if c.Query("live") == "true" {
+						if res := regexp.MustCompile(`/(products|categories)/(\d+)`).FindAllStringSubmatch(referer, 1); len(res) > 0 && len(res[0]) > 2 {
+							return c.Redirect(fmt.Sprintf("/api/v1/preview/%v/%v", res[0][1], res[0][2]), http.StatusFound)
+						}
+					}
 					if referer != "" {
 						if res := regexp.MustCompile(`/products/(\d+)`).FindAllStringSubmatch(referer, 1); len(res) > 0 && len(res[0]) > 1 {
 							if v, err := strconv.Atoi(res[0][1]); err == nil {
@@ -1191,40 +1248,8 @@ func putBasicSettingsHandler(c *fiber.Ctx) error {
 		message = "Saved, background rendering started"
 		logger.Info(message)
 		go func() {
-			// Prepare
-			cmd := exec.Command(os.Args[0], "render", "-p", path.Join(dir, "hugo", "content"))
-			buff := &bytes.Buffer{}
-			cmd.Stderr = buff
-			cmd.Stdout = buff
-			if err := cmd.Run(); err != nil {
-				stdout := buff.String()
-				stderr := err.Error()
-				logger.Errorf("%v\n%v", stdout, stderr)
-			}
-			// Render
-			bin := strings.Split(common.Config.Hugo.Bin, " ")
-			var arguments []string
-			if len(bin) > 1 {
-				for _, x := range bin[1:]{
-					x = strings.Replace(x, "%DIR%", dir, -1)
-					arguments = append(arguments, x)
-				}
-			}
-			arguments = append(arguments, "--cleanDestinationDir")
-			if common.Config.Hugo.Minify {
-				arguments = append(arguments, "--minify")
-			}
-			if len(bin) == 1 {
-				arguments = append(arguments, []string{"-s", path.Join(dir, "hugo")}...)
-			}
-			cmd = exec.Command(bin[0], arguments...)
-			buff = &bytes.Buffer{}
-			cmd.Stderr = buff
-			cmd.Stdout = buff
-			if err := cmd.Run(); err != nil {
-				stdout := buff.String()
-				stderr := err.Error()
-				logger.Errorf("%v\n%v", stdout, stderr)
+			if err := Rebuild(true); err != nil {
+				logger.Errorf("%v", err)
 			}
 			//
 			time.Sleep(1 * time.Second)
@@ -1281,6 +1306,7 @@ type HugoSettingsView struct {
 	Theme string `toml:"theme"`
 	LanguageCode string `toml:"languageCode"`
 	Paginate int `toml:"paginate"`
+	DisableKinds []string `toml:"disableKinds,omitempty"` // sitemap and robots.txt are made by render
 	Params struct {
 		Description string `toml:"description"`
 		Keywords string `toml:"keywords"`
@@ -1454,6 +1480,11 @@ func getPublisherSettingsHandler(c *fiber.Ctx) error {
 	conf.Enabled = common.Config.Publisher.Enabled
 	conf.Bin = common.Config.Publisher.Bin
 	conf.ApiToken = common.Config.Publisher.ApiToken
+	conf.Target = common.Config.Publisher.Target
+	conf.S3 = common.Config.Publisher.S3
+	conf.File = common.Config.Publisher.File
+	conf.CacheControl = common.Config.Publisher.CacheControl
+	conf.PagesCacheControl = common.Config.Publisher.PagesCacheControl
 	return c.JSON(conf)
 }
 
@@ -1492,6 +1523,43 @@ func putPublisherSettingsHandler(c *fiber.Ctx) error {
 			if v, found := data.Value["ApiToken"]; found && len(v) > 0 {
 				common.Config.Publisher.ApiToken = v[0]
 			}
+			if v, found := data.Value["Target"]; found && len(v) > 0 {
+				if value := strings.TrimSpace(v[0]); value == "" || value == "s3" || value == "file" {
+					common.Config.Publisher.Target = value
+				}else{
+					c.Status(http.StatusInternalServerError)
+					return c.JSON(HTTPError{"Unknown publisher target"})
+				}
+			}
+			if v, found := data.Value["S3.AccessKeyID"]; found && len(v) > 0 {
+				common.Config.Publisher.S3.AccessKeyID = v[0]
+			}
+			if v, found := data.Value["S3.SecretAccessKey"]; found && len(v) > 0 {
+				common.Config.Publisher.S3.SecretAccessKey = v[0]
+			}
+			if v, found := data.Value["S3.Region"]; found && len(v) > 0 {
+				common.Config.Publisher.S3.Region = v[0]
+			}
+			if v, found := data.Value["S3.Bucket"]; found && len(v) > 0 {
+				common.Config.Publisher.S3.Bucket = v[0]
+			}
+			if v, found := data.Value["S3.Prefix"]; found && len(v) > 0 {
+				common.Config.Publisher.S3.Prefix = v[0]
+			}
+			if v, found := data.Value["File.Path"]; found && len(v) > 0 {
+				common.Config.Publisher.File.Path = v[0]
+			}
+			if v, found := data.Value["File.Keep"]; found && len(v) > 0 {
+				if value, err := strconv.Atoi(v[0]); err == nil {
+					common.Config.Publisher.File.Keep = value
+				}
+			}
+			if v, found := data.Value["CacheControl"]; found && len(v) > 0 {
+				common.Config.Publisher.CacheControl = v[0]
+			}
+			if v, found := data.Value["PagesCacheControl"]; found && len(v) > 0 {
+				common.Config.Publisher.PagesCacheControl = v[0]
+			}
 			if err = common.Config.Save(); err != nil {
 				c.Status(http.StatusInternalServerError)
 				return c.JSON(HTTPError{err.Error()})
@@ -2234,9 +2302,15 @@ func putOrderHandler(c *fiber.Ctx) error {
 	if len(request.Comment) > 1024 {
 		request.Comment = request.Comment[0:1023]
 	}
+	status := order.Status
 	order.Status = request.Status
 	order.Comment = request.Comment
 	if err := models.UpdateOrder(common.Database, order); err == nil {
+		if status != order.Status && order.Status == models.ORDER_STATUS_READY_FOR_PICKUP && order.PickupLocationId > 0 {
+			if err = sendOrderReadyForPickupEmail(order); err != nil {
+				logger.Warningf("%+v", err)
+			}
+		}
 		return c.JSON(HTTPMessage{"OK"})
 	}else{
 		c.Status(http.StatusInternalServerError)
@@ -2868,6 +2942,9 @@ func delWidgetHandler(c *fiber.Ctx) error {
 		id, _ = strconv.Atoi(v)
 	}
 	if widget, err := models.GetWidget(common.Database, id); err == nil {
+		if err = models.DeleteTranslationsByObject(common.Database, models.TRANSLATION_OBJECT_WIDGET, widget.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
 		if err = models.DeleteWidget(common.Database, widget); err == nil {
 			return c.JSON(HTTPMessage{MESSAGE: "OK"})
 		}else{
@@ -3870,6 +3947,9 @@ func delEmailTemplateHandler(c *fiber.Ctx) error {
 		id, _ = strconv.Atoi(v)
 	}
 	if template, err := models.GetEmailTemplate(common.Database, id); err == nil {
+		if err = models.DeleteTranslationsByObject(common.Database, models.TRANSLATION_OBJECT_EMAIL_TEMPLATE, template.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
 		if err = models.DeleteEmailTemplate(common.Database, template); err == nil {
 			return c.JSON(HTTPMessage{MESSAGE: "OK"})
 		}else{
@@ -3923,6 +4003,8 @@ type VendorView struct{
 	Thumbnail string
 	Description string
 	Content string
+	Cutoff string `json:",omitempty"`
+	Holidays string `json:",omitempty"`
 	Times []TimeView `json:",omitempty"`
 }
 
@@ -3932,6 +4014,8 @@ type NewVendor struct {
 	Title string
 	Description string
 	Content string
+	Cutoff string
+	Holidays string
 }
 
 // @security BasicAuth
@@ -3977,12 +4061,22 @@ func postVendorHandler(c *fiber.Ctx) error {
 			if v, found := data.Value["Content"]; found && len(v) > 0 {
 				content = strings.TrimSpace(v[0])
 			}
+			var cutoff string
+			if v, found := data.Value["Cutoff"]; found && len(v) > 0 {
+				cutoff = strings.TrimSpace(v[0])
+			}
+			var holidays string
+			if v, found := data.Value["Holidays"]; found && len(v) > 0 {
+				holidays = strings.TrimSpace(v[0])
+			}
 			vendor := &models.Vendor {
 				Enabled: enabled,
 				Name:    name,
 				Title:   title,
 				Description: description,
 				Content: content,
+				Cutoff: cutoff,
+				Holidays: holidays,
 			}
 			if id, err := models.CreateVendor(common.Database, vendor); err == nil {
 				if v, found := data.File["Thumbnail"]; found && len(v) > 0 {
@@ -4249,10 +4343,20 @@ func putVendorHandler(c *fiber.Ctx) error {
 			if v, found := data.Value["Content"]; found && len(v) > 0 {
 				content = strings.TrimSpace(v[0])
 			}
+			var cutoff string
+			if v, found := data.Value["Cutoff"]; found && len(v) > 0 {
+				cutoff = strings.TrimSpace(v[0])
+			}
+			var holidays string
+			if v, found := data.Value["Holidays"]; found && len(v) > 0 {
+				holidays = strings.TrimSpace(v[0])
+			}
 			vendor.Enabled = enabled
 			vendor.Title = title
 			vendor.Description = description
 			vendor.Content = content
+			vendor.Cutoff = cutoff
+			vendor.Holidays = holidays
 			if v, found := data.Value["Thumbnail"]; found && len(v) > 0 && v[0] == "" {
 				// To delete existing
 				if vendor.Thumbnail != "" {
@@ -4399,6 +4503,9 @@ func delVendorHandler(c *fiber.Ctx) error {
 				return c.JSON(HTTPError{err.Error()})
 			}
 		}
+		if err = models.DeleteTranslationsByObject(common.Database, models.TRANSLATION_OBJECT_VENDOR, vendor.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
 		if err = models.DeleteVendor(common.Database, vendor); err == nil {
 			return c.JSON(HTTPMessage{MESSAGE: "OK"})
 		}else{
@@ -5314,6 +5421,7 @@ type FilterRequest ListRequest
 type ProductsFilterResponse struct {
 	Categories *models.CatalogItemView
 	Data []ProductsFilterItem
+	Facets *FilterFacetsView `json:",omitempty"`
 	FlatUrl bool `json:",omitempty"`
 	Filtered int64
 	Total int64
@@ -5388,94 +5496,7 @@ func postFilterHandler(c *fiber.Ctx) error {
 		request.Length = 100
 	}
 	// Filter
-	var search string
-	var keys1 []string
-	var values1 []interface{}
-	var keys2 []string
-	var values2 []interface{}
-	if len(request.Filter) > 0 {
-		for key, value := range request.Filter {
-			if key != "" && len(strings.TrimSpace(value)) > 0 {
-				switch key {
-				case "BasePrice", "Price", "SalePrice", "Width", "Height", "Depth", "Weight":
-					parts := strings.Split(value, "-")
-					if key == "Price" {
-						if len(parts) == 1 {
-							if v, err := strconv.ParseFloat(parts[0], 64); err == nil {
-								keys1 = append(keys1, "(cache_products.Base_Price == ? or cache_products.Sale_Price == ?)")
-								values1 = append(values1, math.Round(v * 1000) / 1000, math.Round(v * 1000) / 1000)
-							}
-						} else {
-							//
-							if v, err := strconv.ParseFloat(parts[0], 64); err == nil {
-								keys1 = append(keys1, "((cache_products.Base_Price >= ? or cache_products.Base_Price = ?) or (cache_products.Sale_Price >= ?))")
-								values1 = append(values1, math.Round(v * 1000) / 1000, 0, math.Round(v * 1000) / 1000)
-							}
-							if v, err := strconv.ParseFloat(parts[1], 64); err == nil {
-								keys1 = append(keys1, "(cache_products.Base_Price <= ? or cache_products.Sale_Price <= ?)")
-								values1 = append(values1, math.Round(v * 1000) / 1000, math.Round(v * 1000) / 1000)
-							}
-							//
-						}
-					} else {
-						if key == "BasePrice" {
-							key = "Base_Price"
-						}else if key == "SalePrice" {
-							key = "Sale_Price"
-						}
-						if len(parts) == 1 {
-							if v, err := strconv.ParseFloat(parts[0], 64); err == nil {
-								keys1 = append(keys1, "cache_products." + key + " == ?")
-								values1 = append(values1, math.Round(v * 1000) / 1000)
-							}
-						} else {
-							if v, err := strconv.ParseFloat(parts[0], 64); err == nil {
-								keys1 = append(keys1, "(cache_products." + key + " >= ? or cache_products." + key + " = ?)")
-								values1 = append(values1, math.Round(v * 1000) / 1000, 0)
-							}
-							if v, err := strconv.ParseFloat(parts[1], 64); err == nil {
-								keys1 = append(keys1, "cache_products." + key + " <= ?")
-								values1 = append(values1, math.Round(v * 1000) / 1000)
-							}
-						}
-					}
-				case "Search":
-					if v, err := url.QueryUnescape(value); err == nil {
-						search = strings.TrimSpace(v)
-					}else{
-						logger.Warningf("%+v", err)
-					}
-					keys1 = append(keys1, "(cache_products.Product_Id = ? or cache_products.Title like ? or cache_products.Description like ? or cache_products.Sku like ? or cache_products.Variations like ?)")
-					values1 = append(values1, search, "%" + search + "%", "%" + search + "%", "%" + search + "%", "%" + search + "%")
-				default:
-					if strings.Index(key, "Option-") >= -1 {
-						if res := regexp.MustCompile(`Option-(\d+)`).FindAllStringSubmatch(key, 1); len(res) > 0 && len(res[0]) > 1 {
-							if id, err := strconv.Atoi(res[0][1]); err == nil {
-								values := strings.Split(value, ",")
-								var keys3 []string
-								var values3 []interface{}
-								for _, value := range values {
-									if v, err := strconv.Atoi(value); err == nil {
-										keys3 = append(keys3, "parameters.Value_id = ? or rates.Value_Id = ?")
-										values3 = append(values3, v, v)
-									}
-								}
-								keys2 = append(keys2, fmt.Sprintf("(options.Id = ? and (%v))", strings.Join(keys3, " or ")))
-								values2 = append(values2, append([]interface{}{id}, values3...)...)
-							}
-						}
-					}else{
-						keys1 = append(keys1, fmt.Sprintf("products.%v like ?", key))
-						values1 = append(values1, "%"+strings.TrimSpace(value)+"%")
-					}
-				}
-			}
-		}
-	}
-	if len(keys2) > 0 {
-		keys1 = append(keys1, fmt.Sprintf("(%v)", strings.Join(keys2, " or ")))
-		values1 = append(values1, values2...)
-	}
+	keys1, values1, search := filterConditions(request.Filter, "")
 	//
 	var keys3 []string
 	var values3 []interface{}
@@ -5512,7 +5533,7 @@ func postFilterHandler(c *fiber.Ctx) error {
 		order = strings.Join(orders, ", ")
 	}
 	//logger.Infof("order: %+v", order)
-	rows, err := common.Database.Debug().Model(&models.CacheProduct{}).Select("cache_products.ID, cache_products.Name, cache_products.Title, cache_products.Path, cache_products.Description, cache_products.Thumbnail, cache_products.Images, cache_products.Variations, cache_products.Base_Price as BasePrice, cache_products.Price as Price, cache_products.Sale_Price as SalePrice, cache_products.Width as Width, cache_products.Height as Height, cache_products.Depth as Depth,  cache_products.Weight as Weight, cache_products.Category_Id as CategoryId").Joins("left join parameters on parameters.Product_ID = cache_products.Product_ID").Joins("left join variations on variations.Product_ID = cache_products.Product_ID").Joins("left join properties on properties.Product_ID = cache_products.Product_ID or properties.Variation_Id = variations.Id").Joins("left join options on options.Id = parameters.Option_Id or options.Id = properties.Option_Id").Joins("left join rates on rates.Property_Id = properties.Id").Where(strings.Join(keys1, " and "), values1...).Group("cache_products.category_id, cache_products.product_id")/*.Having(strings.Join(keys2, " and "), values2...)*/.Rows()
+	rows, err := common.Database.Debug().Model(&models.CacheProduct{}).Select("cache_products.ID, cache_products.Name, cache_products.Title, cache_products.Path, cache_products.Description, cache_products.Thumbnail, cache_products.Images, cache_products.Variations, cache_products.Base_Price as BasePrice, cache_products.Price as Price, cache_products.Sale_Price as SalePrice, cache_products.Width as Width, cache_products.Height as Height, cache_products.Depth as Depth,  cache_products.Weight as Weight, cache_products.Category_Id as CategoryId").Where(strings.Join(keys1, " and "), values1...).Group("cache_products.category_id, cache_products.product_id")/*.Having(strings.Join(keys2, " and "), values2...)*/.Rows()
 	if err == nil {
 		for rows.Next() {
 			var item ProductsFilterItem
@@ -5548,7 +5569,7 @@ func postFilterHandler(c *fiber.Ctx) error {
 		rows.Close()
 	}
 	//
-	rows, err = common.Database.Debug().Model(&models.CacheProduct{}).Select("cache_products.ID, cache_products.Name, cache_products.Title, cache_products.Path, cache_products.Description, cache_products.Thumbnail, cache_products.Images, cache_products.Variations, cache_products.Base_Price as BasePrice, cache_products.Price as Price, cache_products.Sale_Price as SalePrice, cache_products.Width as Width, cache_products.Height as Height, cache_products.Depth as Depth,  cache_products.Weight as Weight, cache_products.Product_Id as ProductId, cache_products.Category_Id as CategoryId").Joins("left join parameters on parameters.Product_ID = cache_products.Product_ID").Joins("left join variations on variations.Product_ID = cache_products.Product_ID").Joins("left join properties on properties.Product_ID = cache_products.Product_ID or properties.Variation_Id = variations.Id").Joins("left join options on options.Id = parameters.Option_Id or options.Id = properties.Option_Id").Joins("left join rates on rates.Property_Id = properties.Id").Where(strings.Join(append(keys1, keys3...), " and "), append(values1, values3...)...)/*.Having(strings.Join(keys2, " and "), values2...)*/.Group("cache_products.product_id").Order(order).Limit(request.Length).Offset(request.Start).Rows()
+	rows, err = common.Database.Debug().Model(&models.CacheProduct{}).Select("cache_products.ID, cache_products.Name, cache_products.Title, cache_products.Path, cache_products.Description, cache_products.Thumbnail, cache_products.Images, cache_products.Variations, cache_products.Base_Price as BasePrice, cache_products.Price as Price, cache_products.Sale_Price as SalePrice, cache_products.Width as Width, cache_products.Height as Height, cache_products.Depth as Depth,  cache_products.Weight as Weight, cache_products.Product_Id as ProductId, cache_products.Category_Id as CategoryId").Where(strings.Join(append(keys1, keys3...), " and "), append(values1, values3...)...)/*.Having(strings.Join(keys2, " and "), values2...)*/.Group("cache_products.product_id").Order(order).Limit(request.Length).Offset(request.Start).Rows()
 	if err == nil {
 		for rows.Next() {
 			var item ProductsFilterItem
@@ -5571,21 +5592,132 @@ func postFilterHandler(c *fiber.Ctx) error {
 		rows.Close()
 	}
 	//
-	common.Database.Debug().Model(&models.CacheProduct{}).Select("Product_ID as ID, Name, Title, Path, Description, Thumbnail, Base_Price, Price, SalePrice, Category_Id as CategoryId").Joins("left join parameters on parameters.Product_ID = cache_products.Product_ID").Joins("left join variations on variations.Product_ID = cache_products.Product_ID").Joins("left join properties on properties.Product_ID = cache_products.Product_ID or properties.Variation_Id = variations.Id").Joins("left join options on options.Id = parameters.Option_Id or options.Id = properties.Option_Id").Joins("left join rates on rates.Property_Id = properties.Id").Where(strings.Join(keys1, " and "), values1...).Count(&response.Filtered)
-	common.Database.Debug().Model(&models.CacheProduct{}).Select("Product_ID as ID, Name, Title, Path, Description, Thumbnail, Base_Price, Price, SalePrice, Category_Id as CategoryId").Joins("left join parameters on parameters.Product_ID = cache_products.Product_ID").Joins("left join variations on variations.Product_ID = cache_products.Product_ID").Joins("left join properties on properties.Product_ID = cache_products.Product_ID or properties.Variation_Id = variations.Id").Joins("left join options on options.Id = parameters.Option_Id or options.Id = properties.Option_Id").Joins("left join rates on rates.Property_Id = properties.Id").Where("Path LIKE ?", relPath + "%").Count(&response.Total)
+	common.Database.Debug().Model(&models.CacheProduct{}).Select("Product_ID as ID, Name, Title, Path, Description, Thumbnail, Base_Price, Price, SalePrice, Category_Id as CategoryId").Where(strings.Join(keys1, " and "), values1...).Count(&response.Filtered)
+	common.Database.Debug().Model(&models.CacheProduct{}).Select("Product_ID as ID, Name, Title, Path, Description, Thumbnail, Base_Price, Price, SalePrice, Category_Id as CategoryId").Where("Path LIKE ?", relPath + "%").Count(&response.Total)
+	// Facets
+	response.Facets = filterFacets(request, relPath)
 	//
 	response.FlatUrl = common.Config.FlatUrl
 	c.Status(http.StatusOK)
 	return c.JSON(response)
 }
 
+// filterConditions converts FilterRequest filter to where conditions over cache_products, exclude key is skipped to count its facet against the rest of filter
+func filterConditions(filter map[string]string, exclude string) (keys1 []string, values1 []interface{}, search string) {
+	var keys2 []string
+	var values2 []interface{}
+	if len(filter) > 0 {
+		for key, value := range filter {
+			if key != "" && key != exclude && len(strings.TrimSpace(value)) > 0 {
+				switch key {
+				case "BasePrice", "Price", "SalePrice", "Width", "Height", "Depth", "Weight":
+					parts := strings.Split(value, "-")
+					if key == "Price" {
+						if len(parts) == 1 {
+							if v, err := strconv.ParseFloat(parts[0], 64); err == nil {
+								keys1 = append(keys1, "(cache_products.Base_Price == ? or cache_products.Sale_Price == ?)")
+								values1 = append(values1, math.Round(v * 1000) / 1000, math.Round(v * 1000) / 1000)
+							}
+						} else {
+							//
+							if v, err := strconv.ParseFloat(parts[0], 64); err == nil {
+								keys1 = append(keys1, "((cache_products.Base_Price >= ? or cache_products.Base_Price = ?) or (cache_products.Sale_Price >= ?))")
+								values1 = append(values1, math.Round(v * 1000) / 1000, 0, math.Round(v * 1000) / 1000)
+							}
+							if v, err := strconv.ParseFloat(parts[1], 64); err == nil {
+								keys1 = append(keys1, "(cache_products.Base_Price <= ? or cache_products.Sale_Price <= ?)")
+								values1 = append(values1, math.Round(v * 1000) / 1000, math.Round(v * 1000) / 1000)
+							}
+							//
+						}
+					} else {
+						if key == "BasePrice" {
+							key = "Base_Price"
+						}else if key == "SalePrice" {
+							key = "Sale_Price"
+						}
+						if len(parts) == 1 {
+							if v, err := strconv.ParseFloat(parts[0], 64); err == nil {
+								keys1 = append(keys1, "cache_products." + key + " == ?")
+								values1 = append(values1, math.Round(v * 1000) / 1000)
+							}
+						} else {
+							if v, err := strconv.ParseFloat(parts[0], 64); err == nil {
+								keys1 = append(keys1, "(cache_products." + key + " >= ? or cache_products." + key + " = ?)")
+								values1 = append(values1, math.Round(v * 1000) / 1000, 0)
+							}
+							if v, err := strconv.ParseFloat(parts[1], 64); err == nil {
+								keys1 = append(keys1, "cache_products." + key + " <= ?")
+								values1 = append(values1, math.Round(v * 1000) / 1000)
+							}
+						}
+					}
+				case "Tag", "Vendor":
+					var ids []int
+					for _, v := range strings.Split(value, ",") {
+						if id, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+							ids = append(ids, id)
+						}
+					}
+					if len(ids) > 0 {
+						kind := models.CACHE_FACET_TAG
+						if key == "Vendor" {
+							kind = models.CACHE_FACET_VENDOR
+						}
+						keys1 = append(keys1, "cache_products.Product_Id in (select cache_facets.product_id from cache_facets where cache_facets.type = ? and cache_facets.value_id in ?)")
+						values1 = append(values1, kind, ids)
+					}
+				case "Search":
+					if v, err := url.QueryUnescape(value); err == nil {
+						search = strings.TrimSpace(v)
+					}else{
+						logger.Warningf("%+v", err)
+					}
+					keys1 = append(keys1, "(cache_products.Product_Id = ? or cache_products.Title like ? or cache_products.Description like ? or cache_products.Sku like ? or cache_products.Variations like ?)")
+					values1 = append(values1, search, "%" + search + "%", "%" + search + "%", "%" + search + "%", "%" + search + "%")
+				default:
+					if strings.Index(key, "Option-") >= -1 {
+						if res := regexp.MustCompile(`Option-(\d+)`).FindAllStringSubmatch(key, 1); len(res) > 0 && len(res[0]) > 1 {
+							if id, err := strconv.Atoi(res[0][1]); err == nil {
+								var ids []int
+								for _, value := range strings.Split(value, ",") {
+									if v, err := strconv.Atoi(value); err == nil {
+										ids = append(ids, v)
+									}
+								}
+								if len(ids) > 0 {
+									keys2 = append(keys2, "cache_products.Product_Id in (select cache_facets.product_id from cache_facets where cache_facets.type = ? and cache_facets.option_id = ? and cache_facets.value_id in ?)")
+									values2 = append(values2, models.CACHE_FACET_OPTION, id, ids)
+								}
+							}
+						}
+					}else{
+						keys1 = append(keys1, fmt.Sprintf("products.%v like ?", key))
+						values1 = append(values1, "%"+strings.TrimSpace(value)+"%")
+					}
+				}
+			}
+		}
+	}
+	if len(keys2) > 0 {
+		keys1 = append(keys1, fmt.Sprintf("(%v)", strings.Join(keys2, " or ")))
+		values1 = append(values1, values2...)
+	}
+	return keys1, values1, search
+}
+
 type SearchRequest struct {
 	Term string
+	Page int
 	Limit int
 }
 
 type SearchResult struct {
 	Term string
+	Corrected string `json:",omitempty"` // query used instead of Term when it had typos
+	Total int64
+	Page int
+	Limit int
 	Products []SearchResultProductView
 }
 
@@ -5594,6 +5726,8 @@ type SearchResultProductView struct {
 	Path string `json:",omitempty"`
 	BasePrice float64 `json:",omitempty"`
 	SalePrice float64 `json:",omitempty"`
+	Highlight string `json:",omitempty"` // snippet with matched words wrapped to <mark>
+	Rank float64
 }
 
 type NewStripePayment struct {
@@ -5820,6 +5954,8 @@ type ProductView struct {
 	Sale bool `json:",omitempty"`
 	Start *time.Time `json:",omitempty"`
 	End *time.Time `json:",omitempty"`
+	PublishStart *time.Time `json:",omitempty"`
+	PublishEnd *time.Time `json:",omitempty"`
 	MinQuantity int `json:",omitempty"`
 	MaxQuantity int `json:",omitempty"`
 	PurchasableMultiply int `json:",omitempty"`
@@ -5851,6 +5987,10 @@ type ProductView struct {
 	Tags []TagView                      `json:",omitempty"`
 	RelatedProducts []RelatedProduct    `json:",omitempty"`
 	//
+	Bundle bool `json:",omitempty"`
+	BundlePricing string `json:",omitempty"`
+	BundleDiscount float64 `json:",omitempty"`
+	//
 	Customization string `json:",omitempty"`
 	New bool `json:",omitempty"`
 	Rendered *time.Time `json:",omitempty"`
@@ -5915,6 +6055,8 @@ type VariationView struct {
 	Sale bool `json:",omitempty"`
 	Start *time.Time `json:",omitempty"`
 	End *time.Time `json:",omitempty"`
+	PublishStart *time.Time `json:",omitempty"`
+	PublishEnd *time.Time `json:",omitempty"`
 	MinQuantity int `json:",omitempty"`
 	MaxQuantity int `json:",omitempty"`
 	PurchasableMultiply int `json:",omitempty"`
@@ -6207,6 +6349,46 @@ type DiscountCost struct {
 	Delivery float64 // affect to delivery price
 }
 
+// MarkChanged appends messages to changes file, the file means the site should be rendered and published again
+func MarkChanged(messages ...string) error {
+	p := path.Join(dir, HAS_CHANGES)
+	if pp := path.Dir(p); len(pp) > 0 {
+		if _, err := os.Stat(pp); err != nil {
+			if err = os.MkdirAll(pp, 0755); err != nil {
+				logger.Warningf("%v", err)
+			}
+		}
+	}
+	file, err := os.OpenFile(p,
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	for _, message := range messages {
+		if _, err := file.WriteString(message + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touchProducts marks products changed for incremental render if change itself can not be found by updated_at
+func touchProducts(ids ...uint) {
+	if err := models.TouchProducts(common.Database, ids); err != nil {
+		logger.Warningf("%+v", err)
+	}
+}
+
+// touchVariations marks products of variations changed for incremental render
+func touchVariations(ids ...uint) {
+	for _, id := range ids {
+		if variation, err := models.GetVariation(common.Database, int(id)); err == nil {
+			touchProducts(variation.ProductId)
+		}
+	}
+}
+
 func SendOrderPaidEmail(to *mail.Email, orderId int, template *models.EmailTemplate) error {
 	vars := make(map[string]interface{})
 	if order, err := models.GetOrderFull(common.Database, orderId); err == nil {
@@ -6227,6 +6409,18 @@ func SendOrderPaidEmail(to *mail.Email, orderId int, template *models.EmailTempl
 			orderView.Status = order.Status
 			vars["Order"] = orderView
 		}
+		if order.PickupLocationId > 0 {
+			if location, err := models.GetPickupLocation(common.Database, int(order.PickupLocationId)); err == nil {
+				vars["Pickup"] = location
+			}else{
+				logger.Warningf("%+v", err)
+			}
+		}
+		if downloads, err := GetOrderDownloads(order); err == nil {
+			vars["Downloads"] = downloads
+		}else{
+			logger.Warningf("%+v", err)
+		}
 		if bts, err := json.Marshal(vars); err == nil {
 			logger.Infof("vars: %+v", string(bts))
 		}else{
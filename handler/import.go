@@ -0,0 +1,991 @@
+package handler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"gorm.io/gorm"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ProductsCSVHeader is used by both import and export, one row per product or variation
+	ProductsCSVHeader = []string{"Product", "Variation", "Sku", "Enabled", "Title", "Description", "BasePrice", "SalePrice", "ItemPrice", "Availability", "Stock", "Width", "Height", "Depth", "Weight", "Categories", "Tags", "Images", "Properties", "Prices"}
+	productsCSVFields = []string{"Sku", "Enabled", "Title", "Description", "BasePrice", "SalePrice", "ItemPrice", "Availability", "Stock", "Width", "Height", "Depth", "Weight"}
+)
+
+type ImportResult struct {
+	DryRun bool
+	Rows int
+	Created int
+	Updated int
+	Unchanged int
+	Failed int
+	Changes []ImportRowView `json:",omitempty"`
+	Errors []ImportErrorView `json:",omitempty"`
+}
+
+type ImportRowView struct {
+	Row int
+	Product string
+	Variation string `json:",omitempty"`
+	Action string // create, update
+	Diff []ImportDiffView `json:",omitempty"`
+	published []revisionOwner
+}
+
+type ImportDiffView struct {
+	Field string
+	Old string `json:",omitempty"`
+	New string
+}
+
+type ImportErrorView struct {
+	Row int
+	Message string
+}
+
+// @security BasicAuth
+// ImportProducts godoc
+// @Summary Import products from csv, use DryRun=true to get diff without any changes
+// @Accept multipart/form-data
+// @Produce json
+// @Param File formData file true "csv file"
+// @Param DryRun formData bool false "dry run"
+// @Success 200 {object} ImportResult
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/products/import [post]
+// @Tags product
+func postProductsImportHandler(c *fiber.Ctx) error {
+	var dryRun bool
+	if v := c.Query("DryRun"); v != "" {
+		dryRun, _ = strconv.ParseBool(v)
+	}
+	var reader io.Reader
+	if contentType := string(c.Request().Header.ContentType()); strings.HasPrefix(contentType, fiber.MIMEMultipartForm) {
+		data, err := c.Request().MultipartForm()
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{err.Error()})
+		}
+		if v, found := data.Value["DryRun"]; found && len(v) > 0 {
+			dryRun, _ = strconv.ParseBool(v[0])
+		}
+		if v, found := data.File["File"]; found && len(v) > 0 {
+			file, err := v[0].Open()
+			if err != nil {
+				c.Status(http.StatusInternalServerError)
+				return c.JSON(HTTPError{err.Error()})
+			}
+			defer file.Close()
+			reader = file
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{"File is not defined"})
+		}
+	}else if strings.HasPrefix(contentType, "text/csv") {
+		reader = strings.NewReader(string(c.Body()))
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{"Unsupported Content-Type"})
+	}
+	result, err := ImportProductsCSV(reader, dryRun)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	if !dryRun && result.Created + result.Updated > 0 {
+		if err = MarkChanged(fmt.Sprintf("products imported: %d created, %d updated", result.Created, result.Updated)); err != nil {
+			logger.Warningf("%+v", err)
+		}
+	}
+	return c.JSON(result)
+}
+
+// @security BasicAuth
+// ExportProducts godoc
+// @Summary Export products to csv in the same format as import
+// @Produce text/csv
+// @Success 200 {string} string
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/products/export [get]
+// @Tags product
+func getProductsExportHandler(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="products-%v.csv"`, time.Now().Format("20060102150405")))
+	if err := ExportProductsCSV(c.Response().BodyWriter()); err != nil {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		c.Response().ResetBody()
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	return nil
+}
+
+// ImportProductsCSV creates or updates products and variations keyed by Name or Sku, rows with errors are skipped
+func ImportProductsCSV(reader io.Reader, dryRun bool) (*ImportResult, error) {
+	r := csv.NewReader(reader)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))] = i
+	}
+	if _, found := columns["Product"]; !found {
+		return nil, fmt.Errorf("column Product is required")
+	}
+	result := &ImportResult{DryRun: dryRun}
+	planned := make(map[string]bool)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var line int
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				line = parseErr.StartLine
+			}
+			result.Errors = append(result.Errors, ImportErrorView{Row: line, Message: err.Error()})
+			result.Failed ++
+			continue
+		}
+		// quoted values may span several lines
+		n, _ := r.FieldPos(0)
+		row := make(map[string]string)
+		for column, i := range columns {
+			if i < len(record) {
+				row[column] = strings.TrimSpace(record[i])
+			}
+		}
+		result.Rows ++
+		var view *ImportRowView
+		if dryRun {
+			view, err = importProductRow(common.Database, row, true, planned, nil)
+		}else{
+			// Images are downloaded before transaction, slow hosts do not hold database
+			var images csvImages
+			if images, err = fetchCSVImages(common.Database, row); err == nil {
+				err = common.Database.Transaction(func(tx *gorm.DB) error {
+					var err error
+					view, err = importProductRow(tx, row, false, planned, images)
+					return err
+				})
+				images.remove(err != nil)
+			}
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, ImportErrorView{Row: n, Message: err.Error()})
+			result.Failed ++
+			continue
+		}
+		view.Row = n
+		reindexOwners(view.published)
+		switch {
+		case view.Action == "create":
+			result.Created ++
+		case len(view.Diff) > 0:
+			result.Updated ++
+		default:
+			result.Unchanged ++
+			continue
+		}
+		result.Changes = append(result.Changes, *view)
+	}
+	return result, nil
+}
+
+func importProductRow(tx *gorm.DB, row map[string]string, dryRun bool, planned map[string]bool, fetched csvImages) (*ImportRowView, error) {
+	name := row["Product"]
+	if name == "" {
+		return nil, fmt.Errorf("Product is not defined")
+	}
+	view := &ImportRowView{Product: name, Variation: row["Variation"], Action: "update"}
+	// Product
+	product, variation := findCSVTarget(tx, row)
+	if product == nil && row["Variation"] != "" && !planned[name] {
+		return nil, fmt.Errorf("product %v not found", name)
+	}
+	// Target
+	var target interface{}
+	var err error
+	if row["Variation"] == "" {
+		if product == nil {
+			view.Action = "create"
+			product = &models.Product{Name: name, Title: name}
+			if dryRun {
+				planned[name] = true
+			}
+		}
+		target = product
+	}else{
+		if variation == nil {
+			view.Action = "create"
+			variation = &models.Variation{Enabled: true, Name: row["Variation"], Title: row["Variation"]}
+		}
+		target = variation
+	}
+	// Fields
+	value := reflect.ValueOf(target).Elem()
+	for _, field := range productsCSVFields {
+		if v, found := row[field]; found {
+			if old, changed, err := setCSVField(value.FieldByName(field), v); err == nil {
+				if changed {
+					view.Diff = append(view.Diff, ImportDiffView{Field: field, Old: old, New: v})
+				}
+			}else{
+				return nil, fmt.Errorf("%v: %v", field, err)
+			}
+		}
+	}
+	// Validate relations before any change
+	var categories []*models.Category
+	if v, found := row["Categories"]; found && variation == nil {
+		for _, p := range splitCSVList(v) {
+			var category *models.Category
+			var parentId uint
+			for _, chunk := range strings.Split(strings.Trim(p, "/"), "/") {
+				if category, err = models.GetCategoryByNameAndParentId(tx, strings.TrimSpace(chunk), parentId); err != nil {
+					return nil, fmt.Errorf("category %v not found", p)
+				}
+				parentId = category.ID
+			}
+			if category != nil {
+				categories = append(categories, category)
+			}
+		}
+	}
+	properties, err := parseCSVProperties(tx, row["Properties"])
+	if err != nil {
+		return nil, err
+	}
+	prices, err := parseCSVPrices(row["Prices"])
+	if err != nil {
+		return nil, err
+	}
+	// Old values to compare with
+	var oldCategories, oldTags, oldImages, oldProperties, oldPrices string
+	var images []*models.Image
+	if view.Action == "update" {
+		if variation == nil {
+			if cs, err := models.GetCategoriesOfProduct(tx, product); err == nil {
+				oldCategories = formatCSVCategories(tx, cs)
+			}
+			if tags, err := models.GetTagsOfProduct(tx, product); err == nil {
+				oldTags = formatCSVTags(tags)
+			}
+			images, _ = models.GetImagesOfProduct(tx, product)
+			if ps, err := models.GetPropertiesByProductId(tx, int(product.ID)); err == nil {
+				oldProperties = formatCSVProperties(ps)
+				if pr, err := models.GetPricesByProductId(tx, product.ID); err == nil {
+					oldPrices = formatCSVPrices(pr, ps)
+				}
+			}
+		}else{
+			if v, err := models.GetVariation(tx, int(variation.ID)); err == nil {
+				images = v.Images
+				oldProperties = formatCSVProperties(v.Properties)
+				oldPrices = formatCSVPrices(v.Prices, v.Properties)
+			}
+		}
+		oldImages = formatCSVImages(images)
+	}
+	for _, item := range []struct{ Field, Old string }{{"Categories", oldCategories}, {"Tags", oldTags}, {"Images", oldImages}, {"Properties", oldProperties}, {"Prices", oldPrices}} {
+		if v, found := row[item.Field]; found && (variation == nil || (item.Field != "Categories" && item.Field != "Tags")) {
+			if normalizeCSVList(v) != normalizeCSVList(item.Old) {
+				view.Diff = append(view.Diff, ImportDiffView{Field: item.Field, Old: item.Old, New: v})
+			}
+		}
+	}
+	if dryRun {
+		return view, nil
+	}
+	changed := view.Action == "create" || len(view.Diff) > 0
+	if changed && view.Action == "update" {
+		if variation == nil {
+			initialRevisions(tx, ownerOf(product.ID, 0), 0)
+		}else{
+			initialRevisions(tx, ownerOf(product.ID, variation.ID), 0)
+		}
+	}
+	// Save
+	if variation == nil {
+		if view.Action == "create" {
+			if _, err = models.CreateProduct(tx, product); err != nil {
+				return nil, err
+			}
+		}else if err = models.UpdateProduct(tx, product); err != nil {
+			return nil, err
+		}
+	}else{
+		variation.ProductId = product.ID
+		if view.Action == "create" {
+			if _, err = models.CreateVariation(tx, variation); err != nil {
+				return nil, err
+			}
+		}else if err = models.UpdateVariation(tx, variation); err != nil {
+			return nil, err
+		}
+	}
+	// Categories
+	if _, found := row["Categories"]; found && variation == nil {
+		if err = models.DeleteAllCategoriesFromProduct(tx, product); err != nil {
+			return nil, err
+		}
+		for _, category := range categories {
+			if err = models.AddProductToCategory(tx, category, product); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Tags
+	if v, found := row["Tags"]; found && variation == nil {
+		if err = models.DeleteAllTagsFromProduct(tx, product); err != nil {
+			return nil, err
+		}
+		for _, title := range splitCSVList(v) {
+			name := strings.Trim(reNotAbc.ReplaceAllString(strings.ToLower(title), "-"), "-")
+			var tag *models.Tag
+			if tags, err := models.GetTagsByName(tx, name); err == nil && len(tags) > 0 {
+				tag = tags[0]
+			}else{
+				tag = &models.Tag{Enabled: true, Name: name, Title: title}
+				if _, err = models.CreateTag(tx, tag); err != nil {
+					return nil, err
+				}
+			}
+			if err = models.AddProductToTag(tx, tag, product); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Images
+	if v, found := row["Images"]; found {
+		for _, url := range splitCSVList(v) {
+			if hasCSVImage(images, url) {
+				continue
+			}
+			img, err := fetched.save(tx, url)
+			if err != nil {
+				return nil, fmt.Errorf("image %v: %v", url, err)
+			}
+			images = append(images, img)
+			if variation == nil {
+				if err = models.AddImageToProduct(tx, product, img); err != nil {
+					return nil, err
+				}
+				if product.ImageId == 0 {
+					product.ImageId = img.ID
+					if err = models.UpdateProduct(tx, product); err != nil {
+						return nil, err
+					}
+				}
+			}else if err = models.AddImageToVariation(tx, variation, img); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Properties, options and values missing in the row are removed with prices using them
+	if _, found := row["Properties"]; found {
+		var existing []*models.Property
+		if variation == nil {
+			existing, err = models.GetPropertiesByProductId(tx, int(product.ID))
+		}else{
+			existing, err = models.GetPropertiesByVariationId(tx, int(variation.ID))
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range existing {
+			var property *models.Property
+			for _, item := range properties {
+				if item.Option.Name == p.Name {
+					property = item
+					break
+				}
+			}
+			for _, rate := range p.Rates {
+				var found bool
+				if property != nil {
+					for _, item := range property.Rates {
+						if item.Value.ID == rate.ValueId {
+							found = true
+							break
+						}
+					}
+				}
+				if !found {
+					if err = models.DeleteRate(tx, rate); err != nil {
+						return nil, err
+					}
+				}
+			}
+			if property == nil {
+				if err = models.DeleteProperty(tx, p); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	// Properties
+	for _, property := range properties {
+		var existing []*models.Property
+		if variation == nil {
+			existing, _ = models.GetPropertiesByProductAndName(tx, int(product.ID), property.Option.Name)
+		}else{
+			existing, _ = models.GetPropertiesByVariationAndName(tx, int(variation.ID), property.Option.Name)
+		}
+		var p *models.Property
+		if len(existing) > 0 {
+			p = existing[0]
+		}else{
+			p = &models.Property{Type: "select", Name: property.Option.Name, Title: property.Option.Title, OptionId: property.Option.ID}
+			if variation == nil {
+				p.ProductId = product.ID
+			}else{
+				p.VariationId = variation.ID
+			}
+			if _, err = models.CreateProperty(tx, p); err != nil {
+				return nil, err
+			}
+		}
+		for _, rate := range property.Rates {
+			if rates, err := models.GetRatesByPropertyAndValue(tx, p.ID, rate.Value.ID); err == nil && len(rates) > 0 {
+				rates[0].Price = rate.Price
+				if err = models.UpdateRate(tx, rates[0]); err != nil {
+					return nil, err
+				}
+			}else if _, err = models.CreateRate(tx, &models.Rate{PropertyId: p.ID, ValueId: rate.Value.ID, Enabled: true, Price: rate.Price}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Prices
+	if len(prices) > 0 {
+		var ps []*models.Property
+		var existing []*models.Price
+		if variation == nil {
+			ps, _ = models.GetPropertiesByProductId(tx, int(product.ID))
+			existing, _ = models.GetPricesByProductId(tx, product.ID)
+		}else{
+			ps, _ = models.GetPropertiesByVariationId(tx, int(variation.ID))
+			existing, _ = models.GetPricesByVariationId(tx, variation.ID)
+		}
+		keys := csvRateKeys(ps)
+		for _, price := range prices {
+			var rates []*models.Rate
+			for _, key := range price.Keys {
+				if rate, found := keys[key]; found {
+					rates = append(rates, rate)
+				}else{
+					return nil, fmt.Errorf("price %v: property value %v not found", strings.Join(price.Keys, "+"), key)
+				}
+			}
+			var p *models.Price
+			for _, e := range existing {
+				if sameCSVRates(e.Rates, rates) {
+					p = e
+					break
+				}
+			}
+			if p == nil {
+				p = &models.Price{Enabled: true, Rates: rates, BasePrice: price.BasePrice, SalePrice: price.SalePrice}
+				if variation == nil {
+					p.ProductId = product.ID
+				}else{
+					p.VariationId = variation.ID
+				}
+				if _, err = models.CreatePrice(tx, p); err != nil {
+					return nil, err
+				}
+			}else{
+				p.BasePrice = price.BasePrice
+				p.SalePrice = price.SalePrice
+				if err = models.UpdatePrice(tx, p); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	// Revisions
+	if changed {
+		if variation == nil {
+			view.published = ownerRevisions(tx, ownerOf(product.ID, 0), false, "imported", 0)
+		}else{
+			view.published = ownerRevisions(tx, ownerOf(product.ID, variation.ID), false, "imported", 0)
+		}
+	}
+	return view, nil
+}
+
+// ExportProductsCSV writes all products and variations in the format accepted by ImportProductsCSV
+func ExportProductsCSV(writer io.Writer) error {
+	w := csv.NewWriter(writer)
+	if err := w.Write(ProductsCSVHeader); err != nil {
+		return err
+	}
+	products, err := models.GetProducts(common.Database)
+	if err != nil {
+		return err
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].ID < products[j].ID
+	})
+	for _, product := range products {
+		if product, err = models.GetProductFull(common.Database, int(product.ID)); err != nil {
+			return err
+		}
+		record := formatCSVRecord(product, "")
+		record = append(record, formatCSVCategories(common.Database, product.Categories), formatCSVTags(product.Tags), formatCSVImages(product.Images), formatCSVProperties(product.Properties), formatCSVPrices(product.Prices, product.Properties))
+		if err = w.Write(record); err != nil {
+			return err
+		}
+		for _, variation := range product.Variations {
+			record := formatCSVRecord(variation, product.Name)
+			record = append(record, "", "", formatCSVImages(variation.Images), formatCSVProperties(variation.Properties), formatCSVPrices(variation.Prices, variation.Properties))
+			if err = w.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func formatCSVRecord(target interface{}, productName string) []string {
+	value := reflect.ValueOf(target).Elem()
+	name := value.FieldByName("Name").String()
+	var record []string
+	if productName == "" {
+		record = append(record, name, "")
+	}else{
+		record = append(record, productName, name)
+	}
+	for _, field := range productsCSVFields {
+		record = append(record, formatCSVField(value.FieldByName(field)))
+	}
+	return record
+}
+
+func formatCSVField(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	default:
+		return v.String()
+	}
+}
+
+// setCSVField sets value to field and returns old value and was it changed or not
+func setCSVField(v reflect.Value, value string) (string, bool, error) {
+	old := formatCSVField(v)
+	switch v.Kind() {
+	case reflect.Bool:
+		if value == "" {
+			value = "false"
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return old, false, err
+		}
+		v.SetBool(b)
+	case reflect.Float32, reflect.Float64:
+		if value == "" {
+			value = "0"
+		}
+		f, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+		if err != nil {
+			return old, false, err
+		}
+		v.SetFloat(f)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value == "" {
+			value = "0"
+		}
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return old, false, err
+		}
+		v.SetUint(n)
+	case reflect.String:
+		v.SetString(value)
+	default:
+		return old, false, fmt.Errorf("unsupported field type %v", v.Kind())
+	}
+	return old, old != formatCSVField(v), nil
+}
+
+func splitCSVList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, "|") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func normalizeCSVList(value string) string {
+	items := splitCSVList(value)
+	sort.Strings(items)
+	return strings.Join(items, "|")
+}
+
+func formatCSVCategories(connector *gorm.DB, categories []*models.Category) string {
+	var items []string
+	for _, category := range categories {
+		var chunks []string
+		for _, crumb := range models.GetBreadcrumbs(connector, category.ID) {
+			if crumb.ID > 0 {
+				chunks = append(chunks, crumb.Name)
+			}
+		}
+		items = append(items, strings.Join(chunks, "/"))
+	}
+	return strings.Join(items, "|")
+}
+
+func formatCSVTags(tags []*models.Tag) string {
+	var items []string
+	for _, tag := range tags {
+		items = append(items, tag.Title)
+	}
+	return strings.Join(items, "|")
+}
+
+func formatCSVImages(images []*models.Image) string {
+	var items []string
+	for _, img := range images {
+		if img.Path != "" {
+			items = append(items, common.Config.Base + img.Path)
+		}else if img.Url != "" {
+			items = append(items, img.Url)
+		}
+	}
+	return strings.Join(items, "|")
+}
+
+func csvOptionName(property *models.Property) string {
+	if property.Option != nil {
+		return property.Option.Name
+	}
+	return property.Name
+}
+
+// formatCSVProperties returns list like color:red=0|color:blue=1.5
+func formatCSVProperties(properties []*models.Property) string {
+	var items []string
+	for _, property := range properties {
+		for _, rate := range property.Rates {
+			if rate.Value != nil {
+				items = append(items, fmt.Sprintf("%v:%v=%v", csvOptionName(property), rate.Value.Value, strconv.FormatFloat(rate.Price, 'f', -1, 64)))
+			}
+		}
+	}
+	return strings.Join(items, "|")
+}
+
+// formatCSVPrices returns list like color:red+size:xl=12.5/10
+func formatCSVPrices(prices []*models.Price, properties []*models.Property) string {
+	names := make(map[uint]string)
+	for key, rate := range csvRateKeys(properties) {
+		names[rate.ID] = key
+	}
+	var items []string
+	for _, price := range prices {
+		var keys []string
+		for _, rate := range price.Rates {
+			if key, found := names[rate.ID]; found {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		sort.Strings(keys)
+		item := strings.Join(keys, "+") + "=" + strconv.FormatFloat(price.BasePrice, 'f', -1, 64)
+		if price.SalePrice > 0 {
+			item += "/" + strconv.FormatFloat(price.SalePrice, 'f', -1, 64)
+		}
+		items = append(items, item)
+	}
+	return strings.Join(items, "|")
+}
+
+func csvRateKeys(properties []*models.Property) map[string]*models.Rate {
+	keys := make(map[string]*models.Rate)
+	for _, property := range properties {
+		for _, rate := range property.Rates {
+			if rate.Value != nil {
+				keys[csvOptionName(property) + ":" + rate.Value.Value] = rate
+			}
+		}
+	}
+	return keys
+}
+
+func sameCSVRates(a, b []*models.Rate) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ids := make(map[uint]bool)
+	for _, rate := range a {
+		ids[rate.ID] = true
+	}
+	for _, rate := range b {
+		if !ids[rate.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+func parseCSVProperties(connector *gorm.DB, value string) ([]*models.Property, error) {
+	var properties []*models.Property
+	for _, item := range splitCSVList(value) {
+		var key, price string
+		if i := strings.LastIndex(item, "="); i > -1 {
+			key, price = item[:i], item[i + 1:]
+		}else{
+			key = item
+		}
+		chunks := strings.SplitN(key, ":", 2)
+		if len(chunks) != 2 {
+			return nil, fmt.Errorf("property %v should be option:value=price", item)
+		}
+		var property *models.Property
+		for _, p := range properties {
+			if p.Option.Name == chunks[0] {
+				property = p
+				break
+			}
+		}
+		if property == nil {
+			options, err := models.GetOptionsByName(connector, chunks[0])
+			if err != nil || len(options) == 0 {
+				return nil, fmt.Errorf("option %v not found", chunks[0])
+			}
+			property = &models.Property{Option: options[0]}
+			properties = append(properties, property)
+		}
+		v, err := models.GetValueByOptionIdAndValue(connector, int(property.Option.ID), chunks[1])
+		if err != nil {
+			if v, err = models.GetValueByOptionIdAndTitle(connector, int(property.Option.ID), chunks[1]); err != nil {
+				return nil, fmt.Errorf("value %v of option %v not found", chunks[1], chunks[0])
+			}
+		}
+		rate := &models.Rate{Value: v}
+		if price != "" {
+			if rate.Price, err = strconv.ParseFloat(price, 64); err != nil {
+				return nil, fmt.Errorf("property %v: %v", item, err)
+			}
+		}
+		property.Rates = append(property.Rates, rate)
+	}
+	return properties, nil
+}
+
+type csvPrice struct {
+	Keys []string
+	BasePrice float64
+	SalePrice float64
+}
+
+func parseCSVPrices(value string) ([]csvPrice, error) {
+	var prices []csvPrice
+	for _, item := range splitCSVList(value) {
+		i := strings.LastIndex(item, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("price %v should be option:value+option:value=base/sale", item)
+		}
+		price := csvPrice{Keys: strings.Split(item[:i], "+")}
+		amounts := strings.SplitN(item[i + 1:], "/", 2)
+		var err error
+		if price.BasePrice, err = strconv.ParseFloat(amounts[0], 64); err != nil {
+			return nil, fmt.Errorf("price %v: %v", item, err)
+		}
+		if len(amounts) > 1 {
+			if price.SalePrice, err = strconv.ParseFloat(amounts[1], 64); err != nil {
+				return nil, fmt.Errorf("price %v: %v", item, err)
+			}
+		}
+		prices = append(prices, price)
+	}
+	return prices, nil
+}
+
+// findCSVTarget returns existing product and variation of row, nil if they are not found
+func findCSVTarget(connector *gorm.DB, row map[string]string) (*models.Product, *models.Variation) {
+	product, err := models.GetProductByName(connector, row["Product"])
+	if err != nil && row["Variation"] == "" && row["Sku"] != "" {
+		product, err = models.GetProductBySku(connector, row["Sku"])
+	}
+	if err != nil {
+		return nil, nil
+	}
+	if row["Variation"] == "" {
+		return product, nil
+	}
+	if variations, err := models.GetVariationsByProductAndName(connector, product.ID, row["Variation"]); err == nil && len(variations) > 0 {
+		return product, variations[0]
+	}
+	if row["Sku"] != "" {
+		if variations, err := models.GetVariationsByProductAndSku(connector, product.ID, row["Sku"]); err == nil && len(variations) > 0 {
+			return product, variations[0]
+		}
+	}
+	return product, nil
+}
+
+func hasCSVImage(images []*models.Image, url string) bool {
+	for _, img := range images {
+		if img.Url == url || img.Path == strings.TrimPrefix(url, common.Config.Base) || img.Name == strings.TrimSuffix(path.Base(url), path.Ext(url)) {
+			return true
+		}
+	}
+	return false
+}
+
+// csvImage is image file downloaded before transaction of row, its record is created in transaction
+type csvImage struct {
+	Name string
+	Ext string
+	File string // temporary file, then file of record
+	Size int64
+	Width int
+	Height int
+	saved bool
+}
+
+type csvImages map[string]*csvImage
+
+// fetchCSVImages downloads images of row which target does not have yet
+func fetchCSVImages(connector *gorm.DB, row map[string]string) (csvImages, error) {
+	urls := splitCSVList(row["Images"])
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	var images []*models.Image
+	if product, variation := findCSVTarget(connector, row); variation != nil {
+		if v, err := models.GetVariation(connector, int(variation.ID)); err == nil {
+			images = v.Images
+		}
+	}else if product != nil && row["Variation"] == "" {
+		images, _ = models.GetImagesOfProduct(connector, product)
+	}
+	fetched := make(csvImages)
+	for _, url := range urls {
+		if hasCSVImage(images, url) || fetched[url] != nil {
+			continue
+		}
+		img, err := downloadCSVImage(url)
+		if err != nil {
+			fetched.remove(true)
+			return nil, fmt.Errorf("image %v: %v", url, err)
+		}
+		fetched[url] = img
+	}
+	return fetched, nil
+}
+
+func downloadCSVImage(url string) (*csvImage, error) {
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	p := path.Join(dir, "storage", "images")
+	if _, err := os.Stat(p); err != nil {
+		if err = os.MkdirAll(p, 0755); err != nil {
+			return nil, err
+		}
+	}
+	img := &csvImage{Name: strings.TrimSuffix(path.Base(url), path.Ext(url)), Ext: path.Ext(url)}
+	if i := strings.Index(img.Ext, "?"); i > -1 {
+		img.Ext = img.Ext[:i]
+	}
+	out, err := ioutil.TempFile(p, ".import-*")
+	if err != nil {
+		return nil, err
+	}
+	img.File = out.Name()
+	if img.Size, err = io.Copy(out, resp.Body); err != nil {
+		out.Close()
+		os.Remove(img.File)
+		return nil, err
+	}
+	out.Close()
+	if reader, err := os.Open(img.File); err == nil {
+		if config, _, err := image.DecodeConfig(reader); err == nil {
+			img.Width = config.Width
+			img.Height = config.Height
+		}
+		reader.Close()
+	}
+	return img, nil
+}
+
+// save creates record of downloaded image and moves file under its name
+func (images csvImages) save(connector *gorm.DB, url string) (*models.Image, error) {
+	fetched, found := images[url]
+	if !found {
+		return nil, fmt.Errorf("image is not downloaded")
+	}
+	img := &models.Image{Name: fetched.Name, Size: fetched.Size, Width: fetched.Width, Height: fetched.Height}
+	if _, err := models.CreateImage(connector, img); err != nil {
+		return nil, err
+	}
+	filename := fmt.Sprintf("%d-%s%s", img.ID, reNotAbc.ReplaceAllString(fetched.Name, "-"), fetched.Ext)
+	p := path.Join(dir, "storage", "images", filename)
+	if err := os.Rename(fetched.File, p); err != nil {
+		return nil, err
+	}
+	fetched.File = p
+	fetched.saved = true
+	img.Path = "/" + path.Join("images", filename)
+	if err := models.UpdateImage(connector, img); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// remove deletes files not used by records, saved ones too if transaction is rolled back
+func (images csvImages) remove(all bool) {
+	for _, img := range images {
+		if !img.saved || all {
+			if err := os.Remove(img.File); err != nil && !os.IsNotExist(err) {
+				logger.Warningf("%+v", err)
+			}
+		}
+	}
+}
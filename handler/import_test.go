@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"bytes"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/config"
+	"github.com/yonnic/goshop/models"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+)
+
+func openImportDatabase(t *testing.T) {
+	openCatalogDatabase(t)
+	previous := common.Config
+	common.Config = &config.Config{}
+	t.Cleanup(func() {
+		common.Config = previous
+	})
+}
+
+func TestImportProductsCSV_Lines(t *testing.T) {
+	openImportDatabase(t)
+	// the first record spans two lines
+	result, err := ImportProductsCSV(strings.NewReader("Product,Description\nshirt,\"Linen\nshirt\"\ndress,x,\"broken\n"), false)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if result.Created != 1 || len(result.Changes) != 1 || result.Changes[0].Row != 2 {
+		t.Errorf("unexpected changes: %+v", result.Changes)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Row != 4 {
+		t.Errorf("unexpected errors: %+v", result.Errors)
+	}
+}
+
+func TestImportProductsCSV_Properties(t *testing.T) {
+	openImportDatabase(t)
+	db := common.Database
+	for name, values := range map[string][]string{"color": {"red", "blue"}, "size": {"l"}} {
+		option := &models.Option{Name: name, Title: name}
+		if _, err := models.CreateOption(db, option); err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, v := range values {
+			if _, err := models.CreateValue(db, &models.Value{OptionId: option.ID, Title: v, Value: v}); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+	}
+	for _, example := range []struct{
+		Csv string
+		Properties string
+		Prices int
+	}{
+		{Csv: "Product,Properties,Prices\nshirt,color:red|color:blue=1|size:l,color:blue+size:l=10\n", Properties: "color:blue=1|color:red=0|size:l=0", Prices: 1},
+		// dropped rate removes prices using it
+		{Csv: "Product,Properties\nshirt,color:red|size:l\n", Properties: "color:red=0|size:l=0"},
+		{Csv: "Product,Properties\nshirt,color:red\n", Properties: "color:red=0"},
+		// column is not in file, nothing is changed
+		{Csv: "Product,Title\nshirt,Shirt\n", Properties: "color:red=0"},
+	}{
+		result, err := ImportProductsCSV(strings.NewReader(example.Csv), false)
+		if err != nil || result.Failed > 0 {
+			t.Fatalf("%+v %v", result, err)
+		}
+		product, err := models.GetProductByName(db, "shirt")
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		properties, err := models.GetPropertiesByProductId(db, int(product.ID))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if v := normalizeCSVList(formatCSVProperties(properties)); v != example.Properties {
+			t.Errorf("%v: properties %v, expected %v", example.Csv, v, example.Properties)
+		}
+		if prices, err := models.GetPricesByProductId(db, product.ID); err != nil || len(prices) != example.Prices {
+			t.Errorf("%v: %v prices, expected %v", example.Csv, len(prices), example.Prices)
+		}
+	}
+}
+
+func TestImportProductsCSV_Images(t *testing.T) {
+	openImportDatabase(t)
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, image.NewRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatalf("%v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.png" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(buffer.Bytes())
+	}))
+	defer server.Close()
+	files := func() []string {
+		var names []string
+		if infos, err := ioutil.ReadDir(path.Join(dir, "storage", "images")); err == nil {
+			for _, info := range infos {
+				names = append(names, info.Name())
+			}
+		}
+		return names
+	}
+	// Row fails after download, files are removed
+	result, err := ImportProductsCSV(strings.NewReader("Product,Images,Categories\nshirt," + server.URL + "/front.png,missing\n"), false)
+	if err != nil || result.Failed != 1 {
+		t.Fatalf("%+v %v", result, err)
+	}
+	if names := files(); len(names) != 0 {
+		t.Errorf("files are left: %v", names)
+	}
+	result, err = ImportProductsCSV(strings.NewReader("Product,Images\nshirt," + server.URL + "/front.png|" + server.URL + "/missing.png\n"), false)
+	if err != nil || result.Failed != 1 || !strings.Contains(result.Errors[0].Message, "missing.png") {
+		t.Fatalf("%+v %v", result, err)
+	}
+	if names := files(); len(names) != 0 {
+		t.Errorf("files are left: %v", names)
+	}
+	result, err = ImportProductsCSV(strings.NewReader("Product,Images\nshirt," + server.URL + "/front.png\n"), false)
+	if err != nil || result.Failed != 0 {
+		t.Fatalf("%+v %v", result, err)
+	}
+	product, err := models.GetProductByName(common.Database, "shirt")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	images, err := models.GetImagesOfProduct(common.Database, product)
+	if err != nil || len(images) != 1 || images[0].Width != 4 || images[0].Height != 3 || product.ImageId != images[0].ID {
+		t.Fatalf("unexpected images: %+v %v", images, err)
+	}
+	if names := files(); len(names) != 1 || "/images/" + names[0] != images[0].Path {
+		t.Errorf("unexpected files: %v, image %v", names, images[0].Path)
+	}
+}
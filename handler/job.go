@@ -0,0 +1,752 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	JOB_PREPARE = "prepare"
+	JOB_RENDER  = "render"
+	JOB_PUBLISH = "publish"
+	JOB_CHECK   = "check"
+	JOB_BACKUP  = "backup"
+	//
+	JOB_LOG_LINES   = 2000 // lines of output kept in memory and history
+	JOB_SUBSCRIBERS = 256 // events buffered for one events stream
+)
+
+var (
+	JOBS = &Jobs{items: make(map[uint]*Job)}
+	jobFuncs = make(map[string]JobFunc)
+	ErrJobsLocked = errors.New("jobs are locked")
+	reJobProgress = regexp.MustCompile(`Progress: (\S+) (\d+)/(\d+)\s*$`)
+)
+
+// Jobs keeps running jobs, finished ones are available from history
+type Jobs struct {
+	mutex sync.Mutex
+	items map[uint]*Job
+	pipeline string // owner of lock, only its jobs can start
+}
+
+// Lock reserves jobs for pipeline, it fails if other pipeline or any job is running
+func (jobs *Jobs) Lock(pipeline string) error {
+	jobs.mutex.Lock()
+	defer jobs.mutex.Unlock()
+	if jobs.pipeline != "" {
+		return fmt.Errorf("%w: %v pipeline is running", ErrJobsLocked, jobs.pipeline)
+	}
+	for _, job := range jobs.items {
+		return fmt.Errorf("%w: %v job #%v is running", ErrJobsLocked, job.Name, job.ID)
+	}
+	jobs.pipeline = pipeline
+	return nil
+}
+
+func (jobs *Jobs) Unlock() {
+	jobs.mutex.Lock()
+	defer jobs.mutex.Unlock()
+	jobs.pipeline = ""
+}
+
+// Running returns running job by id or name
+func (jobs *Jobs) Running(id uint, name string) *Job {
+	jobs.mutex.Lock()
+	defer jobs.mutex.Unlock()
+	for _, job := range jobs.items {
+		if job.ID == id || (name != "" && job.Name == name) {
+			return job
+		}
+	}
+	return nil
+}
+
+type JobProgress struct {
+	Stage string
+	Done int
+	Total int
+}
+
+// JobEvent is sent to events stream: log line, progress of stage or final status
+type JobEvent struct {
+	Type string
+	Line string `json:",omitempty"`
+	Progress *JobProgress `json:",omitempty"`
+	Status string `json:",omitempty"`
+	ExitCode int `json:",omitempty"`
+}
+
+// Job runs prepare, render, check, publish or backup in background, stages of this binary run in-process, hugo and
+// external publisher as commands
+type Job struct {
+	*models.Job
+	mutex sync.Mutex
+	cancel context.CancelFunc
+	canceled bool
+	lines []string
+	progress []*JobProgress
+	subscribers map[chan JobEvent]bool // false if subscriber lags and waits for final status only
+	done chan struct{}
+	onSuccess func()
+}
+
+// JobFunc runs job in-process, it has to return when ctx is canceled, progress of stages is reported by callback and
+// messages written to log are kept as output of job
+type JobFunc func(ctx context.Context, log *logger.Logger, full bool, progress func(stage string, done, total int)) error
+
+// RegisterJob makes job run in-process, commands register their functions as handler can not import them
+func RegisterJob(name string, f JobFunc) {
+	jobFuncs[name] = f
+}
+
+// exclusive jobs write content or site and can not overlap, pipelines run them one by one
+func exclusive(name string) bool {
+	return name == JOB_PREPARE || name == JOB_RENDER || name == JOB_PUBLISH
+}
+
+// addJob creates running job, only one job with the same name can run at once, prepare, render and publish exclude each
+// other and only jobs of pipeline holding the lock can start
+func addJob(name, arguments string, userId uint, pipeline string, onSuccess func()) (*Job, context.Context, error) {
+	JOBS.mutex.Lock()
+	defer JOBS.mutex.Unlock()
+	if JOBS.pipeline != pipeline {
+		return nil, nil, fmt.Errorf("%w: %v pipeline is running", ErrJobsLocked, JOBS.pipeline)
+	}
+	for _, job := range JOBS.items {
+		if job.Name == name {
+			return nil, nil, fmt.Errorf("%v job #%v is already running", name, job.ID)
+		}
+		if exclusive(name) && exclusive(job.Name) {
+			return nil, nil, fmt.Errorf("%w: %v job #%v is running", ErrJobsLocked, job.Name, job.ID)
+		}
+	}
+	job := &Job{
+		Job: &models.Job{
+			Name: name,
+			Arguments: arguments,
+			Status: models.JOB_STATUS_RUNNING,
+			Started: time.Now(),
+			UserId: userId,
+		},
+		subscribers: make(map[chan JobEvent]bool),
+		done: make(chan struct{}),
+		onSuccess: onSuccess,
+	}
+	if _, err := models.CreateJob(common.Database, job.Job); err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	job.cancel = cancel
+	JOBS.items[job.ID] = job
+	return job, ctx, nil
+}
+
+// StartJob starts command as job, progress is parsed from its output
+func StartJob(name string, arguments []string, userId uint, pipeline string, onSuccess func()) (*Job, error) {
+	job, ctx, err := addJob(name, strings.Join(arguments, " "), userId, pipeline, onSuccess)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, arguments[0], arguments[1:]...)
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	logger.Infof("Start job #%v %v: %v", job.ID, name, job.Arguments)
+	if err := cmd.Start(); err != nil {
+		job.finish(-1, err)
+		return nil, err
+	}
+	scanned := make(chan struct{})
+	go func() {
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64 * 1024), 1024 * 1024)
+		for scanner.Scan() {
+			job.scan(scanner.Text())
+		}
+		// Too long line stops scanner, the rest of output is dropped not to block command
+		io.Copy(ioutil.Discard, reader)
+		close(scanned)
+	}()
+	go func() {
+		err := cmd.Wait()
+		writer.Close()
+		<-scanned
+		code := 0
+		if err != nil {
+			code = -1
+			if err2, ok := err.(*exec.ExitError); ok {
+				if status, ok := err2.Sys().(syscall.WaitStatus); ok {
+					code = status.ExitStatus()
+				}
+			}
+		}
+		job.finish(code, err)
+	}()
+	return job, nil
+}
+
+// runJob starts function as in-process job with own logger, other messages of application do not get into its output
+func runJob(name string, f JobFunc, full bool, userId uint, pipeline string, onSuccess func()) (*Job, error) {
+	arguments := name
+	if full {
+		arguments += " --full"
+	}
+	job, ctx, err := addJob(name, arguments, userId, pipeline, onSuccess)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("Start job #%v %v: %v", job.ID, name, job.Arguments)
+	go func() {
+		err := callJob(ctx, f, logger.Init(name, true, false, job), full, job.setProgress)
+		code := 0
+		if err != nil {
+			code = 1
+		}
+		job.finish(code, err)
+	}()
+	return job, nil
+}
+
+// callJob turns panic of in-process job into error, it should not stop the application
+func callJob(ctx context.Context, f JobFunc, log *logger.Logger, full bool, progress func(stage string, done, total int)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return f(ctx, log, full, progress)
+}
+
+// Write appends lines of log to in-process job
+func (job *Job) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		job.append(line)
+	}
+	return len(p), nil
+}
+
+// scan appends line of command output, progress lines update progress of stage
+func (job *Job) scan(line string) {
+	job.append(line)
+	if res := reJobProgress.FindStringSubmatch(line); len(res) > 3 {
+		done, _ := strconv.Atoi(res[2])
+		total, _ := strconv.Atoi(res[3])
+		job.setProgress(res[1], done, total)
+	}
+}
+
+func (job *Job) append(line string) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	job.lines = append(job.lines, line)
+	if len(job.lines) > JOB_LOG_LINES {
+		job.lines = job.lines[len(job.lines) - JOB_LOG_LINES:]
+	}
+	job.publish(JobEvent{Type: "log", Line: line})
+}
+
+// setProgress updates progress of stage, about 100 events per stage are sent at most
+func (job *Job) setProgress(stage string, done, total int) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	progress := &JobProgress{Stage: stage, Done: done, Total: total}
+	var found bool
+	for i, p := range job.progress {
+		if p.Stage == progress.Stage {
+			job.progress[i] = progress
+			found = true
+			break
+		}
+	}
+	if !found {
+		job.progress = append(job.progress, progress)
+	}
+	step := total / 100
+	if step < 1 {
+		step = 1
+	}
+	if done % step == 0 || done == total {
+		job.publish(JobEvent{Type: "progress", Progress: progress})
+	}
+}
+
+// publish sends event to subscribers, slow subscriber stops getting events instead of blocking the job, the last slot of
+// its buffer is kept for final status
+func (job *Job) publish(event JobEvent) {
+	for ch, active := range job.subscribers {
+		if !active {
+			continue
+		}
+		if len(ch) < cap(ch) - 1 {
+			ch <- event
+		}else{
+			job.subscribers[ch] = false
+		}
+	}
+}
+
+func (job *Job) finish(code int, err error) {
+	job.cancel()
+	job.mutex.Lock()
+	job.ExitCode = code
+	job.Finished = time.Now()
+	if job.canceled {
+		job.Status = models.JOB_STATUS_CANCELED
+	}else if err != nil {
+		job.Status = models.JOB_STATUS_FAILED
+		job.lines = append(job.lines, err.Error())
+	}else{
+		job.Status = models.JOB_STATUS_FINISHED
+	}
+	job.Log = strings.Join(job.lines, "\n")
+	if bts, err := json.Marshal(job.progress); err == nil {
+		job.Progress = string(bts)
+	}
+	if err := models.UpdateJob(common.Database, job.Job); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	// Status is sent to lagging subscribers too, there is a free slot for it
+	for ch := range job.subscribers {
+		ch <- JobEvent{Type: "status", Status: job.Status, ExitCode: job.ExitCode}
+		close(ch)
+	}
+	job.subscribers = make(map[chan JobEvent]bool)
+	job.mutex.Unlock()
+	// Next job of pipeline may start as soon as this one is done
+	JOBS.mutex.Lock()
+	delete(JOBS.items, job.ID)
+	JOBS.mutex.Unlock()
+	logger.Infof("Job #%v %v %v: %v", job.ID, job.Name, job.Status, code)
+	if job.Status == models.JOB_STATUS_FINISHED && job.onSuccess != nil {
+		job.onSuccess()
+	}
+	close(job.done)
+}
+
+// Cancel kills command of job or cancels context of in-process one
+func (job *Job) Cancel() {
+	job.mutex.Lock()
+	job.canceled = true
+	job.mutex.Unlock()
+	job.cancel()
+}
+
+// Wait blocks until job is finished and returns error with output if job was not successful
+func (job *Job) Wait() error {
+	<-job.done
+	if job.Status != models.JOB_STATUS_FINISHED {
+		return fmt.Errorf("%v\n%v job %v with code %v", job.Log, job.Name, job.Status, job.ExitCode)
+	}
+	return nil
+}
+
+// Subscribe returns events happened so far and channel of next ones, channel is closed when job is finished
+func (job *Job) Subscribe() ([]JobEvent, chan JobEvent) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	var events []JobEvent
+	for _, line := range job.lines {
+		events = append(events, JobEvent{Type: "log", Line: line})
+	}
+	for _, progress := range job.progress {
+		events = append(events, JobEvent{Type: "progress", Progress: progress})
+	}
+	ch := make(chan JobEvent, JOB_SUBSCRIBERS)
+	if job.Status == models.JOB_STATUS_RUNNING {
+		job.subscribers[ch] = true
+	}else{
+		events = append(events, JobEvent{Type: "status", Status: job.Status, ExitCode: job.ExitCode})
+		close(ch)
+	}
+	return events, ch
+}
+
+func (job *Job) Unsubscribe(ch chan JobEvent) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	if _, found := job.subscribers[ch]; found {
+		delete(job.subscribers, ch)
+		close(ch)
+	}
+}
+
+// View returns copy of job with current output
+func (job *Job) View() JobView {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	view := newJobView(job.Job)
+	view.Log = strings.Join(job.lines, "\n")
+	view.Progress = append([]*JobProgress{}, job.progress...)
+	return view
+}
+
+// jobArguments returns command of job which is not run in-process, render is hugo and publish is external publisher
+func jobArguments(name string) ([]string, error) {
+	var bin []string
+	switch name {
+	case JOB_RENDER:
+		bin = strings.Split(common.Config.Hugo.Bin, " ")
+		var arguments []string
+		if len(bin) > 1 {
+			for _, x := range bin[1:]{
+				x = strings.Replace(x, "%DIR%", dir, -1)
+				arguments = append(arguments, x)
+			}
+		}
+		arguments = append(arguments, "--cleanDestinationDir")
+		if common.Config.Hugo.Minify {
+			arguments = append(arguments, "--minify")
+		}
+		if len(bin) == 1 {
+			arguments = append(arguments, []string{"-s", path.Join(dir, "hugo")}...)
+		}
+		return append([]string{bin[0]}, arguments...), nil
+	case JOB_PUBLISH:
+		bin = strings.Split(common.Config.Publisher.Bin, " ")
+		var arguments []string
+		if len(bin) > 1 {
+			for _, x := range bin[1:]{
+				x = strings.Replace(x, "%DIR%", dir, -1)
+				arguments = append(arguments, x)
+			}
+			if common.Config.Publisher.ApiToken == "" {
+				return nil, fmt.Errorf("api_token is not specified")
+			}
+			arguments = append(arguments, common.Config.Publisher.ApiToken)
+		}
+		return append([]string{bin[0]}, arguments...), nil
+	}
+	return nil, fmt.Errorf("unknown job %v", name)
+}
+
+// startJob starts job by name, successful prepare resets pending changes flag
+func startJob(name string, full bool, userId uint, pipeline string) (*Job, error) {
+	if name == JOB_PUBLISH && !common.Config.Publisher.Enabled {
+		return nil, fmt.Errorf("wrangler disabled")
+	}
+	f, found := jobFuncs[name]
+	// Built-in publisher runs in-process only if target is set
+	if name == JOB_PUBLISH && common.Config.Publisher.Target == "" {
+		found = false
+	}
+	var arguments []string
+	if !found {
+		var err error
+		if arguments, err = jobArguments(name); err != nil {
+			return nil, err
+		}
+	}
+	var onSuccess func()
+	switch name {
+	case JOB_PREPARE:
+		onSuccess = func() {
+			if _, err := os.Stat(path.Join(dir, HAS_CHANGES)); err == nil {
+				if err := os.Remove(path.Join(dir, HAS_CHANGES)); err != nil {
+					logger.Errorf("%v", err)
+				}
+			}
+		}
+	case JOB_RENDER:
+		// Pipelines run check themselves
+		if common.Config.Check.Enabled && pipeline == "" {
+			onSuccess = func() {
+				if _, err := startJob(JOB_CHECK, false, userId, ""); err != nil {
+					logger.Errorf("%v", err)
+				}
+			}
+		}
+	case JOB_PUBLISH:
+		if common.Config.Check.Enabled {
+			if err := checkPassed(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if found {
+		return runJob(name, f, full, userId, pipeline, onSuccess)
+	}
+	return StartJob(name, arguments, userId, pipeline, onSuccess)
+}
+
+// checkPassed returns error if the last render is not checked or has broken links, such site should not be published
+func checkPassed() error {
+	render, err := models.GetLastJob(common.Database, JOB_RENDER)
+	if err != nil {
+		return nil
+	}
+	check, err := models.GetLastJob(common.Database, JOB_CHECK)
+	if err != nil || check.ID < render.ID {
+		return fmt.Errorf("render job #%v is not checked yet, publish is blocked", render.ID)
+	}
+	if check.Status != models.JOB_STATUS_FINISHED {
+		return fmt.Errorf("check job #%v %v, publish is blocked", check.ID, check.Status)
+	}
+	return nil
+}
+
+type JobsView []JobView
+
+type JobView struct {
+	ID uint
+	Name string
+	Arguments string
+	Status string
+	Started time.Time
+	Finished time.Time `json:",omitempty"`
+	ExitCode int
+	Log string `json:",omitempty"`
+	Progress []*JobProgress `json:",omitempty"`
+	UserId uint `json:",omitempty"`
+}
+
+func newJobView(job *models.Job) JobView {
+	view := JobView{
+		ID: job.ID,
+		Name: job.Name,
+		Arguments: job.Arguments,
+		Status: job.Status,
+		Started: job.Started,
+		Finished: job.Finished,
+		ExitCode: job.ExitCode,
+		Log: job.Log,
+		UserId: job.UserId,
+	}
+	if job.Progress != "" {
+		if err := json.Unmarshal([]byte(job.Progress), &view.Progress); err != nil {
+			logger.Warningf("%+v", err)
+		}
+	}
+	return view
+}
+
+type NewJob struct {
+	Name string // prepare, render, check, publish or backup
+	Full bool // prepare all products, not only changed ones
+}
+
+// @security BasicAuth
+// CreateJob godoc
+// @Summary Start prepare, render, check, publish or backup job
+// @Accept json
+// @Produce json
+// @Param request body NewJob true "body"
+// @Success 200 {object} JobView
+// @Failure 409 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/jobs [post]
+// @Tags job
+func postJobHandler(c *fiber.Ctx) error {
+	var request NewJob
+	if contentType := string(c.Request().Header.ContentType()); contentType != "" {
+		if strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+			if err := c.BodyParser(&request); err != nil {
+				return err
+			}
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{"Unsupported Content-Type"})
+		}
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{"Content-Type not set"})
+	}
+	if job := JOBS.Running(0, request.Name); job != nil {
+		c.Status(http.StatusConflict)
+		return c.JSON(HTTPError{fmt.Sprintf("%v job #%v is already running", job.Name, job.ID)})
+	}
+	var userId uint
+	if v := c.Locals("user"); v != nil {
+		if user, ok := v.(*models.User); ok {
+			userId = user.ID
+		}
+	}
+	if job, err := startJob(request.Name, request.Full, userId, ""); err == nil {
+		return c.JSON(job.View())
+	}else{
+		if errors.Is(err, ErrJobsLocked) {
+			c.Status(http.StatusConflict)
+			return c.JSON(HTTPError{err.Error()})
+		}
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// @security BasicAuth
+// GetJobs godoc
+// @Summary Get history of jobs
+// @Accept json
+// @Produce json
+// @Param name query string false "prepare, render, check, publish or backup"
+// @Param limit query int false "limit, 50 by default"
+// @Success 200 {object} JobsView
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/jobs [get]
+// @Tags job
+func getJobsHandler(c *fiber.Ctx) error {
+	limit := 50
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	if jobs, err := models.GetJobs(common.Database, c.Query("name"), limit); err == nil {
+		views := JobsView{}
+		for _, job := range jobs {
+			if running := JOBS.Running(job.ID, ""); running != nil {
+				view := running.View()
+				view.Log = ""
+				views = append(views, view)
+			}else{
+				views = append(views, newJobView(job))
+			}
+		}
+		return c.JSON(views)
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// @security BasicAuth
+// GetJob godoc
+// @Summary Get job with log
+// @Accept json
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} JobView
+// @Failure 404 {object} HTTPError
+// @Router /api/v1/jobs/{id} [get]
+// @Tags job
+func getJobHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	if running := JOBS.Running(uint(id), ""); running != nil {
+		return c.JSON(running.View())
+	}
+	if job, err := models.GetJob(common.Database, id); err == nil {
+		return c.JSON(newJobView(job))
+	}else{
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// @security BasicAuth
+// CancelJob godoc
+// @Summary Cancel running job
+// @Accept json
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} HTTPMessage
+// @Failure 404 {object} HTTPError
+// @Router /api/v1/jobs/{id}/cancel [post]
+// @Tags job
+func postJobCancelHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	if running := JOBS.Running(uint(id), ""); running != nil {
+		running.Cancel()
+		return c.JSON(HTTPMessage{MESSAGE: "OK"})
+	}
+	c.Status(http.StatusNotFound)
+	return c.JSON(HTTPError{"Job is not running"})
+}
+
+// @security BasicAuth
+// GetJobEvents godoc
+// @Summary Stream log lines, progress and final status of job as Server-Sent Events
+// @Produce text/event-stream
+// @Param id path int true "Job ID"
+// @Success 200 {object} JobEvent
+// @Failure 404 {object} HTTPError
+// @Router /api/v1/jobs/{id}/events [get]
+// @Tags job
+func getJobEventsHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	var events []JobEvent
+	var ch chan JobEvent
+	running := JOBS.Running(uint(id), "")
+	if running != nil {
+		events, ch = running.Subscribe()
+	}else if job, err := models.GetJob(common.Database, id); err == nil {
+		// Finished job is streamed from history
+		view := newJobView(job)
+		if view.Log != "" {
+			for _, line := range strings.Split(view.Log, "\n") {
+				events = append(events, JobEvent{Type: "log", Line: line})
+			}
+		}
+		for _, progress := range view.Progress {
+			events = append(events, JobEvent{Type: "progress", Progress: progress})
+		}
+		events = append(events, JobEvent{Type: "status", Status: job.Status, ExitCode: job.ExitCode})
+	}else{
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		for _, event := range events {
+			if err := writeJobEvent(w, event); err != nil {
+				break
+			}
+		}
+		if err := w.Flush(); err != nil || ch == nil {
+			if running != nil {
+				running.Unsubscribe(ch)
+			}
+			return
+		}
+		for event := range ch {
+			if err := writeJobEvent(w, event); err != nil {
+				break
+			}
+			if err := w.Flush(); err != nil {
+				break
+			}
+		}
+		running.Unsubscribe(ch)
+	})
+	return nil
+}
+
+func writeJobEvent(w *bufio.Writer, event JobEvent) error {
+	var data string
+	if event.Type == "log" {
+		data = event.Line
+	}else if bts, err := json.Marshal(event); err == nil {
+		data = string(bts)
+	}else{
+		return err
+	}
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/models"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestRunJob_Log(t *testing.T) {
+	// default logger is initialized by main, the first initialized logger becomes default
+	logger.Init("test", false, false, ioutil.Discard)
+	openTestDatabase(t, &models.Job{})
+	job, err := runJob("test", func(ctx context.Context, log *logger.Logger, full bool, progress func(stage string, done, total int)) error {
+		log.Infof("own message")
+		// Messages of application logged meanwhile are not output of job
+		logger.Infof("foreign message")
+		return nil
+	}, false, 0, "", nil)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err = job.Wait(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !strings.Contains(job.Log, "own message") {
+		t.Errorf("job message is lost: %q", job.Log)
+	}
+	if strings.Contains(job.Log, "foreign message") {
+		t.Errorf("application message is in job log: %q", job.Log)
+	}
+}
+
+func TestJobSubscribe_SlowSubscriber(t *testing.T) {
+	openTestDatabase(t, &models.Job{})
+	job, _, err := addJob("test", "test", 0, "", nil)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	_, ch := job.Subscribe()
+	// Nobody reads events while job writes more lines than buffer keeps
+	for i := 0; i < JOB_SUBSCRIBERS * 2; i++ {
+		job.append(fmt.Sprintf("line %d", i))
+	}
+	job.finish(0, nil)
+	var events []JobEvent
+	for event := range ch {
+		events = append(events, event)
+	}
+	if len(events) != JOB_SUBSCRIBERS {
+		t.Errorf("%v events, expected %v", len(events), JOB_SUBSCRIBERS)
+	}
+	if last := events[len(events) - 1]; last.Type != "status" || last.Status != models.JOB_STATUS_FINISHED {
+		t.Errorf("last event %+v, expected finished status", last)
+	}
+	if job.Wait() != nil {
+		t.Errorf("job is not finished")
+	}
+}
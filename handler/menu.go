@@ -306,6 +306,9 @@ func delMenuHandler(c *fiber.Ctx) error {
 		id, _ = strconv.Atoi(v)
 	}
 	if menu, err := models.GetMenu(common.Database, id); err == nil {
+		if err = models.DeleteTranslationsByObject(common.Database, models.TRANSLATION_OBJECT_MENU, menu.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
 		if err = models.DeleteMenu(common.Database, menu); err == nil {
 			return c.JSON(HTTPMessage{MESSAGE: "OK"})
 		}else{
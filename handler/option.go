@@ -443,10 +443,16 @@ func delOptionHandler(c *fiber.Ctx) error {
 	}
 	if option, err := models.GetOption(common.Database, id); err == nil {
 		for _, value := range option.Values {
+			if err = models.DeleteTranslationsByObject(common.Database, models.TRANSLATION_OBJECT_VALUE, value.ID); err != nil {
+				logger.Errorf("%v", err.Error())
+			}
 			if err = models.DeleteValue(common.Database, value); err != nil {
 				logger.Errorf("%v", err)
 			}
 		}
+		if err = models.DeleteTranslationsByObject(common.Database, models.TRANSLATION_OBJECT_OPTION, option.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
 		if err = models.DeleteOption(common.Database, option); err == nil {
 			return c.JSON(HTTPMessage{MESSAGE: "OK"})
 		}else{
@@ -0,0 +1,460 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/logger"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type PickupLocationsView []PickupLocationView
+
+type PickupLocationView struct {
+	ID uint
+	Enabled bool
+	Name string
+	Title string
+	Description string `json:",omitempty"`
+	Address string `json:",omitempty"`
+	Zip string `json:",omitempty"`
+	City string `json:",omitempty"`
+	Region string `json:",omitempty"`
+	Country string `json:",omitempty"`
+	Phone string `json:",omitempty"`
+	Email string `json:",omitempty"`
+	Latitude float64 `json:",omitempty"`
+	Longitude float64 `json:",omitempty"`
+	Hours string `json:",omitempty"`
+	Capacity int `json:",omitempty"`
+	Fee float64
+	Free float64 `json:",omitempty"`
+	Sort int `json:",omitempty"`
+}
+
+type PublicPickupLocationsView []PublicPickupLocationView
+
+// PublicPickupLocationView is location as customers see it, capacity and contacts for staff are not exposed
+type PublicPickupLocationView struct {
+	ID uint
+	Name string
+	Title string
+	Description string `json:",omitempty"`
+	Address string `json:",omitempty"`
+	Zip string `json:",omitempty"`
+	City string `json:",omitempty"`
+	Region string `json:",omitempty"`
+	Country string `json:",omitempty"`
+	Phone string `json:",omitempty"`
+	Latitude float64 `json:",omitempty"`
+	Longitude float64 `json:",omitempty"`
+	Hours string `json:",omitempty"`
+	Fee float64
+	Free float64 `json:",omitempty"`
+}
+
+// GetPickupLocations godoc
+// @Summary Get enabled pickup locations
+// @Accept json
+// @Produce json
+// @Success 200 {object} PublicPickupLocationsView
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/pickups [get]
+// @Tags pickup
+func getPickupLocationsHandler(c *fiber.Ctx) error {
+	if locations, err := models.GetEnabledPickupLocations(common.Database); err == nil {
+		var view PublicPickupLocationsView
+		if bts, err := json.MarshalIndent(locations, "", "   "); err == nil {
+			if err = json.Unmarshal(bts, &view); err == nil {
+				return c.JSON(view)
+			}else{
+				c.Status(http.StatusInternalServerError)
+				return c.JSON(HTTPError{err.Error()})
+			}
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{err.Error()})
+		}
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+type NewPickupLocation struct {
+	Enabled bool
+	Name string
+	Title string
+	Description string
+	Address string
+	Zip string
+	City string
+	Region string
+	Country string
+	Phone string
+	Email string
+	Latitude float64
+	Longitude float64
+	Hours string
+	Capacity int
+	Fee float64
+	Free float64
+	Sort int
+}
+
+// @security BasicAuth
+// CreatePickupLocation godoc
+// @Summary Create pickup location
+// @Accept json
+// @Produce json
+// @Param pickup body NewPickupLocation true "body"
+// @Success 200 {object} PickupLocationView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/pickups [post]
+// @Tags pickup
+func postPickupLocationHandler(c *fiber.Ctx) error {
+	var view PickupLocationView
+	if contentType := string(c.Request().Header.ContentType()); contentType != "" {
+		if strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+			var request NewPickupLocation
+			if err := c.BodyParser(&request); err != nil {
+				return err
+			}
+			request.Title = strings.TrimSpace(request.Title)
+			if request.Title == "" {
+				c.Status(http.StatusInternalServerError)
+				return c.JSON(fiber.Map{"ERROR": "Title is not defined"})
+			}
+			request.Name = strings.TrimSpace(request.Name)
+			if request.Name == "" {
+				request.Name = strings.Trim(reNotAbc.ReplaceAllString(strings.ToLower(request.Title), "-"), "-")
+			}
+			if request.Address = strings.TrimSpace(request.Address); request.Address == "" {
+				c.Status(http.StatusInternalServerError)
+				return c.JSON(fiber.Map{"ERROR": "Address is not defined"})
+			}
+			if locations, err := models.GetPickupLocationsByName(common.Database, request.Name); err == nil && len(locations) > 0 {
+				c.Status(http.StatusInternalServerError)
+				return c.JSON(HTTPError{"Pickup location exists"})
+			}
+			location := &models.PickupLocation{
+				Enabled: request.Enabled,
+				Name: request.Name,
+				Title: request.Title,
+				Description: request.Description,
+				Address: request.Address,
+				Zip: strings.TrimSpace(request.Zip),
+				City: strings.TrimSpace(request.City),
+				Region: strings.TrimSpace(request.Region),
+				Country: strings.TrimSpace(request.Country),
+				Phone: strings.TrimSpace(request.Phone),
+				Email: strings.TrimSpace(request.Email),
+				Latitude: request.Latitude,
+				Longitude: request.Longitude,
+				Hours: request.Hours,
+				Capacity: request.Capacity,
+				Fee: request.Fee,
+				Free: request.Free,
+				Sort: request.Sort,
+			}
+			if _, err := models.CreatePickupLocation(common.Database, location); err != nil {
+				c.Status(http.StatusInternalServerError)
+				return c.JSON(HTTPError{err.Error()})
+			}
+			if bts, err := json.Marshal(location); err == nil {
+				if err = json.Unmarshal(bts, &view); err != nil {
+					c.Status(http.StatusInternalServerError)
+					return c.JSON(HTTPError{err.Error()})
+				}
+			}
+			return c.JSON(view)
+		} else {
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{"Unsupported Content-Type"})
+		}
+	}
+	return c.JSON(view)
+}
+
+type PickupLocationsListResponse struct {
+	Data []PickupLocationsListItem
+	Filtered int64
+	Total int64
+}
+
+type PickupLocationsListItem struct {
+	ID uint
+	Enabled bool
+	Name string
+	Title string
+	Address string
+	Zip string
+	City string
+	Country string
+	Capacity int
+	Fee float64
+}
+
+// @security BasicAuth
+// SearchPickupLocations godoc
+// @Summary Search pickup locations
+// @Accept json
+// @Produce json
+// @Param request body ListRequest true "body"
+// @Success 200 {object} PickupLocationsListResponse
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/pickups/list [post]
+// @Tags pickup
+func postPickupLocationsListHandler(c *fiber.Ctx) error {
+	var response PickupLocationsListResponse
+	var request ListRequest
+	if err := c.BodyParser(&request); err != nil {
+		return err
+	}
+	if len(request.Sort) == 0 {
+		request.Sort = map[string]string{"ID": "asc"}
+	}
+	if request.Length == 0 {
+		request.Length = 10
+	}
+	// Filter
+	var keys1 []string
+	var values1 []interface{}
+	if len(request.Filter) > 0 {
+		for key, value := range request.Filter {
+			if key != "" && len(strings.TrimSpace(value)) > 0 {
+				switch key {
+				default:
+					keys1 = append(keys1, fmt.Sprintf("pickup_locations.%v like ?", key))
+					values1 = append(values1, "%" + strings.TrimSpace(value) + "%")
+				}
+			}
+		}
+	}
+	// Sort
+	var order string
+	if len(request.Sort) > 0 {
+		var orders []string
+		for key, value := range request.Sort {
+			if key != "" && value != "" {
+				switch key {
+				default:
+					orders = append(orders, fmt.Sprintf("pickup_locations.%v %v", key, value))
+				}
+			}
+		}
+		order = strings.Join(orders, ", ")
+	}
+	//
+	rows, err := common.Database.Debug().Model(&models.PickupLocation{}).Select("pickup_locations.ID, pickup_locations.Enabled, pickup_locations.Name, pickup_locations.Title, pickup_locations.Address, pickup_locations.Zip, pickup_locations.City, pickup_locations.Country, pickup_locations.Capacity, pickup_locations.Fee").Where(strings.Join(keys1, " and "), values1...).Order(order).Limit(request.Length).Offset(request.Start).Rows()
+	if err == nil {
+		for rows.Next() {
+			var item PickupLocationsListItem
+			if err = common.Database.ScanRows(rows, &item); err == nil {
+				response.Data = append(response.Data, item)
+			} else {
+				logger.Errorf("%v", err)
+			}
+		}
+		rows.Close()
+	}
+	rows, err = common.Database.Debug().Model(&models.PickupLocation{}).Select("pickup_locations.ID").Where(strings.Join(keys1, " and "), values1...).Rows()
+	if err == nil {
+		for rows.Next() {
+			response.Filtered ++
+		}
+		rows.Close()
+	}
+	if len(keys1) > 0 {
+		common.Database.Debug().Model(&models.PickupLocation{}).Count(&response.Total)
+	}else{
+		response.Total = response.Filtered
+	}
+	c.Status(http.StatusOK)
+	return c.JSON(response)
+}
+
+// @security BasicAuth
+// GetPickupLocation godoc
+// @Summary Get pickup location
+// @Accept json
+// @Produce json
+// @Param id path int true "Pickup location ID"
+// @Success 200 {object} PickupLocationView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/pickups/{id} [get]
+// @Tags pickup
+func getPickupLocationHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	if location, err := models.GetPickupLocation(common.Database, id); err == nil {
+		var view PickupLocationView
+		if bts, err := json.MarshalIndent(location, "", "   "); err == nil {
+			if err = json.Unmarshal(bts, &view); err == nil {
+				return c.JSON(view)
+			}else{
+				c.Status(http.StatusInternalServerError)
+				return c.JSON(HTTPError{err.Error()})
+			}
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{err.Error()})
+		}
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// @security BasicAuth
+// UpdatePickupLocation godoc
+// @Summary Update pickup location
+// @Accept json
+// @Produce json
+// @Param pickup body NewPickupLocation true "body"
+// @Param id path int true "Pickup location ID"
+// @Success 200 {object} PickupLocationView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/pickups/{id} [put]
+// @Tags pickup
+func putPickupLocationHandler(c *fiber.Ctx) error {
+	var request NewPickupLocation
+	if err := c.BodyParser(&request); err != nil {
+		return err
+	}
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(fiber.Map{"ERROR": "ID is not defined"})
+	}
+	var location *models.PickupLocation
+	var err error
+	if location, err = models.GetPickupLocation(common.Database, id); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	request.Title = strings.TrimSpace(request.Title)
+	if request.Title == "" {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(fiber.Map{"ERROR": "Title is not defined"})
+	}
+	if request.Address = strings.TrimSpace(request.Address); request.Address == "" {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(fiber.Map{"ERROR": "Address is not defined"})
+	}
+	location.Enabled = request.Enabled
+	location.Title = request.Title
+	location.Description = request.Description
+	location.Address = request.Address
+	location.Zip = strings.TrimSpace(request.Zip)
+	location.City = strings.TrimSpace(request.City)
+	location.Region = strings.TrimSpace(request.Region)
+	location.Country = strings.TrimSpace(request.Country)
+	location.Phone = strings.TrimSpace(request.Phone)
+	location.Email = strings.TrimSpace(request.Email)
+	location.Latitude = request.Latitude
+	location.Longitude = request.Longitude
+	location.Hours = request.Hours
+	location.Capacity = request.Capacity
+	location.Fee = request.Fee
+	location.Free = request.Free
+	location.Sort = request.Sort
+	if err := models.UpdatePickupLocation(common.Database, location); err == nil {
+		var view PickupLocationView
+		if bts, err := json.Marshal(location); err == nil {
+			if err = json.Unmarshal(bts, &view); err == nil {
+				return c.JSON(view)
+			}else{
+				c.Status(http.StatusInternalServerError)
+				return c.JSON(HTTPError{err.Error()})
+			}
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{err.Error()})
+		}
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// @security BasicAuth
+// DelPickupLocation godoc
+// @Summary Delete pickup location
+// @Accept json
+// @Produce json
+// @Param id path int true "Pickup location ID"
+// @Success 200 {object} HTTPMessage
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/pickups/{id} [delete]
+// @Tags pickup
+func delPickupLocationHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	if location, err := models.GetPickupLocation(common.Database, id); err == nil {
+		if err = models.DeletePickupLocation(common.Database, location); err == nil {
+			return c.JSON(HTTPMessage{MESSAGE: "OK"})
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{err.Error()})
+		}
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// PickupAvailable checks location is enabled and still has free capacity
+func PickupAvailable(location *models.PickupLocation) bool {
+	if !location.Enabled {
+		return false
+	}
+	if location.Capacity > 0 {
+		if load, err := models.GetPickupLocationLoad(common.Database, location.ID); err == nil {
+			return load < int64(location.Capacity)
+		}else{
+			logger.Warningf("%+v", err)
+			return false
+		}
+	}
+	return true
+}
+
+func sendOrderReadyForPickupEmail(order *models.Order) error {
+	if common.Config.Notification.Enabled && common.Config.Notification.Email.Enabled {
+		template, err := models.GetEmailTemplateByType(common.Database, common.NOTIFICATION_TYPE_USER_ORDER_READY_FOR_PICKUP)
+		if err != nil {
+			return err
+		}
+		if !template.Enabled {
+			return nil
+		}
+		user, err := models.GetUser(common.Database, int(order.UserId))
+		if err != nil {
+			return err
+		}
+		if !user.EmailConfirmed {
+			logger.Warningf("User's %v email %v is not confirmed", user.Login, user.Email)
+			return nil
+		}
+		logger.Infof("Send email to user: %+v", user.Email)
+		return SendOrderPaidEmail(mail.NewEmail(user.Login, user.Email), int(order.ID), template)
+	}
+	return nil
+}
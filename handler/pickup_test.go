@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/yonnic/goshop/models"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetPickupLocations(t *testing.T) {
+	db := openTestDatabase(t, &models.PickupLocation{})
+	for _, location := range []*models.PickupLocation{
+		{Enabled: true, Name: "center", Title: "Center", Email: "staff@example.com", Capacity: 10, Sort: 2},
+		{Enabled: false, Name: "closed", Title: "Closed"},
+		{Enabled: true, Name: "north", Title: "North", Sort: 1},
+	}{
+		if _, err := models.CreatePickupLocation(db, location); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	app := fiber.New()
+	app.Get("/api/v1/pickups", getPickupLocationsHandler)
+	res, err := app.Test(httptest.NewRequest("GET", "/api/v1/pickups", nil))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	bts, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, field := range []string{"Capacity", "Email", "Sort", "Enabled", "staff@example.com"} {
+		if strings.Contains(string(bts), field) {
+			t.Errorf("%v is exposed: %s", field, bts)
+		}
+	}
+	var view PublicPickupLocationsView
+	if err = json.Unmarshal(bts, &view); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(view) != 2 || view[0].Name != "north" || view[1].Name != "center" {
+		t.Errorf("unexpected locations: %+v", view)
+	}
+}
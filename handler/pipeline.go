@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/logger"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	PIPELINE_SCHEDULE = "schedule"
+	PIPELINE_WINDOW = "window"
+)
+
+// RunPipeline runs jobs one by one holding the lock, so neither manual jobs nor other pipelines overlap with it,
+// returned job is the last started one
+func RunPipeline(pipeline string, names []string, full bool) (*Job, error) {
+	if err := JOBS.Lock(pipeline); err != nil {
+		return nil, err
+	}
+	defer JOBS.Unlock()
+	var job *Job
+	for _, name := range names {
+		var err error
+		if job, err = startJob(name, full, 0, pipeline); err != nil {
+			return job, err
+		}
+		if err = job.Wait(); err != nil {
+			return job, err
+		}
+	}
+	return job, nil
+}
+
+// pipelineJobs returns jobs making pending changes public, site is published if requested and publisher is enabled
+func pipelineJobs(publish bool) []string {
+	names := []string{JOB_PREPARE, JOB_RENDER}
+	if common.Config.Check.Enabled {
+		names = append(names, JOB_CHECK)
+	}
+	if publish && common.Config.Publisher.Enabled {
+		names = append(names, JOB_PUBLISH)
+	}
+	return names
+}
+
+// StartPipeline checks cron expressions every minute and runs prepare, render and publish if there are pending changes
+func StartPipeline() {
+	conf := common.Config.Pipeline
+	if !conf.Enabled {
+		return
+	}
+	crons, err := parseCrons(conf.Cron)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return
+	}
+	if len(crons) == 0 {
+		logger.Warningf("Pipeline is enabled without cron expressions")
+		return
+	}
+	if _, _, err := parseQuietHours(conf.QuietHours); err != nil {
+		logger.Errorf("%v", err)
+		return
+	}
+	var failed bool
+	startCron(crons, func(now time.Time) {
+		failed = runScheduledPipeline(now, failed)
+	})
+}
+
+func parseCrons(expressions []string) ([]*common.Cron, error) {
+	var crons []*common.Cron
+	for _, expression := range expressions {
+		cron, err := common.ParseCron(expression)
+		if err != nil {
+			return nil, err
+		}
+		crons = append(crons, cron)
+	}
+	return crons, nil
+}
+
+// startCron calls run in background at every minute matching any of crons, next minute is skipped while run works
+func startCron(crons []*common.Cron, run func(now time.Time)) {
+	go func() {
+		for {
+			now := time.Now()
+			time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+			now = time.Now().Truncate(time.Minute)
+			for _, cron := range crons {
+				if cron.Match(now) {
+					run(now)
+					break
+				}
+			}
+		}
+	}()
+}
+
+// runScheduledPipeline returns if the run failed, admins are notified about the first failure of series only
+func runScheduledPipeline(now time.Time, failed bool) bool {
+	conf := common.Config.Pipeline
+	if _, err := os.Stat(path.Join(dir, HAS_CHANGES)); err != nil {
+		return failed
+	}
+	if quiet, err := inQuietHours(conf.QuietHours, now); err != nil || quiet {
+		logger.Infof("Pipeline skipped in quiet hours %v", conf.QuietHours)
+		return failed
+	}
+	names := pipelineJobs(conf.Publish)
+	logger.Infof("Pipeline started: %v", strings.Join(names, ", "))
+	job, err := RunPipeline(PIPELINE_SCHEDULE, names, false)
+	if err != nil {
+		if errors.Is(err, ErrJobsLocked) {
+			logger.Infof("Pipeline postponed: %v", err)
+			return failed
+		}
+		logger.Errorf("Pipeline failed: %v", err)
+		// Successful prepare resets pending changes, they are still not published
+		if job == nil || job.Name != JOB_PREPARE {
+			if err := MarkChanged("scheduled pipeline failed"); err != nil {
+				logger.Warningf("%+v", err)
+			}
+		}
+		if !failed && conf.Notify {
+			if err := sendPipelineFailedEmail(job, err); err != nil {
+				logger.Warningf("%+v", err)
+			}
+		}
+		return true
+	}
+	logger.Infof("Pipeline finished")
+	return false
+}
+
+// parseQuietHours parses "22:00-07:00" to minutes of day, empty range is valid and means no quiet hours
+func parseQuietHours(quietHours string) (int, int, error) {
+	if quietHours == "" {
+		return 0, 0, nil
+	}
+	var from, to [2]int
+	if n, err := fmt.Sscanf(quietHours, "%d:%d-%d:%d", &from[0], &from[1], &to[0], &to[1]); err != nil || n != 4 || from[0] > 23 || to[0] > 23 || from[1] > 59 || to[1] > 59 {
+		return 0, 0, fmt.Errorf("invalid quiet hours '%v', expected like 22:00-07:00", quietHours)
+	}
+	return from[0] * 60 + from[1], to[0] * 60 + to[1], nil
+}
+
+// inQuietHours checks local time, range can pass midnight
+func inQuietHours(quietHours string, t time.Time) (bool, error) {
+	from, to, err := parseQuietHours(quietHours)
+	if err != nil || from == to {
+		return false, err
+	}
+	minute := t.Hour() * 60 + t.Minute()
+	if from < to {
+		return minute >= from && minute < to, nil
+	}
+	return minute >= from || minute < to, nil
+}
+
+func sendPipelineFailedEmail(job *Job, failure error) error {
+	if !common.Config.Notification.Enabled || !common.Config.Notification.Email.Enabled {
+		return nil
+	}
+	template, err := models.GetEmailTemplateByType(common.Database, common.NOTIFICATION_TYPE_ADMIN_PIPELINE_FAILED)
+	if err != nil {
+		return err
+	}
+	if !template.Enabled {
+		return nil
+	}
+	users, err := models.GetUsersByRoleLessOrEqualsAndNotification(common.Database, models.ROLE_ADMIN, true)
+	if err != nil {
+		return err
+	}
+	vars := make(map[string]interface{})
+	vars["Url"] = common.Config.Url
+	vars["Error"] = failure.Error()
+	if job != nil {
+		// Error of finished job contains its log which is in view already
+		vars["Error"] = fmt.Sprintf("%v job #%v %v with code %v", job.Name, job.ID, job.Status, job.ExitCode)
+		vars["Job"] = job.View()
+	}
+	for _, user := range users {
+		logger.Infof("Send email admin user: %+v", user.Email)
+		if err = common.NOTIFICATION.SendEmail(mail.NewEmail(common.Config.Notification.Email.Name, common.Config.Notification.Email.Email), mail.NewEmail(user.Login, user.Email), template.Topic, template.Message, vars); err != nil {
+			logger.Errorf("%+v", err)
+		}
+	}
+	return nil
+}
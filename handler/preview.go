@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PREVIEW_FOLDER  = "preview" // in temp folder, site is never written by preview
+	PREVIEW_TIMEOUT = 5 * time.Minute
+)
+
+var (
+	previewMutex sync.Mutex
+	rePreviewName = regexp.MustCompile(`^(product|category)-\d+$`)
+)
+
+// PreviewConfig is merged over site config, preview workspace has its own content, data, static and database copy and
+// shares the rest
+type PreviewConfig struct {
+	ThemesDir string `toml:"themesDir"`
+	LayoutDir string `toml:"layoutDir"`
+	I18nDir string `toml:"i18nDir"`
+	AssetDir string `toml:"assetDir"`
+	StaticDir []string `toml:"staticDir"`
+}
+
+// renderPreview renders drafts of one product or category to workspace temp/preview/<name> and returns path of its page
+func renderPreview(name string, flags []string, baseURL string) (string, error) {
+	previewMutex.Lock()
+	defer previewMutex.Unlock()
+	site := path.Join(dir, "hugo")
+	ws := path.Join(dir, "temp", PREVIEW_FOLDER, name)
+	if err := os.RemoveAll(ws); err != nil {
+		return "", err
+	}
+	for _, folder := range []string{"content", "data", "static"} {
+		if err := os.MkdirAll(path.Join(ws, folder), 0755); err != nil {
+			return "", err
+		}
+	}
+	// Data files not generated by render are taken from site
+	if infos, err := ioutil.ReadDir(path.Join(dir, "hugo", "data")); err == nil {
+		for _, info := range infos {
+			if !info.IsDir() {
+				if err = common.Copy(path.Join(dir, "hugo", "data", info.Name()), path.Join(ws, "data", info.Name())); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+	rel, err := filepath.Rel(ws, site)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Create(path.Join(ws, "preview.toml"))
+	if err != nil {
+		return "", err
+	}
+	if err = toml.NewEncoder(f).Encode(PreviewConfig{
+		ThemesDir: path.Join(rel, "themes"),
+		LayoutDir: path.Join(rel, "layouts"),
+		I18nDir: path.Join(rel, "i18n"),
+		AssetDir: path.Join(rel, "assets"),
+		StaticDir: []string{path.Join(rel, "static"), "static"},
+	}); err != nil {
+		f.Close()
+		return "", err
+	}
+	f.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), PREVIEW_TIMEOUT)
+	defer cancel()
+	// Prepare
+	arguments := append([]string{"render", "--preview", "-p", path.Join(ws, "content"), "--site", ws}, flags...)
+	if output, err := exec.CommandContext(ctx, os.Args[0], arguments...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%v: %v", err, string(output))
+	}
+	// Render, docker based hugo gets application folder mounted at the same path to see workspace and site
+	bin := strings.Split(common.Config.Hugo.Bin, " ")
+	arguments = []string{}
+	if len(bin) > 1 {
+		var mounted bool
+		for _, x := range bin[1:]{
+			x = strings.Replace(x, "%DIR%", dir, -1)
+			arguments = append(arguments, x)
+			if x == "run" && !mounted {
+				arguments = append(arguments, "-v", dir + ":" + dir)
+				mounted = true
+			}
+		}
+		if !mounted {
+			return "", fmt.Errorf("preview needs hugo binary or docker run command")
+		}
+	}
+	arguments = append(arguments, []string{"-s", ws, "--config", "preview.toml," + path.Join(rel, "config.toml"), "-d", "public", "--baseURL", baseURL, "--cleanDestinationDir"}...)
+	if output, err := exec.CommandContext(ctx, bin[0], arguments...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%v: %v", err, string(output))
+	}
+	bts, err := ioutil.ReadFile(path.Join(ws, "preview.json"))
+	if err != nil {
+		return "", err
+	}
+	var page struct {
+		Path string
+	}
+	if err = json.Unmarshal(bts, &page); err != nil {
+		return "", err
+	}
+	return page.Path, nil
+}
+
+func previewHandler(c *fiber.Ctx, kind string) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		c.Status(http.StatusBadRequest)
+		return c.JSON(HTTPError{"Invalid id"})
+	}
+	name := fmt.Sprintf("%v-%d", kind, id)
+	base := c.BaseURL() + "/api/v1/preview/site/" + name
+	t1 := time.Now()
+	p, err := renderPreview(name, []string{"--" + kind, strconv.Itoa(id)}, base + "/")
+	if err != nil {
+		logger.Errorf("%+v", err)
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	logger.Infof("Preview %v rendered ~ %.3f ms", name, float64(time.Since(t1).Nanoseconds())/1000000)
+	return c.Redirect(base + p, http.StatusFound)
+}
+
+// @security BasicAuth
+// GetPreviewProduct godoc
+// @Summary Get product preview
+// @Description Render product drafts without saving and redirect to rendered page
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 302
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/preview/products/{id} [get]
+// @Tags preview
+func getPreviewProductHandler(c *fiber.Ctx) error {
+	return previewHandler(c, "product")
+}
+
+// @security BasicAuth
+// GetPreviewCategory godoc
+// @Summary Get category preview
+// @Description Render category with its product drafts without saving and redirect to rendered page
+// @Accept json
+// @Produce json
+// @Param id path int true "Category ID"
+// @Success 302
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/preview/categories/{id} [get]
+// @Tags preview
+func getPreviewCategoryHandler(c *fiber.Ctx) error {
+	return previewHandler(c, "category")
+}
+
+// @security BasicAuth
+// GetPreviewSite godoc
+// @Summary Get preview site file
+// @Description Serve file of rendered preview
+// @Produce html
+// @Param name path string true "Preview name"
+// @Success 200
+// @Failure 404 {object} HTTPError
+// @Router /api/v1/preview/site/{name}/{path} [get]
+// @Tags preview
+func getPreviewSiteHandler(c *fiber.Ctx) error {
+	name := c.Params("name")
+	if !rePreviewName.MatchString(name) {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{"Preview not found"})
+	}
+	// Cleaned absolute path can not leave public folder
+	p := path.Join(dir, "temp", PREVIEW_FOLDER, name, "public", path.Clean("/" + c.Params("*")))
+	if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+		p = path.Join(p, "index.html")
+	}
+	if _, err := os.Stat(p); err != nil {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{"File not found"})
+	}
+	return c.SendFile(p)
+}
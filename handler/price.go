@@ -122,6 +122,15 @@ func postPriceAllHandler(c *fiber.Ctx) error {
 		return err
 	}
 	//
+	if _, err := createPrices(requests); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	return c.JSON(HTTPMessage{"OK"})
+}
+
+func createPrices(requests []NewPrice) ([]*models.Price, error) {
+	var prices []*models.Price
 	for _, request := range requests {
 		price := &models.Price{
 			Enabled: request.Enabled,
@@ -143,13 +152,12 @@ func postPriceAllHandler(c *fiber.Ctx) error {
 			}
 		}
 		//
-		var err error
-		if _, err = models.CreatePrice(common.Database, price); err != nil {
-			c.Status(http.StatusInternalServerError)
-			return c.JSON(HTTPError{err.Error()})
+		if _, err := models.CreatePrice(common.Database, price); err != nil {
+			return prices, err
 		}
+		prices = append(prices, price)
 	}
-	return c.JSON(HTTPMessage{"OK"})
+	return prices, nil
 }
 
 type ExistingPrice struct {
@@ -290,6 +290,18 @@ func postProductsHandler(c *fiber.Ctx) error {
 	if v, found := data.Value["Content"]; found && len(v) > 0 {
 		content = strings.TrimSpace(v[0])
 	}
+	var bundle bool
+	if v, found := data.Value["Bundle"]; found && len(v) > 0 {
+		bundle, _ = strconv.ParseBool(v[0])
+	}
+	var bundlePricing string
+	if v, found := data.Value["BundlePricing"]; found && len(v) > 0 {
+		bundlePricing = strings.TrimSpace(v[0])
+	}
+	var bundleDiscount float64
+	if v, found := data.Value["BundleDiscount"]; found && len(v) > 0 {
+		bundleDiscount, _ = strconv.ParseFloat(v[0], 10)
+	}
 	var customization string
 	if v, found := data.Value["Customization"]; found && len(v) > 0 {
 		customization = strings.TrimSpace(v[0])
@@ -301,7 +313,8 @@ func postProductsHandler(c *fiber.Ctx) error {
 		MinQuantity: minQuantity, MaxQuantity: maxQuantity, PurchasableMultiply: purchasableMultiply,
 		Pattern: pattern, Dimensions: dimensions, DimensionUnit: dimensionUnit, Width: width, Height: height,
 		Depth: depth, Volume: volume, Weight: weight, WeightUnit: weightUnit, Packages: packages, Availability: availability, TimeId: timeId,
-		Sku: sku, Stock: stock, Content: content, Customization: customization,
+		Sku: sku, Stock: stock, Content: content, Bundle: bundle, BundlePricing: bundlePricing, BundleDiscount: bundleDiscount,
+		Customization: customization,
 	}
 	if _, err := models.CreateProduct(common.Database, product); err == nil {
 		// Create new product automatically
@@ -381,6 +394,7 @@ func postProductsHandler(c *fiber.Ctx) error {
 		/*if _, err = models.CreateVariation(common.Database, &models.Variation{Title: "Default", Name: "default", Description: "", BasePrice: basePrice, ProductId: product.ID}); err != nil {
 			logger.Errorf("%v", err)
 		}*/
+		reindex(product.ID)
 	}else{
 		c.Status(http.StatusInternalServerError)
 		return c.JSON(HTTPError{err.Error()})
@@ -465,6 +479,65 @@ func postProductsListHandler(c *fiber.Ctx) error {
 		request.Length = 10
 	}
 	// Filter
+	keys1, values1, keys2, values2 := productsListFilter(request, cid)
+	//logger.Infof("keys1: %+v, values1: %+v", keys1, values1)
+	//
+	// Sort
+	var order string
+	if len(request.Sort) > 0 {
+		var orders []string
+		for key, value := range request.Sort {
+			if key != "" && value != "" {
+				switch key {
+				case "Sort":
+					orders = append(orders, fmt.Sprintf("%v %v", key, value))
+				case "Variations":
+					orders = append(orders, fmt.Sprintf("%v %v", key, value))
+				default:
+					orders = append(orders, fmt.Sprintf("products.%v %v", key, value))
+				}
+			}
+		}
+		order = strings.Join(orders, ", ")
+	}
+	//logger.Infof("order: %+v", order)
+	//
+	rows, err := common.Database.Debug().Model(&models.Product{}).Select("products.ID, products.Enabled, products.Name, products.Title, cache_images.Thumbnail as Thumbnail, products.Description, products.base_price as BasePrice, products.sale_price as SalePrice, products.Sku, products.Stock, group_concat(distinct variations.Id) as VariationIds, group_concat(distinct variations.Title) as Variations, group_concat(distinct variations.Sku) as VariationSkus, categories_products_sort.Value as Sort").Joins("left join categories_products on categories_products.product_id = products.id").Joins("left join categories_products_sort on categories_products_sort.productId = products.id")/*.Joins("left join cache_products on products.id = cache_products.product_id")*/.Joins("left join cache_images on products.image_id = cache_images.image_id").Joins("left join variations on variations.product_id = products.id").Group("products.id").Where(strings.Join(keys1, " and "), values1...).Having(strings.Join(keys2, " and "), values2...).Order(order).Limit(request.Length).Offset(request.Start).Rows()
+	if err == nil {
+		if err == nil {
+			for rows.Next() {
+				var item ProductsListItem
+				if err = common.Database.ScanRows(rows, &item); err == nil {
+					response.Data = append(response.Data, item)
+				} else {
+					logger.Errorf("%v", err)
+				}
+			}
+		}else{
+			logger.Errorf("%v", err)
+		}
+		rows.Close()
+	}
+	rows, err = common.Database.Debug().Model(&models.Product{}).Select("products.ID, products.Enabled, products.Name, products.Title, cache_images.Thumbnail as Thumbnail, products.Description, products.base_price as BasePrice, products.sale_price as SalePrice, products.Sku, products.Stock, group_concat(distinct variations.Id) as VariationIds, group_concat(distinct variations.Title) as Variations, group_concat(distinct variations.Sku) as VariationSkus").Joins("left join categories_products on categories_products.product_id = products.id").Joins("left join cache_images on products.image_id = cache_images.image_id").Joins("left join variations on variations.product_id = products.id").Group("products.id").Where(strings.Join(keys1, " and "), values1...).Having(strings.Join(keys2, " and "), values2...).Rows()
+	if err == nil {
+		for rows.Next() {
+			response.Filtered ++
+		}
+		rows.Close()
+	}
+	//
+	if cid > 0 {
+		common.Database.Debug().Model(&models.Product{}).Joins("left join categories_products on categories_products.product_id = products.id").Where("categories_products.category_id = ?", cid).Count(&response.Total)
+	}else{
+		response.Total = response.Filtered
+	}
+	//
+	c.Status(http.StatusOK)
+	return c.JSON(response)
+}
+
+// productsListFilter returns where and having conditions of products list request
+func productsListFilter(request ListRequest, cid int) ([]string, []interface{}, []string, []interface{}) {
 	var keys1 []string
 	var values1 []interface{}
 	var keys2 []string
@@ -540,60 +613,7 @@ func postProductsListHandler(c *fiber.Ctx) error {
 		keys1 = append(keys1, "categories_products.category_id = ?")
 		values1 = append(values1, cid)
 	}
-	//logger.Infof("keys1: %+v, values1: %+v", keys1, values1)
-	//
-	// Sort
-	var order string
-	if len(request.Sort) > 0 {
-		var orders []string
-		for key, value := range request.Sort {
-			if key != "" && value != "" {
-				switch key {
-				case "Sort":
-					orders = append(orders, fmt.Sprintf("%v %v", key, value))
-				case "Variations":
-					orders = append(orders, fmt.Sprintf("%v %v", key, value))
-				default:
-					orders = append(orders, fmt.Sprintf("products.%v %v", key, value))
-				}
-			}
-		}
-		order = strings.Join(orders, ", ")
-	}
-	//logger.Infof("order: %+v", order)
-	//
-	rows, err := common.Database.Debug().Model(&models.Product{}).Select("products.ID, products.Enabled, products.Name, products.Title, cache_images.Thumbnail as Thumbnail, products.Description, products.base_price as BasePrice, products.sale_price as SalePrice, products.Sku, products.Stock, group_concat(distinct variations.Id) as VariationIds, group_concat(distinct variations.Title) as Variations, group_concat(distinct variations.Sku) as VariationSkus, categories_products_sort.Value as Sort").Joins("left join categories_products on categories_products.product_id = products.id").Joins("left join categories_products_sort on categories_products_sort.productId = products.id")/*.Joins("left join cache_products on products.id = cache_products.product_id")*/.Joins("left join cache_images on products.image_id = cache_images.image_id").Joins("left join variations on variations.product_id = products.id").Group("products.id").Where(strings.Join(keys1, " and "), values1...).Having(strings.Join(keys2, " and "), values2...).Order(order).Limit(request.Length).Offset(request.Start).Rows()
-	if err == nil {
-		if err == nil {
-			for rows.Next() {
-				var item ProductsListItem
-				if err = common.Database.ScanRows(rows, &item); err == nil {
-					response.Data = append(response.Data, item)
-				} else {
-					logger.Errorf("%v", err)
-				}
-			}
-		}else{
-			logger.Errorf("%v", err)
-		}
-		rows.Close()
-	}
-	rows, err = common.Database.Debug().Model(&models.Product{}).Select("products.ID, products.Enabled, products.Name, products.Title, cache_images.Thumbnail as Thumbnail, products.Description, products.base_price as BasePrice, products.sale_price as SalePrice, products.Sku, products.Stock, group_concat(distinct variations.Id) as VariationIds, group_concat(distinct variations.Title) as Variations, group_concat(distinct variations.Sku) as VariationSkus").Joins("left join categories_products on categories_products.product_id = products.id").Joins("left join cache_images on products.image_id = cache_images.image_id").Joins("left join variations on variations.product_id = products.id").Group("products.id").Where(strings.Join(keys1, " and "), values1...).Having(strings.Join(keys2, " and "), values2...).Rows()
-	if err == nil {
-		for rows.Next() {
-			response.Filtered ++
-		}
-		rows.Close()
-	}
-	//
-	if cid > 0 {
-		common.Database.Debug().Model(&models.Product{}).Joins("left join categories_products on categories_products.product_id = products.id").Where("categories_products.category_id = ?", cid).Count(&response.Total)
-	}else{
-		response.Total = response.Filtered
-	}
-	//
-	c.Status(http.StatusOK)
-	return c.JSON(response)
+	return keys1, values1, keys2, values2
 }
 
 // @security BasicAuth
@@ -649,38 +669,11 @@ func getProductHandler(c *fiber.Ctx) error {
 						view.Images[i].Thumbnail = cache.Thumbnail
 					}
 				}
-				// Related Products 2
-				if rows, err := common.Database.Debug().Table("products_relations").Select("products_relations.ProductIdL as ProductIdL, products_relations.ProductIdR as ProductIdR").Where("products_relations.ProductIdL = ? or products_relations.ProductIdR = ?", product.ID, product.ID).Rows(); err == nil {
-					for rows.Next() {
-						var r struct {
-							ProductIdL uint
-							ProductIdR uint
-						}
-						if err = common.Database.ScanRows(rows, &r); err == nil {
-							if r.ProductIdL == product.ID {
-								var found bool
-								for _, p := range view.RelatedProducts {
-									if p.ID == r.ProductIdR {
-										found = true
-										break
-									}
-								}
-								if !found {
-									view.RelatedProducts = append(view.RelatedProducts, RelatedProduct{ID: r.ProductIdR})
-								}
-							}else{
-								var found bool
-								for _, p := range view.RelatedProducts {
-									if p.ID == r.ProductIdL {
-										found = true
-										break
-									}
-								}
-								if !found {
-									view.RelatedProducts = append(view.RelatedProducts, RelatedProduct{ID: r.ProductIdL})
-								}
-							}
-						}
+				// Related Products
+				if relations, err := models.GetProductRelations(common.Database, product.ID, models.RELATION_TYPE_RELATED); err == nil {
+					for _, relation := range relations {
+						id, _ := relation.Other(product.ID)
+						view.RelatedProducts = append(view.RelatedProducts, RelatedProduct{ID: id})
 					}
 				}
 				return c.JSON(view)
@@ -1189,6 +1182,14 @@ func putProductHandler(c *fiber.Ctx) error {
 			if v, found := data.Value["End"]; found && len(v) > 0 {
 				end, _ = time.Parse(time.RFC3339, v[0])
 			}
+			var publishStart time.Time
+			if v, found := data.Value["PublishStart"]; found && len(v) > 0 {
+				publishStart, _ = time.Parse(time.RFC3339, v[0])
+			}
+			var publishEnd time.Time
+			if v, found := data.Value["PublishEnd"]; found && len(v) > 0 {
+				publishEnd, _ = time.Parse(time.RFC3339, v[0])
+			}
 			var itemPrice float64
 			if v, found := data.Value["ItemPrice"]; found && len(v) > 0 {
 				itemPrice, _ = strconv.ParseFloat(v[0], 10)
@@ -1847,6 +1848,18 @@ func putProductHandler(c *fiber.Ctx) error {
 					stock = uint(vv)
 				}
 			}
+			var bundle bool
+			if v, found := data.Value["Bundle"]; found && len(v) > 0 {
+				bundle, _ = strconv.ParseBool(v[0])
+			}
+			var bundlePricing string
+			if v, found := data.Value["BundlePricing"]; found && len(v) > 0 {
+				bundlePricing = strings.TrimSpace(v[0])
+			}
+			var bundleDiscount float64
+			if v, found := data.Value["BundleDiscount"]; found && len(v) > 0 {
+				bundleDiscount, _ = strconv.ParseFloat(v[0], 10)
+			}
 			var customization string
 			if v, found := data.Value["Customization"]; found && len(v) > 0 {
 				customization = strings.TrimSpace(v[0])
@@ -1868,6 +1881,8 @@ func putProductHandler(c *fiber.Ctx) error {
 			product.SalePrice = salePrice
 			product.Start = start
 			product.End = end
+			product.PublishStart = publishStart
+			product.PublishEnd = publishEnd
 			oldItemPrice := product.ItemPrice
 			product.ItemPrice = oldItemPrice
 			product.MinQuantity = minQuantity
@@ -1890,6 +1905,9 @@ func putProductHandler(c *fiber.Ctx) error {
 			product.VendorId = vendorId
 			product.TimeId = timeId
 			product.Content = content
+			product.Bundle = bundle
+			product.BundlePricing = bundlePricing
+			product.BundleDiscount = bundleDiscount
 			product.Customization = customization
 			// Off => On
 			if container && !oldContainer {
@@ -2047,40 +2065,40 @@ func putProductHandler(c *fiber.Ctx) error {
 					}
 				}
 			}
-			// Related Products
-			if err = models.DeleteAllProductsFromProduct(common.Database, product); err != nil {
-				logger.Errorf("%v", err)
-			}
+			// Related Products, relations of other types are managed by /products/:id/relations
 			if v, found := data.Value["RelatedProducts"]; found && len(v) > 0 {
+				var ids []uint
 				for _, vv := range strings.Split(strings.TrimSpace(v[0]), ",") {
 					if vv != "" {
-						if productId, err := strconv.Atoi(strings.TrimSpace(vv)); err == nil {
-							if p, err := models.GetProduct(common.Database, productId); err == nil {
-								if err = models.AddProductToProduct(common.Database, product, p); err != nil {
-									logger.Errorf("%v", err)
-								}
-							} else {
-								logger.Errorf("%v", err)
-							}
-						} else {
-							logger.Errorf("%v", err)
+						if productId, err := strconv.Atoi(strings.TrimSpace(vv)); err == nil && uint(productId) != product.ID {
+							ids = append(ids, uint(productId))
 						}
 					}
 				}
-			}
-			// Related Products 2
-			if err := common.Database.Exec("delete from products_relations where ProductIdL = ? or ProductIdR = ?", product.ID, product.ID).Error; err != nil {
-				logger.Errorf("%+v", err)
-			}
-			if v, found := data.Value["RelatedProducts"]; found && len(v) > 0 {
-				for _, vv := range strings.Split(strings.TrimSpace(v[0]), ",") {
-					if vv != "" {
-						if productId, err := strconv.Atoi(strings.TrimSpace(vv)); err == nil {
-							if p, err := models.GetProduct(common.Database, productId); err == nil {
-								if err := common.Database.Exec("insert into products_relations (ProductIdL, ProductIdR) values (?, ?)", product.ID, p.ID).Error; err != nil {
-									logger.Errorf("%+v", err)
-								}
+				if relations, err := models.GetProductRelations(common.Database, product.ID, models.RELATION_TYPE_RELATED); err == nil {
+					for _, relation := range relations {
+						other, _ := relation.Other(product.ID)
+						var found bool
+						for i, id := range ids {
+							if id == other {
+								ids = append(ids[:i], ids[i + 1:]...)
+								found = true
+								break
+							}
+						}
+						if !found {
+							if err = models.DeleteProductRelation(common.Database, relation); err != nil {
+								logger.Errorf("%+v", err)
 							}
+							touchProducts(other)
+						}
+					}
+				}
+				for _, id := range ids {
+					if p, err := models.GetProduct(common.Database, int(id)); err == nil {
+						relation := &models.ProductRelation{ProductId: product.ID, RelatedId: p.ID, Type: models.RELATION_TYPE_RELATED, Bidirectional: true}
+						if _, err = models.CreateProductRelation(common.Database, relation); err != nil {
+							logger.Errorf("%+v", err)
 						}
 					}
 				}
@@ -2137,11 +2155,33 @@ func delProductHandler(c *fiber.Ctx) error {
 					}
 				}
 			}
+			if err = models.DeleteRevisionsByObject(common.Database, models.REVISION_OBJECT_VARIATION, variation.ID); err != nil {
+				logger.Errorf("%v", err.Error())
+			}
+			if err = models.DeleteTranslationsByObject(common.Database, models.TRANSLATION_OBJECT_VARIATION, variation.ID); err != nil {
+				logger.Errorf("%v", err.Error())
+			}
 			if err = models.DeleteVariation(common.Database, variation); err != nil {
 				logger.Errorf("%v", err.Error())
 			}
 		}
 		//
+		if err = models.DeleteRevisionsByObject(common.Database, models.REVISION_OBJECT_PRODUCT, product.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
+		if err = models.DeleteBundleComponentsByBundleId(common.Database, product.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
+		if err = models.DeleteProductRelationsByProductId(common.Database, product.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
+		if err = models.RemoveSearchDocument(common.Database, product.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
+		//
+		if err = models.DeleteTranslationsByObject(common.Database, models.TRANSLATION_OBJECT_PRODUCT, product.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
 		if err = models.DeleteProduct(common.Database, product); err != nil {
 			c.Status(http.StatusInternalServerError)
 			return c.JSON(HTTPError{err.Error()})
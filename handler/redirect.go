@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RedirectsView []RedirectView
+
+type RedirectView struct {
+	ID uint
+	CreatedAt time.Time
+	Source string
+	Target string
+	Code int
+	Auto bool
+	ObjectType string `json:",omitempty"`
+	ObjectId uint `json:",omitempty"`
+}
+
+type NewRedirect struct {
+	Source string // old path, /products/old-name/
+	Target string // new path or absolute url
+	Code int // 301 (default) or 302
+}
+
+func newRedirectView(redirect *models.Redirect) RedirectView {
+	return RedirectView{
+		ID: redirect.ID,
+		CreatedAt: redirect.CreatedAt,
+		Source: redirect.Source,
+		Target: redirect.Target,
+		Code: redirect.Code,
+		Auto: redirect.Auto,
+		ObjectType: redirect.ObjectType,
+		ObjectId: redirect.ObjectId,
+	}
+}
+
+// @security BasicAuth
+// GetRedirects godoc
+// @Summary Get redirects
+// @Accept json
+// @Produce json
+// @Success 200 {object} RedirectsView
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/redirects [get]
+// @Tags redirect
+func getRedirectsHandler(c *fiber.Ctx) error {
+	if redirects, err := models.GetRedirects(common.Database); err == nil {
+		views := RedirectsView{}
+		for _, redirect := range redirects {
+			views = append(views, newRedirectView(redirect))
+		}
+		return c.JSON(views)
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// @security BasicAuth
+// CreateRedirect godoc
+// @Summary Create redirect, chains are collapsed to the final target
+// @Accept json
+// @Produce json
+// @Param request body NewRedirect true "body"
+// @Success 200 {object} RedirectView
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/redirects [post]
+// @Tags redirect
+func postRedirectHandler(c *fiber.Ctx) error {
+	redirect := &models.Redirect{}
+	return saveRedirect(c, redirect)
+}
+
+// @security BasicAuth
+// GetRedirect godoc
+// @Summary Get redirect
+// @Accept json
+// @Produce json
+// @Param id path int true "Redirect ID"
+// @Success 200 {object} RedirectView
+// @Failure 404 {object} HTTPError
+// @Router /api/v1/redirects/{id} [get]
+// @Tags redirect
+func getRedirectHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	if redirect, err := models.GetRedirect(common.Database, id); err == nil {
+		return c.JSON(newRedirectView(redirect))
+	}else{
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// @security BasicAuth
+// UpdateRedirect godoc
+// @Summary Update redirect, it becomes manual and is not changed by render anymore
+// @Accept json
+// @Produce json
+// @Param id path int true "Redirect ID"
+// @Param request body NewRedirect true "body"
+// @Success 200 {object} RedirectView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/redirects/{id} [put]
+// @Tags redirect
+func putRedirectHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	redirect, err := models.GetRedirect(common.Database, id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	return saveRedirect(c, redirect)
+}
+
+// @security BasicAuth
+// DelRedirect godoc
+// @Summary Delete redirect
+// @Accept json
+// @Produce json
+// @Param id path int true "Redirect ID"
+// @Success 200 {object} HTTPMessage
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/redirects/{id} [delete]
+// @Tags redirect
+func delRedirectHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	if redirect, err := models.GetRedirect(common.Database, id); err == nil {
+		if err = models.DeleteRedirect(common.Database, redirect); err == nil {
+			return c.JSON(HTTPMessage{MESSAGE: "OK"})
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{err.Error()})
+		}
+	}else{
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+func saveRedirect(c *fiber.Ctx, redirect *models.Redirect) error {
+	var request NewRedirect
+	if contentType := string(c.Request().Header.ContentType()); contentType != "" {
+		if strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+			if err := c.BodyParser(&request); err != nil {
+				return err
+			}
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{"Unsupported Content-Type"})
+		}
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{"Content-Type not set"})
+	}
+	request.Source = strings.TrimSpace(request.Source)
+	if !strings.HasPrefix(request.Source, "/") {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{"Source should be a path starting with /"})
+	}
+	request.Target = strings.TrimSpace(request.Target)
+	if !strings.HasPrefix(request.Target, "/") && !strings.HasPrefix(request.Target, "http://") && !strings.HasPrefix(request.Target, "https://") {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{"Target should be a path or absolute url"})
+	}
+	switch request.Code {
+	case 0:
+		request.Code = models.REDIRECT_CODE_PERMANENT
+	case models.REDIRECT_CODE_PERMANENT, models.REDIRECT_CODE_TEMPORARY:
+	default:
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{fmt.Sprintf("Unsupported code %v", request.Code)})
+	}
+	redirect.Source = request.Source
+	redirect.Target = request.Target
+	redirect.Code = request.Code
+	redirect.Auto = false
+	if err := models.SaveRedirect(common.Database, redirect); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	return c.JSON(newRedirectView(redirect))
+}
@@ -0,0 +1,331 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RelationsView []RelationView
+
+type RelationView struct {
+	ID uint
+	ProductId uint
+	Name string `json:",omitempty"`
+	Title string `json:",omitempty"`
+	Thumbnail string `json:",omitempty"`
+	Type string
+	Sort int
+	Bidirectional bool
+	Incoming bool `json:",omitempty"` // defined on related product
+}
+
+type NewRelation struct {
+	ProductId uint
+	Type string
+	Sort int
+	Bidirectional bool
+}
+
+func newRelationView(relation *models.ProductRelation, productId uint) RelationView {
+	id, product := relation.Other(productId)
+	view := RelationView{
+		ID: relation.ID,
+		ProductId: id,
+		Type: relation.Type,
+		Sort: relation.Sort,
+		Bidirectional: relation.Bidirectional,
+		Incoming: relation.ProductId != productId,
+	}
+	if product != nil {
+		view.Name = product.Name
+		view.Title = product.Title
+	}
+	if cache, err := models.GetCacheProductByProductId(common.Database, id); err == nil {
+		view.Thumbnail = cache.Thumbnail
+	}
+	return view
+}
+
+// @security BasicAuth
+// GetProductRelations godoc
+// @Summary Get product relations
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param type query string false "related, cross-sell, up-sell or accessory"
+// @Success 200 {object} RelationsView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/products/{id}/relations [get]
+// @Tags product
+func getProductRelationsHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	var types []string
+	if v := c.Query("type"); v != "" {
+		types = strings.Split(v, ",")
+	}
+	if relations, err := models.GetProductRelations(common.Database, uint(id), types...); err == nil {
+		views := RelationsView{}
+		for _, relation := range relations {
+			views = append(views, newRelationView(relation, uint(id)))
+		}
+		return c.JSON(views)
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// @security BasicAuth
+// CreateProductRelation godoc
+// @Summary Create product relation
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param relation body NewRelation true "body"
+// @Success 200 {object} RelationView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/products/{id}/relations [post]
+// @Tags product
+func postProductRelationHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	var err error
+	var product *models.Product
+	if product, err = models.GetProduct(common.Database, id); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	if contentType := string(c.Request().Header.ContentType()); contentType != "" {
+		if strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+			var request NewRelation
+			if err := c.BodyParser(&request); err != nil {
+				return err
+			}
+			if err = validateRelation(product, &request); err != nil {
+				c.Status(http.StatusInternalServerError)
+				return c.JSON(HTTPError{err.Error()})
+			}
+			if relations, err := models.GetProductRelations(common.Database, product.ID, request.Type); err == nil {
+				for _, relation := range relations {
+					if other, _ := relation.Other(product.ID); other == request.ProductId {
+						c.Status(http.StatusInternalServerError)
+						return c.JSON(HTTPError{"Relation already exists"})
+					}
+				}
+			}
+			relation := &models.ProductRelation{
+				ProductId: product.ID,
+				RelatedId: request.ProductId,
+				Type: request.Type,
+				Sort: request.Sort,
+				Bidirectional: request.Bidirectional,
+			}
+			if _, err = models.CreateProductRelation(common.Database, relation); err != nil {
+				c.Status(http.StatusInternalServerError)
+				return c.JSON(HTTPError{err.Error()})
+			}
+			if relation, err = models.GetProductRelation(common.Database, int(relation.ID)); err != nil {
+				c.Status(http.StatusInternalServerError)
+				return c.JSON(HTTPError{err.Error()})
+			}
+			return c.JSON(newRelationView(relation, product.ID))
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{"Unsupported Content-Type"})
+		}
+	}
+	c.Status(http.StatusInternalServerError)
+	return c.JSON(HTTPError{"Something went wrong"})
+}
+
+// @security BasicAuth
+// UpdateProductRelation godoc
+// @Summary Update product relation
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param relation_id path int true "Relation ID"
+// @Param relation body NewRelation true "body"
+// @Success 200 {object} RelationView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/products/{id}/relations/{relation_id} [put]
+// @Tags product
+func putProductRelationHandler(c *fiber.Ctx) error {
+	var request NewRelation
+	if err := c.BodyParser(&request); err != nil {
+		return err
+	}
+	relation, product, err := getProductRelation(c)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	if err = validateRelation(product, &request); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	// Incoming relation keeps its owner, only other side could be changed
+	if relation.ProductId == product.ID {
+		relation.RelatedId = request.ProductId
+	} else {
+		relation.ProductId = request.ProductId
+	}
+	relation.Product = nil
+	relation.Related = nil
+	relation.Type = request.Type
+	relation.Sort = request.Sort
+	relation.Bidirectional = request.Bidirectional
+	if err = models.UpdateProductRelation(common.Database, relation); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	if relation, err = models.GetProductRelation(common.Database, int(relation.ID)); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	return c.JSON(newRelationView(relation, product.ID))
+}
+
+// @security BasicAuth
+// DelProductRelation godoc
+// @Summary Delete product relation
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param relation_id path int true "Relation ID"
+// @Success 200 {object} HTTPMessage
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/products/{id}/relations/{relation_id} [delete]
+// @Tags product
+func delProductRelationHandler(c *fiber.Ctx) error {
+	relation, _, err := getProductRelation(c)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	if err = models.DeleteProductRelation(common.Database, relation); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	touchProducts(relation.ProductId, relation.RelatedId)
+	return c.JSON(HTTPMessage{MESSAGE: "OK"})
+}
+
+// getProductRelation loads relation from path and checks it is visible from the product
+func getProductRelation(c *fiber.Ctx) (*models.ProductRelation, *models.Product, error) {
+	var id, relationId int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	if v := c.Params("relation_id"); v != "" {
+		relationId, _ = strconv.Atoi(v)
+	}
+	product, err := models.GetProduct(common.Database, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	relation, err := models.GetProductRelation(common.Database, relationId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if relation.ProductId != product.ID && (relation.RelatedId != product.ID || !relation.Bidirectional) {
+		return nil, nil, fmt.Errorf("relation #%v of product #%v not found", relationId, id)
+	}
+	return relation, product, nil
+}
+
+func validateRelation(product *models.Product, request *NewRelation) error {
+	if request.Type == "" {
+		request.Type = models.RELATION_TYPE_RELATED
+	}
+	var found bool
+	for _, typ := range models.RelationTypes {
+		if typ == request.Type {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("unknown relation type %v", request.Type)
+	}
+	if request.ProductId == product.ID {
+		return fmt.Errorf("product can not be related to itself")
+	}
+	if _, err := models.GetProduct(common.Database, int(request.ProductId)); err != nil {
+		return fmt.Errorf("product #%v not found", request.ProductId)
+	}
+	return nil
+}
+
+type SuggestionView struct {
+	ID uint
+	Type string
+	Name string
+	Title string
+	Path string `json:",omitempty"`
+	Thumbnail string `json:",omitempty"`
+	BasePrice float64
+	SalePrice float64 `json:",omitempty"`
+}
+
+// GetSuggestions returns cross-sell and accessory products of cart items which are not in the cart yet
+func GetSuggestions(items []*models.Item, tax float64) []SuggestionView {
+	var suggestions []SuggestionView
+	now := time.Now()
+	ids := make(map[uint]bool)
+	for _, item := range items {
+		ids[item.ProductId] = true
+	}
+	for _, item := range items {
+		if item.BundleId > 0 {
+			continue
+		}
+		relations, err := models.GetProductRelations(common.Database, item.ProductId, models.RELATION_TYPE_CROSS_SELL, models.RELATION_TYPE_ACCESSORY)
+		if err != nil {
+			logger.Warningf("%+v", err)
+			continue
+		}
+		for _, relation := range relations {
+			id, product := relation.Other(item.ProductId)
+			if product == nil || ids[id] {
+				continue
+			}
+			if product, err = models.GetPublishedProduct(common.Database, product); err != nil || !product.IsPublished(now) {
+				continue
+			}
+			ids[id] = true
+			suggestion := SuggestionView{
+				ID: id,
+				Type: relation.Type,
+				Name: product.Name,
+				Title: product.Title,
+				BasePrice: product.BasePrice * tax,
+				SalePrice: product.SalePrice * tax,
+			}
+			if cache, err := models.GetCacheProductByProductId(common.Database, id); err == nil {
+				suggestion.Path = cache.Path
+				suggestion.Thumbnail = cache.Thumbnail
+				suggestion.BasePrice = cache.BasePrice * tax
+				suggestion.SalePrice = cache.SalePrice * tax
+			}
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return suggestions
+}
@@ -0,0 +1,641 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"gorm.io/gorm"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RevisionsView []RevisionView
+
+type RevisionView struct {
+	ID uint
+	CreatedAt time.Time
+	ObjectType string
+	ObjectId uint
+	Status string
+	Comment string `json:",omitempty"`
+	UserId uint `json:",omitempty"`
+	Data json.RawMessage `json:",omitempty"`
+}
+
+type RevisionDiffView struct {
+	From uint
+	To uint
+	Changes []RevisionChangeView
+}
+
+type RevisionChangeView struct {
+	Path string
+	Old interface{} `json:",omitempty"`
+	New interface{} `json:",omitempty"`
+}
+
+type NewRevisionsPublish struct {
+	Ids []uint // empty means all drafts
+}
+
+func newRevisionView(revision *models.Revision, data bool) RevisionView {
+	view := RevisionView{
+		ID: revision.ID,
+		CreatedAt: revision.CreatedAt,
+		ObjectType: revision.ObjectType,
+		ObjectId: revision.ObjectId,
+		Status: revision.Status,
+		Comment: revision.Comment,
+		UserId: revision.UserId,
+	}
+	if data {
+		view.Data = json.RawMessage(revision.Data)
+	}
+	return view
+}
+
+// isDraft reports whether save request asks to stage changes instead of publishing them
+func isDraft(c *fiber.Ctx) bool {
+	draft, _ := strconv.ParseBool(c.Query("draft"))
+	return draft
+}
+
+// saveRevision stores snapshot of object from path, initial snapshot is made before the very first change
+func saveRevision(c *fiber.Ctx, objectType string, status string, comment string) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	return saveObjectRevision(c, objectType, uint(id), status, comment)
+}
+
+func saveObjectRevision(c *fiber.Ctx, objectType string, id uint, status string, comment string) error {
+	return newObjectRevision(common.Database, objectType, id, status, comment, userOf(c))
+}
+
+// newObjectRevision stores snapshot of object, connector may be transaction of the change itself
+func newObjectRevision(connector *gorm.DB, objectType string, id uint, status string, comment string, userId uint) error {
+	data, err := models.NewSnapshot(connector, objectType, id)
+	if err != nil {
+		return err
+	}
+	revision := &models.Revision{
+		ObjectType: objectType,
+		ObjectId: id,
+		Status: status,
+		Comment: comment,
+		UserId: userId,
+		Data: data,
+	}
+	_, err = models.CreateRevision(connector, revision)
+	return err
+}
+
+func userOf(c *fiber.Ctx) uint {
+	if v := c.Locals("user"); v != nil {
+		if user, ok := v.(*models.User); ok {
+			return user.ID
+		}
+	}
+	return 0
+}
+
+// revisioned wraps save handler to record revision before and after it
+func revisioned(objectType string) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		var id int
+		if v := c.Params("id"); v != "" {
+			id, _ = strconv.Atoi(v)
+		}
+		if _, err := models.GetLastRevision(common.Database, objectType, uint(id)); err != nil {
+			if err = saveRevision(c, objectType, models.REVISION_STATUS_PUBLISHED, "initial"); err != nil {
+				logger.Warningf("%+v", err)
+			}
+		}
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if c.Response().StatusCode() == http.StatusOK {
+			status := models.REVISION_STATUS_PUBLISHED
+			if isDraft(c) {
+				status = models.REVISION_STATUS_DRAFT
+			}
+			if err := saveRevision(c, objectType, status, c.Query("comment")); err != nil {
+				logger.Warningf("%+v", err)
+			}
+			if status == models.REVISION_STATUS_PUBLISHED {
+				reindexObject(objectType, uint(id))
+			}
+		}
+		return nil
+	}
+}
+
+type revisionOwner struct {
+	ObjectType string
+	ObjectId uint
+}
+
+// revisionedOwners wraps save handler of product or variation part (price, parameter, property, rate, image) to record
+// revisions of objects it belongs to, change is staged if owner already has unpublished draft
+func revisionedOwners(resolve func(c *fiber.Ctx) []revisionOwner) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		owners := resolve(c)
+		initialRevisions(common.Database, owners, userOf(c))
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if c.Response().StatusCode() == http.StatusOK {
+			reindexOwners(ownerRevisions(common.Database, owners, isDraft(c), c.Query("comment"), userOf(c)))
+		}
+		return nil
+	}
+}
+
+// initialRevisions stores state of objects before their very first recorded change
+func initialRevisions(connector *gorm.DB, owners []revisionOwner, userId uint) {
+	for _, owner := range owners {
+		if _, err := models.GetLastRevision(connector, owner.ObjectType, owner.ObjectId); err != nil {
+			if err = newObjectRevision(connector, owner.ObjectType, owner.ObjectId, models.REVISION_STATUS_PUBLISHED, "initial", userId); err != nil {
+				logger.Warningf("%+v", err)
+			}
+		}
+	}
+}
+
+// ownerRevisions stores state of objects after change, change is staged if requested or object already has unpublished draft,
+// returns objects with published changes
+func ownerRevisions(connector *gorm.DB, owners []revisionOwner, draft bool, comment string, userId uint) []revisionOwner {
+	var published []revisionOwner
+	for _, owner := range owners {
+		status := models.REVISION_STATUS_PUBLISHED
+		if draft || models.HasDraft(connector, owner.ObjectType, owner.ObjectId) {
+			status = models.REVISION_STATUS_DRAFT
+		}
+		if err := newObjectRevision(connector, owner.ObjectType, owner.ObjectId, status, comment, userId); err != nil {
+			logger.Warningf("%+v", err)
+		}
+		if status == models.REVISION_STATUS_PUBLISHED {
+			published = append(published, owner)
+		}
+	}
+	return published
+}
+
+// reindexOwners makes published changes visible to incremental render and search, call it after transaction is committed
+func reindexOwners(owners []revisionOwner) {
+	for _, owner := range owners {
+		// deleted parts and join rows are not found by incremental render otherwise
+		if owner.ObjectType == models.REVISION_OBJECT_PRODUCT {
+			touchProducts(owner.ObjectId)
+		}else{
+			touchVariations(owner.ObjectId)
+		}
+		reindexObject(owner.ObjectType, owner.ObjectId)
+	}
+}
+
+func queryOwner(c *fiber.Ctx, key string, objectType string) []revisionOwner {
+	if id, err := strconv.Atoi(c.Query(key)); err == nil && id > 0 {
+		return []revisionOwner{{ObjectType: objectType, ObjectId: uint(id)}}
+	}
+	return nil
+}
+
+func paramId(c *fiber.Ctx) int {
+	id, _ := strconv.Atoi(c.Params("id"))
+	return id
+}
+
+// ownerOf returns product or variation having given ids, variation wins as for prices and properties
+func ownerOf(productId, variationId uint) []revisionOwner {
+	if variationId > 0 {
+		return []revisionOwner{{ObjectType: models.REVISION_OBJECT_VARIATION, ObjectId: variationId}}
+	}else if productId > 0 {
+		return []revisionOwner{{ObjectType: models.REVISION_OBJECT_PRODUCT, ObjectId: productId}}
+	}
+	return nil
+}
+
+func parameterOwners(c *fiber.Ctx) []revisionOwner {
+	if c.Params("id") == "" {
+		return queryOwner(c, "product_id", models.REVISION_OBJECT_PRODUCT)
+	}
+	if parameter, err := models.GetParameter(common.Database, paramId(c)); err == nil {
+		return ownerOf(parameter.ProductId, 0)
+	}
+	return nil
+}
+
+func propertyOwners(c *fiber.Ctx) []revisionOwner {
+	if c.Params("id") == "" {
+		if owners := queryOwner(c, "product_id", models.REVISION_OBJECT_PRODUCT); len(owners) > 0 {
+			return owners
+		}
+		return queryOwner(c, "variation_id", models.REVISION_OBJECT_VARIATION)
+	}
+	if property, err := models.GetProperty(common.Database, paramId(c)); err == nil {
+		return ownerOf(property.ProductId, property.VariationId)
+	}
+	return nil
+}
+
+func rateOwners(c *fiber.Ctx) []revisionOwner {
+	var propertyId int
+	if c.Params("id") == "" {
+		propertyId, _ = strconv.Atoi(c.Query("property_id"))
+	}else if rate, err := models.GetRate(common.Database, paramId(c)); err == nil {
+		propertyId = int(rate.PropertyId)
+	}
+	if property, err := models.GetProperty(common.Database, propertyId); err == nil {
+		return ownerOf(property.ProductId, property.VariationId)
+	}
+	return nil
+}
+
+func priceOwners(c *fiber.Ctx) []revisionOwner {
+	var owners []revisionOwner
+	seen := make(map[revisionOwner]bool)
+	add := func(productId, variationId uint) {
+		for _, owner := range ownerOf(productId, variationId) {
+			if !seen[owner] {
+				seen[owner] = true
+				owners = append(owners, owner)
+			}
+		}
+	}
+	if c.Params("id") != "" {
+		if price, err := models.GetPrice(common.Database, paramId(c)); err == nil {
+			add(price.ProductId, price.VariationId)
+		}
+		return owners
+	}
+	if strings.HasSuffix(c.Path(), "/all") {
+		var requests []NewPrice
+		if err := json.Unmarshal(c.Body(), &requests); err == nil {
+			for _, request := range requests {
+				if c.Method() == http.MethodPut {
+					if price, err := models.GetPrice(common.Database, int(request.ID)); err == nil {
+						add(price.ProductId, price.VariationId)
+					}
+				}else{
+					add(request.ProductId, request.VariationId)
+				}
+			}
+		}
+		return owners
+	}
+	var request NewPrice
+	if err := json.Unmarshal(c.Body(), &request); err == nil {
+		add(request.ProductId, request.VariationId)
+	}
+	return owners
+}
+
+func imageOwners(c *fiber.Ctx) []revisionOwner {
+	if c.Params("id") == "" {
+		if owners := queryOwner(c, "pid", models.REVISION_OBJECT_PRODUCT); len(owners) > 0 {
+			return owners
+		}
+		return queryOwner(c, "vid", models.REVISION_OBJECT_VARIATION)
+	}
+	productIds, variationIds, err := models.GetImageOwners(common.Database, uint(paramId(c)))
+	if err != nil {
+		logger.Warningf("%+v", err)
+		return nil
+	}
+	var owners []revisionOwner
+	for _, id := range productIds {
+		owners = append(owners, revisionOwner{ObjectType: models.REVISION_OBJECT_PRODUCT, ObjectId: id})
+	}
+	for _, id := range variationIds {
+		owners = append(owners, revisionOwner{ObjectType: models.REVISION_OBJECT_VARIATION, ObjectId: id})
+	}
+	return owners
+}
+
+func getRevisions(c *fiber.Ctx, objectType string) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	if revisions, err := models.GetRevisions(common.Database, objectType, uint(id)); err == nil {
+		views := RevisionsView{}
+		for _, revision := range revisions {
+			views = append(views, newRevisionView(revision, false))
+		}
+		return c.JSON(views)
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// @security BasicAuth
+// GetProductRevisions godoc
+// @Summary Get product revisions
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {object} RevisionsView
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/products/{id}/revisions [get]
+// @Tags revision
+func getProductRevisionsHandler(c *fiber.Ctx) error {
+	return getRevisions(c, models.REVISION_OBJECT_PRODUCT)
+}
+
+// @security BasicAuth
+// GetVariationRevisions godoc
+// @Summary Get variation revisions
+// @Accept json
+// @Produce json
+// @Param id path int true "Variation ID"
+// @Success 200 {object} RevisionsView
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/variations/{id}/revisions [get]
+// @Tags revision
+func getVariationRevisionsHandler(c *fiber.Ctx) error {
+	return getRevisions(c, models.REVISION_OBJECT_VARIATION)
+}
+
+// @security BasicAuth
+// GetCategoryRevisions godoc
+// @Summary Get category revisions
+// @Accept json
+// @Produce json
+// @Param id path int true "Category ID"
+// @Success 200 {object} RevisionsView
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/categories/{id}/revisions [get]
+// @Tags revision
+func getCategoryRevisionsHandler(c *fiber.Ctx) error {
+	return getRevisions(c, models.REVISION_OBJECT_CATEGORY)
+}
+
+// @security BasicAuth
+// GetDrafts godoc
+// @Summary Get staged drafts
+// @Accept json
+// @Produce json
+// @Success 200 {object} RevisionsView
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/revisions [get]
+// @Tags revision
+func getDraftsHandler(c *fiber.Ctx) error {
+	if revisions, err := models.GetRevisionsByStatus(common.Database, models.REVISION_STATUS_DRAFT); err == nil {
+		views := RevisionsView{}
+		for _, revision := range revisions {
+			views = append(views, newRevisionView(revision, false))
+		}
+		return c.JSON(views)
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// @security BasicAuth
+// GetRevision godoc
+// @Summary Get revision with snapshot
+// @Accept json
+// @Produce json
+// @Param id path int true "Revision ID"
+// @Success 200 {object} RevisionView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/revisions/{id} [get]
+// @Tags revision
+func getRevisionHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	if revision, err := models.GetRevision(common.Database, id); err == nil {
+		return c.JSON(newRevisionView(revision, true))
+	}else{
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+}
+
+// @security BasicAuth
+// GetRevisionDiff godoc
+// @Summary Get changes between revisions, previous revision of the same object is used by default
+// @Accept json
+// @Produce json
+// @Param id path int true "Revision ID"
+// @Param from query int false "Revision ID to compare with"
+// @Success 200 {object} RevisionDiffView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/revisions/{id}/diff [get]
+// @Tags revision
+func getRevisionDiffHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	revision, err := models.GetRevision(common.Database, id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	var from *models.Revision
+	if v := c.Query("from"); v != "" {
+		fromId, _ := strconv.Atoi(v)
+		if from, err = models.GetRevision(common.Database, fromId); err == nil && (from.ObjectType != revision.ObjectType || from.ObjectId != revision.ObjectId) {
+			err = fmt.Errorf("revisions #%v and #%v belong to different objects", fromId, id)
+		}
+	}else{
+		from, err = models.GetPreviousRevision(common.Database, revision)
+	}
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	changes, err := diffRevisions(from, revision)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	return c.JSON(RevisionDiffView{From: from.ID, To: revision.ID, Changes: changes})
+}
+
+// @security BasicAuth
+// RestoreRevision godoc
+// @Summary Restore object state from revision
+// @Accept json
+// @Produce json
+// @Param id path int true "Revision ID"
+// @Param draft query bool false "Stage restored state as draft"
+// @Success 200 {object} RevisionView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/revisions/{id}/restore [post]
+// @Tags revision
+func postRevisionRestoreHandler(c *fiber.Ctx) error {
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	revision, err := models.GetRevision(common.Database, id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	if err = models.RestoreRevision(common.Database, revision); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	restored := &models.Revision{
+		ObjectType: revision.ObjectType,
+		ObjectId: revision.ObjectId,
+		Status: models.REVISION_STATUS_PUBLISHED,
+		Comment: fmt.Sprintf("restored from #%v", revision.ID),
+		Data: revision.Data,
+	}
+	if isDraft(c) {
+		restored.Status = models.REVISION_STATUS_DRAFT
+	}else{
+		if err = MarkChanged(fmt.Sprintf("%v restored", revision.ObjectType)); err != nil {
+			logger.Warningf("%v", err)
+		}
+		reindexObject(revision.ObjectType, revision.ObjectId)
+	}
+	restored.UserId = userOf(c)
+	if _, err = models.CreateRevision(common.Database, restored); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	return c.JSON(newRevisionView(restored, false))
+}
+
+// @security BasicAuth
+// PublishRevisions godoc
+// @Summary Publish staged drafts together
+// @Accept json
+// @Produce json
+// @Param request body NewRevisionsPublish true "body"
+// @Success 200 {object} RevisionsView
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/revisions/publish [post]
+// @Tags revision
+func postRevisionsPublishHandler(c *fiber.Ctx) error {
+	var request NewRevisionsPublish
+	if contentType := string(c.Request().Header.ContentType()); contentType != "" {
+		if strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+			if err := c.BodyParser(&request); err != nil {
+				return err
+			}
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{"Unsupported Content-Type"})
+		}
+	}
+	drafts, err := models.GetRevisionsByStatus(common.Database, models.REVISION_STATUS_DRAFT)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	views := RevisionsView{}
+	published := make(map[string]bool)
+	for _, draft := range drafts {
+		if len(request.Ids) > 0 {
+			var found bool
+			for _, id := range request.Ids {
+				if id == draft.ID {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		// Object is published as a whole with all its drafts
+		key := fmt.Sprintf("%v-%v", draft.ObjectType, draft.ObjectId)
+		if published[key] {
+			continue
+		}
+		published[key] = true
+		if err = models.PublishRevisions(common.Database, draft.ObjectType, draft.ObjectId); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{err.Error()})
+		}
+		reindexObject(draft.ObjectType, draft.ObjectId)
+		draft.Status = models.REVISION_STATUS_PUBLISHED
+		views = append(views, newRevisionView(draft, false))
+	}
+	if len(views) > 0 {
+		if err = MarkChanged(fmt.Sprintf("%d drafts published", len(views))); err != nil {
+			logger.Warningf("%v", err)
+		}
+	}
+	return c.JSON(views)
+}
+
+// diffRevisions compares snapshots field by field, list items are matched by ID
+func diffRevisions(from, to *models.Revision) ([]RevisionChangeView, error) {
+	var a, b interface{}
+	if err := json.Unmarshal([]byte(from.Data), &a); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(to.Data), &b); err != nil {
+		return nil, err
+	}
+	x := make(map[string]interface{})
+	flattenSnapshot("", a, x)
+	y := make(map[string]interface{})
+	flattenSnapshot("", b, y)
+	var keys []string
+	for k := range x {
+		keys = append(keys, k)
+	}
+	for k := range y {
+		if _, found := x[k]; !found {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	changes := []RevisionChangeView{}
+	for _, k := range keys {
+		if strings.HasSuffix(k, "UpdatedAt") || strings.HasSuffix(k, "CreatedAt") {
+			continue
+		}
+		if fmt.Sprintf("%v", x[k]) != fmt.Sprintf("%v", y[k]) {
+			changes = append(changes, RevisionChangeView{Path: k, Old: x[k], New: y[k]})
+		}
+	}
+	return changes, nil
+}
+
+func flattenSnapshot(prefix string, v interface{}, result map[string]interface{}) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, vv := range value {
+			if prefix != "" {
+				k = prefix + "." + k
+			}
+			flattenSnapshot(k, vv, result)
+		}
+	case []interface{}:
+		for i, vv := range value {
+			key := fmt.Sprintf("%v.%d", prefix, i)
+			if m, ok := vv.(map[string]interface{}); ok {
+				if id, found := m["ID"]; found {
+					key = fmt.Sprintf("%v.#%v", prefix, id)
+				}
+			}
+			flattenSnapshot(key, vv, result)
+		}
+	default:
+		result[prefix] = value
+	}
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/yonnic/goshop/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRevisionDraftAndPublish(t *testing.T) {
+	db := openCatalogDatabase(t)
+	product := &models.Product{Enabled: true, Name: "shirt", Title: "Shirt"}
+	if _, err := models.CreateProduct(db, product); err != nil {
+		t.Fatalf("%v", err)
+	}
+	variation := &models.Variation{Enabled: true, Name: "red", Title: "Red", ProductId: product.ID}
+	if _, err := models.CreateVariation(db, variation); err != nil {
+		t.Fatalf("%v", err)
+	}
+	app := fiber.New()
+	app.Patch("/api/v1/variations/:id", revisioned(models.REVISION_OBJECT_VARIATION), patchVariationHandler)
+	app.Post("/api/v1/revisions/publish", postRevisionsPublishHandler)
+	request := func(method, url, body string) {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", fiber.MIMEApplicationJSON)
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%v %v: status %v", method, url, res.StatusCode)
+		}
+	}
+	published := func() *models.Variation {
+		v, err := models.GetVariation(db, int(variation.ID))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if v, err = models.GetPublishedVariation(db, v); err != nil {
+			t.Fatalf("%v", err)
+		}
+		return v
+	}
+	// Draft keeps published state
+	request("PATCH", fmt.Sprintf("/api/v1/variations/%v?action=setEnable&draft=true", variation.ID), `{"Enabled": false}`)
+	if revisions, err := models.GetRevisions(db, models.REVISION_OBJECT_VARIATION, variation.ID); err != nil || len(revisions) != 2 || revisions[0].Status != models.REVISION_STATUS_DRAFT || revisions[1].Comment != "initial" {
+		t.Fatalf("unexpected revisions: %+v %v", revisions, err)
+	}
+	if !published().Enabled {
+		t.Errorf("draft is published")
+	}
+	// Publish makes it live
+	request("POST", "/api/v1/revisions/publish", `{}`)
+	if models.HasDraft(db, models.REVISION_OBJECT_VARIATION, variation.ID) {
+		t.Errorf("draft is not published")
+	}
+	if published().Enabled {
+		t.Errorf("published variation is still enabled")
+	}
+	// Change without draft is published at once
+	request("PATCH", fmt.Sprintf("/api/v1/variations/%v?action=setEnable", variation.ID), `{"Enabled": true}`)
+	if revisions, err := models.GetRevisions(db, models.REVISION_OBJECT_VARIATION, variation.ID); err != nil || len(revisions) != 3 || revisions[0].Status != models.REVISION_STATUS_PUBLISHED {
+		t.Fatalf("unexpected revisions: %+v %v", revisions, err)
+	}
+	if !published().Enabled {
+		t.Errorf("change is not published")
+	}
+}
+
+func TestImportProductsCSV_Revisions(t *testing.T) {
+	db := openCatalogDatabase(t)
+	if _, err := ImportProductsCSV(strings.NewReader("Product,Title\nshirt,Shirt\n"), false); err != nil {
+		t.Fatalf("%v", err)
+	}
+	product, err := models.GetProductByName(db, "shirt")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	// Staged product keeps draft until it is published
+	if err = newObjectRevision(db, models.REVISION_OBJECT_PRODUCT, product.ID, models.REVISION_STATUS_DRAFT, "", 0); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, err = ImportProductsCSV(strings.NewReader("Product,Title\nshirt,New shirt\n"), false); err != nil {
+		t.Fatalf("%v", err)
+	}
+	revisions, err := models.GetRevisions(db, models.REVISION_OBJECT_PRODUCT, product.ID)
+	if err != nil || len(revisions) != 3 {
+		t.Fatalf("unexpected revisions: %+v %v", revisions, err)
+	}
+	if revisions[0].Comment != "imported" || revisions[0].Status != models.REVISION_STATUS_DRAFT || revisions[2].Comment != "imported" || revisions[2].Status != models.REVISION_STATUS_PUBLISHED {
+		t.Errorf("unexpected revisions: %+v", revisions)
+	}
+	if published, err := models.GetPublishedProduct(db, product); err != nil || published.Title != "Shirt" {
+		t.Errorf("draft title is published: %+v %v", published, err)
+	}
+}
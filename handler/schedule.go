@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"time"
+)
+
+const SCHEDULE_INTERVAL = time.Minute
+
+// StartScheduler watches product and variation publish and sale windows and publishes the site once any of them opens or closes
+func StartScheduler() {
+	go func() {
+		last := time.Now()
+		for {
+			now := time.Now()
+			next, err := models.GetNextScheduleChange(common.Database, last)
+			if err != nil {
+				logger.Warningf("%+v", err)
+				time.Sleep(SCHEDULE_INTERVAL)
+				continue
+			}
+			if !next.IsZero() && !next.After(now) {
+				logger.Infof("Schedule window changed at %v, publish", next.Format(time.RFC3339))
+				// Change is found again from the same last time until pipeline succeeds, e.g. after running pipeline releases lock
+				if err = runWindowPipeline(last, now); err != nil {
+					logger.Errorf("%v, retry in %v", err, SCHEDULE_INTERVAL)
+					time.Sleep(SCHEDULE_INTERVAL)
+					continue
+				}
+				last = now
+				continue
+			}
+			last = now
+			wait := SCHEDULE_INTERVAL
+			if !next.IsZero() && next.Sub(now) < wait {
+				wait = next.Sub(now)
+			}
+			time.Sleep(wait)
+		}
+	}()
+}
+
+// runWindowPipeline makes products which windows opened or closed in (last, now] visible or hidden on published site
+func runWindowPipeline(last, now time.Time) error {
+	if err := MarkChanged("schedule " + now.Format(time.RFC3339)); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	// products are found in search only inside their publish windows
+	if ids, err := models.GetScheduledProductIds(common.Database, last, now); err == nil {
+		for _, id := range ids {
+			reindex(id)
+		}
+	}else{
+		logger.Warningf("%+v", err)
+	}
+	_, err := RunPipeline(PIPELINE_WINDOW, pipelineJobs(true), false)
+	return err
+}
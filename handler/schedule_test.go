@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/config"
+	"github.com/yonnic/goshop/models"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunWindowPipeline(t *testing.T) {
+	db := openCatalogDatabase(t, &models.Job{})
+	previous := common.Config
+	common.Config = &config.Config{}
+	common.Config.Publisher.Enabled = true
+	common.Config.Publisher.Target = "file"
+	funcs := jobFuncs
+	jobFuncs = make(map[string]JobFunc)
+	t.Cleanup(func() {
+		common.Config = previous
+		jobFuncs = funcs
+	})
+	var mutex sync.Mutex
+	var calls []string
+	for _, name := range []string{JOB_PREPARE, JOB_RENDER, JOB_PUBLISH} {
+		name := name
+		RegisterJob(name, func(ctx context.Context, log *logger.Logger, full bool, progress func(stage string, done, total int)) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			calls = append(calls, name)
+			return nil
+		})
+	}
+	now := time.Now()
+	last := now.Add(-time.Minute)
+	// Document is left from the time product was inside its window
+	product := &models.Product{Enabled: true, Name: "shirt", Title: "Shirt", PublishEnd: now.Add(-time.Second)}
+	if _, err := models.CreateProduct(db, product); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := db.Create(&models.SearchDocument{ID: product.ID, Title: product.Title}).Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := runWindowPipeline(last, now); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(calls, []string{JOB_PREPARE, JOB_RENDER, JOB_PUBLISH}) {
+		t.Errorf("unexpected jobs: %v", calls)
+	}
+	var count int64
+	if db.Model(&models.SearchDocument{}).Count(&count); count != 0 {
+		t.Errorf("product is still indexed after its window")
+	}
+}
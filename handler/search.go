@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"gorm.io/gorm"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	SEARCH_DEFAULT_LIMIT = 20
+	SEARCH_MAX_LIMIT = 100
+	SEARCH_SNIPPET_LENGTH = 160
+)
+
+// Search godoc
+// @Summary Full text search of products with ranking and typo tolerance
+// @Accept json
+// @Produce json
+// @Param q query string true "Query"
+// @Param page query int false "Page, starts from 1"
+// @Param limit query int false "Limit"
+// @Success 200 {object} SearchResult
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/search [get]
+// @Tags frontend
+func getSearchHandler(c *fiber.Ctx) error {
+	var request SearchRequest
+	request.Term = c.Query("q")
+	if v := c.Query("page"); v != "" {
+		request.Page, _ = strconv.Atoi(v)
+	}
+	if v := c.Query("limit"); v != "" {
+		request.Limit, _ = strconv.Atoi(v)
+	}
+	return searchProducts(c, request)
+}
+
+// Search godoc
+// @Summary Full text search of products with ranking and typo tolerance
+// @Accept json
+// @Produce json
+// @Param request body SearchRequest true "body"
+// @Success 200 {object} SearchResult
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/search [post]
+// @Tags frontend
+func postSearchHandler(c *fiber.Ctx) error {
+	var request SearchRequest
+	if contentType := string(c.Request().Header.ContentType()); contentType != "" {
+		if strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+			if err := c.BodyParser(&request); err != nil {
+				return err
+			}
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{"Unsupported Content-Type"})
+		}
+	}
+	return searchProducts(c, request)
+}
+
+func searchProducts(c *fiber.Ctx, request SearchRequest) error {
+	result := SearchResult{Term: strings.TrimSpace(request.Term), Page: request.Page, Limit: request.Limit, Products: []SearchResultProductView{}}
+	if result.Page < 1 {
+		result.Page = 1
+	}
+	if result.Limit < 1 {
+		result.Limit = SEARCH_DEFAULT_LIMIT
+	}else if result.Limit > SEARCH_MAX_LIMIT {
+		result.Limit = SEARCH_MAX_LIMIT
+	}
+	if result.Term == "" {
+		return c.JSON(result)
+	}
+	query := result.Term
+	hits, total, err := models.SearchProducts(common.Database, query, (result.Page - 1) * result.Limit, result.Limit)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	if total == 0 {
+		if corrected := models.CorrectSearchQuery(common.Database, query); corrected != "" {
+			if hits, total, err = models.SearchProducts(common.Database, corrected, (result.Page - 1) * result.Limit, result.Limit); err != nil {
+				c.Status(http.StatusInternalServerError)
+				return c.JSON(HTTPError{err.Error()})
+			}
+			if total > 0 {
+				query = corrected
+				result.Corrected = corrected
+			}
+		}
+	}
+	result.Total = total
+	tokens := models.SearchTokens(query)
+	for _, hit := range hits {
+		// product without page is not rendered yet or is removed by render, it has no link to show
+		cache, err := models.GetCacheProductByProductId(common.Database, hit.ProductId)
+		if err != nil {
+			continue
+		}
+		view := SearchResultProductView{Rank: hit.Rank}
+		view.ID = hit.ProductId
+		view.Title = hit.Title
+		view.Highlight = highlight(hit.Title + " " + hit.Body, tokens)
+		view.Name = cache.Name
+		view.Thumbnail = cache.Thumbnail
+		view.Sku = cache.Sku
+		view.Path = cache.Path
+		view.BasePrice = cache.BasePrice
+		view.SalePrice = cache.SalePrice
+		result.Products = append(result.Products, view)
+	}
+	return c.JSON(result)
+}
+
+// highlight cuts snippet around the first matched word and wraps matched words to <mark>, result is html escaped
+func highlight(text string, tokens []string) string {
+	words := strings.Fields(text)
+	matched := func(word string) bool {
+		word = strings.ToLower(word)
+		for _, token := range tokens {
+			if strings.Contains(word, token) {
+				return true
+			}
+		}
+		return false
+	}
+	start := 0
+	for i, word := range words {
+		if matched(word) {
+			start = i - 5
+			break
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	var parts []string
+	var length int
+	for _, word := range words[start:] {
+		if length > SEARCH_SNIPPET_LENGTH {
+			parts = append(parts, "...")
+			break
+		}
+		length += utf8.RuneCountInString(word) + 1
+		if matched(word) {
+			parts = append(parts, "<mark>" + html.EscapeString(word) + "</mark>")
+		}else{
+			parts = append(parts, html.EscapeString(word))
+		}
+	}
+	if start > 0 {
+		parts = append([]string{"..."}, parts...)
+	}
+	return strings.Join(parts, " ")
+}
+
+// @security BasicAuth
+// Reindex godoc
+// @Summary Rebuild search index of all products
+// @Accept json
+// @Produce json
+// @Success 200 {object} HTTPMessage
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/search/reindex [post]
+// @Tags product
+func postSearchReindexHandler(c *fiber.Ctx) error {
+	count, err := models.ReindexProducts(common.Database)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	return c.JSON(HTTPMessage{strconv.Itoa(count) + " products indexed"})
+}
+
+// reindex updates search index of product after it was changed
+func reindex(id uint) {
+	if err := models.IndexProduct(common.Database, id); err != nil {
+		logger.Warningf("%+v", err)
+	}
+}
+
+// reindexObject updates search index of revisioned object, variation is indexed as a part of its product
+func reindexObject(objectType string, id uint) {
+	switch objectType {
+	case models.REVISION_OBJECT_PRODUCT:
+		reindex(id)
+	case models.REVISION_OBJECT_VARIATION:
+		if variation, err := models.GetVariation(common.Database, int(id)); err == nil {
+			reindex(variation.ProductId)
+		}
+	}
+}
+
+// reindexed wraps save handler of object shown in documents of many products (tag, category, value) to update index of
+// these products, they are resolved before change too as join rows are gone after delete
+func reindexed(resolve func(connector *gorm.DB, id uint) ([]uint, error)) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		id := uint(paramId(c))
+		before, err := resolve(common.Database, id)
+		if err != nil {
+			logger.Warningf("%+v", err)
+		}
+		if err = c.Next(); err != nil {
+			return err
+		}
+		if c.Response().StatusCode() == http.StatusOK {
+			after, err := resolve(common.Database, id)
+			if err != nil {
+				logger.Warningf("%+v", err)
+			}
+			seen := make(map[uint]bool)
+			for _, productId := range append(before, after...) {
+				if !seen[productId] {
+					seen[productId] = true
+					reindex(productId)
+				}
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReindexed(t *testing.T) {
+	db := openCatalogDatabase(t)
+	tag := &models.Tag{Enabled: true, Name: "summer", Title: "Summer"}
+	if _, err := models.CreateTag(db, tag); err != nil {
+		t.Fatalf("%v", err)
+	}
+	product := &models.Product{Enabled: true, Name: "shirt", Title: "Shirt"}
+	if _, err := models.CreateProduct(db, product); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := models.AddProductToTag(db, tag, product); err != nil {
+		t.Fatalf("%v", err)
+	}
+	reindex(product.ID)
+	app := fiber.New()
+	app.Put("/api/v1/tags/:id", reindexed(models.GetProductIdsByTag), func(c *fiber.Ctx) error {
+		tag.Title = "Winter"
+		if err := models.UpdateTag(common.Database, tag); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{err.Error()})
+		}
+		return c.JSON(HTTPMessage{"OK"})
+	})
+	app.Delete("/api/v1/tags/:id", reindexed(models.GetProductIdsByTag), func(c *fiber.Ctx) error {
+		if err := models.DeleteTag(common.Database, tag); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{err.Error()})
+		}
+		return c.JSON(HTTPMessage{"OK"})
+	})
+	body := func() string {
+		var document models.SearchDocument
+		if err := db.Where("id = ?", product.ID).First(&document).Error; err != nil {
+			t.Fatalf("%v", err)
+		}
+		return document.Body
+	}
+	if v := body(); v != "Summer" {
+		t.Fatalf("unexpected body: %v", v)
+	}
+	for _, example := range []struct{
+		Method string
+		Body string
+	}{
+		{Method: "PUT", Body: "Winter"},
+		{Method: "DELETE", Body: ""},
+	}{
+		res, err := app.Test(httptest.NewRequest(example.Method, fmt.Sprintf("/api/v1/tags/%v", tag.ID), nil))
+		if err != nil || res.StatusCode != http.StatusOK {
+			t.Fatalf("%v: %v %v", example.Method, res.StatusCode, err)
+		}
+		if v := body(); v != example.Body {
+			t.Errorf("%v: body '%v', expected '%v'", example.Method, v, example.Body)
+		}
+	}
+}
@@ -505,6 +505,9 @@ func delTagHandler(c *fiber.Ctx) error {
 				logger.Errorf("%v", err.Error())
 			}
 		}
+		if err = models.DeleteTranslationsByObject(common.Database, models.TRANSLATION_OBJECT_TAG, tag.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
 		if err = models.DeleteTag(common.Database, tag); err == nil {
 			return c.JSON(HTTPMessage{MESSAGE: "OK"})
 		}else{
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/logger"
+	"github.com/yonnic/goshop/common"
+	"github.com/yonnic/goshop/models"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type TranslationsView struct {
+	ObjectType string
+	ObjectId uint
+	Fields []string
+	Languages map[string]map[string]string // language code => field => value
+}
+
+type NewTranslation map[string]string // field => value, empty value removes translation
+
+// @security BasicAuth
+// GetTranslations godoc
+// @Summary Get translations of object for every language
+// @Accept json
+// @Produce json
+// @Param type path string true "Object type: product, variation, category, option, value, tag, vendor, menu, widget or email_template"
+// @Param id path int true "Object ID"
+// @Success 200 {object} TranslationsView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/translations/{type}/{id} [get]
+// @Tags translation
+func getTranslationsHandler(c *fiber.Ctx) error {
+	objectType, id, err := translationObject(c)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	translations, err := models.GetTranslations(common.Database, objectType, id)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	view := TranslationsView{ObjectType: objectType, ObjectId: id, Fields: models.TranslatableFields[objectType], Languages: make(map[string]map[string]string)}
+	for _, language := range common.Config.I18n.Languages {
+		if language.Enabled {
+			view.Languages[language.Code] = make(map[string]string)
+		}
+	}
+	for _, translation := range translations {
+		if _, found := view.Languages[translation.Language]; !found {
+			view.Languages[translation.Language] = make(map[string]string)
+		}
+		view.Languages[translation.Language][translation.Field] = translation.Value
+	}
+	return c.JSON(view)
+}
+
+// @security BasicAuth
+// PutTranslations godoc
+// @Summary Set translations of object in language, missing translations fall back to default language
+// @Accept json
+// @Produce json
+// @Param type path string true "Object type"
+// @Param id path int true "Object ID"
+// @Param language path string true "Language code"
+// @Param request body NewTranslation true "body"
+// @Success 200 {object} TranslationsView
+// @Failure 404 {object} HTTPError
+// @Failure 500 {object} HTTPError
+// @Router /api/v1/translations/{type}/{id}/{language} [put]
+// @Tags translation
+func putTranslationsHandler(c *fiber.Ctx) error {
+	objectType, id, err := translationObject(c)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	language := c.Params("language")
+	var found bool
+	for _, l := range common.Config.I18n.Languages {
+		if l.Code == language {
+			found = true
+			break
+		}
+	}
+	if !found {
+		c.Status(http.StatusNotFound)
+		return c.JSON(HTTPError{fmt.Sprintf("Unknown language %v", language)})
+	}
+	var request NewTranslation
+	if contentType := string(c.Request().Header.ContentType()); contentType != "" {
+		if strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+			if err := c.BodyParser(&request); err != nil {
+				return err
+			}
+		}else{
+			c.Status(http.StatusInternalServerError)
+			return c.JSON(HTTPError{"Unsupported Content-Type"})
+		}
+	}else{
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{"Content-Type not set"})
+	}
+	if err = models.SetTranslations(common.Database, objectType, id, language, request); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return c.JSON(HTTPError{err.Error()})
+	}
+	if err = MarkChanged(fmt.Sprintf("%v translated", objectType)); err != nil {
+		logger.Warningf("%v", err)
+	}
+	return getTranslationsHandler(c)
+}
+
+// translationObject checks type and id of translated object
+func translationObject(c *fiber.Ctx) (string, uint, error) {
+	objectType := c.Params("type")
+	var id int
+	if v := c.Params("id"); v != "" {
+		id, _ = strconv.Atoi(v)
+	}
+	var model interface{}
+	switch objectType {
+	case models.TRANSLATION_OBJECT_PRODUCT:
+		model = &models.Product{}
+	case models.TRANSLATION_OBJECT_VARIATION:
+		model = &models.Variation{}
+	case models.TRANSLATION_OBJECT_CATEGORY:
+		model = &models.Category{}
+	case models.TRANSLATION_OBJECT_OPTION:
+		model = &models.Option{}
+	case models.TRANSLATION_OBJECT_VALUE:
+		model = &models.Value{}
+	case models.TRANSLATION_OBJECT_TAG:
+		model = &models.Tag{}
+	case models.TRANSLATION_OBJECT_VENDOR:
+		model = &models.Vendor{}
+	case models.TRANSLATION_OBJECT_MENU:
+		model = &models.Menu{}
+	case models.TRANSLATION_OBJECT_WIDGET:
+		model = &models.Widget{}
+	case models.TRANSLATION_OBJECT_EMAIL_TEMPLATE:
+		model = &models.EmailTemplate{}
+	default:
+		return "", 0, fmt.Errorf("unknown object type %v", objectType)
+	}
+	var count int64
+	if err := common.Database.Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+		return "", 0, err
+	}
+	if count == 0 {
+		return "", 0, fmt.Errorf("%v %v not found", objectType, id)
+	}
+	return objectType, uint(id), nil
+}
@@ -33,6 +33,8 @@ type TransportView struct{
 	M3 float64
 	Free float64 `json:",omitempty"`
 	Services string `json:",omitempty"`
+	TransitMin int `json:",omitempty"`
+	TransitMax int `json:",omitempty"`
 }
 
 // @security BasicAuth
@@ -78,6 +80,8 @@ type NewTransport struct {
 	Item string
 	Kg float64
 	M3 float64
+	TransitMin int
+	TransitMax int
 }
 
 // @security BasicAuth
@@ -162,6 +166,20 @@ func postTransportHandler(c *fiber.Ctx) error {
 			if v, found := data.Value["Services"]; found && len(v) > 0 {
 				services = strings.TrimSpace(v[0])
 			}
+			var transitMin int
+			if v, found := data.Value["TransitMin"]; found && len(v) > 0 {
+				transitMin, err = strconv.Atoi(v[0])
+				if err != nil {
+					logger.Infof("%+v", err)
+				}
+			}
+			var transitMax int
+			if v, found := data.Value["TransitMax"]; found && len(v) > 0 {
+				transitMax, err = strconv.Atoi(v[0])
+				if err != nil {
+					logger.Infof("%+v", err)
+				}
+			}
 			transport := &models.Transport {
 				Enabled: enabled,
 				Name:    name,
@@ -174,6 +192,8 @@ func postTransportHandler(c *fiber.Ctx) error {
 				M3:      m3,
 				Free: free,
 				Services: services,
+				TransitMin: transitMin,
+				TransitMax: transitMax,
 			}
 			if id, err := models.CreateTransport(common.Database, transport); err == nil {
 				if v, found := data.File["Thumbnail"]; found && len(v) > 0 {
@@ -461,6 +481,20 @@ func putTransportHandler(c *fiber.Ctx) error {
 			if v, found := data.Value["Services"]; found && len(v) > 0 {
 				services = strings.TrimSpace(v[0])
 			}
+			var transitMin int
+			if v, found := data.Value["TransitMin"]; found && len(v) > 0 {
+				transitMin, err = strconv.Atoi(v[0])
+				if err != nil {
+					logger.Infof("%+v", err)
+				}
+			}
+			var transitMax int
+			if v, found := data.Value["TransitMax"]; found && len(v) > 0 {
+				transitMax, err = strconv.Atoi(v[0])
+				if err != nil {
+					logger.Infof("%+v", err)
+				}
+			}
 			transport.Enabled = enabled
 			transport.Title = title
 			transport.Weight = weight
@@ -471,6 +505,8 @@ func putTransportHandler(c *fiber.Ctx) error {
 			transport.M3 = m3
 			transport.Free = free
 			transport.Services = services
+			transport.TransitMin = transitMin
+			transport.TransitMax = transitMax
 			if v, found := data.Value["Thumbnail"]; found && len(v) > 0 && v[0] == "" {
 				// To delete existing
 				if transport.Thumbnail != "" {
@@ -622,6 +622,9 @@ func delValueHandler(c *fiber.Ctx) error {
 				logger.Errorf("%v", err.Error())
 			}
 		}
+		if err = models.DeleteTranslationsByObject(common.Database, models.TRANSLATION_OBJECT_VALUE, value.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
 		if err = models.DeleteValue(common.Database, value); err == nil {
 			return c.JSON(HTTPMessage{MESSAGE: "OK"})
 		}else{
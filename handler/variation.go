@@ -47,6 +47,63 @@ func postVariationsListHandler(c *fiber.Ctx) error {
 		request.Length = 10
 	}
 	// Filter
+	keys1, values1, keys2, values2 := variationsListFilter(request, id)
+	//logger.Infof("keys1: %+v, values1: %+v", keys1, values1)
+	//
+	// Sort
+	var order string
+	if len(request.Sort) > 0 {
+		var orders []string
+		for key, value := range request.Sort {
+			if key != "" && value != "" {
+				switch key {
+				case "Options":
+					orders = append(orders, fmt.Sprintf("%v %v", key, value))
+				default:
+					orders = append(orders, fmt.Sprintf("variations.%v %v", key, value))
+				}
+			}
+		}
+		order = strings.Join(orders, ", ")
+	}
+	//logger.Infof("order: %+v", order)
+	//
+	rows, err := common.Database.Debug().Model(&models.Variation{}).Select("variations.ID, variations.Name, variations.Title, cache_variation.Thumbnail as Thumbnail, variations.Description, variations.Base_Price, variations.Stock, variations.Product_id as ProductId, products.Title as ProductTitle, cache_products.Thumbnail as ProductThumbnail, group_concat(properties.ID) as PropertiesIds, group_concat(properties.Title) as PropertiesTitles").Joins("left join cache_variation on variations.id = cache_variation.variation_id").Joins("left join products on products.id = variations.product_id").Joins("left join cache_products on variations.product_id = cache_products.product_id").Joins("left join properties on properties.variation_id = variations.id").Group("variations.id").Where(strings.Join(keys1, " and "), values1...).Having(strings.Join(keys2, " and "), values2...).Order(order).Limit(request.Length).Offset(request.Start).Rows()
+	if err == nil {
+		if err == nil {
+			for rows.Next() {
+				var item VariationsListItem
+				if err = common.Database.ScanRows(rows, &item); err == nil {
+					item.PropertiesIds = strings.TrimRight(item.PropertiesIds, ", ")
+					item.PropertiesTitles = strings.TrimRight(item.PropertiesTitles, ", ")
+					response.Data = append(response.Data, item)
+				} else {
+					logger.Errorf("%v", err)
+				}
+			}
+		}else{
+			logger.Errorf("%v", err)
+		}
+		rows.Close()
+	}
+	rows, err = common.Database.Debug().Model(&models.Variation{}).Select("variations.ID, variations.Name, variations.Title, variations.Thumbnail, variations.Description, variations.Base_Price, variations.Stock, variations.Product_id as ProductId, group_concat(properties.ID) as PropertiesIds, group_concat(properties.Title) as PropertiesTitles").Joins("left join properties on properties.variation_id = variations.id").Group("variations.id").Where(strings.Join(keys1, " and "), values1...).Having(strings.Join(keys2, " and "), values2...).Rows()
+	if err == nil {
+		for rows.Next() {
+			response.Filtered ++
+		}
+		rows.Close()
+	}
+	if len(keys1) > 0 || len(keys2) > 0 {
+		common.Database.Debug().Model(&models.Variation{}).Where("product_id = ?", id).Count(&response.Total)
+	}else{
+		response.Total = response.Filtered
+	}
+	c.Status(http.StatusOK)
+	return c.JSON(response)
+}
+
+// variationsListFilter returns where and having conditions of variations list request
+func variationsListFilter(request ListRequest, id int) ([]string, []interface{}, []string, []interface{}) {
 	var keys1 []string
 	var values1 []interface{}
 	var keys2 []string
@@ -102,58 +159,7 @@ func postVariationsListHandler(c *fiber.Ctx) error {
 		keys1 = append(keys1, "variations.product_id = ?")
 		values1 = append(values1, id)
 	}
-	//logger.Infof("keys1: %+v, values1: %+v", keys1, values1)
-	//
-	// Sort
-	var order string
-	if len(request.Sort) > 0 {
-		var orders []string
-		for key, value := range request.Sort {
-			if key != "" && value != "" {
-				switch key {
-				case "Options":
-					orders = append(orders, fmt.Sprintf("%v %v", key, value))
-				default:
-					orders = append(orders, fmt.Sprintf("variations.%v %v", key, value))
-				}
-			}
-		}
-		order = strings.Join(orders, ", ")
-	}
-	//logger.Infof("order: %+v", order)
-	//
-	rows, err := common.Database.Debug().Model(&models.Variation{}).Select("variations.ID, variations.Name, variations.Title, cache_variation.Thumbnail as Thumbnail, variations.Description, variations.Base_Price, variations.Stock, variations.Product_id as ProductId, products.Title as ProductTitle, cache_products.Thumbnail as ProductThumbnail, group_concat(properties.ID) as PropertiesIds, group_concat(properties.Title) as PropertiesTitles").Joins("left join cache_variation on variations.id = cache_variation.variation_id").Joins("left join products on products.id = variations.product_id").Joins("left join cache_products on variations.product_id = cache_products.product_id").Joins("left join properties on properties.variation_id = variations.id").Group("variations.id").Where(strings.Join(keys1, " and "), values1...).Having(strings.Join(keys2, " and "), values2...).Order(order).Limit(request.Length).Offset(request.Start).Rows()
-	if err == nil {
-		if err == nil {
-			for rows.Next() {
-				var item VariationsListItem
-				if err = common.Database.ScanRows(rows, &item); err == nil {
-					item.PropertiesIds = strings.TrimRight(item.PropertiesIds, ", ")
-					item.PropertiesTitles = strings.TrimRight(item.PropertiesTitles, ", ")
-					response.Data = append(response.Data, item)
-				} else {
-					logger.Errorf("%v", err)
-				}
-			}
-		}else{
-			logger.Errorf("%v", err)
-		}
-		rows.Close()
-	}
-	rows, err = common.Database.Debug().Model(&models.Variation{}).Select("variations.ID, variations.Name, variations.Title, variations.Thumbnail, variations.Description, variations.Base_Price, variations.Stock, variations.Product_id as ProductId, group_concat(properties.ID) as PropertiesIds, group_concat(properties.Title) as PropertiesTitles").Joins("left join properties on properties.variation_id = variations.id").Group("variations.id").Where(strings.Join(keys1, " and "), values1...).Having(strings.Join(keys2, " and "), values2...).Rows()
-	if err == nil {
-		for rows.Next() {
-			response.Filtered ++
-		}
-		rows.Close()
-	}
-	if len(keys1) > 0 || len(keys2) > 0 {
-		common.Database.Debug().Model(&models.Variation{}).Where("product_id = ?", id).Count(&response.Total)
-	}else{
-		response.Total = response.Filtered
-	}
-	c.Status(http.StatusOK)
-	return c.JSON(response)
+	return keys1, values1, keys2, values2
 }
 
 // @security BasicAuth
@@ -428,6 +434,7 @@ func postVariationHandler(c *fiber.Ctx) error {
 						}
 					}
 				}
+				reindex(product.ID)
 				if bts, err := json.Marshal(variation); err == nil {
 					if err = json.Unmarshal(bts, &view); err != nil {
 						c.Status(http.StatusInternalServerError)
@@ -534,6 +541,14 @@ func putVariationHandler(c *fiber.Ctx) error {
 			if v, found := data.Value["End"]; found && len(v) > 0 {
 				end, _ = time.Parse(time.RFC3339, v[0])
 			}
+			var publishStart time.Time
+			if v, found := data.Value["PublishStart"]; found && len(v) > 0 {
+				publishStart, _ = time.Parse(time.RFC3339, v[0])
+			}
+			var publishEnd time.Time
+			if v, found := data.Value["PublishEnd"]; found && len(v) > 0 {
+				publishEnd, _ = time.Parse(time.RFC3339, v[0])
+			}
 			var itemPrice float64
 			if v, found := data.Value["ItemPrice"]; found && len(v) > 0 {
 				itemPrice, _ = strconv.ParseFloat(v[0], 10)
@@ -1105,6 +1120,8 @@ func putVariationHandler(c *fiber.Ctx) error {
 			variation.SalePrice = salePrice
 			variation.Start = start
 			variation.End = end
+			variation.PublishStart = publishStart
+			variation.PublishEnd = publishEnd
 			variation.ItemPrice = itemPrice
 			variation.MinQuantity = minQuantity
 			variation.MaxQuantity = maxQuantity
@@ -1198,6 +1215,8 @@ func delVariationHandler(c *fiber.Ctx) error {
 					product.SalePrice = variation.SalePrice
 					product.Start = variation.Start
 					product.End = variation.End
+					product.PublishStart = variation.PublishStart
+					product.PublishEnd = variation.PublishEnd
 					product.ItemPrice = variation.ItemPrice
 					product.MinQuantity = variation.MinQuantity
 					product.MaxQuantity = variation.MaxQuantity
@@ -1275,7 +1294,15 @@ func delVariationHandler(c *fiber.Ctx) error {
 				}
 			}
 		}
+		if err = models.DeleteRevisionsByObject(common.Database, models.REVISION_OBJECT_VARIATION, variation.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
+		if err = models.DeleteTranslationsByObject(common.Database, models.TRANSLATION_OBJECT_VARIATION, variation.ID); err != nil {
+			logger.Errorf("%v", err.Error())
+		}
 		if err = models.DeleteVariation(common.Database, variation); err == nil {
+			touchProducts(variation.ProductId)
+			reindex(variation.ProductId)
 			return c.JSON(HTTPMessage{MESSAGE: "OK"})
 		}else{
 			c.Status(http.StatusInternalServerError)
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"github.com/yonnic/goshop/common"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	BUNDLE_PRICING_SUM     = "sum"     // sum of components prices
+	BUNDLE_PRICING_FIXED   = "fixed"   // bundle own price, selected optional components are added
+	BUNDLE_PRICING_PERCENT = "percent" // sum of components prices minus BundleDiscount percent
+)
+
+// BundleComponent is product or variation included into bundle product
+type BundleComponent struct {
+	gorm.Model
+	BundleId uint `gorm:"index:idx_bundle_component_bundle_id"`
+	ProductId uint
+	Product *Product `gorm:"foreignKey:ProductId"`
+	VariationId uint
+	Variation *Variation `gorm:"foreignKey:VariationId"`
+	Quantity int
+	Optional bool
+	Sort int
+}
+
+func GetBundleComponents(connector *gorm.DB, bundleId uint) ([]*BundleComponent, error) {
+	db := connector
+	var components []*BundleComponent
+	if err := db.Debug().Preload("Product").Preload("Variation").Where("bundle_id = ?", bundleId).Order("sort asc, id asc").Find(&components).Error; err != nil {
+		return nil, err
+	}
+	return components, nil
+}
+
+func CreateBundleComponent(connector *gorm.DB, component *BundleComponent) (uint, error) {
+	db := connector
+	db.Debug().Create(&component)
+	if err := db.Error; err != nil {
+		return 0, err
+	}
+	return component.ID, nil
+}
+
+func GetBundleComponent(connector *gorm.DB, id int) (*BundleComponent, error) {
+	db := connector
+	var component BundleComponent
+	if err := db.Debug().Preload("Product").Preload("Variation").Where("id = ?", id).First(&component).Error; err != nil {
+		return nil, err
+	}
+	return &component, nil
+}
+
+func UpdateBundleComponent(connector *gorm.DB, component *BundleComponent) error {
+	db := connector
+	db.Debug().Save(&component)
+	return db.Error
+}
+
+func DeleteBundleComponent(connector *gorm.DB, component *BundleComponent) error {
+	db := connector
+	db.Debug().Unscoped().Delete(&component)
+	return db.Error
+}
+
+func DeleteBundleComponentsByBundleId(connector *gorm.DB, bundleId uint) error {
+	db := connector
+	return db.Debug().Unscoped().Where("bundle_id = ?", bundleId).Delete(&BundleComponent{}).Error
+}
+
+// Title returns product title extended by variation title if any
+func (c *BundleComponent) Title() string {
+	var title string
+	if c.Product != nil {
+		title = c.Product.Title
+	}
+	if c.Variation != nil && c.Variation.Title != "" {
+		if title != "" {
+			title += " - "
+		}
+		title += c.Variation.Title
+	}
+	return title
+}
+
+// Prices returns base and effective (sale if active) unit price of component
+func (c *BundleComponent) Prices(now time.Time) (float64, float64) {
+	if c.Variation != nil {
+		if c.Variation.IsSale(now) {
+			return c.Variation.BasePrice, c.Variation.SalePrice
+		}
+		return c.Variation.BasePrice, c.Variation.BasePrice
+	} else if c.Product != nil {
+		if c.Product.IsSale(now) {
+			return c.Product.BasePrice, c.Product.SalePrice
+		}
+		return c.Product.BasePrice, c.Product.BasePrice
+	}
+	return 0, 0
+}
+
+// GetBundlePrice calculates base and sale price of bundle for selected components, sale price is 0 if there is no discount
+func GetBundlePrice(bundle *Product, components []*BundleComponent, now time.Time) (float64, float64) {
+	var basePrice, price float64
+	for _, component := range components {
+		if bundle.BundlePricing == BUNDLE_PRICING_FIXED && !component.Optional {
+			continue
+		}
+		b, p := component.Prices(now)
+		basePrice += b * float64(component.Quantity)
+		price += p * float64(component.Quantity)
+	}
+	switch bundle.BundlePricing {
+	case BUNDLE_PRICING_FIXED:
+		b, p := bundle.BasePrice, bundle.BasePrice
+		if bundle.IsSale(now) {
+			p = bundle.SalePrice
+		}
+		basePrice += b
+		price += p
+	case BUNDLE_PRICING_PERCENT:
+		price = price * (100 - bundle.BundleDiscount) / 100
+	}
+	if price < basePrice {
+		return basePrice, price
+	}
+	return basePrice, 0
+}
+
+// GetBundleStock derives stock and availability from required components, bundle is available as long as every component is
+func GetBundleStock(components []*BundleComponent) (uint, string) {
+	var stock uint
+	var first = true
+	availability := common.AVAILABILITY_AVAILABLE
+	for _, component := range components {
+		if component.Optional || component.Quantity <= 0 {
+			continue
+		}
+		var s uint
+		var a string
+		if component.Variation != nil {
+			s, a = component.Variation.Stock, component.Variation.Availability
+		} else if component.Product != nil {
+			s, a = component.Product.Stock, component.Product.Availability
+		}
+		if n := s / uint(component.Quantity); first || n < stock {
+			stock = n
+			first = false
+		}
+		if a != "" && a != common.AVAILABILITY_AVAILABLE && availability == common.AVAILABILITY_AVAILABLE {
+			availability = a
+		}
+	}
+	return stock, availability
+}
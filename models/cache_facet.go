@@ -0,0 +1,67 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	CACHE_FACET_OPTION = "option"
+	CACHE_FACET_TAG    = "tag"
+	CACHE_FACET_VENDOR = "vendor"
+)
+
+// CacheFacet is filterable value of rendered product as it was published: option value of parameter or property, tag or vendor
+type CacheFacet struct {
+	gorm.Model
+	ProductId uint `gorm:"index:idx_cache_facet_product_id"`
+	Type string `gorm:"size:16"`
+	OptionId uint
+	ValueId uint // value, tag or vendor ID
+	Filtering bool
+}
+
+func (CacheFacet) TableName() string {
+	return "cache_facets"
+}
+
+// NewCacheFacets collects filterable values of product including properties of its variations
+func NewCacheFacets(product *Product) []*CacheFacet {
+	var facets []*CacheFacet
+	found := make(map[CacheFacet]bool)
+	add := func(facet CacheFacet) {
+		facet.ProductId = product.ID
+		if facet.ValueId == 0 || found[facet] {
+			return
+		}
+		found[facet] = true
+		facets = append(facets, &facet)
+	}
+	for _, parameter := range product.Parameters {
+		add(CacheFacet{Type: CACHE_FACET_OPTION, OptionId: parameter.OptionId, ValueId: parameter.ValueId, Filtering: parameter.Filtering})
+	}
+	properties := product.Properties
+	for _, variation := range product.Variations {
+		properties = append(properties, variation.Properties...)
+	}
+	for _, property := range properties {
+		for _, rate := range property.Rates {
+			add(CacheFacet{Type: CACHE_FACET_OPTION, OptionId: property.OptionId, ValueId: rate.ValueId, Filtering: property.Filtering})
+		}
+	}
+	for _, tag := range product.Tags {
+		add(CacheFacet{Type: CACHE_FACET_TAG, ValueId: tag.ID})
+	}
+	add(CacheFacet{Type: CACHE_FACET_VENDOR, ValueId: product.VendorId})
+	return facets
+}
+
+func CreateCacheFacets(connector *gorm.DB, facets []*CacheFacet) error {
+	db := connector
+	if len(facets) == 0 {
+		return nil
+	}
+	return db.Debug().Create(&facets).Error
+}
+
+func DeleteCacheFacetsByProductIds(connector *gorm.DB, ids []uint) error {
+	db := connector
+	return db.Debug().Unscoped().Where("product_id in ?", ids).Delete(&CacheFacet{}).Error
+}
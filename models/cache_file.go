@@ -20,6 +20,22 @@ func HasCacheFileByFileId(connector *gorm.DB, fileId uint) bool {
 	return count > 0
 }
 
+func HasCacheFile(connector *gorm.DB, fileId uint, file string) bool {
+	db := connector
+	var count int64
+	db.Model(&CacheFile{}).Where("file_id = ? and file = ?", fileId, file).Count(&count)
+	return count > 0
+}
+
+func GetCacheFilesByFileId(connector *gorm.DB, fileId uint) ([]*CacheFile, error){
+	db := connector
+	var cacheFiles []*CacheFile
+	if err := db.Where("file_id = ?", fileId).Find(&cacheFiles).Error; err != nil {
+		return nil, err
+	}
+	return cacheFiles, nil
+}
+
 func GetCacheFileByFileId(connector *gorm.DB, fileId uint) (*CacheFile, error){
 	db := connector
 	var cacheFile CacheFile
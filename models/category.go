@@ -53,6 +53,15 @@ func GetCategoryByName(connector *gorm.DB, name string) (*Category, error) {
 	return &category, nil
 }
 
+func GetCategoryByNameAndParentId(connector *gorm.DB, name string, parentId uint) (*Category, error) {
+	db := connector
+	var category Category
+	if err := db.Where("name = ? and parent_id = ?", name, parentId).First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
 func CreateCategory(connector *gorm.DB, category *Category) (uint, error) {
 	db := connector
 	db.Debug().Create(&category)
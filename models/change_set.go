@@ -0,0 +1,82 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	CHANGE_SET_STATUS_RUNNING  = "running"
+	CHANGE_SET_STATUS_FINISHED = "finished"
+	CHANGE_SET_STATUS_FAILED   = "failed"
+	CHANGE_SET_STATUS_UNDOING  = "undoing"
+	CHANGE_SET_STATUS_UNDONE   = "undone"
+	CHANGE_SET_STATUS_UNDO_FAILED = "undo_failed"
+)
+
+// ChangeSet is a bulk edit of products or variations, Changes keep previous values to undo it
+type ChangeSet struct {
+	gorm.Model
+	Type string // products or variations
+	Status string
+	Total int
+	Processed int
+	Operations string // JSON
+	Changes string // JSON
+	Skipped string // JSON, ids of items having pending drafts
+	Error string
+	UserId uint
+}
+
+func GetChangeSets(connector *gorm.DB) ([]*ChangeSet, error) {
+	db := connector
+	var changeSets []*ChangeSet
+	if err := db.Debug().Omit("changes").Order("id desc").Find(&changeSets).Error; err != nil {
+		return nil, err
+	}
+	return changeSets, nil
+}
+
+func CreateChangeSet(connector *gorm.DB, changeSet *ChangeSet) (uint, error) {
+	db := connector
+	db.Debug().Create(&changeSet)
+	if err := db.Error; err != nil {
+		return 0, err
+	}
+	return changeSet.ID, nil
+}
+
+func GetChangeSet(connector *gorm.DB, id int) (*ChangeSet, error) {
+	db := connector
+	var changeSet ChangeSet
+	if err := db.Debug().Where("id = ?", id).First(&changeSet).Error; err != nil {
+		return nil, err
+	}
+	return &changeSet, nil
+}
+
+// BeginChangeSetUndo switches finished or failed change set to undoing, returns false if it is undone or being undone already
+func BeginChangeSetUndo(connector *gorm.DB, changeSet *ChangeSet) (bool, error) {
+	db := connector
+	res := db.Debug().Model(&ChangeSet{}).Where("id = ? and status in ?", changeSet.ID, []string{CHANGE_SET_STATUS_FINISHED, CHANGE_SET_STATUS_FAILED}).Update("status", CHANGE_SET_STATUS_UNDOING)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	changeSet.Status = CHANGE_SET_STATUS_UNDOING
+	return true, nil
+}
+
+func UpdateChangeSet(connector *gorm.DB, changeSet *ChangeSet) error {
+	db := connector
+	db.Debug().Save(&changeSet)
+	return db.Error
+}
+
+// FailRunningChangeSets marks change sets interrupted by previous application stop, applied items stay recorded to be undone
+func FailRunningChangeSets(connector *gorm.DB) error {
+	db := connector
+	if err := db.Debug().Model(&ChangeSet{}).Where("status = ?", CHANGE_SET_STATUS_RUNNING).Updates(map[string]interface{}{"status": CHANGE_SET_STATUS_FAILED, "error": "interrupted"}).Error; err != nil {
+		return err
+	}
+	return db.Debug().Model(&ChangeSet{}).Where("status = ?", CHANGE_SET_STATUS_UNDOING).Updates(map[string]interface{}{"status": CHANGE_SET_STATUS_UNDO_FAILED, "error": "interrupted"}).Error
+}
@@ -0,0 +1,409 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sync"
+)
+
+var cloneLock sync.Mutex
+
+// CloneOptions describes how product graph should be copied
+type CloneOptions struct {
+	Name string // "<name>-copy" or "<name>-copy-N" if empty
+	Title string
+	Enabled bool
+	Sku func(sku string) string // renaming rule, nil keeps SKUs as is
+	Image func(tx *gorm.DB, image *Image) (*Image, error) // nil shares image records
+	File func(tx *gorm.DB, file *File) (*File, error) // nil shares file records
+}
+
+type cloner struct {
+	tx *gorm.DB
+	options CloneOptions
+	images map[uint]*Image
+	files map[uint]*File
+	variations map[uint]uint
+}
+
+// CloneProduct deep copies product with variations, properties, rates, prices, parameters, images, files, tags, categories, translations, relations and bundle components in one transaction
+func CloneProduct(connector *gorm.DB, id uint, options CloneOptions) (*Product, error) {
+	db := connector
+	// name is checked and taken in the same transaction, lock keeps parallel clones from choosing the same free name
+	cloneLock.Lock()
+	defer cloneLock.Unlock()
+	var product *Product
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// source is read in the transaction to copy consistent graph
+		source, err := GetProductFull(tx, int(id))
+		if err != nil {
+			return err
+		}
+		c := &cloner{tx: tx.Debug(), options: options, images: make(map[uint]*Image), files: make(map[uint]*File), variations: make(map[uint]uint)}
+		if c.options.Name, err = c.name(source.Name); err != nil {
+			return err
+		}
+		product, err = c.product(source)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// name returns requested name if it is free or first free "<name>-copy-N", deleted products keep their names
+func (c *cloner) name(source string) (string, error) {
+	taken := func(name string) bool {
+		var count int64
+		c.tx.Unscoped().Model(&Product{}).Where("name = ?", name).Count(&count)
+		return count > 0
+	}
+	if name := c.options.Name; name != "" {
+		if taken(name) {
+			return "", fmt.Errorf("Product %v already exists", name)
+		}
+		return name, nil
+	}
+	name := source + "-copy"
+	for i := 2; taken(name); i++ {
+		name = fmt.Sprintf("%v-copy-%d", source, i)
+	}
+	return name, nil
+}
+
+func (c *cloner) product(source *Product) (*Product, error) {
+	product := *source
+	product.Model = gorm.Model{}
+	product.Name = c.options.Name
+	product.Title = c.options.Title
+	product.Enabled = c.options.Enabled
+	product.Sku = c.sku(source.Sku)
+	product.Parameters, product.Prices, product.Properties, product.Variations = nil, nil, nil, nil
+	product.Categories, product.Files, product.Images, product.Tags = nil, nil, nil, nil
+	product.Image, product.Vendor, product.Time = nil, nil, nil
+	if source.ImageId > 0 {
+		if image, err := GetImage(c.tx, int(source.ImageId)); err == nil {
+			if image, err = c.image(image); err != nil {
+				return nil, err
+			}
+			product.ImageId = image.ID
+		}
+	}
+	if err := c.tx.Omit(clause.Associations).Create(&product).Error; err != nil {
+		return nil, err
+	}
+	if err := c.translations(TRANSLATION_OBJECT_PRODUCT, source.ID, product.ID); err != nil {
+		return nil, err
+	}
+	if err := c.relations(source.ID, product.ID); err != nil {
+		return nil, err
+	}
+	// Shared
+	if len(source.Categories) > 0 {
+		if err := c.tx.Model(&product).Association("Categories").Append(source.Categories); err != nil {
+			return nil, err
+		}
+	}
+	if len(source.Tags) > 0 {
+		if err := c.tx.Model(&product).Association("Tags").Append(source.Tags); err != nil {
+			return nil, err
+		}
+	}
+	// Media
+	images, err := c.imageList(source.Images)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) > 0 {
+		if err = c.tx.Model(&product).Association("Images").Append(images); err != nil {
+			return nil, err
+		}
+	}
+	files, err := c.fileList(source.Files)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 0 {
+		if err = c.tx.Model(&product).Association("Files").Append(files); err != nil {
+			return nil, err
+		}
+	}
+	// Parameters
+	for _, parameter := range source.Parameters {
+		clone := *parameter
+		clone.Model = gorm.Model{}
+		clone.ProductId = product.ID
+		clone.Option = nil
+		clone.Value = nil
+		if parameter.Value != nil && parameter.Value.OptionId == 0 {
+			if clone.ValueId, err = c.value(parameter.Value); err != nil {
+				return nil, err
+			}
+		}
+		if err = c.tx.Omit(clause.Associations).Create(&clone).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err = c.properties(source.Properties, source.Prices, product.ID, 0); err != nil {
+		return nil, err
+	}
+	// Variations
+	for _, variation := range source.Variations {
+		clone := *variation
+		clone.Model = gorm.Model{}
+		clone.ID = 0
+		clone.ProductId = product.ID
+		clone.Sku = c.sku(variation.Sku)
+		clone.Properties, clone.Prices, clone.Images, clone.Files, clone.Time = nil, nil, nil, nil, nil
+		if err = c.tx.Omit(clause.Associations).Create(&clone).Error; err != nil {
+			return nil, err
+		}
+		c.variations[variation.ID] = clone.ID
+		if err = c.translations(TRANSLATION_OBJECT_VARIATION, variation.ID, clone.ID); err != nil {
+			return nil, err
+		}
+		if images, err := c.imageList(variation.Images); err != nil {
+			return nil, err
+		} else if len(images) > 0 {
+			if err = c.tx.Model(&clone).Association("Images").Append(images); err != nil {
+				return nil, err
+			}
+		}
+		if files, err := c.fileList(variation.Files); err != nil {
+			return nil, err
+		} else if len(files) > 0 {
+			if err = c.tx.Model(&clone).Association("Files").Append(files); err != nil {
+				return nil, err
+			}
+		}
+		if err = c.properties(variation.Properties, variation.Prices, 0, clone.ID); err != nil {
+			return nil, err
+		}
+		if variation.Customization != "" {
+			if err = c.tx.Model(&clone).Update("customization", c.customization(variation.Customization)).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+	if source.Customization != "" {
+		if err = c.tx.Model(&product).Update("customization", c.customization(source.Customization)).Error; err != nil {
+			return nil, err
+		}
+	}
+	// Bundle
+	if source.Bundle {
+		components, err := GetBundleComponents(c.tx, source.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, component := range components {
+			clone := *component
+			clone.Model = gorm.Model{}
+			clone.BundleId = product.ID
+			clone.Product, clone.Variation = nil, nil
+			if err = c.tx.Omit(clause.Associations).Create(&clone).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &product, nil
+}
+
+// properties copies properties with rates and prices bound to them
+func (c *cloner) properties(properties []*Property, prices []*Price, productId, variationId uint) error {
+	rates := make(map[uint]*Rate)
+	for _, property := range properties {
+		clone := *property
+		clone.Model = gorm.Model{}
+		clone.ProductId, clone.VariationId = productId, variationId
+		clone.Sku = c.sku(property.Sku)
+		clone.Option, clone.Rates = nil, nil
+		if err := c.tx.Omit(clause.Associations).Create(&clone).Error; err != nil {
+			return err
+		}
+		for _, rate := range property.Rates {
+			cloneRate := *rate
+			cloneRate.Model = gorm.Model{}
+			cloneRate.PropertyId = clone.ID
+			cloneRate.Sku = c.sku(rate.Sku)
+			cloneRate.Property, cloneRate.Value, cloneRate.Prices = nil, nil, nil
+			// custom values belong to the rate and are removed with it
+			if rate.Value != nil && rate.Value.OptionId == 0 {
+				var err error
+				if cloneRate.ValueId, err = c.value(rate.Value); err != nil {
+					return err
+				}
+			}
+			if err := c.tx.Omit(clause.Associations).Create(&cloneRate).Error; err != nil {
+				return err
+			}
+			rates[rate.ID] = &cloneRate
+		}
+	}
+	for _, price := range prices {
+		clone := *price
+		clone.Model = gorm.Model{}
+		clone.ProductId, clone.VariationId = productId, variationId
+		clone.Sku = c.sku(price.Sku)
+		clone.Product, clone.Variation, clone.Rates = nil, nil, nil
+		if err := c.tx.Omit(clause.Associations).Create(&clone).Error; err != nil {
+			return err
+		}
+		var list []*Rate
+		for _, rate := range price.Rates {
+			if r, found := rates[rate.ID]; found {
+				list = append(list, r)
+			}
+		}
+		if len(list) > 0 {
+			if err := c.tx.Model(&clone).Association("Rates").Append(list); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *cloner) value(value *Value) (uint, error) {
+	clone := *value
+	clone.Model = gorm.Model{}
+	if err := c.tx.Create(&clone).Error; err != nil {
+		return 0, err
+	}
+	if err := c.translations(TRANSLATION_OBJECT_VALUE, value.ID, clone.ID); err != nil {
+		return 0, err
+	}
+	return clone.ID, nil
+}
+
+// translations copies translated fields of object to its clone
+func (c *cloner) translations(objectType string, sourceId, id uint) error {
+	translations, err := GetTranslations(c.tx, objectType, sourceId)
+	if err != nil {
+		return err
+	}
+	for _, translation := range translations {
+		clone := *translation
+		clone.Model = gorm.Model{}
+		clone.ObjectId = id
+		if err = c.tx.Create(&clone).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relations copies own relations and incoming bidirectional ones, so clone is listed where source is
+func (c *cloner) relations(sourceId, id uint) error {
+	relations, err := GetProductRelations(c.tx, sourceId)
+	if err != nil {
+		return err
+	}
+	for _, relation := range relations {
+		clone := *relation
+		clone.Model = gorm.Model{}
+		clone.Product, clone.Related = nil, nil
+		if relation.ProductId == sourceId {
+			clone.ProductId = id
+		}
+		if relation.RelatedId == sourceId {
+			clone.RelatedId = id
+		}
+		if err = c.tx.Omit(clause.Associations).Create(&clone).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *cloner) sku(sku string) string {
+	if sku == "" || c.options.Sku == nil {
+		return sku
+	}
+	return c.options.Sku(sku)
+}
+
+func (c *cloner) image(image *Image) (*Image, error) {
+	if c.options.Image == nil {
+		return image, nil
+	}
+	if clone, found := c.images[image.ID]; found {
+		return clone, nil
+	}
+	clone, err := c.options.Image(c.tx, image)
+	if err != nil {
+		return nil, err
+	}
+	c.images[image.ID] = clone
+	return clone, nil
+}
+
+func (c *cloner) imageList(images []*Image) ([]*Image, error) {
+	var list []*Image
+	for _, image := range images {
+		clone, err := c.image(image)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, clone)
+	}
+	return list, nil
+}
+
+func (c *cloner) fileList(files []*File) ([]*File, error) {
+	var list []*File
+	for _, file := range files {
+		clone := file
+		if c.options.File != nil {
+			if f, found := c.files[file.ID]; found {
+				clone = f
+			}else{
+				var err error
+				if clone, err = c.options.File(c.tx, file); err != nil {
+					return nil, err
+				}
+				c.files[file.ID] = clone
+			}
+		}
+		list = append(list, clone)
+	}
+	return list, nil
+}
+
+// customization remaps image and variation ids used to keep custom order
+func (c *cloner) customization(customization string) string {
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(customization), &raw); err != nil {
+		return customization
+	}
+	remap := func(key string, f func(id uint) uint) {
+		if section, ok := raw[key].(map[string]interface{}); ok {
+			if order, ok := section["Order"].([]interface{}); ok {
+				for i, v := range order {
+					if id, ok := v.(float64); ok {
+						order[i] = f(uint(id))
+					}
+				}
+			}
+		}
+	}
+	remap("Images", func(id uint) uint {
+		if image, found := c.images[id]; found {
+			return image.ID
+		}
+		return id
+	})
+	remap("Variations", func(id uint) uint {
+		if vid, found := c.variations[id]; found {
+			return vid
+		}
+		return id
+	})
+	if bts, err := json.Marshal(raw); err == nil {
+		return string(bts)
+	}
+	return customization
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Download is issued per order and purchasable file, Count is number of downloads already made
+type Download struct {
+	gorm.Model
+	OrderId uint `gorm:"index:idx_download_order_id"`
+	ItemId uint
+	FileId uint
+	File *File `gorm:"foreignKey:FileId"`
+	Count int
+	Quota int // max downloads, 0 - unlimited
+	Expires time.Time
+}
+
+func GetDownloadsByOrderId(connector *gorm.DB, orderId uint) ([]*Download, error) {
+	db := connector
+	var downloads []*Download
+	if err := db.Debug().Preload("File").Where("order_id = ?", orderId).Find(&downloads).Error; err != nil {
+		return nil, err
+	}
+	return downloads, nil
+}
+
+func CreateDownload(connector *gorm.DB, download *Download) (uint, error) {
+	db := connector
+	db.Debug().Create(&download)
+	if err := db.Error; err != nil {
+		return 0, err
+	}
+	return download.ID, nil
+}
+
+func GetDownload(connector *gorm.DB, id int) (*Download, error) {
+	db := connector
+	var download Download
+	if err := db.Debug().Preload("File").Where("id = ?", id).First(&download).Error; err != nil {
+		return nil, err
+	}
+	return &download, nil
+}
+
+// IncreaseDownloadCount increments counter only if quota is not exhausted yet, returns false otherwise
+func IncreaseDownloadCount(connector *gorm.DB, download *Download) (bool, error) {
+	db := connector
+	res := db.Debug().Model(&Download{}).Where("id = ? and (quota = 0 or count < quota)", download.ID).Update("count", gorm.Expr("count + 1"))
+	if err := res.Error; err != nil {
+		return false, err
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func DeleteDownload(connector *gorm.DB, download *Download) error {
+	db := connector
+	db.Debug().Unscoped().Delete(&download)
+	return db.Error
+}
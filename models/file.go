@@ -9,6 +9,8 @@ type File struct {
 	Path string
 	Url string
 	Size int64
+	Download bool // purchasable download, never published, available by signed link after payment
+	Limit int // downloads per order, 0 - use default
 }
 
 func GetFiles(connector *gorm.DB) ([]*File, error) {
@@ -21,6 +23,16 @@ func GetFiles(connector *gorm.DB) ([]*File, error) {
 	return files, nil
 }
 
+// GetDownloadFileIds returns files which are never published
+func GetDownloadFileIds(connector *gorm.DB) ([]uint, error) {
+	db := connector
+	var ids []uint
+	if err := db.Debug().Model(&File{}).Where("download = ?", true).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
 func CreateFile(connector *gorm.DB, file *File) (uint, error) {
 	db := connector
 	db.Debug().Create(&file)
@@ -49,4 +61,18 @@ func DeleteFile(connector *gorm.DB, file *File) error {
 	db := connector
 	db.Debug().Unscoped().Delete(&file)
 	return db.Error
-}
\ No newline at end of file
+}
+
+// GetFileOwners returns ids of products and variations file is attached to
+func GetFileOwners(connector *gorm.DB, id uint) ([]uint, []uint, error) {
+	db := connector
+	var productIds []uint
+	if err := db.Debug().Table("products_files").Where("file_id = ?", id).Pluck("product_id", &productIds).Error; err != nil {
+		return nil, nil, err
+	}
+	var variationIds []uint
+	if err := db.Debug().Table("variations_files").Where("file_id = ?", id).Pluck("variation_id", &variationIds).Error; err != nil {
+		return nil, nil, err
+	}
+	return productIds, variationIds, nil
+}
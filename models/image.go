@@ -42,4 +42,18 @@ func DeleteImage(connector *gorm.DB, image *Image) error {
 	db := connector
 	db.Debug().Unscoped().Delete(&image)
 	return db.Error
+}
+
+// GetImageOwners returns ids of products and variations image is attached to
+func GetImageOwners(connector *gorm.DB, id uint) ([]uint, []uint, error) {
+	db := connector
+	var productIds []uint
+	if err := db.Debug().Table("products_images").Where("image_id = ?", id).Pluck("product_id", &productIds).Error; err != nil {
+		return nil, nil, err
+	}
+	var variationIds []uint
+	if err := db.Debug().Table("variations_images").Where("image_id = ?", id).Pluck("variation_id", &variationIds).Error; err != nil {
+		return nil, nil, err
+	}
+	return productIds, variationIds, nil
 }
\ No newline at end of file
@@ -27,6 +27,8 @@ type Item struct {
 	//
 	CommentId uint
 	Comment       *Comment `gorm:"foreignKey:comment_id;"`
+	//
+	BundleId uint // component line of bundle product, price is included into bundle line
 }
 
 func GetItemsCountByProductId(connector *gorm.DB, productId uint) (int64, error) {
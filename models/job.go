@@ -0,0 +1,82 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	JOB_STATUS_RUNNING  = "running"
+	JOB_STATUS_FINISHED = "finished"
+	JOB_STATUS_FAILED   = "failed"
+	JOB_STATUS_CANCELED = "canceled"
+)
+
+// Job is one run of prepare, render or publish, Log keeps last lines of output and Progress is JSON of stages
+type Job struct {
+	gorm.Model
+	Name string `gorm:"size:32;index:idx_job_name"`
+	Arguments string
+	Status string `gorm:"size:16"`
+	Started time.Time
+	Finished time.Time
+	ExitCode int
+	Log string
+	Progress string
+	UserId uint
+}
+
+// GetJobs returns history of jobs without logs
+func GetJobs(connector *gorm.DB, name string, limit int) ([]*Job, error) {
+	db := connector
+	var jobs []*Job
+	if name != "" {
+		db = db.Where("name = ?", name)
+	}
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	if err := db.Debug().Omit("log").Order("id desc").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func GetJob(connector *gorm.DB, id int) (*Job, error) {
+	db := connector
+	var job Job
+	if err := db.Debug().Where("id = ?", id).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func GetLastJob(connector *gorm.DB, name string) (*Job, error) {
+	db := connector
+	var job Job
+	if err := db.Debug().Where("name = ?", name).Order("id desc").First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func CreateJob(connector *gorm.DB, job *Job) (uint, error) {
+	db := connector
+	db.Debug().Create(&job)
+	if err := db.Error; err != nil {
+		return 0, err
+	}
+	return job.ID, nil
+}
+
+func UpdateJob(connector *gorm.DB, job *Job) error {
+	db := connector
+	db.Debug().Save(&job)
+	return db.Error
+}
+
+// FailRunningJobs marks jobs of previous application run as failed, they can not be running anymore
+func FailRunningJobs(connector *gorm.DB) error {
+	db := connector
+	return db.Debug().Model(&Job{}).Where("status = ?", JOB_STATUS_RUNNING).Updates(map[string]interface{}{"status": JOB_STATUS_FAILED, "finished": time.Now()}).Error
+}
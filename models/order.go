@@ -10,6 +10,7 @@ const (
 	ORDER_STATUS_PAID                 = "paid"
 	ORDER_STATUS_MANUFACTURING        = "manufacturing"
 	ORDER_STATUS_SHIPPING             = "shipping"
+	ORDER_STATUS_READY_FOR_PICKUP     = "ready for pickup"
 	ORDER_STATUS_COMPLETE             = "complete"
 	ORDER_STATUS_CANCELED             = "canceled"
 )
@@ -64,6 +65,8 @@ type Order struct {
 	ShippingProfileServices string
 	Transport       *Transport `gorm:"foreignKey:ID"`
 	TransportId     uint
+	PickupLocationId uint
+	PickupLocationTitle string
 	PaymentMethod   string
 }
 
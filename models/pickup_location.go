@@ -0,0 +1,93 @@
+package models
+
+import "gorm.io/gorm"
+
+type PickupLocation struct {
+	gorm.Model
+	Enabled bool
+	Name  string
+	Title string
+	Description string
+	Address  string
+	Zip      string
+	City     string
+	Region   string
+	Country  string
+	Phone    string
+	Email    string
+	Latitude float64
+	Longitude float64
+	Hours string // opening hours, free text or json
+	Capacity int // max open orders waiting for pickup, 0 = unlimited
+	Fee float64 `sql:"type:decimal(8,2);"`
+	Free float64 `sql:"type:decimal(8,2);"` // free after order total
+	Sort int
+}
+
+func GetPickupLocations(connector *gorm.DB) ([]*PickupLocation, error) {
+	db := connector
+	var locations []*PickupLocation
+	if err := db.Debug().Order("sort asc, id asc").Find(&locations).Error; err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+// GetEnabledPickupLocations returns locations offered to customers
+func GetEnabledPickupLocations(connector *gorm.DB) ([]*PickupLocation, error) {
+	db := connector
+	var locations []*PickupLocation
+	if err := db.Debug().Where("enabled = ?", true).Order("sort asc, id asc").Find(&locations).Error; err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+func GetPickupLocationsByName(connector *gorm.DB, name string) ([]*PickupLocation, error) {
+	db := connector
+	var locations []*PickupLocation
+	if err := db.Debug().Where("name = ?", name).Find(&locations).Error; err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+func CreatePickupLocation(connector *gorm.DB, location *PickupLocation) (uint, error) {
+	db := connector
+	db.Debug().Create(&location)
+	if err := db.Error; err != nil {
+		return 0, err
+	}
+	return location.ID, nil
+}
+
+func GetPickupLocation(connector *gorm.DB, id int) (*PickupLocation, error) {
+	db := connector
+	var location PickupLocation
+	if err := db.Debug().Where("id = ?", id).First(&location).Error; err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+// GetPickupLocationLoad returns number of paid orders assigned to location which are not picked up yet, unpaid checkouts do not take capacity
+func GetPickupLocationLoad(connector *gorm.DB, id uint) (int64, error) {
+	db := connector
+	var count int64
+	if err := db.Debug().Model(&Order{}).Where("pickup_location_id = ? and status in ?", id, []string{ORDER_STATUS_PAID, ORDER_STATUS_MANUFACTURING, ORDER_STATUS_SHIPPING, ORDER_STATUS_READY_FOR_PICKUP}).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func UpdatePickupLocation(connector *gorm.DB, location *PickupLocation) error {
+	db := connector
+	db.Debug().Save(&location)
+	return db.Error
+}
+
+func DeletePickupLocation(connector *gorm.DB, location *PickupLocation) error {
+	db := connector
+	db.Debug().Unscoped().Delete(&location)
+	return db.Error
+}
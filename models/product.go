@@ -26,8 +26,10 @@ type Product struct {
 	ManufacturerPrice float64          `sql:"type:decimal(8,2);"`
 	SalePrice float64          `sql:"type:decimal(8,2);"`
 	ItemPrice float64          `sql:"type:decimal(8,2);"`
-	Start time.Time
+	Start time.Time // sale window
 	End time.Time
+	PublishStart time.Time // product is visible and purchasable inside the window only
+	PublishEnd time.Time
 	MinQuantity int
 	MaxQuantity int
 	PurchasableMultiply int
@@ -71,19 +73,13 @@ type Product struct {
 	//
 	//RelatedProducts []*Product `gorm:"many2many:products_related;"`
 	//
+	Bundle bool // product is a set of other products, see BundleComponent
+	BundlePricing string // sum, fixed or percent
+	BundleDiscount float64 `sql:"type:decimal(8,2);"` // percent off for percent pricing
+	//
 	Customization string
 }
 
-func SearchProducts(connector *gorm.DB, term string, limit int) ([]*Product, error) {
-	db := connector
-	var products []*Product
-	db.Debug().Preload("Categories").Preload("Variations").Where("name like ? OR title like ? OR description like ?", term, term, term).Limit(limit).Find(&products)
-	if err := db.Error; err != nil {
-		return nil, err
-	}
-	return products, nil
-}
-
 func GetProducts(connector *gorm.DB) ([]*Product, error) {
 	db := connector
 	var products []*Product
@@ -240,6 +236,16 @@ func GetProductFull(connector *gorm.DB, id int) (*Product, error) {
 	return &product, nil
 }
 
+// TouchProducts bumps updated_at of products so incremental render notices changes which leave no updated row behind,
+// like deleted parts or changed join tables
+func TouchProducts(connector *gorm.DB, ids []uint) error {
+	db := connector
+	if len(ids) == 0 {
+		return nil
+	}
+	return db.Debug().Model(&Product{}).Where("id in ?", ids).UpdateColumn("updated_at", time.Now()).Error
+}
+
 func GetProductByName(connector *gorm.DB, name string) (*Product, error) {
 	db := connector
 	var product Product
@@ -249,6 +255,15 @@ func GetProductByName(connector *gorm.DB, name string) (*Product, error) {
 	return &product, nil
 }
 
+func GetProductBySku(connector *gorm.DB, sku string) (*Product, error) {
+	db := connector
+	var product Product
+	if err := db.Where("sku = ?", sku).First(&product).Error; err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
 func GetProductVariations(connector *gorm.DB, id int) ([]*Variation, error) {
 	db := connector
 	var product Product
@@ -286,6 +301,24 @@ func GetFilesOfProduct(connector *gorm.DB, product *Product) ([]*File, error) {
 	return files, nil
 }
 
+func GetImagesOfProduct(connector *gorm.DB, product *Product) ([]*Image, error) {
+	db := connector
+	var images []*Image
+	if err := db.Model(&product).Association("Images").Find(&images); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func GetTagsOfProduct(connector *gorm.DB, product *Product) ([]*Tag, error) {
+	db := connector
+	var tags []*Tag
+	if err := db.Model(&product).Association("Tags").Find(&tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
 func AddFileToProduct(connector *gorm.DB, product *Product, file *File) error {
 	db := connector
 	return db.Model(&product).Association("Files").Append(file)
@@ -316,16 +349,6 @@ func DeleteAllTagsFromProduct(connector *gorm.DB, product *Product) error {
 	return db.Model(&category).Association("Products").Append(product)
 }*/
 
-func AddProductToProduct(connector *gorm.DB, product1 *Product, product2 *Product) error {
-	db := connector
-	return db.Model(&product1).Association("RelatedProducts").Append(product2)
-}
-
-func DeleteAllProductsFromProduct(connector *gorm.DB, product *Product) error {
-	db := connector
-	return db.Debug().Unscoped().Model(&product).Association("RelatedProducts").Clear()
-}
-
 func UpdateProduct(connector *gorm.DB, product *Product) error {
 	db := connector
 	db.Debug().Unscoped().Save(&product)
@@ -0,0 +1,81 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	RELATION_TYPE_RELATED    = "related"
+	RELATION_TYPE_CROSS_SELL = "cross-sell"
+	RELATION_TYPE_UP_SELL    = "up-sell"
+	RELATION_TYPE_ACCESSORY  = "accessory"
+)
+
+var RelationTypes = []string{RELATION_TYPE_RELATED, RELATION_TYPE_CROSS_SELL, RELATION_TYPE_UP_SELL, RELATION_TYPE_ACCESSORY}
+
+// ProductRelation links product to another one, bidirectional relation is visible from both sides
+type ProductRelation struct {
+	gorm.Model
+	ProductId uint `gorm:"index:idx_product_relation_product_id"`
+	Product *Product `gorm:"foreignKey:ProductId"`
+	RelatedId uint `gorm:"index:idx_product_relation_related_id"`
+	Related *Product `gorm:"foreignKey:RelatedId"`
+	Type string
+	Sort int
+	Bidirectional bool
+}
+
+// Other returns id and product of the opposite side of relation
+func (r *ProductRelation) Other(productId uint) (uint, *Product) {
+	if r.ProductId == productId {
+		return r.RelatedId, r.Related
+	}
+	return r.ProductId, r.Product
+}
+
+// GetProductRelations returns own and incoming bidirectional relations of product filtered by types if any
+func GetProductRelations(connector *gorm.DB, productId uint, types ...string) ([]*ProductRelation, error) {
+	db := connector
+	var relations []*ProductRelation
+	db = db.Debug().Preload("Product").Preload("Related").Where("product_id = ? or (related_id = ? and bidirectional = ?)", productId, productId, true)
+	if len(types) > 0 {
+		db = db.Where("type in ?", types)
+	}
+	if err := db.Order("sort asc, id asc").Find(&relations).Error; err != nil {
+		return nil, err
+	}
+	return relations, nil
+}
+
+func CreateProductRelation(connector *gorm.DB, relation *ProductRelation) (uint, error) {
+	db := connector
+	db.Debug().Create(&relation)
+	if err := db.Error; err != nil {
+		return 0, err
+	}
+	return relation.ID, nil
+}
+
+func GetProductRelation(connector *gorm.DB, id int) (*ProductRelation, error) {
+	db := connector
+	var relation ProductRelation
+	if err := db.Debug().Preload("Product").Preload("Related").Where("id = ?", id).First(&relation).Error; err != nil {
+		return nil, err
+	}
+	return &relation, nil
+}
+
+func UpdateProductRelation(connector *gorm.DB, relation *ProductRelation) error {
+	db := connector
+	db.Debug().Save(&relation)
+	return db.Error
+}
+
+func DeleteProductRelation(connector *gorm.DB, relation *ProductRelation) error {
+	db := connector
+	db.Debug().Unscoped().Delete(&relation)
+	return db.Error
+}
+
+func DeleteProductRelationsByProductId(connector *gorm.DB, productId uint) error {
+	db := connector
+	return db.Debug().Unscoped().Where("product_id = ? or related_id = ?", productId, productId).Delete(&ProductRelation{}).Error
+}
@@ -1,7 +1,6 @@
 package models
 
 import (
-	"github.com/yonnic/goshop/common"
 	"gorm.io/gorm"
 )
 
@@ -69,16 +68,17 @@ func UpdateRate(connector *gorm.DB, rate *Rate) error {
 
 func DeleteRate(connector *gorm.DB, rate *Rate) error {
 	db := connector
-	db.Model(&rate).Association("Prices").Clear()
-	if prices, err := GetPricesOfRate(common.Database, rate); err == nil {
+	// prices are found by join rows, so before they are cleared
+	if prices, err := GetPricesOfRate(db, rate); err == nil {
 		for _, price := range prices {
-			if err = DeletePrice(common.Database, price); err != nil {
+			if err = DeletePrice(db, price); err != nil {
 				return err
 			}
 		}
 	}
+	db.Model(&rate).Association("Prices").Clear()
 	if rate.Value != nil && rate.Value.OptionId == 0 {
-		if err := DeleteValue(common.Database, rate.Value); err != nil {
+		if err := DeleteValue(db, rate.Value); err != nil {
 			return err
 		}
 	}
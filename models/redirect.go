@@ -0,0 +1,110 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+)
+
+const (
+	REDIRECT_CODE_PERMANENT = 301
+	REDIRECT_CODE_TEMPORARY = 302
+	REDIRECT_MAX_CHAIN      = 16
+)
+
+// Redirect sends visitors of old Source url to Target, Auto redirects are created by render when product or category url changed
+type Redirect struct {
+	gorm.Model
+	Source string `gorm:"size:255;uniqueIndex"`
+	Target string `gorm:"size:255"`
+	Code int
+	Auto bool
+	ObjectType string // product, category or empty for manual
+	ObjectId uint
+}
+
+func GetRedirects(connector *gorm.DB) ([]*Redirect, error) {
+	db := connector
+	var redirects []*Redirect
+	if err := db.Debug().Order("source asc").Find(&redirects).Error; err != nil {
+		return nil, err
+	}
+	return redirects, nil
+}
+
+func GetRedirect(connector *gorm.DB, id int) (*Redirect, error) {
+	db := connector
+	var redirect Redirect
+	if err := db.Debug().Where("id = ?", id).First(&redirect).Error; err != nil {
+		return nil, err
+	}
+	return &redirect, nil
+}
+
+func GetRedirectBySource(connector *gorm.DB, source string) (*Redirect, error) {
+	db := connector
+	var redirect Redirect
+	if err := db.Debug().Where("source = ?", source).First(&redirect).Error; err != nil {
+		return nil, err
+	}
+	return &redirect, nil
+}
+
+func DeleteRedirect(connector *gorm.DB, redirect *Redirect) error {
+	db := connector
+	db.Debug().Unscoped().Delete(&redirect)
+	return db.Error
+}
+
+// DeleteAutoRedirectsBySource removes automatic redirects of urls which are served again, manual ones are kept
+func DeleteAutoRedirectsBySource(connector *gorm.DB, urls []string) error {
+	db := connector
+	if len(urls) == 0 {
+		return nil
+	}
+	return db.Debug().Unscoped().Where("auto = ? and source in ?", true, urls).Delete(&Redirect{}).Error
+}
+
+// SaveRedirect creates or updates redirect by its Source url and collapses chains: target is resolved to the final url
+// and existing redirects pointing to Source are moved to the final url too
+func SaveRedirect(connector *gorm.DB, redirect *Redirect) error {
+	db := connector
+	if redirect.Source == "" || redirect.Target == "" {
+		return fmt.Errorf("source and target are required")
+	}
+	if redirect.Code == 0 {
+		redirect.Code = REDIRECT_CODE_PERMANENT
+	}
+	// Resolve target
+	visited := map[string]bool{redirect.Source: true}
+	for i := 0; i < REDIRECT_MAX_CHAIN; i++ {
+		next, err := GetRedirectBySource(db, redirect.Target)
+		if err != nil {
+			break
+		}
+		if visited[next.Target] {
+			return fmt.Errorf("redirect %v => %v makes a loop", redirect.Source, redirect.Target)
+		}
+		visited[redirect.Target] = true
+		redirect.Target = next.Target
+	}
+	if redirect.Source == redirect.Target {
+		return fmt.Errorf("redirect %v points to itself", redirect.Source)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if existing, err := GetRedirectBySource(tx, redirect.Source); err == nil && existing.ID != redirect.ID {
+			if redirect.ID > 0 {
+				return fmt.Errorf("redirect from %v already exists", redirect.Source)
+			}
+			redirect.ID = existing.ID
+			redirect.CreatedAt = existing.CreatedAt
+		}
+		if err := tx.Debug().Save(redirect).Error; err != nil {
+			return err
+		}
+		// Collapse chains ending on Source
+		if err := tx.Debug().Model(&Redirect{}).Where("target = ?", redirect.Source).Update("target", redirect.Target).Error; err != nil {
+			return err
+		}
+		return tx.Debug().Unscoped().Where("source = target").Delete(&Redirect{}).Error
+	})
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"testing"
+)
+
+func openRedirects(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%v?mode=memory&cache=shared", t.Name())), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err = db.AutoMigrate(&Redirect{}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	return db
+}
+
+func TestSaveRedirect(t *testing.T) {
+	for _, example := range []struct{
+		Name string
+		Existing [][2]string
+		Source string
+		Target string
+		Err bool
+		Expected map[string]string // target by source after save
+	}{
+		{Name: "new", Source: "/a/", Target: "/b/", Expected: map[string]string{"/a/": "/b/"}},
+		{Name: "target is resolved", Existing: [][2]string{{"/b/", "/c/"}}, Source: "/a/", Target: "/b/", Expected: map[string]string{"/a/": "/c/", "/b/": "/c/"}},
+		{Name: "chain is collapsed", Existing: [][2]string{{"/a/", "/b/"}}, Source: "/b/", Target: "/c/", Expected: map[string]string{"/a/": "/c/", "/b/": "/c/"}},
+		{Name: "same source is updated", Existing: [][2]string{{"/a/", "/b/"}}, Source: "/a/", Target: "/c/", Expected: map[string]string{"/a/": "/c/"}},
+		{Name: "url served again", Existing: [][2]string{{"/a/", "/b/"}}, Source: "/b/", Target: "/a/", Err: true, Expected: map[string]string{"/a/": "/b/"}},
+		{Name: "loop", Existing: [][2]string{{"/b/", "/c/"}, {"/c/", "/a/"}}, Source: "/a/", Target: "/b/", Err: true, Expected: map[string]string{"/b/": "/c/", "/c/": "/a/"}},
+		{Name: "itself", Source: "/a/", Target: "/a/", Err: true, Expected: map[string]string{}},
+		{Name: "no target", Source: "/a/", Err: true, Expected: map[string]string{}},
+	}{
+		t.Run(example.Name, func(t *testing.T) {
+			db := openRedirects(t)
+			for _, existing := range example.Existing {
+				if err := db.Create(&Redirect{Source: existing[0], Target: existing[1], Code: REDIRECT_CODE_PERMANENT}).Error; err != nil {
+					t.Fatalf("%v", err)
+				}
+			}
+			redirect := &Redirect{Source: example.Source, Target: example.Target}
+			if err := SaveRedirect(db, redirect); (err != nil) != example.Err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !example.Err && redirect.Code != REDIRECT_CODE_PERMANENT {
+				t.Errorf("unexpected code: %v", redirect.Code)
+			}
+			redirects, err := GetRedirects(db)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			targets := make(map[string]string)
+			for _, redirect := range redirects {
+				targets[redirect.Source] = redirect.Target
+			}
+			if fmt.Sprintf("%v", targets) != fmt.Sprintf("%v", example.Expected) {
+				t.Errorf("redirects %v, expected %v", targets, example.Expected)
+			}
+		})
+	}
+}
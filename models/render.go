@@ -0,0 +1,165 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// RenderState is one run of render command, Started of the last finished run is watermark for incremental render
+type RenderState struct {
+	gorm.Model
+	Full bool
+	Started time.Time
+	Finished time.Time
+	Products int64 // rendered products
+	Removed int64 // removed products
+}
+
+func GetLastRenderState(connector *gorm.DB) (*RenderState, error) {
+	db := connector
+	var state RenderState
+	if err := db.Debug().Where("finished > ?", time.Time{}).Order("id desc").First(&state).Error; err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func CreateRenderState(connector *gorm.DB, state *RenderState) (uint, error) {
+	db := connector
+	db.Debug().Create(&state)
+	if err := db.Error; err != nil {
+		return 0, err
+	}
+	return state.ID, nil
+}
+
+func UpdateRenderState(connector *gorm.DB, state *RenderState) error {
+	db := connector
+	db.Debug().Save(&state)
+	return db.Error
+}
+
+// HasCatalogChanges reports whether anything shared by many pages (categories, options, tags, vendors, translations, transports)
+// was changed since watermark, such changes need full render
+func HasCatalogChanges(connector *gorm.DB, since time.Time) (bool, error) {
+	db := connector
+	for _, model := range []interface{}{&Category{}, &Option{}, &Value{}, &Tag{}, &Vendor{}, &Translation{}, &Transport{}} {
+		var count int64
+		if err := db.Debug().Model(model).Where("updated_at > ?", since).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	// Category revisions
+	var count int64
+	if err := db.Debug().Model(&Revision{}).Where("object_type = ? and updated_at > ?", REVISION_OBJECT_CATEGORY, since).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	// Deleted categories
+	var categories, cached int64
+	if err := db.Debug().Model(&Category{}).Count(&categories).Error; err != nil {
+		return false, err
+	}
+	if err := db.Debug().Model(&CacheCategory{}).Count(&cached).Error; err != nil {
+		return false, err
+	}
+	return categories != cached, nil
+}
+
+// GetChangedProductIds returns ids of products which own data, published revision or schedule window was changed since watermark,
+// deleted parts and join table changes are expected to touch updated_at of the product
+func GetChangedProductIds(connector *gorm.DB, since, now time.Time) ([]uint, error) {
+	db := connector
+	ids := make(map[uint]bool)
+	pluck := func(query *gorm.DB, column string) error {
+		var values []uint
+		if err := query.Pluck(column, &values).Error; err != nil {
+			return err
+		}
+		for _, value := range values {
+			ids[value] = true
+		}
+		return nil
+	}
+	if err := pluck(db.Debug().Model(&Product{}).Where("updated_at > ?", since), "id"); err != nil {
+		return nil, err
+	}
+	for _, model := range []interface{}{&Variation{}, &Parameter{}, &Property{}, &Price{}, &Comment{}} {
+		if err := pluck(db.Debug().Model(model).Where("updated_at > ?", since), "product_id"); err != nil {
+			return nil, err
+		}
+	}
+	if err := pluck(db.Debug().Model(&Property{}).Where("id in (?)", db.Model(&Rate{}).Select("property_id").Where("updated_at > ?", since)), "product_id"); err != nil {
+		return nil, err
+	}
+	if err := pluck(db.Debug().Model(&BundleComponent{}).Where("updated_at > ?", since), "bundle_id"); err != nil {
+		return nil, err
+	}
+	// Shared media
+	images := db.Model(&Image{}).Select("id").Where("updated_at > ?", since)
+	files := db.Model(&File{}).Select("id").Where("updated_at > ?", since)
+	if err := pluck(db.Debug().Table("products_images").Where("image_id in (?)", images), "product_id"); err != nil {
+		return nil, err
+	}
+	if err := pluck(db.Debug().Table("products_files").Where("file_id in (?)", files), "product_id"); err != nil {
+		return nil, err
+	}
+	if err := pluck(db.Debug().Model(&Variation{}).Where("id in (?) or id in (?)", db.Table("variations_images").Select("variation_id").Where("image_id in (?)", images), db.Table("variations_files").Select("variation_id").Where("file_id in (?)", files)), "product_id"); err != nil {
+		return nil, err
+	}
+	// Relations are shown on both sides
+	for _, column := range []string{"product_id", "related_id"} {
+		if err := pluck(db.Debug().Model(&ProductRelation{}).Where("updated_at > ?", since), column); err != nil {
+			return nil, err
+		}
+	}
+	// Published revisions, staged drafts are not visible yet
+	if err := pluck(db.Debug().Model(&Revision{}).Where("object_type = ? and status = ? and updated_at > ?", REVISION_OBJECT_PRODUCT, REVISION_STATUS_PUBLISHED, since), "object_id"); err != nil {
+		return nil, err
+	}
+	if err := pluck(db.Debug().Model(&Variation{}).Where("id in (?)", db.Model(&Revision{}).Select("object_id").Where("object_type = ? and status = ? and updated_at > ?", REVISION_OBJECT_VARIATION, REVISION_STATUS_PUBLISHED, since)), "product_id"); err != nil {
+		return nil, err
+	}
+	// Publish and sale windows opened or closed in between
+	scheduled, err := GetScheduledProductIds(db, since, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range scheduled {
+		ids[id] = true
+	}
+	var result []uint
+	for id := range ids {
+		if id > 0 {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+// GetScheduledProductIds returns ids of products which own or variation publish or sale window opened or closed in (since, now]
+func GetScheduledProductIds(connector *gorm.DB, since, now time.Time) ([]uint, error) {
+	db := connector
+	var ids []uint
+	for _, column := range []string{"start", "end", "publish_start", "publish_end"} {
+		// end is reserved word, quoting is left to dialect
+		after, before := clause.Gt{Column: clause.Column{Name: column}, Value: since}, clause.Lte{Column: clause.Column{Name: column}, Value: now}
+		var values []uint
+		if err := db.Debug().Model(&Product{}).Where(after).Where(before).Pluck("id", &values).Error; err != nil {
+			return nil, err
+		}
+		ids = append(ids, values...)
+		values = nil
+		if err := db.Debug().Model(&Variation{}).Where(after).Where(before).Pluck("product_id", &values).Error; err != nil {
+			return nil, err
+		}
+		ids = append(ids, values...)
+	}
+	return ids, nil
+}
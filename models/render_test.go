@@ -0,0 +1,147 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"sort"
+	"testing"
+	"time"
+)
+
+func openRender(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%v?mode=memory&cache=shared", t.Name())), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, value := range []interface{}{&Category{}, &Product{}, &Parameter{}, &File{}, &Image{}, &Variation{}, &Property{}, &Option{},
+		&Value{}, &Rate{}, &Price{}, &Tag{}, &Time{}, &Vendor{}, &Revision{}, &Comment{}, &BundleComponent{}, &ProductRelation{},
+		&Translation{}, &Transport{}, &CacheCategory{}} {
+		// index names are shared by tables in sqlite, so migration of dependent table stops at index of its dependency
+		if err := db.AutoMigrate(value); err != nil && !db.Migrator().HasTable(value) {
+			db.Migrator().CreateTable(value)
+		}
+	}
+	return db
+}
+
+func TestGetChangedProductIds(t *testing.T) {
+	db := openRender(t)
+	products := make(map[string]*Product)
+	for _, name := range []string{"untouched", "product", "variation", "rate", "image", "relation", "related", "published", "draft", "variation revision", "window", "bundle"} {
+		product := &Product{Enabled: true, Name: name, Title: name}
+		if _, err := CreateProduct(db, product); err != nil {
+			t.Fatalf("%v", err)
+		}
+		products[name] = product
+	}
+	variation := &Variation{Enabled: true, Name: "red", ProductId: products["variation"].ID}
+	revisioned := &Variation{Enabled: true, Name: "blue", ProductId: products["variation revision"].ID}
+	property := &Property{Name: "size", ProductId: products["rate"].ID}
+	for _, value := range []interface{}{variation, revisioned, property} {
+		if err := db.Create(value).Error; err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	rate := &Rate{PropertyId: property.ID}
+	image := &Image{Name: "shared"}
+	component := &BundleComponent{BundleId: products["bundle"].ID, ProductId: products["untouched"].ID}
+	for _, value := range []interface{}{rate, image, component} {
+		if err := db.Create(value).Error; err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	if err := db.Model(products["image"]).Association("Images").Append(image); err != nil {
+		t.Fatalf("%v", err)
+	}
+	// Everything above belongs to previous render
+	old := time.Now().Add(-time.Hour)
+	for _, table := range []string{"products", "variations", "properties", "rates", "images", "bundle_components"} {
+		if err := db.Exec("UPDATE " + table + " SET updated_at = ?", old).Error; err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	since := time.Now().Add(-time.Minute)
+	now := time.Now()
+	if ids, err := GetChangedProductIds(db, since, now); err != nil || len(ids) != 0 {
+		t.Fatalf("unexpected changes: %v %v", ids, err)
+	}
+	// Changes after watermark
+	if err := db.Model(products["product"]).Update("title", "Product").Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, value := range []interface{}{variation, rate, image, component} {
+		if err := db.Model(value).Update("updated_at", now).Error; err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	if _, err := CreateProductRelation(db, &ProductRelation{ProductId: products["relation"].ID, RelatedId: products["related"].ID}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, revision := range []*Revision{
+		{ObjectType: REVISION_OBJECT_PRODUCT, ObjectId: products["published"].ID, Status: REVISION_STATUS_PUBLISHED},
+		{ObjectType: REVISION_OBJECT_PRODUCT, ObjectId: products["draft"].ID, Status: REVISION_STATUS_DRAFT},
+		{ObjectType: REVISION_OBJECT_VARIATION, ObjectId: revisioned.ID, Status: REVISION_STATUS_PUBLISHED},
+	}{
+		if _, err := CreateRevision(db, revision); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	// Window opens without any edit after watermark
+	if err := db.Model(products["window"]).UpdateColumns(map[string]interface{}{"publish_start": since.Add(30 * time.Second), "updated_at": old}).Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	ids, err := GetChangedProductIds(db, since, now)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var names []string
+	for _, id := range ids {
+		for name, product := range products {
+			if product.ID == id {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	if expected := []string{"bundle", "image", "product", "published", "rate", "related", "relation", "variation", "variation revision", "window"}; fmt.Sprint(names) != fmt.Sprint(expected) {
+		t.Errorf("changed %v, expected %v", names, expected)
+	}
+}
+
+func TestHasCatalogChanges(t *testing.T) {
+	db := openRender(t)
+	category := &Category{Name: "shirts", Title: "Shirts"}
+	if _, err := CreateCategory(db, category); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := db.Create(&CacheCategory{CategoryID: category.ID}).Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	since := time.Now().Add(time.Minute)
+	if changed, err := HasCatalogChanges(db, since); err != nil || changed {
+		t.Fatalf("unexpected catalog changes: %v %v", changed, err)
+	}
+	// Shared object is edited
+	tag := &Tag{Name: "summer"}
+	if _, err := CreateTag(db, tag); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := db.Model(tag).UpdateColumn("updated_at", since.Add(time.Second)).Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	if changed, err := HasCatalogChanges(db, since); err != nil || !changed {
+		t.Errorf("tag change is not detected: %v", err)
+	}
+	// Category is deleted
+	if changed, err := HasCatalogChanges(db, since.Add(time.Minute)); err != nil || changed {
+		t.Fatalf("unexpected catalog changes: %v %v", changed, err)
+	}
+	if err := db.Unscoped().Delete(category).Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	if changed, err := HasCatalogChanges(db, since.Add(time.Minute)); err != nil || !changed {
+		t.Errorf("deleted category is not detected: %v", err)
+	}
+}
@@ -0,0 +1,403 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	REVISION_OBJECT_PRODUCT   = "product"
+	REVISION_OBJECT_VARIATION = "variation"
+	REVISION_OBJECT_CATEGORY  = "category"
+	//
+	REVISION_STATUS_DRAFT     = "draft"
+	REVISION_STATUS_PUBLISHED = "published"
+)
+
+// Revision is a JSON snapshot of product, variation or category made on every save
+type Revision struct {
+	gorm.Model
+	ObjectType string `gorm:"size:32;index:idx_revision_object"`
+	ObjectId uint `gorm:"index:idx_revision_object"`
+	Status string // draft or published
+	Comment string
+	UserId uint
+	Data string
+}
+
+func GetRevisions(connector *gorm.DB, objectType string, objectId uint) ([]*Revision, error) {
+	db := connector
+	var revisions []*Revision
+	if err := db.Debug().Where("object_type = ? and object_id = ?", objectType, objectId).Order("id desc").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func GetRevisionsByStatus(connector *gorm.DB, status string) ([]*Revision, error) {
+	db := connector
+	var revisions []*Revision
+	if err := db.Debug().Where("status = ?", status).Order("id desc").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func GetLastRevision(connector *gorm.DB, objectType string, objectId uint) (*Revision, error) {
+	db := connector
+	var revision Revision
+	if err := db.Debug().Where("object_type = ? and object_id = ?", objectType, objectId).Order("id desc").First(&revision).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// HasDraft reports whether object has changes staged after it was published last time
+func HasDraft(connector *gorm.DB, objectType string, objectId uint) bool {
+	last, err := GetLastRevision(connector, objectType, objectId)
+	return err == nil && last.Status == REVISION_STATUS_DRAFT
+}
+
+func GetLastPublishedRevision(connector *gorm.DB, objectType string, objectId uint) (*Revision, error) {
+	db := connector
+	var revision Revision
+	if err := db.Debug().Where("object_type = ? and object_id = ? and status = ?", objectType, objectId, REVISION_STATUS_PUBLISHED).Order("id desc").First(&revision).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// GetPreviousRevision returns revision of the same object made right before given one
+func GetPreviousRevision(connector *gorm.DB, revision *Revision) (*Revision, error) {
+	db := connector
+	var previous Revision
+	if err := db.Debug().Where("object_type = ? and object_id = ? and id < ?", revision.ObjectType, revision.ObjectId, revision.ID).Order("id desc").First(&previous).Error; err != nil {
+		return nil, err
+	}
+	return &previous, nil
+}
+
+func CreateRevision(connector *gorm.DB, revision *Revision) (uint, error) {
+	db := connector
+	db.Debug().Create(&revision)
+	if err := db.Error; err != nil {
+		return 0, err
+	}
+	return revision.ID, nil
+}
+
+func GetRevision(connector *gorm.DB, id int) (*Revision, error) {
+	db := connector
+	var revision Revision
+	if err := db.Debug().Where("id = ?", id).First(&revision).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+func UpdateRevision(connector *gorm.DB, revision *Revision) error {
+	db := connector
+	db.Debug().Save(&revision)
+	return db.Error
+}
+
+// PublishRevisions marks all drafts of the object as published
+func PublishRevisions(connector *gorm.DB, objectType string, objectId uint) error {
+	db := connector
+	return db.Debug().Model(&Revision{}).Where("object_type = ? and object_id = ? and status = ?", objectType, objectId, REVISION_STATUS_DRAFT).Update("status", REVISION_STATUS_PUBLISHED).Error
+}
+
+func DeleteRevisionsByObject(connector *gorm.DB, objectType string, objectId uint) error {
+	db := connector
+	return db.Debug().Unscoped().Where("object_type = ? and object_id = ?", objectType, objectId).Delete(&Revision{}).Error
+}
+
+// NewSnapshot returns current object state to be stored in revision, product variations have own revisions
+func NewSnapshot(connector *gorm.DB, objectType string, objectId uint) (string, error) {
+	var object interface{}
+	switch objectType {
+	case REVISION_OBJECT_PRODUCT:
+		product, err := GetProductFull(connector, int(objectId))
+		if err != nil {
+			return "", err
+		}
+		product.Variations = nil
+		object = product
+	case REVISION_OBJECT_VARIATION:
+		variation, err := GetVariation(connector, int(objectId))
+		if err != nil {
+			return "", err
+		}
+		object = variation
+	case REVISION_OBJECT_CATEGORY:
+		category, err := GetCategory(connector, int(objectId))
+		if err != nil {
+			return "", err
+		}
+		object = category
+	default:
+		return "", fmt.Errorf("unknown revision object %v", objectType)
+	}
+	bts, err := json.Marshal(object)
+	if err != nil {
+		return "", err
+	}
+	return string(bts), nil
+}
+
+// getPublishedSnapshot loads last published state into v if object has unpublished draft, returns false if live state is actual
+func getPublishedSnapshot(connector *gorm.DB, objectType string, objectId uint, v interface{}) (bool, error) {
+	last, err := GetLastRevision(connector, objectType, objectId)
+	if err != nil || last.Status != REVISION_STATUS_DRAFT {
+		return false, nil
+	}
+	published, err := GetLastPublishedRevision(connector, objectType, objectId)
+	if err != nil {
+		return false, fmt.Errorf("%v #%v is not published yet", objectType, objectId)
+	}
+	if err = json.Unmarshal([]byte(published.Data), v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetPublishedProduct returns product as it was published if there are staged drafts, variations are kept as is
+func GetPublishedProduct(connector *gorm.DB, product *Product) (*Product, error) {
+	var published Product
+	if found, err := getPublishedSnapshot(connector, REVISION_OBJECT_PRODUCT, product.ID, &published); err != nil {
+		return nil, err
+	} else if !found {
+		return product, nil
+	}
+	published.Variations = product.Variations
+	return &published, nil
+}
+
+func GetPublishedVariation(connector *gorm.DB, variation *Variation) (*Variation, error) {
+	var published Variation
+	if found, err := getPublishedSnapshot(connector, REVISION_OBJECT_VARIATION, variation.ID, &published); err != nil {
+		return nil, err
+	} else if !found {
+		return variation, nil
+	}
+	return &published, nil
+}
+
+// GetPublishedProductFull loads product with variations as they were published, variations which were never published are skipped
+func GetPublishedProductFull(connector *gorm.DB, id int) (*Product, error) {
+	product, err := GetProductFull(connector, id)
+	if err != nil {
+		return nil, err
+	}
+	if product, err = GetPublishedProduct(connector, product); err != nil {
+		return nil, err
+	}
+	var variations []*Variation
+	for _, variation := range product.Variations {
+		if published, err := GetPublishedVariation(connector, variation); err == nil {
+			variations = append(variations, published)
+		}
+	}
+	product.Variations = variations
+	return product, nil
+}
+
+// GetPublishedBundleComponents returns components of bundle with their products and variations as they were published
+func GetPublishedBundleComponents(connector *gorm.DB, bundleId uint) ([]*BundleComponent, error) {
+	components, err := GetBundleComponents(connector, bundleId)
+	if err != nil {
+		return nil, err
+	}
+	for _, component := range components {
+		if component.Product != nil {
+			if component.Product, err = GetPublishedProduct(connector, component.Product); err != nil {
+				return nil, err
+			}
+		}
+		if component.Variation != nil {
+			if component.Variation, err = GetPublishedVariation(connector, component.Variation); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return components, nil
+}
+
+func GetPublishedCategory(connector *gorm.DB, category *Category) (*Category, error) {
+	var published Category
+	if found, err := getPublishedSnapshot(connector, REVISION_OBJECT_CATEGORY, category.ID, &published); err != nil {
+		return nil, err
+	} else if !found {
+		return category, nil
+	}
+	return &published, nil
+}
+
+// RestoreRevision writes snapshot back to database including parameters, properties, prices, images, files and tags
+func RestoreRevision(connector *gorm.DB, revision *Revision) error {
+	db := connector
+	switch revision.ObjectType {
+	case REVISION_OBJECT_PRODUCT:
+		var product Product
+		if err := json.Unmarshal([]byte(revision.Data), &product); err != nil {
+			return err
+		}
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Debug().Omit(clause.Associations).Save(&product).Error; err != nil {
+				return err
+			}
+			var ids []uint
+			for _, parameter := range product.Parameters {
+				parameter.Option, parameter.Value = nil, nil
+				parameter.ProductId = product.ID
+				if err := tx.Debug().Omit(clause.Associations).Save(parameter).Error; err != nil {
+					return err
+				}
+				ids = append(ids, parameter.ID)
+			}
+			if err := whereMissing(tx, "product_id", product.ID, ids).Delete(&Parameter{}).Error; err != nil {
+				return err
+			}
+			if err := restoreProperties(tx, "product_id", product.ID, product.Properties); err != nil {
+				return err
+			}
+			if err := restorePrices(tx, "product_id", product.ID, product.Prices); err != nil {
+				return err
+			}
+			if err := tx.Model(&product).Association("Categories").Replace(product.Categories); err != nil {
+				return err
+			}
+			if err := tx.Model(&product).Association("Images").Replace(product.Images); err != nil {
+				return err
+			}
+			if err := tx.Model(&product).Association("Files").Replace(product.Files); err != nil {
+				return err
+			}
+			return tx.Model(&product).Association("Tags").Replace(product.Tags)
+		})
+	case REVISION_OBJECT_VARIATION:
+		var variation Variation
+		if err := json.Unmarshal([]byte(revision.Data), &variation); err != nil {
+			return err
+		}
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Debug().Omit(clause.Associations).Save(&variation).Error; err != nil {
+				return err
+			}
+			if err := restoreProperties(tx, "variation_id", variation.ID, variation.Properties); err != nil {
+				return err
+			}
+			if err := restorePrices(tx, "variation_id", variation.ID, variation.Prices); err != nil {
+				return err
+			}
+			if err := tx.Model(&variation).Association("Images").Replace(variation.Images); err != nil {
+				return err
+			}
+			return tx.Model(&variation).Association("Files").Replace(variation.Files)
+		})
+	case REVISION_OBJECT_CATEGORY:
+		var category Category
+		if err := json.Unmarshal([]byte(revision.Data), &category); err != nil {
+			return err
+		}
+		return db.Debug().Omit(clause.Associations).Save(&category).Error
+	}
+	return fmt.Errorf("unknown revision object %v", revision.ObjectType)
+}
+
+func restoreProperties(tx *gorm.DB, column string, ownerId uint, properties []*Property) error {
+	var ids []uint
+	for _, property := range properties {
+		property.Option = nil
+		rates := property.Rates
+		property.Rates = nil
+		if err := tx.Debug().Omit(clause.Associations).Save(property).Error; err != nil {
+			return err
+		}
+		var rateIds []uint
+		for _, rate := range rates {
+			rate.Property, rate.Value, rate.Prices = nil, nil, nil
+			rate.PropertyId = property.ID
+			if err := tx.Debug().Omit(clause.Associations).Save(rate).Error; err != nil {
+				return err
+			}
+			rateIds = append(rateIds, rate.ID)
+		}
+		if err := deleteRates(tx, whereMissing(tx, "property_id", property.ID, rateIds)); err != nil {
+			return err
+		}
+		ids = append(ids, property.ID)
+	}
+	var missing []*Property
+	if err := whereMissing(tx, column, ownerId, ids).Find(&missing).Error; err != nil {
+		return err
+	}
+	for _, property := range missing {
+		if err := deleteRates(tx, tx.Where("property_id = ?", property.ID)); err != nil {
+			return err
+		}
+		if err := tx.Debug().Unscoped().Delete(property).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func restorePrices(tx *gorm.DB, column string, ownerId uint, prices []*Price) error {
+	var ids []uint
+	for _, price := range prices {
+		price.Product, price.Variation = nil, nil
+		rates := price.Rates
+		price.Rates = nil
+		if err := tx.Debug().Omit(clause.Associations).Save(price).Error; err != nil {
+			return err
+		}
+		for _, rate := range rates {
+			rate.Property, rate.Value, rate.Prices = nil, nil, nil
+		}
+		if err := tx.Model(price).Association("Rates").Replace(rates); err != nil {
+			return err
+		}
+		ids = append(ids, price.ID)
+	}
+	var missing []*Price
+	if err := whereMissing(tx, column, ownerId, ids).Find(&missing).Error; err != nil {
+		return err
+	}
+	for _, price := range missing {
+		if err := tx.Model(price).Association("Rates").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Debug().Unscoped().Delete(price).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteRates(tx *gorm.DB, query *gorm.DB) error {
+	var rates []*Rate
+	if err := query.Find(&rates).Error; err != nil {
+		return err
+	}
+	for _, rate := range rates {
+		if err := tx.Model(rate).Association("Prices").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Debug().Unscoped().Delete(rate).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// whereMissing selects children of owner which ids are not in the list
+func whereMissing(tx *gorm.DB, column string, ownerId uint, ids []uint) *gorm.DB {
+	db := tx.Debug().Unscoped().Where(column + " = ?", ownerId)
+	if len(ids) > 0 {
+		db = db.Where("id not in ?", ids)
+	}
+	return db
+}
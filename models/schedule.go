@@ -0,0 +1,49 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// InWindow reports whether now is inside [start, end), zero bound is not limited
+func InWindow(now, start, end time.Time) bool {
+	return (start.IsZero() || !now.Before(start)) && (end.IsZero() || now.Before(end))
+}
+
+// IsPublished reports whether product is enabled and inside its publish window
+func (p *Product) IsPublished(now time.Time) bool {
+	return p.Enabled && InWindow(now, p.PublishStart, p.PublishEnd)
+}
+
+// IsSale reports whether product sale price is active
+func (p *Product) IsSale(now time.Time) bool {
+	return p.SalePrice > 0 && InWindow(now, p.Start, p.End)
+}
+
+func (v *Variation) IsPublished(now time.Time) bool {
+	return v.Enabled && InWindow(now, v.PublishStart, v.PublishEnd)
+}
+
+func (v *Variation) IsSale(now time.Time) bool {
+	return v.SalePrice > 0 && InWindow(now, v.Start, v.End)
+}
+
+// GetNextScheduleChange returns the nearest moment after now when any publish or sale window opens or closes, zero if there is no one
+func GetNextScheduleChange(connector *gorm.DB, now time.Time) (time.Time, error) {
+	db := connector
+	var next time.Time
+	for _, model := range []interface{}{&Product{}, &Variation{}} {
+		for _, column := range []string{"start", "end", "publish_start", "publish_end"} {
+			var times []time.Time
+			// start and end are reserved words, quoted by dialect
+			if err := db.Debug().Model(model).Where(clause.Gt{Column: clause.Column{Name: column}, Value: now}).Order(clause.OrderByColumn{Column: clause.Column{Name: column}}).Limit(1).Pluck(column, &times).Error; err != nil {
+				return next, err
+			}
+			if len(times) > 0 && (next.IsZero() || times[0].Before(next)) {
+				next = times[0]
+			}
+		}
+	}
+	return next, nil
+}
@@ -0,0 +1,419 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	SEARCH_ENGINE_FTS5     = "fts5"     // sqlite
+	SEARCH_ENGINE_FULLTEXT = "fulltext" // mysql
+	SEARCH_ENGINE_TSVECTOR = "tsvector" // postgres
+	SEARCH_ENGINE_LIKE     = "like"     // fallback
+)
+
+var (
+	SearchEngine = SEARCH_ENGINE_LIKE
+	reSearchTags = regexp.MustCompile(`<[^>]*>`)
+)
+
+// SearchDocument is text of enabled product with its variations, SKUs, tags and parameters, ID is product id
+type SearchDocument struct {
+	ID uint `gorm:"primarykey"`
+	Title string
+	Body string
+	Skus string
+	UpdatedAt time.Time
+}
+
+// SearchTerm is vocabulary of indexed words used to correct typos
+type SearchTerm struct {
+	Term string `gorm:"primarykey;size:64"`
+}
+
+type SearchHit struct {
+	ProductId uint
+	Rank float64
+	Title string
+	Body string
+}
+
+// InitSearch creates search tables and full text index supported by database
+func InitSearch(connector *gorm.DB) error {
+	db := connector
+	if err := db.AutoMigrate(&SearchDocument{}, &SearchTerm{}); err != nil {
+		return err
+	}
+	switch db.Dialector.Name() {
+	case "sqlite":
+		if err := db.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS search_fts USING fts5(title, body, skus)").Error; err != nil {
+			// driver is built without fts5
+			SearchEngine = SEARCH_ENGINE_LIKE
+			return nil
+		}
+		SearchEngine = SEARCH_ENGINE_FTS5
+	case "mysql":
+		var count int64
+		db.Raw("SELECT count(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'search_documents' AND index_name = 'idx_search_fulltext'").Scan(&count)
+		if count == 0 {
+			if err := db.Exec("ALTER TABLE search_documents ADD FULLTEXT INDEX idx_search_fulltext (title, body, skus)").Error; err != nil {
+				return err
+			}
+		}
+		SearchEngine = SEARCH_ENGINE_FULLTEXT
+	case "postgres":
+		if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_search_tsvector ON search_documents USING GIN (to_tsvector('simple', title || ' ' || body || ' ' || skus))").Error; err != nil {
+			return err
+		}
+		SearchEngine = SEARCH_ENGINE_TSVECTOR
+	default:
+		SearchEngine = SEARCH_ENGINE_LIKE
+	}
+	return nil
+}
+
+// IndexProduct updates search document of product as it was published, staged drafts are not searchable, disabled, missing or
+// never published products are removed from index
+func IndexProduct(connector *gorm.DB, id uint) error {
+	db := connector
+	now := time.Now()
+	product, err := GetPublishedProductFull(db, int(id))
+	if err != nil || product.ID == 0 || !product.IsPublished(now) {
+		return RemoveSearchDocument(db, id)
+	}
+	var body, skus []string
+	body = append(body, searchText(product.Description))
+	for _, parameter := range product.Parameters {
+		if parameter.Value != nil {
+			body = append(body, parameter.Value.Title)
+		}else if parameter.CustomValue != "" {
+			body = append(body, parameter.CustomValue)
+		}
+	}
+	for _, tag := range product.Tags {
+		body = append(body, tag.Title)
+	}
+	for _, category := range product.Categories {
+		body = append(body, category.Title)
+	}
+	skus = append(skus, product.Sku)
+	addSkus := func(properties []*Property, prices []*Price) {
+		for _, property := range properties {
+			skus = append(skus, property.Sku)
+			for _, rate := range property.Rates {
+				skus = append(skus, rate.Sku)
+			}
+		}
+		for _, price := range prices {
+			skus = append(skus, price.Sku)
+		}
+	}
+	addSkus(product.Properties, product.Prices)
+	for _, variation := range product.Variations {
+		if !variation.IsPublished(now) {
+			continue
+		}
+		body = append(body, variation.Title, searchText(variation.Description))
+		skus = append(skus, variation.Sku)
+		addSkus(variation.Properties, variation.Prices)
+	}
+	document := &SearchDocument{
+		ID: product.ID,
+		Title: product.Title,
+		Body: strings.Join(compact(body), " "),
+		Skus: strings.Join(compact(skus), " "),
+	}
+	if err = db.Debug().Save(document).Error; err != nil {
+		return err
+	}
+	if SearchEngine == SEARCH_ENGINE_FTS5 {
+		if err = db.Debug().Exec("DELETE FROM search_fts WHERE rowid = ?", document.ID).Error; err != nil {
+			return err
+		}
+		if err = db.Debug().Exec("INSERT INTO search_fts (rowid, title, body, skus) VALUES (?, ?, ?, ?)", document.ID, document.Title, document.Body, document.Skus).Error; err != nil {
+			return err
+		}
+	}
+	var terms []SearchTerm
+	for _, token := range SearchTokens(document.Title + " " + document.Body) {
+		if n := utf8.RuneCountInString(token); n > 2 && n <= 64 {
+			terms = append(terms, SearchTerm{Term: token})
+		}
+	}
+	if len(terms) > 0 {
+		return db.Debug().Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&terms, 100).Error
+	}
+	return nil
+}
+
+func RemoveSearchDocument(connector *gorm.DB, id uint) error {
+	db := connector
+	if SearchEngine == SEARCH_ENGINE_FTS5 {
+		if err := db.Debug().Exec("DELETE FROM search_fts WHERE rowid = ?", id).Error; err != nil {
+			return err
+		}
+	}
+	return db.Debug().Where("id = ?", id).Delete(&SearchDocument{}).Error
+}
+
+// GetProductIdsByTag returns products which documents contain title of tag
+func GetProductIdsByTag(connector *gorm.DB, tagId uint) ([]uint, error) {
+	db := connector
+	var ids []uint
+	if err := db.Debug().Table("products_tags").Where("tag_id = ?", tagId).Pluck("product_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetProductIdsByCategory returns products which documents contain title of category
+func GetProductIdsByCategory(connector *gorm.DB, categoryId uint) ([]uint, error) {
+	db := connector
+	var ids []uint
+	if err := db.Debug().Table("categories_products").Where("category_id = ?", categoryId).Pluck("product_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// GetProductIdsByValue returns products which documents contain title of value as parameter
+func GetProductIdsByValue(connector *gorm.DB, valueId uint) ([]uint, error) {
+	db := connector
+	var ids []uint
+	if err := db.Debug().Model(&Parameter{}).Where("value_id = ?", valueId).Pluck("product_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// ReindexProducts rebuilds the whole index
+func ReindexProducts(connector *gorm.DB) (int, error) {
+	db := connector
+	if err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&SearchDocument{}).Error; err != nil {
+		return 0, err
+	}
+	if err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&SearchTerm{}).Error; err != nil {
+		return 0, err
+	}
+	if SearchEngine == SEARCH_ENGINE_FTS5 {
+		if err := db.Exec("DELETE FROM search_fts").Error; err != nil {
+			return 0, err
+		}
+	}
+	// product disabled by draft can be still enabled in published revision
+	var ids []uint
+	if err := db.Model(&Product{}).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		if err := IndexProduct(db, id); err != nil {
+			return 0, err
+		}
+	}
+	var count int64
+	if err := db.Model(&SearchDocument{}).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// searchRendered limits hits to products with rendered pages, the rest have no link to show
+const searchRendered = "(SELECT product_id FROM cache_products WHERE deleted_at IS NULL)"
+
+// SearchProducts returns ranked page of products matching all words of the query and total count
+func SearchProducts(connector *gorm.DB, query string, offset, limit int) ([]*SearchHit, int64, error) {
+	db := connector
+	tokens := SearchTokens(query)
+	if len(tokens) == 0 {
+		return nil, 0, nil
+	}
+	var hits []*SearchHit
+	var total int64
+	switch SearchEngine {
+	case SEARCH_ENGINE_FTS5:
+		var terms []string
+		for _, token := range tokens {
+			terms = append(terms, fmt.Sprintf(`"%v"*`, token))
+		}
+		match := strings.Join(terms, " ")
+		if err := db.Raw("SELECT count(*) FROM search_fts WHERE search_fts MATCH ? AND rowid IN " + searchRendered, match).Scan(&total).Error; err != nil {
+			return nil, 0, err
+		}
+		// bm25 is negative, the less the better
+		if err := db.Raw("SELECT rowid AS product_id, -bm25(search_fts, 10.0, 1.0, 5.0) AS rank, title, body FROM search_fts WHERE search_fts MATCH ? AND rowid IN " + searchRendered + " ORDER BY bm25(search_fts, 10.0, 1.0, 5.0) LIMIT ? OFFSET ?", match, limit, offset).Scan(&hits).Error; err != nil {
+			return nil, 0, err
+		}
+	case SEARCH_ENGINE_FULLTEXT:
+		var terms []string
+		for _, token := range tokens {
+			terms = append(terms, "+" + token + "*")
+		}
+		match := strings.Join(terms, " ")
+		if err := db.Raw("SELECT count(*) FROM search_documents WHERE MATCH(title, body, skus) AGAINST(? IN BOOLEAN MODE) AND id IN " + searchRendered, match).Scan(&total).Error; err != nil {
+			return nil, 0, err
+		}
+		if err := db.Raw("SELECT id AS product_id, MATCH(title, body, skus) AGAINST(? IN BOOLEAN MODE) + IF(title LIKE ?, 10, 0) AS `rank`, title, body FROM search_documents WHERE MATCH(title, body, skus) AGAINST(? IN BOOLEAN MODE) AND id IN " + searchRendered + " ORDER BY `rank` DESC LIMIT ? OFFSET ?", match, "%" + tokens[0] + "%", match, limit, offset).Scan(&hits).Error; err != nil {
+			return nil, 0, err
+		}
+	case SEARCH_ENGINE_TSVECTOR:
+		var terms []string
+		for _, token := range tokens {
+			terms = append(terms, token + ":*")
+		}
+		match := strings.Join(terms, " & ")
+		vector := "to_tsvector('simple', title || ' ' || body || ' ' || skus)"
+		if err := db.Raw("SELECT count(*) FROM search_documents WHERE " + vector + " @@ to_tsquery('simple', ?) AND id IN " + searchRendered, match).Scan(&total).Error; err != nil {
+			return nil, 0, err
+		}
+		if err := db.Raw("SELECT id AS product_id, ts_rank(setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', skus), 'B') || to_tsvector('simple', body), to_tsquery('simple', ?)) AS rank, title, body FROM search_documents WHERE " + vector + " @@ to_tsquery('simple', ?) AND id IN " + searchRendered + " ORDER BY rank DESC LIMIT ? OFFSET ?", match, match, limit, offset).Scan(&hits).Error; err != nil {
+			return nil, 0, err
+		}
+	default:
+		var documents []*SearchDocument
+		db = db.Debug().Model(&SearchDocument{}).Where("id IN " + searchRendered)
+		for _, token := range tokens {
+			term := "%" + token + "%"
+			db = db.Where("(title LIKE ? OR body LIKE ? OR skus LIKE ?)", term, term, term)
+		}
+		if err := db.Find(&documents).Error; err != nil {
+			return nil, 0, err
+		}
+		for _, document := range documents {
+			hit := &SearchHit{ProductId: document.ID, Title: document.Title, Body: document.Body}
+			title, body, skus := strings.ToLower(document.Title), strings.ToLower(document.Body), strings.ToLower(document.Skus)
+			for _, token := range tokens {
+				hit.Rank += 10 * float64(strings.Count(title, token)) + 5 * float64(strings.Count(skus, token)) + float64(strings.Count(body, token))
+			}
+			hits = append(hits, hit)
+		}
+		sort.SliceStable(hits, func(i, j int) bool {
+			return hits[i].Rank > hits[j].Rank
+		})
+		total = int64(len(hits))
+		if offset >= len(hits) {
+			hits = nil
+		}else{
+			hits = hits[offset:]
+			if len(hits) > limit {
+				hits = hits[:limit]
+			}
+		}
+	}
+	return hits, total, nil
+}
+
+// CorrectSearchQuery replaces unknown words with the closest indexed ones, returns empty string if nothing to correct
+func CorrectSearchQuery(connector *gorm.DB, query string) string {
+	db := connector
+	tokens := SearchTokens(query)
+	var corrected bool
+	// lengths are in letters, not bytes
+	length := "length"
+	if db.Dialector.Name() == "mysql" {
+		length = "char_length"
+	}
+	for i, token := range tokens {
+		runes := []rune(token)
+		if len(runes) < 4 {
+			continue
+		}
+		var count int64
+		db.Model(&SearchTerm{}).Where("term = ?", token).Count(&count)
+		if count > 0 {
+			continue
+		}
+		max := 1
+		if len(runes) > 7 {
+			max = 2
+		}
+		var candidates []string
+		if err := db.Model(&SearchTerm{}).Where("term LIKE ? AND " + length + "(term) BETWEEN ? AND ?", string(runes[:1]) + "%", len(runes) - max, len(runes) + max).Pluck("term", &candidates).Error; err != nil {
+			continue
+		}
+		best, min := "", max + 1
+		for _, candidate := range candidates {
+			if d := distance(token, candidate); d < min {
+				best, min = candidate, d
+			}
+		}
+		if best != "" {
+			tokens[i] = best
+			corrected = true
+		}
+	}
+	if !corrected {
+		return ""
+	}
+	return strings.Join(tokens, " ")
+}
+
+// SearchTokens splits text to lower case words
+func SearchTokens(text string) []string {
+	var tokens []string
+	for _, token := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(token) > 1 {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+func searchText(text string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(reSearchTags.ReplaceAllString(text, " "))), " ")
+}
+
+func compact(values []string) []string {
+	var result []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// distance is Damerau-Levenshtein (optimal string alignment), swapped letters are a single typo
+func distance(a, b string) int {
+	x, y := []rune(a), []rune(b)
+	d := make([][]int, len(x) + 1)
+	for i := range d {
+		d[i] = make([]int, len(y) + 1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i - 1] == y[j - 1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i - 1][j] + 1, d[i][j - 1] + 1, d[i - 1][j - 1] + cost)
+			if i > 1 && j > 1 && x[i - 1] == y[j - 2] && x[i - 2] == y[j - 1] && d[i - 2][j - 2] + 1 < d[i][j] {
+				d[i][j] = d[i - 2][j - 2] + 1
+			}
+		}
+	}
+	return d[len(x)][len(y)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
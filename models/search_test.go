@@ -0,0 +1,139 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openSearch(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%v?mode=memory&cache=shared", t.Name())), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, value := range []interface{}{&Category{}, &Product{}, &Parameter{}, &File{}, &Image{}, &Variation{}, &Property{}, &Option{},
+		&Value{}, &Rate{}, &Price{}, &Tag{}, &Time{}, &Vendor{}, &Revision{}, &CacheProduct{}} {
+		// index names are shared by tables in sqlite, so migration of dependent table stops at index of its dependency
+		if err := db.AutoMigrate(value); err != nil && !db.Migrator().HasTable(value) {
+			db.Migrator().CreateTable(value)
+		}
+	}
+	// join table of prices is created after their indexes
+	db.Exec("CREATE TABLE IF NOT EXISTS prices_rates (price_id integer, rate_id integer, PRIMARY KEY (price_id, rate_id))")
+	if err = InitSearch(db); err != nil {
+		t.Fatalf("%v", err)
+	}
+	return db
+}
+
+func TestIndexProduct(t *testing.T) {
+	db := openSearch(t)
+	now := time.Now()
+	tag := &Tag{Enabled: true, Name: "summer", Title: "Summer"}
+	if _, err := CreateTag(db, tag); err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, example := range []struct{
+		Name string
+		Product *Product
+		Variations []*Variation
+		Indexed bool
+		Contains []string
+		Missing []string
+	}{
+		{Name: "published", Product: &Product{Enabled: true, Name: "shirt", Title: "Linen shirt", Sku: "SH-1"}, Variations: []*Variation{{Enabled: true, Name: "red", Title: "Crimson", Sku: "SH-1-R"}, {Enabled: false, Name: "blue", Title: "Navy"}}, Indexed: true, Contains: []string{"Crimson", "Summer", "SH-1-R"}, Missing: []string{"Navy"}},
+		{Name: "disabled", Product: &Product{Enabled: false, Name: "hat", Title: "Hat"}},
+		{Name: "window is over", Product: &Product{Enabled: true, Name: "scarf", Title: "Scarf", PublishEnd: now.Add(-time.Hour)}},
+		{Name: "window is not open", Product: &Product{Enabled: true, Name: "coat", Title: "Coat", PublishStart: now.Add(time.Hour)}},
+		{Name: "variation out of window", Product: &Product{Enabled: true, Name: "dress", Title: "Dress"}, Variations: []*Variation{{Enabled: true, Name: "long", Title: "Maxi", PublishStart: now.Add(time.Hour)}}, Indexed: true, Missing: []string{"Maxi"}},
+	}{
+		if _, err := CreateProduct(db, example.Product); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := AddProductToTag(db, tag, example.Product); err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, variation := range example.Variations {
+			variation.ProductId = example.Product.ID
+			if _, err := CreateVariation(db, variation); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+		if err := IndexProduct(db, example.Product.ID); err != nil {
+			t.Fatalf("%v: %v", example.Name, err)
+		}
+		var document SearchDocument
+		err := db.Where("id = ?", example.Product.ID).First(&document).Error
+		if indexed := err == nil; indexed != example.Indexed {
+			t.Errorf("%v: indexed %v, expected %v", example.Name, indexed, example.Indexed)
+			continue
+		}
+		text := document.Title + " " + document.Body + " " + document.Skus
+		for _, s := range example.Contains {
+			if !strings.Contains(text, s) {
+				t.Errorf("%v: %v is not indexed in '%v'", example.Name, s, text)
+			}
+		}
+		for _, s := range example.Missing {
+			if strings.Contains(text, s) {
+				t.Errorf("%v: %v is indexed in '%v'", example.Name, s, text)
+			}
+		}
+	}
+}
+
+func TestSearchProducts(t *testing.T) {
+	db := openSearch(t)
+	for i, title := range []string{"Linen shirt", "Linen dress"} {
+		product := &Product{Enabled: true, Name: fmt.Sprintf("product-%d", i), Title: title}
+		if _, err := CreateProduct(db, product); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := IndexProduct(db, product.ID); err != nil {
+			t.Fatalf("%v", err)
+		}
+		// the second product is not rendered yet
+		if i == 0 {
+			if _, err := CreateCacheProduct(db, &CacheProduct{ProductID: product.ID, Name: product.Name}); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+	}
+	hits, total, err := SearchProducts(db, "linen", 0, 10)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if total != 1 || len(hits) != 1 || hits[0].Title != "Linen shirt" {
+		t.Errorf("unexpected hits: %v %+v", total, hits)
+	}
+}
+
+func TestCorrectSearchQuery(t *testing.T) {
+	db := openSearch(t)
+	if err := db.Create(&[]SearchTerm{{Term: "рубашка"}, {Term: "linen"}, {Term: "shirt"}}).Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, example := range []struct{
+		Query string
+		Corrected string
+	}{
+		{Query: "linen shrit", Corrected: "linen shirt"},
+		// lengths are compared in letters, not bytes
+		{Query: "рубашкв", Corrected: "рубашка"},
+		{Query: "linen", Corrected: ""},
+	}{
+		if corrected := CorrectSearchQuery(db, example.Query); corrected != example.Corrected {
+			t.Errorf("%v: corrected '%v', expected '%v'", example.Query, corrected, example.Corrected)
+		}
+	}
+}
+
+func TestSearchTokens(t *testing.T) {
+	if tokens := SearchTokens("Я люблю Go, a b"); fmt.Sprintf("%v", tokens) != "[люблю go]" {
+		t.Errorf("unexpected tokens: %v", tokens)
+	}
+}
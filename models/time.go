@@ -23,13 +23,30 @@ func GetTimes(connector *gorm.DB) ([]*Time, error) {
 func GetTimesByVendorId(connector *gorm.DB, vendorId uint) ([]*Time, error) {
 	db := connector
 	var times []*Time
-	db.Model(&Time{}).Joins("inner join vendors_times on vendors_times.time_id = times.id").Where("vendors_times.vendor_id = ?", vendorId).Find(&times)
-	if err :=  db.Error; err != nil {
+	if err := db.Model(&Time{}).Joins("inner join vendors_times on vendors_times.time_id = times.id").Where("vendors_times.vendor_id = ?", vendorId).Find(&times).Error; err != nil {
 		return nil, err
 	}
 	return times, nil
 }
 
+// GetLeadTime returns lead time of item, items without own lead time get the longest time linked to vendor
+func GetLeadTime(connector *gorm.DB, t *Time, vendorId uint) int {
+	if t != nil {
+		return t.Value
+	}
+	var lead int
+	if vendorId > 0 {
+		if times, err := GetTimesByVendorId(connector, vendorId); err == nil {
+			for _, t := range times {
+				if t.Value > lead {
+					lead = t.Value
+				}
+			}
+		}
+	}
+	return lead
+}
+
 func CreateTime(connector *gorm.DB, time *Time) (uint, error) {
 	db := connector
 	db.Debug().Create(&time)
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"testing"
+)
+
+func TestGetLeadTime(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%v?mode=memory&cache=shared", t.Name())), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err = db.AutoMigrate(&Time{}, &Vendor{}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	vendor := &Vendor{Name: "vendor", Times: []*Time{{Name: "short", Value: 2}, {Name: "long", Value: 5}}}
+	if err = db.Create(vendor).Error; err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, example := range []struct{
+		Name string
+		Time *Time
+		VendorId uint
+		Lead int
+	}{
+		{Name: "own time", Time: &Time{Value: 1}, VendorId: vendor.ID, Lead: 1},
+		{Name: "vendor times", VendorId: vendor.ID, Lead: 5},
+		{Name: "no vendor", Lead: 0},
+		{Name: "unknown vendor", VendorId: vendor.ID + 1, Lead: 0},
+	}{
+		if lead := GetLeadTime(db, example.Time, example.VendorId); lead != example.Lead {
+			t.Errorf("%v: lead %v, expected %v", example.Name, lead, example.Lead)
+		}
+	}
+}
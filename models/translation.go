@@ -0,0 +1,139 @@
+package models
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+)
+
+const (
+	TRANSLATION_OBJECT_PRODUCT        = "product"
+	TRANSLATION_OBJECT_VARIATION      = "variation"
+	TRANSLATION_OBJECT_CATEGORY       = "category"
+	TRANSLATION_OBJECT_OPTION         = "option"
+	TRANSLATION_OBJECT_VALUE          = "value"
+	TRANSLATION_OBJECT_TAG            = "tag"
+	TRANSLATION_OBJECT_VENDOR         = "vendor"
+	TRANSLATION_OBJECT_MENU           = "menu"
+	TRANSLATION_OBJECT_WIDGET         = "widget"
+	TRANSLATION_OBJECT_EMAIL_TEMPLATE = "email_template"
+)
+
+// TranslatableFields lists text fields of every object type which can be translated
+var TranslatableFields = map[string][]string{
+	TRANSLATION_OBJECT_PRODUCT: {"Title", "Description", "Notes", "Content"},
+	TRANSLATION_OBJECT_VARIATION: {"Title", "Description", "Notes"},
+	TRANSLATION_OBJECT_CATEGORY: {"Title", "Description", "Content"},
+	TRANSLATION_OBJECT_OPTION: {"Title", "Description"},
+	TRANSLATION_OBJECT_VALUE: {"Title", "Description"},
+	TRANSLATION_OBJECT_TAG: {"Title", "Description"},
+	TRANSLATION_OBJECT_VENDOR: {"Title", "Description", "Content"},
+	TRANSLATION_OBJECT_MENU: {"Title", "Description"}, // Description keeps menu items JSON
+	TRANSLATION_OBJECT_WIDGET: {"Title", "Description", "Content"},
+	TRANSLATION_OBJECT_EMAIL_TEMPLATE: {"Topic", "Message"},
+}
+
+// Translation is a value of one text field of object in not default language
+type Translation struct {
+	gorm.Model
+	ObjectType string `gorm:"size:32;index:idx_translation_object"`
+	ObjectId uint `gorm:"index:idx_translation_object"`
+	Language string `gorm:"size:16;index:idx_translation_language"`
+	Field string `gorm:"size:32"`
+	Value string
+}
+
+func IsTranslatable(objectType, field string) bool {
+	for _, f := range TranslatableFields[objectType] {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func GetTranslations(connector *gorm.DB, objectType string, objectId uint) ([]*Translation, error) {
+	db := connector
+	var translations []*Translation
+	if err := db.Debug().Where("object_type = ? and object_id = ?", objectType, objectId).Order("language asc, field asc").Find(&translations).Error; err != nil {
+		return nil, err
+	}
+	return translations, nil
+}
+
+func GetTranslationsByLanguage(connector *gorm.DB, language string) ([]*Translation, error) {
+	db := connector
+	var translations []*Translation
+	if err := db.Debug().Where("language = ?", language).Find(&translations).Error; err != nil {
+		return nil, err
+	}
+	return translations, nil
+}
+
+// SetTranslations saves fields of object in language, empty value removes translation to fall back to default language
+func SetTranslations(connector *gorm.DB, objectType string, objectId uint, language string, fields map[string]string) error {
+	db := connector
+	for field := range fields {
+		if !IsTranslatable(objectType, field) {
+			return fmt.Errorf("field %v of %v is not translatable", field, objectType)
+		}
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for field, value := range fields {
+			var translation Translation
+			err := tx.Debug().Where("object_type = ? and object_id = ? and language = ? and field = ?", objectType, objectId, language, field).First(&translation).Error
+			if value == "" {
+				if err == nil {
+					if err = tx.Debug().Unscoped().Delete(&translation).Error; err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			translation.ObjectType, translation.ObjectId, translation.Language, translation.Field, translation.Value = objectType, objectId, language, field, value
+			if err = tx.Debug().Save(&translation).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func DeleteTranslationsByObject(connector *gorm.DB, objectType string, objectId uint) error {
+	db := connector
+	return db.Debug().Unscoped().Where("object_type = ? and object_id = ?", objectType, objectId).Delete(&Translation{}).Error
+}
+
+// Translator keeps all translations of one language, nil Translator is default language and returns values as is
+type Translator struct {
+	Language string
+	values map[string]map[uint]map[string]string
+}
+
+func NewTranslator(connector *gorm.DB, language string) (*Translator, error) {
+	translations, err := GetTranslationsByLanguage(connector, language)
+	if err != nil {
+		return nil, err
+	}
+	translator := &Translator{Language: language, values: make(map[string]map[uint]map[string]string)}
+	for _, translation := range translations {
+		if _, found := translator.values[translation.ObjectType]; !found {
+			translator.values[translation.ObjectType] = make(map[uint]map[string]string)
+		}
+		if _, found := translator.values[translation.ObjectType][translation.ObjectId]; !found {
+			translator.values[translation.ObjectType][translation.ObjectId] = make(map[string]string)
+		}
+		translator.values[translation.ObjectType][translation.ObjectId][translation.Field] = translation.Value
+	}
+	return translator, nil
+}
+
+// Get returns translated field or fallback of default language
+func (t *Translator) Get(objectType string, objectId uint, field string, fallback string) string {
+	if t == nil {
+		return fallback
+	}
+	if value, found := t.values[objectType][objectId][field]; found && value != "" {
+		return value
+	}
+	return fallback
+}
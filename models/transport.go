@@ -16,6 +16,8 @@ type Transport struct {
 	M3 float64 `sql:"type:decimal(8,3);"`
 	Free float64 `sql:"type:decimal(8,2);"` // free after order total
 	Services string
+	TransitMin int // business days
+	TransitMax int
 }
 
 func GetTransports(connector *gorm.DB) ([]*Transport, error) {
@@ -46,7 +46,7 @@ func GetValue(connector *gorm.DB, id int) (*Value, error) {
 func GetValueByOptionIdAndTitle(connector *gorm.DB, id int, title string) (*Value, error) {
 	db := connector
 	var value Value
-	if err := db.Where("option_id = ? and title = ?", id).First(&title).Error; err != nil {
+	if err := db.Where("option_id = ? and title = ?", id, title).First(&value).Error; err != nil {
 		return nil, err
 	}
 	return &value, nil
@@ -55,7 +55,7 @@ func GetValueByOptionIdAndTitle(connector *gorm.DB, id int, title string) (*Valu
 func GetValueByOptionIdAndValue(connector *gorm.DB, id int, val string) (*Value, error) {
 	db := connector
 	var value Value
-	if err := db.Where("option_id = ? and value = ?", id).First(&val).Error; err != nil {
+	if err := db.Where("option_id = ? and value = ?", id, val).First(&value).Error; err != nil {
 		return nil, err
 	}
 	return &value, nil
@@ -21,8 +21,10 @@ type Variation struct {
 	ManufacturerPrice float64          `sql:"type:decimal(8,2);"`
 	SalePrice float64      `sql:"type:decimal(8,2);"`
 	ItemPrice float64          `sql:"type:decimal(8,2);"`
-	Start time.Time
+	Start time.Time // sale window
 	End time.Time
+	PublishStart time.Time
+	PublishEnd time.Time
 	MinQuantity int
 	MaxQuantity int
 	PurchasableMultiply int
@@ -80,6 +82,15 @@ func GetVariationsByProductAndName(connector *gorm.DB, productId uint, name stri
 	return variations, nil
 }
 
+func GetVariationsByProductAndSku(connector *gorm.DB, productId uint, sku string) ([]*Variation, error) {
+	db := connector
+	var variations []*Variation
+	if err := db.Debug().Where("product_id = ? and sku = ?", productId, sku).Find(&variations).Error; err != nil {
+		return nil, err
+	}
+	return variations, nil
+}
+
 func GetVariation(connector *gorm.DB, id int) (*Variation, error) {
 	db := connector
 	var variation Variation
@@ -129,6 +140,15 @@ func AddFileToVariation(connector *gorm.DB, variation *Variation, file *File) er
 	return db.Model(&variation).Association("Files").Append(file)
 }
 
+func GetFilesOfVariation(connector *gorm.DB, variation *Variation) ([]*File, error) {
+	db := connector
+	var files []*File
+	if err := db.Model(&variation).Association("Files").Find(&files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
 func AddImageToVariation(connector *gorm.DB, variation *Variation, image *Image) error {
 	db := connector
 	return db.Model(&variation).Association("Images").Append(image)
@@ -10,6 +10,8 @@ type Vendor struct {
 	Thumbnail string
 	Description string
 	Content string
+	Cutoff string // 14:00
+	Holidays string // 2021-12-31,12-25
 	//
 	Times []*Time `gorm:"many2many:vendors_times;"`
 }
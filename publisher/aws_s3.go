@@ -0,0 +1,203 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/yonnic/goshop/storage"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+const (
+	AWS_S3_MANIFEST = ".goshop/manifest.json"
+	AWS_S3_WORKERS = 8 // parallel uploads
+)
+
+// NewAWSS3Publisher publishes to S3 bucket configured as static website
+func NewAWSS3Publisher(accessKeyID, secretAccessKey, region, bucket, prefix string, headers Headers) (*AWSS3Publisher, error) {
+	s, err := storage.NewAWSSession(accessKeyID, secretAccessKey, region)
+	if err != nil {
+		return nil, err
+	}
+	return &AWSS3Publisher{session: s, bucket: bucket, prefix: prefix, headers: headers}, nil
+}
+
+// AWSS3Publisher replaces objects one by one, so publish is not atomic: visitors may get old and new pages mixed while
+// it runs. New and changed assets are uploaded before pages which refer to them, manifest is written after all uploads
+// and stale objects are deleted only after that, so no page refers to missing asset. Failed publish is continued from
+// the last written manifest. Use file target for atomic switch of releases
+type AWSS3Publisher struct {
+	session *session.Session
+	bucket string
+	prefix string
+	headers Headers
+}
+
+func (publisher *AWSS3Publisher) key(p string) string {
+	return path.Join(publisher.prefix, p)
+}
+
+// Manifest returns state of last publish, it is empty for the first one
+func (publisher *AWSS3Publisher) Manifest() (Manifest, error) {
+	manifest := make(Manifest)
+	svc := s3.New(publisher.session)
+	output, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(publisher.bucket),
+		Key: aws.String(publisher.key(AWS_S3_MANIFEST)),
+	})
+	if err != nil {
+		if err, ok := err.(awserr.Error); ok && err.Code() == s3.ErrCodeNoSuchKey {
+			return manifest, nil
+		}
+		return manifest, err
+	}
+	defer output.Body.Close()
+	bts, err := ioutil.ReadAll(output.Body)
+	if err != nil {
+		return manifest, err
+	}
+	err = json.Unmarshal(bts, &manifest)
+	return manifest, err
+}
+
+func (publisher *AWSS3Publisher) Publish(ctx context.Context, src string, progress func(done, total int)) (*Result, error) {
+	manifest, err := Scan(src)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := publisher.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Release: time.Now().Format("20060102150405")}
+	changed := manifest.Changed(previous)
+	assets, pages := manifest.Sorted()
+	var done int
+	var mutex sync.Mutex
+	for _, files := range [][]string{assets, pages} {
+		var uploads []string
+		for _, p := range files {
+			if changed[p] {
+				uploads = append(uploads, p)
+			}else{
+				result.Unchanged++
+			}
+		}
+		if err = publisher.upload(ctx, src, uploads, func() {
+			mutex.Lock()
+			defer mutex.Unlock()
+			done++
+			result.Uploaded++
+			if progress != nil {
+				progress(done, len(changed))
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+	// Switch
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	bts, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	uploader := s3manager.NewUploader(publisher.session)
+	if _, err = uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(publisher.bucket),
+		Key: aws.String(publisher.key(AWS_S3_MANIFEST)),
+		Body: bytes.NewReader(bts),
+		ContentType: aws.String("application/json"),
+	}); err != nil {
+		return nil, err
+	}
+	// Stale objects, by 1000 per request
+	stale := manifest.Stale(previous)
+	svc := s3.New(publisher.session)
+	for i := 0; i < len(stale); i += 1000 {
+		var objects []*s3.ObjectIdentifier
+		for _, p := range stale[i:min(i + 1000, len(stale))] {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(publisher.key(p))})
+		}
+		if _, err = svc.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(publisher.bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		}); err != nil {
+			return nil, err
+		}
+		result.Deleted += len(objects)
+	}
+	return result, nil
+}
+
+// upload puts files in parallel and returns the first error, files are not put after ctx is canceled
+func (publisher *AWSS3Publisher) upload(ctx context.Context, src string, files []string, uploaded func()) error {
+	uploader := s3manager.NewUploader(publisher.session)
+	jobs := make(chan string)
+	errs := make(chan error, len(files))
+	var wg sync.WaitGroup
+	for w := 0; w < AWS_S3_WORKERS; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				if err := publisher.put(ctx, uploader, path.Join(src, p), p); err != nil {
+					errs <- err
+					continue
+				}
+				uploaded()
+			}
+		}()
+	}
+	for _, p := range files {
+		select {
+		case jobs <- p:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return <-errs
+}
+
+func (publisher *AWSS3Publisher) put(ctx context.Context, uploader *s3manager.Uploader, src, p string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	contentType, cacheControl := publisher.headers.Get(src, p)
+	_, err = uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(publisher.bucket),
+		ACL: aws.String("public-read"),
+		Key: aws.String(publisher.key(p)),
+		Body: file,
+		ContentType: aws.String(contentType),
+		CacheControl: aws.String(cacheControl),
+	})
+	return err
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,181 @@
+package publisher
+
+import (
+	"context"
+	"github.com/google/logger"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	FILE_CURRENT = "current"
+	FILE_RELEASES = "releases"
+	FILE_KEEP = 3
+)
+
+// NewFilePublisher publishes to local or mounted remote folder: every publish is new folder in root/releases
+// and root/current link is switched to it at once, web server should serve root/current
+func NewFilePublisher(root string, keep int) (*FilePublisher, error) {
+	if keep < 1 {
+		keep = FILE_KEEP
+	}
+	if _, err := os.Stat(path.Join(root, FILE_RELEASES)); err != nil {
+		if err = os.MkdirAll(path.Join(root, FILE_RELEASES), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &FilePublisher{root: root, keep: keep}, nil
+}
+
+type FilePublisher struct {
+	root string
+	keep int
+}
+
+// Current returns name of published release
+func (publisher *FilePublisher) Current() (string, error) {
+	link, err := os.Readlink(path.Join(publisher.root, FILE_CURRENT))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return path.Base(link), nil
+}
+
+func (publisher *FilePublisher) Publish(ctx context.Context, src string, progress func(done, total int)) (*Result, error) {
+	manifest, err := Scan(src)
+	if err != nil {
+		return nil, err
+	}
+	current, err := publisher.Current()
+	if err != nil {
+		return nil, err
+	}
+	previous := make(Manifest)
+	if current != "" {
+		if previous, err = ReadManifest(path.Join(publisher.root, FILE_RELEASES, current + ".json")); err != nil {
+			logger.Warningf("%+v", err)
+		}
+	}
+	// Names of releases are sorted by time
+	result := &Result{}
+	for {
+		result.Release = time.Now().Format("20060102150405.000000")
+		if _, err = os.Stat(path.Join(publisher.root, FILE_RELEASES, result.Release)); err != nil {
+			break
+		}
+	}
+	// Release is built in temporary folder, unchanged files are hard linked from current release
+	dst := path.Join(publisher.root, FILE_RELEASES, result.Release)
+	tmp := dst + ".tmp"
+	if err = os.RemoveAll(tmp); err != nil {
+		return nil, err
+	}
+	changed := manifest.Changed(previous)
+	assets, pages := manifest.Sorted()
+	files := append(assets, pages...)
+	for i, p := range files {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err = os.MkdirAll(path.Dir(path.Join(tmp, p)), 0755); err != nil {
+			return nil, err
+		}
+		if changed[p] {
+			if err = copyFile(path.Join(src, p), path.Join(tmp, p)); err != nil {
+				return nil, err
+			}
+			result.Uploaded++
+		}else{
+			if err = os.Link(path.Join(publisher.root, FILE_RELEASES, current, p), path.Join(tmp, p)); err != nil {
+				if err = copyFile(path.Join(src, p), path.Join(tmp, p)); err != nil {
+					return nil, err
+				}
+			}
+			result.Unchanged++
+		}
+		if progress != nil {
+			progress(i + 1, len(files))
+		}
+	}
+	if err = WriteManifest(path.Join(publisher.root, FILE_RELEASES, result.Release + ".json"), manifest); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(tmp, dst); err != nil {
+		return nil, err
+	}
+	// Switch, rename of link is atomic
+	link := path.Join(publisher.root, FILE_CURRENT + ".tmp")
+	if err = os.RemoveAll(link); err != nil {
+		return nil, err
+	}
+	if err = os.Symlink(path.Join(FILE_RELEASES, result.Release), link); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(link, path.Join(publisher.root, FILE_CURRENT)); err != nil {
+		return nil, err
+	}
+	result.Deleted = len(manifest.Stale(previous))
+	if err = publisher.prune(result.Release); err != nil {
+		logger.Warningf("%+v", err)
+	}
+	return result, nil
+}
+
+// prune removes old and unfinished releases
+func (publisher *FilePublisher) prune(current string) error {
+	infos, err := ioutil.ReadDir(path.Join(publisher.root, FILE_RELEASES))
+	if err != nil {
+		return err
+	}
+	var releases []string
+	for _, info := range infos {
+		if info.IsDir() {
+			if strings.HasSuffix(info.Name(), ".tmp") {
+				if err = os.RemoveAll(path.Join(publisher.root, FILE_RELEASES, info.Name())); err != nil {
+					return err
+				}
+			}else{
+				releases = append(releases, info.Name())
+			}
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(releases)))
+	for i, release := range releases {
+		if i < publisher.keep || release == current {
+			continue
+		}
+		logger.Infof("Remove release %v", release)
+		if err = os.RemoveAll(path.Join(publisher.root, FILE_RELEASES, release)); err != nil {
+			return err
+		}
+		if err = os.Remove(path.Join(publisher.root, FILE_RELEASES, release + ".json")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,174 @@
+package publisher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	DEFAULT_CACHE_CONTROL = "public, max-age=86400"
+	DEFAULT_PAGES_CACHE_CONTROL = "public, max-age=0, must-revalidate"
+)
+
+// Publisher transfers rendered site to hosting, only files changed since previous publish are transferred, canceled
+// publish leaves previous site published
+type Publisher interface {
+	Publish(ctx context.Context, src string, progress func(done, total int)) (*Result, error)
+}
+
+type Result struct {
+	Release string
+	Uploaded int
+	Unchanged int
+	Deleted int
+}
+
+// Manifest is state of published site: hash and size of files by path relative to site root
+type Manifest map[string]*ManifestItem
+
+type ManifestItem struct {
+	Hash string
+	Size int64
+}
+
+// Scan walks site folder and hashes its files
+func Scan(src string) (Manifest, error) {
+	manifest := make(Manifest)
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		hash, err := hashFile(p)
+		if err != nil {
+			return err
+		}
+		manifest[filepath.ToSlash(rel)] = &ManifestItem{Hash: hash, Size: info.Size()}
+		return nil
+	})
+	return manifest, err
+}
+
+func hashFile(p string) (string, error) {
+	file, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Changed returns paths which are new or have other content than in previous manifest
+func (manifest Manifest) Changed(previous Manifest) map[string]bool {
+	changed := make(map[string]bool)
+	for p, item := range manifest {
+		if prev, found := previous[p]; !found || prev.Hash != item.Hash {
+			changed[p] = true
+		}
+	}
+	return changed
+}
+
+// Stale returns paths of previous manifest which are not published anymore
+func (manifest Manifest) Stale(previous Manifest) []string {
+	var stale []string
+	for p := range previous {
+		if _, found := manifest[p]; !found {
+			stale = append(stale, p)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// Sorted returns assets and pages separately, pages have to be transferred after assets they refer to
+func (manifest Manifest) Sorted() ([]string, []string) {
+	var assets, pages []string
+	for p := range manifest {
+		if IsPage(p) {
+			pages = append(pages, p)
+		}else{
+			assets = append(assets, p)
+		}
+	}
+	sort.Strings(assets)
+	sort.Strings(pages)
+	return assets, pages
+}
+
+func ReadManifest(p string) (Manifest, error) {
+	manifest := make(Manifest)
+	bts, err := ioutil.ReadFile(p)
+	if err != nil {
+		return manifest, err
+	}
+	err = json.Unmarshal(bts, &manifest)
+	return manifest, err
+}
+
+func WriteManifest(p string, manifest Manifest) error {
+	bts, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, bts, 0644)
+}
+
+func IsPage(p string) bool {
+	switch strings.ToLower(path.Ext(p)) {
+	case ".html", ".htm", ".xml", ".json", ".txt":
+		return true
+	}
+	return false
+}
+
+// Headers are Content-Type and Cache-Control of published files, pages are revalidated and assets are cached
+type Headers struct {
+	CacheControl string
+	PagesCacheControl string
+}
+
+func (headers Headers) Get(src, p string) (string, string) {
+	contentType := mime.TypeByExtension(path.Ext(p))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+		if file, err := os.Open(src); err == nil {
+			buff := make([]byte, 512)
+			if n, err := file.Read(buff); err == nil {
+				contentType = http.DetectContentType(buff[:n])
+			}
+			file.Close()
+		}
+	}
+	if IsPage(p) {
+		if headers.PagesCacheControl != "" {
+			return contentType, headers.PagesCacheControl
+		}
+		return contentType, DEFAULT_PAGES_CACHE_CONTROL
+	}
+	if headers.CacheControl != "" {
+		return contentType, headers.CacheControl
+	}
+	return contentType, DEFAULT_CACHE_CONTROL
+}
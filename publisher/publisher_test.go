@@ -0,0 +1,52 @@
+package publisher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestManifest_Changed(t *testing.T) {
+	previous := Manifest{
+		"index.html": {Hash: "a", Size: 1},
+		"css/main.css": {Hash: "b", Size: 1},
+		"old.html": {Hash: "c", Size: 1},
+	}
+	for _, example := range []struct{
+		Name string
+		Manifest Manifest
+		Changed map[string]bool
+		Stale []string
+	}{
+		{Name: "same site", Manifest: Manifest{"index.html": {Hash: "a", Size: 1}, "css/main.css": {Hash: "b", Size: 1}, "old.html": {Hash: "c", Size: 1}}, Changed: map[string]bool{}},
+		{Name: "changed page", Manifest: Manifest{"index.html": {Hash: "x", Size: 1}, "css/main.css": {Hash: "b", Size: 1}, "old.html": {Hash: "c", Size: 1}}, Changed: map[string]bool{"index.html": true}},
+		{Name: "new and removed", Manifest: Manifest{"index.html": {Hash: "a", Size: 1}, "css/main.css": {Hash: "b", Size: 1}, "new.html": {Hash: "d", Size: 1}}, Changed: map[string]bool{"new.html": true}, Stale: []string{"old.html"}},
+		{Name: "empty site", Manifest: Manifest{}, Changed: map[string]bool{}, Stale: []string{"css/main.css", "index.html", "old.html"}},
+	}{
+		if changed := example.Manifest.Changed(previous); !reflect.DeepEqual(changed, example.Changed) {
+			t.Errorf("%v: changed %v, expected %v", example.Name, changed, example.Changed)
+		}
+		if stale := example.Manifest.Stale(previous); !reflect.DeepEqual(stale, example.Stale) {
+			t.Errorf("%v: stale %v, expected %v", example.Name, stale, example.Stale)
+		}
+	}
+	// The first publish uploads everything
+	manifest := Manifest{"index.html": {Hash: "a", Size: 1}}
+	if changed := manifest.Changed(Manifest{}); !changed["index.html"] {
+		t.Errorf("first publish: changed %v", changed)
+	}
+}
+
+func TestManifest_Sorted(t *testing.T) {
+	assets, pages := Manifest{
+		"index.html": {},
+		"sitemap.xml": {},
+		"images/a.png": {},
+		"css/main.css": {},
+	}.Sorted()
+	if !reflect.DeepEqual(assets, []string{"css/main.css", "images/a.png"}) {
+		t.Errorf("unexpected assets: %v", assets)
+	}
+	if !reflect.DeepEqual(pages, []string{"index.html", "sitemap.xml"}) {
+		t.Errorf("unexpected pages: %v", pages)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -41,15 +42,7 @@ func NewAWSS3Storage(accessKeyID, secretAccessKey, region, bucket, prefix string
 		cdn: cdn,
 	}
 	var err error
-	storage.session, err = session.NewSession(
-		&aws.Config{
-			Region: aws.String(region),
-			Credentials: credentials.NewStaticCredentials(
-				accessKeyID,
-				secretAccessKey,
-				"", // a token will be created when the session it's used.
-			),
-		})
+	storage.session, err = NewAWSSession(accessKeyID, secretAccessKey, region)
 	if err != nil {
 		return storage, err
 	}
@@ -66,6 +59,19 @@ func NewAWSS3Storage(accessKeyID, secretAccessKey, region, bucket, prefix string
 	return storage, err
 }
 
+// NewAWSSession creates session with static credentials, it is shared by storage and publisher
+func NewAWSSession(accessKeyID, secretAccessKey, region string) (*session.Session, error) {
+	return session.NewSession(
+		&aws.Config{
+			Region: aws.String(region),
+			Credentials: credentials.NewStaticCredentials(
+				accessKeyID,
+				secretAccessKey,
+				"", // a token will be created when the session it's used.
+			),
+		})
+}
+
 type AWSS3Storage struct {
 	session *session.Session
 	AccessKeyID string
@@ -80,6 +86,8 @@ type AWSS3Storage struct {
 	quality int
 	cdn string
 	rewrite string
+	mutex sync.RWMutex // guards Database
+	locks locks
 }
 
 func (storage *AWSS3Storage) Open() error {
@@ -100,6 +108,8 @@ func (storage *AWSS3Storage) Open() error {
 
 func (storage *AWSS3Storage) Save() error {
 	database := path.Join(storage.temp, "database.json")
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
 	if bts, err := json.Marshal(storage.Database); err == nil {
 		return ioutil.WriteFile(database, bts, 0755)
 	} else {
@@ -118,6 +128,13 @@ type AWSS3StorageItem struct {
 	Modified time.Time
 }
 
+func (storage *AWSS3Storage) item(location string) (*AWSS3StorageItem, bool) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+	item, found := storage.Database[location]
+	return item, found
+}
+
 func (storage *AWSS3Storage) upload(src, location string) (string, error) {
 	//logger.Infof("upload: %+v, %+v", src, location)
 	var url string
@@ -154,7 +171,7 @@ func (storage *AWSS3Storage) delete(location string) error {
 	svc := s3.New(storage.session)
 	input := &s3.DeleteObjectInput{
 		Bucket: aws.String(storage.Bucket),
-		Key:    aws.String(location),
+		Key:    aws.String(path.Join(storage.prefix, location)),
 	}
 
 	_, err := svc.DeleteObject(input)
@@ -185,6 +202,7 @@ func (storage *AWSS3Storage) rw(link string) string {
 }
 
 func (storage *AWSS3Storage) PutFile(src, location string) (string, error) {
+	defer storage.locks.Lock(location)()
 	dst := path.Join(storage.temp, location)
 	if _, err := os.Stat(path.Dir(dst)); err != nil {
 		if err = os.MkdirAll(path.Dir(dst), 0755); err != nil {
@@ -197,7 +215,7 @@ func (storage *AWSS3Storage) PutFile(src, location string) (string, error) {
 		return location, err
 	}
 	// //
-	if item, found := storage.Database[location]; !found || fi1.ModTime().Sub(item.Modified) > time.Second {
+	if item, found := storage.item(location); !found || fi1.ModTime().Sub(item.Modified) > time.Second {
 		if url, err := storage.upload(src, location); err == nil {
 			urls[location] = &AWSS3StorageItem{
 				Created:  time.Now(),
@@ -213,19 +231,90 @@ func (storage *AWSS3Storage) PutFile(src, location string) (string, error) {
 		location = storage.rw(item.Url)
 	}
 
+	storage.mutex.Lock()
 	for key, value := range urls {
 		storage.Database[key] = value
 	}
+	storage.mutex.Unlock()
 
 	return location, err
 }
 
+// ReadFile streams object from bucket, caller should close it
+func (storage *AWSS3Storage) ReadFile(location string) (io.ReadCloser, error) {
+	svc := s3.New(storage.session)
+	output, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(storage.Bucket),
+		Key:    aws.String(path.Join(storage.prefix, location)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.Body, nil
+}
+
+// Exists checks object by location or by url returned from PutFile and PutImage, so CDN and rewrite are reverted
+func (storage *AWSS3Storage) Exists(link string) (bool, error) {
+	location, err := storage.Location(link)
+	if err != nil {
+		return false, err
+	}
+	svc := s3.New(storage.session)
+	if _, err = svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(storage.Bucket),
+		Key:    aws.String(path.Join(storage.prefix, location)),
+	}); err != nil {
+		if err, ok := err.(awserr.Error); ok && (err.Code() == "NotFound" || err.Code() == s3.ErrCodeNoSuchKey) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Location reverts CDN and rewrite of link, both virtual-hosted and path-style urls are supported
+func (storage *AWSS3Storage) Location(link string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return strings.TrimPrefix(path.Clean("/" + u.Path), "/"), nil
+	}
+	key := u.Path
+	if storage.rewrite != "" {
+		if !strings.HasPrefix(key, storage.rewrite) {
+			return "", ErrForeignLink
+		}
+		key = strings.TrimPrefix(key, storage.rewrite)
+	}
+	switch {
+	case storage.cdn != "" && u.Host == storage.cdn:
+	case strings.HasPrefix(u.Host, storage.Bucket + ".s3") && strings.HasSuffix(u.Host, ".amazonaws.com"):
+	case strings.HasPrefix(u.Host, "s3") && strings.HasSuffix(u.Host, ".amazonaws.com") && strings.HasPrefix(key, "/" + storage.Bucket + "/"):
+		key = strings.TrimPrefix(key, "/" + storage.Bucket)
+	default:
+		return "", ErrForeignLink
+	}
+	key = strings.TrimPrefix(path.Clean(key), "/")
+	if prefix := strings.Trim(storage.prefix, "/"); prefix != "" {
+		if !strings.HasPrefix(key, prefix + "/") {
+			return "", ErrForeignLink
+		}
+		key = strings.TrimPrefix(key, prefix + "/")
+	}
+	return key, nil
+}
+
 func (storage *AWSS3Storage) DeleteFile(location string) (error) {
+	storage.mutex.Lock()
 	delete(storage.Database, location)
+	storage.mutex.Unlock()
 	return storage.delete(location)
 }
 
 func (storage *AWSS3Storage) PutImage(src, location, sizes string) ([]string, error) {
+	defer storage.locks.Lock(location)()
 	//logger.Infof("PutImages: %+v, %+v, %+v", src, location, sizes)
 	var locations []string
 
@@ -240,7 +329,7 @@ func (storage *AWSS3Storage) PutImage(src, location, sizes string) ([]string, er
 	if err != nil {
 		return locations, err
 	}
-	if item, found := storage.Database[location]; !found || fi1.ModTime().Sub(item.Modified) > time.Second {
+	if item, found := storage.item(location); !found || fi1.ModTime().Sub(item.Modified) > time.Second {
 		if url, err := storage.upload(src, location); err == nil {
 			urls[location] = &AWSS3StorageItem{
 				Created:  time.Now(),
@@ -282,7 +371,7 @@ func (storage *AWSS3Storage) PutImage(src, location, sizes string) ([]string, er
 				dst2 := path.Join(path.Dir(dst), "resize", filename)
 				// //
 				key := path.Join(path.Dir(location), "resize", filename)
-				if item, found := storage.Database[key]; !found || fi1.ModTime().Sub(item.Modified) > time.Second {
+				if item, found := storage.item(key); !found || fi1.ModTime().Sub(item.Modified) > time.Second {
 					if img == nil {
 						file, err := os.Open(src)
 						if err != nil {
@@ -355,9 +444,11 @@ func (storage *AWSS3Storage) PutImage(src, location, sizes string) ([]string, er
 			}
 		}
 	}
+	storage.mutex.Lock()
 	for key, value := range urls {
 		storage.Database[key] = value
 	}
+	storage.mutex.Unlock()
 	if rand.Intn(100) == 0 {
 		if err = storage.Save(); err != nil {
 			logger.Warningf("%+v", err)
@@ -368,7 +459,9 @@ func (storage *AWSS3Storage) PutImage(src, location, sizes string) ([]string, er
 
 func (storage *AWSS3Storage) DeleteImage(location, sizes string) error {
 	var err error
+	storage.mutex.Lock()
 	delete(storage.Database, location)
+	storage.mutex.Unlock()
 	if err = storage.delete(location); err != nil {
 		return err
 	}
@@ -385,7 +478,9 @@ func (storage *AWSS3Storage) DeleteImage(location, sizes string) error {
 		filename := path.Base(location)
 		filename = filename[:len(filename) - len(filepath.Ext(filename))]
 		filename = fmt.Sprintf("%s_%dx%d%s", filename, width, height, filepath.Ext(location))
+		storage.mutex.Lock()
 		delete(storage.Database, path.Join(path.Dir(location), "resize", filename))
+		storage.mutex.Unlock()
 		if err = storage.delete(path.Join(path.Dir(location), "resize", filename)); err != nil {
 			logger.Warningf("%+v", err)
 		}
@@ -8,6 +8,7 @@ import (
 	"image/jpeg"
 	"image/png"
 	"io"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
@@ -29,6 +30,7 @@ type LocalStorage struct {
 	root string
 	resize bool
 	quality int
+	locks locks
 }
 
 func (local *LocalStorage) copy(src, dst string) error {
@@ -77,12 +79,45 @@ func (local *LocalStorage) Open() error{
 
 // PutFile src - full local path to file, dst - relative path to file
 func (local *LocalStorage) PutFile(src, location string) (string, error) {
+	defer local.locks.Lock(location)()
 	for _, suffix := range []string{"public", "static"} {
-		if err := local.copy(src, path.Join(local.root, location, suffix)); err != nil {
+		if err := local.copy(src, path.Join(local.root, suffix, location)); err != nil {
 			return location, err
 		}
 	}
-	return location, nil
+	return "/" + location, nil
+}
+
+// ReadFile opens file by relative path from storage root, caller should close it
+func (local *LocalStorage) ReadFile(location string) (io.ReadCloser, error) {
+	return os.Open(path.Join(local.root, path.Clean("/" + location)))
+}
+
+// Exists checks file by location returned from PutFile and PutImage, local files have no host
+func (local *LocalStorage) Exists(link string) (bool, error) {
+	location, err := local.Location(link)
+	if err != nil {
+		return false, err
+	}
+	if _, err = os.Stat(path.Join(local.root, "static", location)); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Location returns location of link returned from PutFile and PutImage
+func (local *LocalStorage) Location(link string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	if u.Host != "" {
+		return "", ErrForeignLink
+	}
+	return strings.TrimPrefix(path.Clean("/" + u.Path), "/"), nil
 }
 
 func (local *LocalStorage) DeleteFile(location string) error {
@@ -95,6 +130,7 @@ func (local *LocalStorage) DeleteFile(location string) error {
 }
 
 func (local *LocalStorage) PutImage(src, location, sizes string) ([]string, error) {
+	defer local.locks.Lock(location)()
 	logger.Infof("PutImages: %+v, %+v, %+v", src, location, sizes)
 	var locations []string
 	for _, suffix := range []string{"public", "static"} {
@@ -1,11 +1,23 @@
 package storage
 
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrForeignLink is returned by Exists for url which is not served by storage
+var ErrForeignLink = errors.New("link is not served by storage")
+
 type Storage interface {
 	Open() error
 	PutFile(src, location string) (string, error)
+	ReadFile(location string) (io.ReadCloser, error)
 	DeleteFile(location string) error
 	PutImage(src, location, sizes string) ([]string, error)
 	DeleteImage(location, sizes string) error
+	Exists(link string) (bool, error)
+	Location(link string) (string, error)
 	Close() error
 	//
 	//Copy(src, dst string) error
@@ -16,3 +28,25 @@ type Image struct {
 	Filename string
 	Size string
 }
+
+// locks serializes work with the same location when files are put from several goroutines
+type locks struct {
+	mutex sync.Mutex
+	items map[string]*sync.Mutex
+}
+
+// Lock locks location and returns function to unlock it
+func (l *locks) Lock(location string) func() {
+	l.mutex.Lock()
+	if l.items == nil {
+		l.items = make(map[string]*sync.Mutex)
+	}
+	item, found := l.items[location]
+	if !found {
+		item = &sync.Mutex{}
+		l.items[location] = item
+	}
+	l.mutex.Unlock()
+	item.Lock()
+	return item.Unlock
+}